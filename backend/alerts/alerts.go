@@ -0,0 +1,143 @@
+// Package alerts notifies merchants when a customer's usage in the current
+// billing period crosses the thresholds of a budget.
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"billbo.com/backend/billing"
+	"billbo.com/backend/database"
+	"billbo.com/backend/database/sqlcgen"
+	"billbo.com/backend/outbox"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// Evaluator compares usage against the active alerts. Each threshold of an
+// alert fires at most once per billing period: crossings are recorded, and
+// the usage.threshold_crossed event emitted, in the same transaction.
+type Evaluator struct {
+	logger  *zap.Logger
+	pool    *pgxpool.Pool
+	queries *sqlcgen.Queries
+}
+
+func NewEvaluator(logger *zap.Logger, pool *pgxpool.Pool) *Evaluator {
+	return &Evaluator{
+		logger:  logger.With(zap.String("component", "alerts")),
+		pool:    pool,
+		queries: sqlcgen.New(pool),
+	}
+}
+
+// EvaluateAll evaluates every active alert over its customer's current
+// billing period.
+func (e *Evaluator) EvaluateAll(ctx context.Context) error {
+	alerts, err := e.queries.ListUsageAlertsToEvaluate(ctx)
+	if err != nil {
+		return fmt.Errorf("queries.ListUsageAlertsToEvaluate: %w", err)
+	}
+
+	now := time.Now()
+	for _, alert := range alerts {
+		if err := e.evaluate(ctx, alert, now); err != nil {
+			return fmt.Errorf("evaluate: %w", err)
+		}
+	}
+	return nil
+}
+
+func (e *Evaluator) evaluate(ctx context.Context, alert *sqlcgen.ListUsageAlertsToEvaluateRow, now time.Time) error {
+	schedule, err := billing.NewPeriodSchedule(alert.Timezone, int(alert.AnchorDay))
+	if err != nil {
+		e.logger.Error("invalid billing period schedule",
+			zap.String("alert_id", alert.ID.String()),
+			zap.Error(err),
+		)
+		return nil
+	}
+	period := schedule.PeriodContaining(now)
+
+	usage, err := e.usage(ctx, alert, period)
+	if err != nil {
+		return fmt.Errorf("usage: %w", err)
+	}
+
+	thresholds := slices.Sorted(slices.Values(alert.Thresholds))
+	for _, threshold := range thresholds {
+		if usage < alert.Budget*float64(threshold)/100 {
+			break
+		}
+		err := database.InTx(ctx, e.pool, func(q *sqlcgen.Queries) error {
+			n, err := q.RecordUsageAlertCrossing(ctx, sqlcgen.RecordUsageAlertCrossingParams{
+				AlertID:     alert.ID,
+				PeriodStart: pgtype.Timestamptz{Time: period.Start, Valid: true},
+				Threshold:   threshold,
+				Usage:       usage,
+			})
+			if err != nil {
+				return fmt.Errorf("queries.RecordUsageAlertCrossing: %w", err)
+			}
+			if n == 0 {
+				// Already notified this period.
+				return nil
+			}
+			return outbox.Emit(ctx, q, alert.MerchantID, outbox.EventUsageThresholdCrossed, newThresholdData(alert, threshold, usage, period))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// usage is the quantity of the alert's SKU used over the period or, for
+// alerts without a SKU, the customer's spend at the current prices, before
+// discounts and taxes. Both are read from the usage counters.
+func (e *Evaluator) usage(ctx context.Context, alert *sqlcgen.ListUsageAlertsToEvaluateRow, period billing.Period) (float64, error) {
+	periodStart := pgtype.Timestamptz{Time: period.Start, Valid: true}
+
+	if alert.SkuID.Valid {
+		quantity, err := e.queries.GetSKUUsage(ctx, sqlcgen.GetSKUUsageParams{
+			MerchantID:  alert.MerchantID,
+			CustomerID:  alert.CustomerID,
+			SkuID:       alert.SkuID,
+			PeriodStart: periodStart,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("queries.GetSKUUsage: %w", err)
+		}
+		return quantity, nil
+	}
+
+	spend, err := e.queries.GetCustomerSpend(ctx, sqlcgen.GetCustomerSpendParams{
+		MerchantID:  alert.MerchantID,
+		CustomerID:  alert.CustomerID,
+		PeriodStart: periodStart,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("queries.GetCustomerSpend: %w", err)
+	}
+	return spend, nil
+}
+
+func newThresholdData(alert *sqlcgen.ListUsageAlertsToEvaluateRow, threshold int32, usage float64, period billing.Period) *outbox.UsageThresholdData {
+	d := &outbox.UsageThresholdData{
+		AlertID:     alert.ID.String(),
+		CustomerID:  alert.CustomerID.String(),
+		Threshold:   threshold,
+		Budget:      alert.Budget,
+		Usage:       usage,
+		PeriodStart: period.Start.Format(time.RFC3339),
+		PeriodEnd:   period.End.Format(time.RFC3339),
+	}
+	if alert.SkuID.Valid {
+		s := alert.SkuID.String()
+		d.SKUID = &s
+	}
+	return d
+}
@@ -2,28 +2,41 @@ package apikeys
 
 import (
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"billbo.com/backend/api/dashboard/auth"
+	"billbo.com/backend/apikey"
+	"billbo.com/backend/database"
 	"billbo.com/backend/database/sqlcgen"
+	"billbo.com/backend/outbox"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 )
 
+// DEFAULT_ROTATION_GRACE_PERIOD is how long a rotated key keeps
+// authenticating, unless the rotation request sets it.
+const DEFAULT_ROTATION_GRACE_PERIOD = 24 * time.Hour
+
 type APIKeyHandler struct {
 	logger  *zap.Logger
 	queries *sqlcgen.Queries
+	pool    *pgxpool.Pool
+	hasher  *apikey.Hasher
 }
 
 func NewAPIKeyHandler(
 	logger *zap.Logger,
 	queries *sqlcgen.Queries,
+	pool *pgxpool.Pool,
+	pepper []byte,
 ) *APIKeyHandler {
 	return &APIKeyHandler{
 		logger: logger.With(
@@ -31,18 +44,32 @@ func NewAPIKeyHandler(
 			zap.String("handler", "apikeys"),
 		),
 		queries: queries,
+		pool:    pool,
+		hasher:  apikey.NewHasher(pepper),
 	}
 }
 
+// CreateAPIKeyRequest creates a key granted the given scopes on the ingest
+// API, expiring at ExpiresAt if set.
 type CreateAPIKeyRequest struct {
-	Name string `json:"name" validate:"required"`
+	Name      string     `json:"name" validate:"required"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,unique,dive,oneof=events:write events:read usage:read"`
+	ExpiresAt *time.Time `json:"expires_at"`
 }
 
 type CreateAPIKeyResponse struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	Key       string `json:"key"`
-	KeyPrefix string `json:"key_prefix"`
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Key       string   `json:"key"`
+	KeyPrefix string   `json:"key_prefix"`
+	Scopes    []string `json:"scopes"`
+	ExpiresAt *string  `json:"expires_at"`
+	// SigningSecret signs ingest requests instead of sending the key. Like
+	// the key, it is only returned once.
+	SigningSecret *string `json:"signing_secret"`
+	// PreviousKeyExpiresAt is when the replaced key stops authenticating,
+	// for rotated keys.
+	PreviousKeyExpiresAt *string `json:"previous_key_expires_at"`
 }
 
 func (r *CreateAPIKeyResponse) FromDB(row *sqlcgen.ApiKey, rawKey string) *CreateAPIKeyResponse {
@@ -53,15 +80,35 @@ func (r *CreateAPIKeyResponse) FromDB(row *sqlcgen.ApiKey, rawKey string) *Creat
 	r.Name = row.Name
 	r.Key = rawKey
 	r.KeyPrefix = row.KeyPrefix
+	r.Scopes = row.Scopes
+	if row.ExpiresAt.Valid {
+		s := row.ExpiresAt.Time.Format(time.RFC3339)
+		r.ExpiresAt = &s
+	}
+	if row.SigningSecret.Valid {
+		r.SigningSecret = &row.SigningSecret.String
+	}
+	if row.PreviousKeyExpiresAt.Valid {
+		s := row.PreviousKeyExpiresAt.Time.Format(time.RFC3339)
+		r.PreviousKeyExpiresAt = &s
+	}
 	return r
 }
 
 type APIKeyResponse struct {
-	ID        string  `json:"ID"`
-	Name      string  `json:"Name"`
-	KeyPrefix string  `json:"KeyPrefix"`
-	RevokedAt *string `json:"RevokedAt"`
-	CreatedAt string  `json:"CreatedAt"`
+	ID                   string   `json:"ID"`
+	Name                 string   `json:"Name"`
+	KeyPrefix            string   `json:"KeyPrefix"`
+	Scopes               []string `json:"Scopes"`
+	HasSigningSecret     bool     `json:"HasSigningSecret"`
+	RateLimitPerSecond   *int32   `json:"RateLimitPerSecond"`
+	RateLimitBurst       *int32   `json:"RateLimitBurst"`
+	ExpiresAt            *string  `json:"ExpiresAt"`
+	RotatedAt            *string  `json:"RotatedAt"`
+	PreviousKeyExpiresAt *string  `json:"PreviousKeyExpiresAt"`
+	LastUsedAt           *string  `json:"LastUsedAt"`
+	RevokedAt            *string  `json:"RevokedAt"`
+	CreatedAt            string   `json:"CreatedAt"`
 }
 
 func (r *APIKeyResponse) FromDB(row *sqlcgen.ApiKey) *APIKeyResponse {
@@ -71,6 +118,30 @@ func (r *APIKeyResponse) FromDB(row *sqlcgen.ApiKey) *APIKeyResponse {
 	r.ID = row.ID.String()
 	r.Name = row.Name
 	r.KeyPrefix = row.KeyPrefix
+	r.Scopes = row.Scopes
+	r.HasSigningSecret = row.SigningSecret.Valid
+	if row.RateLimitPerSecond.Valid {
+		r.RateLimitPerSecond = &row.RateLimitPerSecond.Int32
+	}
+	if row.RateLimitBurst.Valid {
+		r.RateLimitBurst = &row.RateLimitBurst.Int32
+	}
+	if row.ExpiresAt.Valid {
+		s := row.ExpiresAt.Time.Format(time.RFC3339)
+		r.ExpiresAt = &s
+	}
+	if row.RotatedAt.Valid {
+		s := row.RotatedAt.Time.Format(time.RFC3339)
+		r.RotatedAt = &s
+	}
+	if row.PreviousKeyExpiresAt.Valid {
+		s := row.PreviousKeyExpiresAt.Time.Format(time.RFC3339)
+		r.PreviousKeyExpiresAt = &s
+	}
+	if row.LastUsedAt.Valid {
+		s := row.LastUsedAt.Time.Format(time.RFC3339)
+		r.LastUsedAt = &s
+	}
 	if row.RevokedAt.Valid {
 		s := row.RevokedAt.Time.Format(time.RFC3339)
 		r.RevokedAt = &s
@@ -92,24 +163,48 @@ func (h *APIKeyHandler) CreateAPIKey(c echo.Context) error {
 			WithInternal(fmt.Errorf("c.Bind: %w", err))
 	}
 
-	rawKey, err := generateAPIKey()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return echo.NewHTTPError(http.StatusBadRequest, "expires_at must be in the future")
+	}
+	var expiresAt pgtype.Timestamptz
+	if req.ExpiresAt != nil {
+		expiresAt = pgtype.Timestamptz{Time: *req.ExpiresAt, Valid: true}
+	}
+
+	id := uuid.New()
+	rawKey, err := apikey.Generate(auth.Mode(c), id)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate API key").
-			WithInternal(fmt.Errorf("generateAPIKey: %w", err))
+			WithInternal(fmt.Errorf("apikey.Generate: %w", err))
 	}
 
-	keyHash := hashKey(rawKey)
-	keyPrefix := rawKey[:11] // "bb_" + first 8 hex chars
+	signingSecret, err := generateSigningSecret()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate signing secret").
+			WithInternal(fmt.Errorf("generateSigningSecret: %w", err))
+	}
 
-	row, err := h.queries.CreateAPIKey(c.Request().Context(), sqlcgen.CreateAPIKeyParams{
-		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
-		Name:       req.Name,
-		KeyPrefix:  keyPrefix,
-		KeyHash:    keyHash,
+	ctx := c.Request().Context()
+	var row *sqlcgen.ApiKey
+	err = database.InTx(ctx, h.pool, func(q *sqlcgen.Queries) error {
+		row, err = q.CreateAPIKey(ctx, sqlcgen.CreateAPIKeyParams{
+			ID:            pgtype.UUID{Bytes: id, Valid: true},
+			MerchantID:    pgtype.UUID{Bytes: merchantID, Valid: true},
+			Name:          req.Name,
+			KeyPrefix:     apikey.Prefix(rawKey),
+			KeyHash:       h.hasher.Hash(rawKey),
+			Scopes:        req.Scopes,
+			ExpiresAt:     expiresAt,
+			SigningSecret: pgtype.Text{String: signingSecret, Valid: true},
+		})
+		if err != nil {
+			return fmt.Errorf("queries.CreateAPIKey: %w", err)
+		}
+		return outbox.Emit(ctx, q, row.MerchantID, outbox.EventAPIKeyCreated, outbox.NewAPIKeyData(row))
 	})
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create API key").
-			WithInternal(fmt.Errorf("queries.CreateAPIKey: %w", err))
+			WithInternal(fmt.Errorf("CreateAPIKey: %w", err))
 	}
 
 	return c.JSON(http.StatusCreated, new(CreateAPIKeyResponse).FromDB(row, rawKey))
@@ -152,29 +247,122 @@ func (h *APIKeyHandler) RevokeAPIKey(c echo.Context) error {
 			WithInternal(fmt.Errorf("c.Bind: %w", err))
 	}
 
-	err = h.queries.RevokeAPIKey(c.Request().Context(), sqlcgen.RevokeAPIKeyParams{
-		ID:         pgtype.UUID{Bytes: req.ID, Valid: true},
-		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+	ctx := c.Request().Context()
+	err = database.InTx(ctx, h.pool, func(q *sqlcgen.Queries) error {
+		row, err := q.RevokeAPIKey(ctx, sqlcgen.RevokeAPIKeyParams{
+			ID:         pgtype.UUID{Bytes: req.ID, Valid: true},
+			MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Unknown or already revoked.
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("queries.RevokeAPIKey: %w", err)
+		}
+		if err := q.NotifyAPIKeyChanged(ctx, row.ID); err != nil {
+			return fmt.Errorf("queries.NotifyAPIKeyChanged: %w", err)
+		}
+		return outbox.Emit(ctx, q, row.MerchantID, outbox.EventAPIKeyRevoked, outbox.NewAPIKeyData(row))
 	})
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke API key").
-			WithInternal(fmt.Errorf("queries.RevokeAPIKey: %w", err))
+			WithInternal(fmt.Errorf("RevokeAPIKey: %w", err))
 	}
 
 	return c.NoContent(http.StatusNoContent)
 }
 
-// generateAPIKey creates a random API key with the format "bb_<32 hex chars>".
-func generateAPIKey() (string, error) {
-	b := make([]byte, 16)
-	if _, err := rand.Read(b); err != nil {
-		return "", fmt.Errorf("generateAPIKey: %w", err)
+// RotateAPIKeyRequest rotates a key. The replaced key keeps authenticating
+// for GracePeriodHours, DEFAULT_ROTATION_GRACE_PERIOD if unset, and a key
+// it replaced itself stops right away.
+type RotateAPIKeyRequest struct {
+	ID               uuid.UUID `param:"id" validate:"required"`
+	GracePeriodHours *int32    `json:"grace_period_hours" validate:"omitempty,gte=0,lte=720"`
+}
+
+func (h *APIKeyHandler) RotateAPIKey(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("RotateAPIKey: %w", err))
 	}
-	return "bb_" + hex.EncodeToString(b), nil
+
+	var req RotateAPIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	gracePeriod := DEFAULT_ROTATION_GRACE_PERIOD
+	if req.GracePeriodHours != nil {
+		gracePeriod = time.Duration(*req.GracePeriodHours) * time.Hour
+	}
+
+	ctx := c.Request().Context()
+	// The mode of the key, rather than the dashboard's: keys belong to the
+	// test merchant in test mode.
+	testMode, err := h.queries.IsTestMerchant(ctx, pgtype.UUID{Bytes: merchantID, Valid: true})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to rotate API key").
+			WithInternal(fmt.Errorf("queries.IsTestMerchant: %w", err))
+	}
+	mode := auth.MODE_LIVE
+	if testMode {
+		mode = auth.MODE_TEST
+	}
+
+	// Rotating a legacy key, which embeds no ID, replaces it with one that
+	// does. The legacy key still authenticates by hash for the grace period.
+	rawKey, err := apikey.Generate(mode, req.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate API key").
+			WithInternal(fmt.Errorf("apikey.Generate: %w", err))
+	}
+
+	var row *sqlcgen.ApiKey
+	err = database.InTx(ctx, h.pool, func(q *sqlcgen.Queries) error {
+		id := pgtype.UUID{Bytes: req.ID, Valid: true}
+		// Before the rotation, for the hash it drops.
+		if err := q.NotifyAPIKeyChanged(ctx, id); err != nil {
+			return fmt.Errorf("queries.NotifyAPIKeyChanged: %w", err)
+		}
+		row, err = q.RotateAPIKey(ctx, sqlcgen.RotateAPIKeyParams{
+			PreviousKeyExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(gracePeriod), Valid: true},
+			KeyPrefix:            apikey.Prefix(rawKey),
+			KeyHash:              h.hasher.Hash(rawKey),
+			ID:                   id,
+			MerchantID:           pgtype.UUID{Bytes: merchantID, Valid: true},
+		})
+		if err != nil {
+			return fmt.Errorf("queries.RotateAPIKey: %w", err)
+		}
+		if err := q.NotifyAPIKeyChanged(ctx, id); err != nil {
+			return fmt.Errorf("queries.NotifyAPIKeyChanged: %w", err)
+		}
+		return nil
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Unknown, revoked or expired.
+		return echo.NewHTTPError(http.StatusNotFound, "API key not found")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to rotate API key").
+			WithInternal(fmt.Errorf("RotateAPIKey: %w", err))
+	}
+
+	resp := new(CreateAPIKeyResponse).FromDB(row, rawKey)
+	// The signing secret is not rotated along with the key, nor returned again.
+	resp.SigningSecret = nil
+	return c.JSON(http.StatusOK, resp)
 }
 
-// hashKey returns the SHA-256 hex digest of a raw API key.
-func hashKey(rawKey string) string {
-	h := sha256.Sum256([]byte(rawKey))
-	return hex.EncodeToString(h[:])
+// generateSigningSecret creates a random request signing secret with the
+// format "bbsig_<64 hex chars>".
+func generateSigningSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generateSigningSecret: %w", err)
+	}
+	return "bbsig_" + hex.EncodeToString(b), nil
 }
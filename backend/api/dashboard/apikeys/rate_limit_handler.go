@@ -0,0 +1,74 @@
+package apikeys
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"billbo.com/backend/api/dashboard/auth"
+	"billbo.com/backend/database"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+// PutAPIKeyRateLimitRequest sets the ingest rate limit of the key, in
+// requests per second with bursts of up to RateLimitBurst requests. Unset
+// values fall back to the ingest API defaults. The limits of the merchant
+// apply on top of those of the key.
+type PutAPIKeyRateLimitRequest struct {
+	ID                 uuid.UUID `param:"id" validate:"required"`
+	RateLimitPerSecond *int32    `json:"rate_limit_per_second" validate:"omitempty,gt=0"`
+	RateLimitBurst     *int32    `json:"rate_limit_burst" validate:"omitempty,gt=0"`
+}
+
+func (h *APIKeyHandler) PutAPIKeyRateLimit(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("PutAPIKeyRateLimit: %w", err))
+	}
+
+	var req PutAPIKeyRateLimitRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	var rateLimitPerSecond, rateLimitBurst pgtype.Int4
+	if req.RateLimitPerSecond != nil {
+		rateLimitPerSecond = pgtype.Int4{Int32: *req.RateLimitPerSecond, Valid: true}
+	}
+	if req.RateLimitBurst != nil {
+		rateLimitBurst = pgtype.Int4{Int32: *req.RateLimitBurst, Valid: true}
+	}
+
+	ctx := c.Request().Context()
+	var row *sqlcgen.ApiKey
+	err = database.InTx(ctx, h.pool, func(q *sqlcgen.Queries) error {
+		row, err = q.UpdateAPIKeyRateLimit(ctx, sqlcgen.UpdateAPIKeyRateLimitParams{
+			ID:                 pgtype.UUID{Bytes: req.ID, Valid: true},
+			MerchantID:         pgtype.UUID{Bytes: merchantID, Valid: true},
+			RateLimitPerSecond: rateLimitPerSecond,
+			RateLimitBurst:     rateLimitBurst,
+		})
+		if err != nil {
+			return fmt.Errorf("queries.UpdateAPIKeyRateLimit: %w", err)
+		}
+		if err := q.NotifyAPIKeyChanged(ctx, row.ID); err != nil {
+			return fmt.Errorf("queries.NotifyAPIKeyChanged: %w", err)
+		}
+		return nil
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "API key not found")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update API key rate limit").
+			WithInternal(fmt.Errorf("PutAPIKeyRateLimit: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, new(APIKeyResponse).FromDB(row))
+}
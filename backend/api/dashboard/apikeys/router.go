@@ -6,4 +6,7 @@ func (h *APIKeyHandler) Routes(e *echo.Group) {
 	e.POST("", h.CreateAPIKey)
 	e.GET("", h.ListAPIKeys)
 	e.DELETE("/:id", h.RevokeAPIKey)
+	e.POST("/:id/rotate", h.RotateAPIKey)
+	e.POST("/:id/signing-secret", h.ResetSigningSecret)
+	e.PUT("/:id/rate-limit", h.PutAPIKeyRateLimit)
 }
@@ -0,0 +1,71 @@
+package apikeys
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"billbo.com/backend/api/dashboard/auth"
+	"billbo.com/backend/database"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+type ResetSigningSecretRequest struct {
+	ID uuid.UUID `param:"id" validate:"required"`
+}
+
+type ResetSigningSecretResponse struct {
+	SigningSecret string `json:"signing_secret"`
+}
+
+// ResetSigningSecret generates a new request signing secret for the key,
+// such as one created before signed requests. The previous secret stops
+// authenticating right away.
+func (h *APIKeyHandler) ResetSigningSecret(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("ResetSigningSecret: %w", err))
+	}
+
+	var req ResetSigningSecretRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid API key ID").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	signingSecret, err := generateSigningSecret()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate signing secret").
+			WithInternal(fmt.Errorf("generateSigningSecret: %w", err))
+	}
+
+	ctx := c.Request().Context()
+	err = database.InTx(ctx, h.pool, func(q *sqlcgen.Queries) error {
+		row, err := q.SetAPIKeySigningSecret(ctx, sqlcgen.SetAPIKeySigningSecretParams{
+			ID:            pgtype.UUID{Bytes: req.ID, Valid: true},
+			MerchantID:    pgtype.UUID{Bytes: merchantID, Valid: true},
+			SigningSecret: pgtype.Text{String: signingSecret, Valid: true},
+		})
+		if err != nil {
+			return fmt.Errorf("queries.SetAPIKeySigningSecret: %w", err)
+		}
+		if err := q.NotifyAPIKeyChanged(ctx, row.ID); err != nil {
+			return fmt.Errorf("queries.NotifyAPIKeyChanged: %w", err)
+		}
+		return nil
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusNotFound, "API key not found")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to reset signing secret").
+			WithInternal(fmt.Errorf("ResetSigningSecret: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, &ResetSigningSecretResponse{SigningSecret: signingSecret})
+}
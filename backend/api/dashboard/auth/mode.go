@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// MODE_COOKIE_NAME is the cookie the dashboard sets to view the data of
+	// either mode. Without it, the live mode is viewed.
+	MODE_COOKIE_NAME = "mode"
+
+	MODE_LIVE = "live"
+	MODE_TEST = "test"
+)
+
+// Mode returns the mode of the request, MODE_LIVE or MODE_TEST (set by
+// ModeMiddleware).
+func Mode(c echo.Context) string {
+	if mode, ok := c.Get("mode").(string); ok {
+		return mode
+	}
+	return MODE_LIVE
+}
+
+// LiveMerchantID returns the ID of the logged in merchant, whatever the mode
+// of the request (set by ModeMiddleware).
+func LiveMerchantID(c echo.Context) (uuid.UUID, error) {
+	raw, ok := c.Get("live_merchant_id").(string)
+	if !ok {
+		return uuid.UUID{}, fmt.Errorf("LiveMerchantID: missing or invalid live_merchant_id in context")
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("LiveMerchantID: %w", err)
+	}
+	return id, nil
+}
+
+// ModeMiddleware returns an Echo middleware, set after JWTMiddleware, that
+// replaces the merchant ID in the context with the ID of its test merchant
+// for requests in test mode, creating the test merchant the first time.
+func ModeMiddleware(queries *sqlcgen.Queries) echo.MiddlewareFunc {
+	// Test merchants never change, so they are cached by live merchant ID.
+	var testMerchantIDs sync.Map
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			merchantID, err := MerchantID(c)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+					WithInternal(fmt.Errorf("ModeMiddleware: %w", err))
+			}
+			c.Set("live_merchant_id", merchantID.String())
+
+			mode := MODE_LIVE
+			if cookie, err := c.Cookie(MODE_COOKIE_NAME); err == nil {
+				mode = cookie.Value
+			}
+			switch mode {
+			case MODE_LIVE:
+			case MODE_TEST:
+				testMerchantID, ok := testMerchantIDs.Load(merchantID)
+				if !ok {
+					testMerchantID, err = getOrCreateTestMerchant(c, queries, merchantID)
+					if err != nil {
+						return echo.NewHTTPError(http.StatusInternalServerError, "failed to switch to test mode").
+							WithInternal(fmt.Errorf("getOrCreateTestMerchant: %w", err))
+					}
+					testMerchantIDs.Store(merchantID, testMerchantID)
+				}
+				c.Set("merchant_id", testMerchantID.(uuid.UUID).String())
+			default:
+				return echo.NewHTTPError(http.StatusBadRequest, "invalid mode")
+			}
+			c.Set("mode", mode)
+
+			return next(c)
+		}
+	}
+}
+
+func getOrCreateTestMerchant(c echo.Context, queries *sqlcgen.Queries, merchantID uuid.UUID) (uuid.UUID, error) {
+	ctx := c.Request().Context()
+	liveMerchantID := pgtype.UUID{Bytes: merchantID, Valid: true}
+
+	id, err := queries.GetTestMerchantID(ctx, liveMerchantID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if err := queries.CreateTestMerchant(ctx, liveMerchantID); err != nil {
+			return uuid.UUID{}, fmt.Errorf("queries.CreateTestMerchant: %w", err)
+		}
+		id, err = queries.GetTestMerchantID(ctx, liveMerchantID)
+	}
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("queries.GetTestMerchantID: %w", err)
+	}
+	return id.Bytes, nil
+}
@@ -0,0 +1,131 @@
+package billingsettings
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"billbo.com/backend/api/dashboard/auth"
+	"billbo.com/backend/database"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+type BillingSettingsHandler struct {
+	logger  *zap.Logger
+	queries *sqlcgen.Queries
+	pool    *pgxpool.Pool
+}
+
+func NewBillingSettingsHandler(
+	logger *zap.Logger,
+	queries *sqlcgen.Queries,
+	pool *pgxpool.Pool,
+) *BillingSettingsHandler {
+	return &BillingSettingsHandler{
+		logger: logger.With(
+			zap.String("api", "dashboard"),
+			zap.String("handler", "billingsettings"),
+		),
+		queries: queries,
+		pool:    pool,
+	}
+}
+
+type BillingSettingsResponse struct {
+	Timezone             string `json:"Timezone"`
+	AnchorDay            int32  `json:"AnchorDay"`
+	EventRetentionMonths *int32 `json:"EventRetentionMonths"`
+	UpdatedAt            string `json:"UpdatedAt"`
+}
+
+func (r *BillingSettingsResponse) FromDB(row *sqlcgen.BillingSetting) *BillingSettingsResponse {
+	if row == nil {
+		return nil
+	}
+	r.Timezone = row.Timezone
+	r.AnchorDay = row.AnchorDay
+	if row.EventRetentionMonths.Valid {
+		r.EventRetentionMonths = &row.EventRetentionMonths.Int32
+	}
+	r.UpdatedAt = row.UpdatedAt.Time.Format(time.RFC3339)
+	return r
+}
+
+func (h *BillingSettingsHandler) GetBillingSettings(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("GetBillingSettings: %w", err))
+	}
+
+	row, err := h.queries.GetOrCreateBillingSettings(c.Request().Context(), pgtype.UUID{Bytes: merchantID, Valid: true})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch billing settings").
+			WithInternal(fmt.Errorf("queries.GetOrCreateBillingSettings: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, new(BillingSettingsResponse).FromDB(row))
+}
+
+// PutBillingSettingsRequest sets when the merchant's billing periods close:
+// at midnight in Timezone (an IANA name such as "Europe/Paris"), on
+// AnchorDay of every month, or on the last day of months shorter than that.
+// Customers with a timezone of their own are billed in it. Changes apply
+// from the next period close.
+//
+// EventRetentionMonths is how long raw events are kept once their month is
+// over and they are invoiced; they are kept forever if unset. Only archived
+// events are dropped, so without archiving they are kept forever too. Events
+// are stored in monthly partitions shared with other merchants, so they may
+// be kept longer.
+type PutBillingSettingsRequest struct {
+	Timezone             string `json:"timezone" validate:"required,timezone"`
+	AnchorDay            int32  `json:"anchor_day" validate:"gte=1,lte=31"`
+	EventRetentionMonths *int32 `json:"event_retention_months" validate:"omitempty,gte=1"`
+}
+
+func (h *BillingSettingsHandler) PutBillingSettings(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("PutBillingSettings: %w", err))
+	}
+
+	var req PutBillingSettingsRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	var eventRetentionMonths pgtype.Int4
+	if req.EventRetentionMonths != nil {
+		eventRetentionMonths = pgtype.Int4{Int32: *req.EventRetentionMonths, Valid: true}
+	}
+
+	ctx := c.Request().Context()
+	var row *sqlcgen.BillingSetting
+	err = database.InTx(ctx, h.pool, func(q *sqlcgen.Queries) error {
+		var err error
+		row, err = q.UpdateBillingSettings(ctx, sqlcgen.UpdateBillingSettingsParams{
+			MerchantID:           pgtype.UUID{Bytes: merchantID, Valid: true},
+			Timezone:             req.Timezone,
+			AnchorDay:            req.AnchorDay,
+			EventRetentionMonths: eventRetentionMonths,
+		})
+		if err != nil {
+			return fmt.Errorf("queries.UpdateBillingSettings: %w", err)
+		}
+		// The customers' billing periods follow the merchant's settings.
+		return q.NotifyMerchantCustomersChanged(ctx, row.MerchantID)
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update billing settings").
+			WithInternal(fmt.Errorf("PutBillingSettings: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, new(BillingSettingsResponse).FromDB(row))
+}
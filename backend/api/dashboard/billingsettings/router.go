@@ -0,0 +1,8 @@
+package billingsettings
+
+import "github.com/labstack/echo/v4"
+
+func (h *BillingSettingsHandler) Routes(e *echo.Group) {
+	e.GET("", h.GetBillingSettings)
+	e.PUT("", h.PutBillingSettings)
+}
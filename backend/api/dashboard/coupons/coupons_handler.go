@@ -0,0 +1,335 @@
+package coupons
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"billbo.com/backend/api/dashboard/auth"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+type CouponHandler struct {
+	logger  *zap.Logger
+	queries *sqlcgen.Queries
+}
+
+func NewCouponHandler(
+	logger *zap.Logger,
+	queries *sqlcgen.Queries,
+) *CouponHandler {
+	return &CouponHandler{
+		logger: logger.With(
+			zap.String("api", "dashboard"),
+			zap.String("handler", "coupons"),
+		),
+		queries: queries,
+	}
+}
+
+type CouponResponse struct {
+	ID              string   `json:"ID"`
+	Name            string   `json:"Name"`
+	PercentOff      *float64 `json:"PercentOff"`
+	AmountOff       *float64 `json:"AmountOff"`
+	Duration        string   `json:"Duration"`
+	DurationPeriods *int32   `json:"DurationPeriods"`
+	SkuIDs          []string `json:"SkuIDs"`
+	RevokedAt       *string  `json:"RevokedAt"`
+	CreatedAt       string   `json:"CreatedAt"`
+}
+
+func (r *CouponResponse) FromDB(row *sqlcgen.Coupon) *CouponResponse {
+	if row == nil {
+		return nil
+	}
+	r.ID = row.ID.String()
+	r.Name = row.Name
+	if row.PercentOff.Valid {
+		r.PercentOff = &row.PercentOff.Float64
+	}
+	if row.AmountOff.Valid {
+		r.AmountOff = &row.AmountOff.Float64
+	}
+	r.Duration = row.Duration
+	if row.DurationPeriods.Valid {
+		r.DurationPeriods = &row.DurationPeriods.Int32
+	}
+	r.SkuIDs = make([]string, len(row.SkuIds))
+	for i, id := range row.SkuIds {
+		r.SkuIDs[i] = id.String()
+	}
+	if row.RevokedAt.Valid {
+		s := row.RevokedAt.Time.Format(time.RFC3339)
+		r.RevokedAt = &s
+	}
+	r.CreatedAt = row.CreatedAt.Time.Format(time.RFC3339)
+	return r
+}
+
+// CreateCouponRequest describes a coupon. Exactly one of percent_off and
+// amount_off must be set. duration_periods is required for "repeating"
+// coupons. An empty sku_ids applies the coupon to the whole invoice.
+type CreateCouponRequest struct {
+	Name            string      `json:"name" validate:"required"`
+	PercentOff      *float64    `json:"percent_off" validate:"required_without=AmountOff,excluded_with=AmountOff,omitempty,gt=0,lte=100"`
+	AmountOff       *float64    `json:"amount_off" validate:"omitempty,gt=0"`
+	Duration        string      `json:"duration" validate:"required,oneof=once repeating forever"`
+	DurationPeriods *int32      `json:"duration_periods" validate:"required_if=Duration repeating,excluded_unless=Duration repeating,omitempty,gt=0"`
+	SkuIDs          []uuid.UUID `json:"sku_ids" validate:"unique"`
+}
+
+func (h *CouponHandler) CreateCoupon(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("CreateCoupon: %w", err))
+	}
+
+	var req CreateCouponRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	var percentOff, amountOff pgtype.Float8
+	if req.PercentOff != nil {
+		percentOff = pgtype.Float8{Float64: *req.PercentOff, Valid: true}
+	}
+	if req.AmountOff != nil {
+		amountOff = pgtype.Float8{Float64: *req.AmountOff, Valid: true}
+	}
+	var durationPeriods pgtype.Int4
+	if req.DurationPeriods != nil {
+		durationPeriods = pgtype.Int4{Int32: *req.DurationPeriods, Valid: true}
+	}
+	skuIDs := make([]pgtype.UUID, len(req.SkuIDs))
+	for i, id := range req.SkuIDs {
+		skuIDs[i] = pgtype.UUID{Bytes: id, Valid: true}
+	}
+
+	ctx := c.Request().Context()
+	if len(skuIDs) > 0 {
+		count, err := h.queries.CountMerchantSKUs(ctx, sqlcgen.CountMerchantSKUsParams{
+			MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+			Ids:        skuIDs,
+		})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to create coupon").
+				WithInternal(fmt.Errorf("queries.CountMerchantSKUs: %w", err))
+		}
+		if count != int64(len(skuIDs)) {
+			return echo.NewHTTPError(http.StatusBadRequest, "unknown SKU in sku_ids")
+		}
+	}
+
+	row, err := h.queries.CreateCoupon(ctx, sqlcgen.CreateCouponParams{
+		MerchantID:      pgtype.UUID{Bytes: merchantID, Valid: true},
+		Name:            req.Name,
+		PercentOff:      percentOff,
+		AmountOff:       amountOff,
+		Duration:        req.Duration,
+		DurationPeriods: durationPeriods,
+		SkuIds:          skuIDs,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create coupon").
+			WithInternal(fmt.Errorf("queries.CreateCoupon: %w", err))
+	}
+
+	return c.JSON(http.StatusCreated, new(CouponResponse).FromDB(row))
+}
+
+func (h *CouponHandler) ListCoupons(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("ListCoupons: %w", err))
+	}
+
+	rows, err := h.queries.ListCouponsByMerchantID(c.Request().Context(), pgtype.UUID{Bytes: merchantID, Valid: true})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list coupons").
+			WithInternal(fmt.Errorf("queries.ListCouponsByMerchantID: %w", err))
+	}
+
+	coupons := make([]*CouponResponse, len(rows))
+	for i, row := range rows {
+		coupons[i] = new(CouponResponse).FromDB(row)
+	}
+	return c.JSON(http.StatusOK, coupons)
+}
+
+type RevokeCouponRequest struct {
+	ID uuid.UUID `param:"id" validate:"required"`
+}
+
+// RevokeCoupon prevents the coupon from being redeemed again. Existing
+// redemptions keep applying until they run out or are revoked themselves.
+func (h *CouponHandler) RevokeCoupon(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("RevokeCoupon: %w", err))
+	}
+
+	var req RevokeCouponRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid coupon ID").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	err = h.queries.RevokeCoupon(c.Request().Context(), sqlcgen.RevokeCouponParams{
+		ID:         pgtype.UUID{Bytes: req.ID, Valid: true},
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke coupon").
+			WithInternal(fmt.Errorf("queries.RevokeCoupon: %w", err))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+type RedemptionResponse struct {
+	ID             string  `json:"ID"`
+	CouponID       string  `json:"CouponID"`
+	CustomerID     string  `json:"CustomerID"`
+	PeriodsApplied int32   `json:"PeriodsApplied"`
+	RevokedAt      *string `json:"RevokedAt"`
+	CreatedAt      string  `json:"CreatedAt"`
+}
+
+func (r *RedemptionResponse) FromDB(row *sqlcgen.CouponRedemption) *RedemptionResponse {
+	if row == nil {
+		return nil
+	}
+	r.ID = row.ID.String()
+	r.CouponID = row.CouponID.String()
+	r.CustomerID = row.CustomerID.String()
+	r.PeriodsApplied = row.PeriodsApplied
+	if row.RevokedAt.Valid {
+		s := row.RevokedAt.Time.Format(time.RFC3339)
+		r.RevokedAt = &s
+	}
+	r.CreatedAt = row.CreatedAt.Time.Format(time.RFC3339)
+	return r
+}
+
+type RedeemCouponRequest struct {
+	ID         uuid.UUID `param:"id" validate:"required"`
+	CustomerID uuid.UUID `json:"customer_id" validate:"required"`
+}
+
+// RedeemCoupon attaches a coupon to a customer. It is applied to every
+// invoice generated for that customer until its duration runs out.
+func (h *CouponHandler) RedeemCoupon(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("RedeemCoupon: %w", err))
+	}
+
+	var req RedeemCouponRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	coupon, err := h.queries.GetCoupon(c.Request().Context(), sqlcgen.GetCouponParams{
+		ID:         pgtype.UUID{Bytes: req.ID, Valid: true},
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "coupon not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch coupon").
+			WithInternal(fmt.Errorf("queries.GetCoupon: %w", err))
+	}
+	if coupon.RevokedAt.Valid {
+		return echo.NewHTTPError(http.StatusConflict, "coupon has been revoked")
+	}
+
+	row, err := h.queries.CreateCouponRedemption(c.Request().Context(), sqlcgen.CreateCouponRedemptionParams{
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+		CouponID:   coupon.ID,
+		CustomerID: pgtype.UUID{Bytes: req.CustomerID, Valid: true},
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to redeem coupon").
+			WithInternal(fmt.Errorf("queries.CreateCouponRedemption: %w", err))
+	}
+
+	return c.JSON(http.StatusCreated, new(RedemptionResponse).FromDB(row))
+}
+
+type ListRedemptionsRequest struct {
+	ID uuid.UUID `param:"id" validate:"required"`
+}
+
+func (h *CouponHandler) ListRedemptions(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("ListRedemptions: %w", err))
+	}
+
+	var req ListRedemptionsRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid coupon ID").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	rows, err := h.queries.ListCouponRedemptionsByCouponID(c.Request().Context(), sqlcgen.ListCouponRedemptionsByCouponIDParams{
+		CouponID:   pgtype.UUID{Bytes: req.ID, Valid: true},
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list redemptions").
+			WithInternal(fmt.Errorf("queries.ListCouponRedemptionsByCouponID: %w", err))
+	}
+
+	redemptions := make([]*RedemptionResponse, len(rows))
+	for i, row := range rows {
+		redemptions[i] = new(RedemptionResponse).FromDB(row)
+	}
+	return c.JSON(http.StatusOK, redemptions)
+}
+
+type RevokeRedemptionRequest struct {
+	ID           uuid.UUID `param:"id" validate:"required"`
+	RedemptionID uuid.UUID `param:"redemption_id" validate:"required"`
+}
+
+func (h *CouponHandler) RevokeRedemption(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("RevokeRedemption: %w", err))
+	}
+
+	var req RevokeRedemptionRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid redemption ID").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	err = h.queries.RevokeCouponRedemption(c.Request().Context(), sqlcgen.RevokeCouponRedemptionParams{
+		ID:         pgtype.UUID{Bytes: req.RedemptionID, Valid: true},
+		CouponID:   pgtype.UUID{Bytes: req.ID, Valid: true},
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke redemption").
+			WithInternal(fmt.Errorf("queries.RevokeCouponRedemption: %w", err))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
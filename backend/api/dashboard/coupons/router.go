@@ -0,0 +1,12 @@
+package coupons
+
+import "github.com/labstack/echo/v4"
+
+func (h *CouponHandler) Routes(e *echo.Group) {
+	e.POST("", h.CreateCoupon)
+	e.GET("", h.ListCoupons)
+	e.DELETE("/:id", h.RevokeCoupon)
+	e.POST("/:id/redemptions", h.RedeemCoupon)
+	e.GET("/:id/redemptions", h.ListRedemptions)
+	e.DELETE("/:id/redemptions/:redemption_id", h.RevokeRedemption)
+}
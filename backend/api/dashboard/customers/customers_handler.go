@@ -0,0 +1,266 @@
+package customers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"billbo.com/backend/api/dashboard/auth"
+	"billbo.com/backend/database"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+type CustomerHandler struct {
+	logger  *zap.Logger
+	queries *sqlcgen.Queries
+	pool    *pgxpool.Pool
+}
+
+func NewCustomerHandler(
+	logger *zap.Logger,
+	queries *sqlcgen.Queries,
+	pool *pgxpool.Pool,
+) *CustomerHandler {
+	return &CustomerHandler{
+		logger: logger.With(
+			zap.String("api", "dashboard"),
+			zap.String("handler", "customers"),
+		),
+		queries: queries,
+		pool:    pool,
+	}
+}
+
+type CustomerResponse struct {
+	ID           string  `json:"ID"`
+	Name         string  `json:"Name"`
+	Email        *string `json:"Email"`
+	AddressLine1 *string `json:"AddressLine1"`
+	AddressLine2 *string `json:"AddressLine2"`
+	City         *string `json:"City"`
+	PostalCode   *string `json:"PostalCode"`
+	Region       *string `json:"Region"`
+	Country      *string `json:"Country"`
+	TaxID        *string `json:"TaxID"`
+	// Timezone overrides the merchant's billing timezone.
+	Timezone *string `json:"Timezone"`
+	// IngestSuspendedAt is set when dunning suspended the customer's usage
+	// ingestion.
+	IngestSuspendedAt *string `json:"IngestSuspendedAt"`
+	CreatedAt         string  `json:"CreatedAt"`
+	UpdatedAt         string  `json:"UpdatedAt"`
+}
+
+func (r *CustomerResponse) FromDB(row *sqlcgen.Customer) *CustomerResponse {
+	if row == nil {
+		return nil
+	}
+	r.ID = row.ID.String()
+	r.Name = row.Name
+	r.Email = textPtr(row.Email)
+	r.AddressLine1 = textPtr(row.AddressLine1)
+	r.AddressLine2 = textPtr(row.AddressLine2)
+	r.City = textPtr(row.City)
+	r.PostalCode = textPtr(row.PostalCode)
+	r.Region = textPtr(row.Region)
+	r.Country = textPtr(row.Country)
+	r.TaxID = textPtr(row.TaxID)
+	r.Timezone = textPtr(row.Timezone)
+	if row.IngestSuspendedAt.Valid {
+		s := row.IngestSuspendedAt.Time.Format(time.RFC3339)
+		r.IngestSuspendedAt = &s
+	}
+	r.CreatedAt = row.CreatedAt.Time.Format(time.RFC3339)
+	r.UpdatedAt = row.UpdatedAt.Time.Format(time.RFC3339)
+	return r
+}
+
+func (h *CustomerHandler) ListCustomers(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("ListCustomers: %w", err))
+	}
+
+	rows, err := h.queries.ListCustomersByMerchantID(c.Request().Context(), pgtype.UUID{Bytes: merchantID, Valid: true})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list customers").
+			WithInternal(fmt.Errorf("queries.ListCustomersByMerchantID: %w", err))
+	}
+
+	customers := make([]*CustomerResponse, len(rows))
+	for i, row := range rows {
+		customers[i] = new(CustomerResponse).FromDB(row)
+	}
+	return c.JSON(http.StatusOK, customers)
+}
+
+type GetCustomerRequest struct {
+	ID uuid.UUID `param:"id" validate:"required"`
+}
+
+func (h *CustomerHandler) GetCustomer(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("GetCustomer: %w", err))
+	}
+
+	var req GetCustomerRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid customer ID").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	row, err := h.queries.GetCustomer(c.Request().Context(), sqlcgen.GetCustomerParams{
+		ID:         pgtype.UUID{Bytes: req.ID, Valid: true},
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "customer not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch customer").
+			WithInternal(fmt.Errorf("queries.GetCustomer: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, new(CustomerResponse).FromDB(row))
+}
+
+// PutCustomerRequest creates or replaces the billing details of a customer.
+// The ID is the one the merchant sends as customer_id in usage events.
+type PutCustomerRequest struct {
+	ID           uuid.UUID `param:"id" validate:"required"`
+	Name         string    `json:"name" validate:"required"`
+	Email        *string   `json:"email" validate:"omitempty,email"`
+	AddressLine1 *string   `json:"address_line1"`
+	AddressLine2 *string   `json:"address_line2"`
+	City         *string   `json:"city"`
+	PostalCode   *string   `json:"postal_code"`
+	Region       *string   `json:"region"`
+	Country      *string   `json:"country" validate:"omitempty,iso3166_1_alpha2"`
+	TaxID        *string   `json:"tax_id"`
+	Timezone     *string   `json:"timezone" validate:"omitempty,timezone"`
+}
+
+func (h *CustomerHandler) PutCustomer(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("PutCustomer: %w", err))
+	}
+
+	var req PutCustomerRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	ctx := c.Request().Context()
+	var row *sqlcgen.Customer
+	err = database.InTx(ctx, h.pool, func(q *sqlcgen.Queries) error {
+		var err error
+		row, err = q.UpsertCustomer(ctx, sqlcgen.UpsertCustomerParams{
+			ID:           pgtype.UUID{Bytes: req.ID, Valid: true},
+			MerchantID:   pgtype.UUID{Bytes: merchantID, Valid: true},
+			Name:         req.Name,
+			Email:        toText(req.Email),
+			AddressLine1: toText(req.AddressLine1),
+			AddressLine2: toText(req.AddressLine2),
+			City:         toText(req.City),
+			PostalCode:   toText(req.PostalCode),
+			Region:       toText(upper(req.Region)),
+			Country:      toText(req.Country),
+			TaxID:        toText(req.TaxID),
+			Timezone:     toText(req.Timezone),
+		})
+		if err != nil {
+			return fmt.Errorf("queries.UpsertCustomer: %w", err)
+		}
+		return q.NotifyCustomerChanged(ctx, sqlcgen.NotifyCustomerChangedParams{
+			MerchantID: row.MerchantID,
+			CustomerID: row.ID,
+		})
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save customer").
+			WithInternal(fmt.Errorf("PutCustomer: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, new(CustomerResponse).FromDB(row))
+}
+
+type ResumeIngestRequest struct {
+	ID uuid.UUID `param:"id" validate:"required"`
+}
+
+// ResumeIngest lifts the ingestion suspension dunning put on a customer.
+func (h *CustomerHandler) ResumeIngest(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("ResumeIngest: %w", err))
+	}
+
+	var req ResumeIngestRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid customer ID").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	ctx := c.Request().Context()
+	var row *sqlcgen.Customer
+	err = database.InTx(ctx, h.pool, func(q *sqlcgen.Queries) error {
+		var err error
+		row, err = q.ResumeCustomerIngest(ctx, sqlcgen.ResumeCustomerIngestParams{
+			ID:         pgtype.UUID{Bytes: req.ID, Valid: true},
+			MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+		})
+		if err != nil {
+			return fmt.Errorf("queries.ResumeCustomerIngest: %w", err)
+		}
+		return q.NotifyCustomerChanged(ctx, sqlcgen.NotifyCustomerChangedParams{
+			MerchantID: row.MerchantID,
+			CustomerID: row.ID,
+		})
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "customer not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to resume customer ingest").
+			WithInternal(fmt.Errorf("ResumeIngest: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, new(CustomerResponse).FromDB(row))
+}
+
+func toText(s *string) pgtype.Text {
+	if s == nil || *s == "" {
+		return pgtype.Text{}
+	}
+	return pgtype.Text{String: *s, Valid: true}
+}
+
+func textPtr(t pgtype.Text) *string {
+	if !t.Valid {
+		return nil
+	}
+	return &t.String
+}
+
+func upper(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	u := strings.ToUpper(*s)
+	return &u
+}
@@ -0,0 +1,13 @@
+package customers
+
+import "github.com/labstack/echo/v4"
+
+func (h *CustomerHandler) Routes(e *echo.Group) {
+	e.GET("", h.ListCustomers)
+	e.GET("/:id", h.GetCustomer)
+	e.PUT("/:id", h.PutCustomer)
+	e.DELETE("/:id/ingest-suspension", h.ResumeIngest)
+	e.GET("/:id/spend-cap", h.GetSpendCap)
+	e.PUT("/:id/spend-cap", h.PutSpendCap)
+	e.DELETE("/:id/spend-cap", h.DeleteSpendCap)
+}
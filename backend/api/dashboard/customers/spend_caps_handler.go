@@ -0,0 +1,141 @@
+package customers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"billbo.com/backend/api/dashboard/auth"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+type SpendCapResponse struct {
+	CustomerID  string  `json:"CustomerID"`
+	AmountLimit float64 `json:"AmountLimit"`
+	PerPeriod   bool    `json:"PerPeriod"`
+	// Spent is the spend charged against the cap, over PeriodStart's period
+	// for per-period caps.
+	Spent       float64 `json:"Spent"`
+	PeriodStart *string `json:"PeriodStart"`
+	UpdatedAt   string  `json:"UpdatedAt"`
+}
+
+func (r *SpendCapResponse) FromDB(row *sqlcgen.SpendCap) *SpendCapResponse {
+	if row == nil {
+		return nil
+	}
+	r.CustomerID = row.CustomerID.String()
+	r.AmountLimit = row.AmountLimit
+	r.PerPeriod = row.PerPeriod
+	r.Spent = row.Spent
+	if row.PeriodStart.Valid {
+		s := row.PeriodStart.Time.Format(time.RFC3339)
+		r.PeriodStart = &s
+	}
+	r.UpdatedAt = row.UpdatedAt.Time.Format(time.RFC3339)
+	return r
+}
+
+type GetSpendCapRequest struct {
+	ID uuid.UUID `param:"id" validate:"required"`
+}
+
+func (h *CustomerHandler) GetSpendCap(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("GetSpendCap: %w", err))
+	}
+
+	var req GetSpendCapRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid customer ID").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	row, err := h.queries.GetSpendCap(c.Request().Context(), sqlcgen.GetSpendCapParams{
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+		CustomerID: pgtype.UUID{Bytes: req.ID, Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "spend cap not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch spend cap").
+			WithInternal(fmt.Errorf("queries.GetSpendCap: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, new(SpendCapResponse).FromDB(row))
+}
+
+// PutSpendCapRequest caps what the customer can spend: once the cost of
+// their usage events, at their prices, reaches AmountLimit, the ingest API
+// rejects their events with 402 Payment Required. A per-period cap starts
+// over with each billing period; otherwise the cap is a prepaid balance,
+// topped up by raising the limit. Spend is counted from when the cap is set.
+type PutSpendCapRequest struct {
+	ID          uuid.UUID `param:"id" validate:"required"`
+	AmountLimit float64   `json:"amount_limit" validate:"gte=0"`
+	PerPeriod   bool      `json:"per_period"`
+}
+
+func (h *CustomerHandler) PutSpendCap(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("PutSpendCap: %w", err))
+	}
+
+	var req PutSpendCapRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	row, err := h.queries.PutSpendCap(c.Request().Context(), sqlcgen.PutSpendCapParams{
+		MerchantID:  pgtype.UUID{Bytes: merchantID, Valid: true},
+		CustomerID:  pgtype.UUID{Bytes: req.ID, Valid: true},
+		AmountLimit: req.AmountLimit,
+		PerPeriod:   req.PerPeriod,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save spend cap").
+			WithInternal(fmt.Errorf("queries.PutSpendCap: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, new(SpendCapResponse).FromDB(row))
+}
+
+type DeleteSpendCapRequest struct {
+	ID uuid.UUID `param:"id" validate:"required"`
+}
+
+func (h *CustomerHandler) DeleteSpendCap(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("DeleteSpendCap: %w", err))
+	}
+
+	var req DeleteSpendCapRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid customer ID").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	err = h.queries.DeleteSpendCap(c.Request().Context(), sqlcgen.DeleteSpendCapParams{
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+		CustomerID: pgtype.UUID{Bytes: req.ID, Valid: true},
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete spend cap").
+			WithInternal(fmt.Errorf("queries.DeleteSpendCap: %w", err))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,113 @@
+package dunningsettings
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"billbo.com/backend/api/dashboard/auth"
+	"billbo.com/backend/database/sqlcgen"
+	"billbo.com/backend/payments"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+type DunningSettingsHandler struct {
+	logger  *zap.Logger
+	queries *sqlcgen.Queries
+}
+
+func NewDunningSettingsHandler(
+	logger *zap.Logger,
+	queries *sqlcgen.Queries,
+) *DunningSettingsHandler {
+	return &DunningSettingsHandler{
+		logger: logger.With(
+			zap.String("api", "dashboard"),
+			zap.String("handler", "dunningsettings"),
+		),
+		queries: queries,
+	}
+}
+
+type DunningSettingsResponse struct {
+	RetryDays     []int32 `json:"RetryDays"`
+	SuspendIngest bool    `json:"SuspendIngest"`
+	UpdatedAt     string  `json:"UpdatedAt"`
+}
+
+func (r *DunningSettingsResponse) FromDB(row *sqlcgen.DunningSetting) *DunningSettingsResponse {
+	if row == nil {
+		return nil
+	}
+	r.RetryDays = row.RetryDays
+	r.SuspendIngest = row.SuspendIngest
+	r.UpdatedAt = row.UpdatedAt.Time.Format(time.RFC3339)
+	return r
+}
+
+func (h *DunningSettingsHandler) GetDunningSettings(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("GetDunningSettings: %w", err))
+	}
+
+	merchantUUID := pgtype.UUID{Bytes: merchantID, Valid: true}
+	row, err := h.queries.GetDunningSettings(c.Request().Context(), merchantUUID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Saved by the first PUT.
+		row = payments.DefaultDunningSettings(merchantUUID)
+	} else if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch dunning settings").
+			WithInternal(fmt.Errorf("queries.GetDunningSettings: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, new(DunningSettingsResponse).FromDB(row))
+}
+
+// PutDunningSettingsRequest configures how failed payments are retried.
+// RetryDays are counted from the first failure and must be increasing; an
+// empty schedule marks invoices uncollectible on their first failure.
+// SuspendIngest rejects the usage events of customers with uncollectible
+// invoices.
+type PutDunningSettingsRequest struct {
+	RetryDays     []int32 `json:"retry_days" validate:"max=10,dive,gte=1,lte=90"`
+	SuspendIngest bool    `json:"suspend_ingest"`
+}
+
+func (h *DunningSettingsHandler) PutDunningSettings(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("PutDunningSettings: %w", err))
+	}
+
+	var req PutDunningSettingsRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+	if req.RetryDays == nil {
+		req.RetryDays = []int32{}
+	}
+	if !slices.IsSorted(req.RetryDays) || len(slices.Compact(slices.Clone(req.RetryDays))) != len(req.RetryDays) {
+		return echo.NewHTTPError(http.StatusBadRequest, "retry days must be increasing")
+	}
+
+	row, err := h.queries.UpdateDunningSettings(c.Request().Context(), sqlcgen.UpdateDunningSettingsParams{
+		MerchantID:    pgtype.UUID{Bytes: merchantID, Valid: true},
+		RetryDays:     req.RetryDays,
+		SuspendIngest: req.SuspendIngest,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update dunning settings").
+			WithInternal(fmt.Errorf("queries.UpdateDunningSettings: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, new(DunningSettingsResponse).FromDB(row))
+}
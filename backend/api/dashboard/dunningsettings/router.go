@@ -0,0 +1,8 @@
+package dunningsettings
+
+import "github.com/labstack/echo/v4"
+
+func (h *DunningSettingsHandler) Routes(e *echo.Group) {
+	e.GET("", h.GetDunningSettings)
+	e.PUT("", h.PutDunningSettings)
+}
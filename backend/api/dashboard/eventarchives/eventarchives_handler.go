@@ -0,0 +1,116 @@
+package eventarchives
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"billbo.com/backend/api/dashboard/auth"
+	"billbo.com/backend/archive"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+type EventArchiveHandler struct {
+	logger   *zap.Logger
+	queries  *sqlcgen.Queries
+	archiver *archive.Archiver
+}
+
+func NewEventArchiveHandler(
+	logger *zap.Logger,
+	queries *sqlcgen.Queries,
+	archiver *archive.Archiver,
+) *EventArchiveHandler {
+	return &EventArchiveHandler{
+		logger: logger.With(
+			zap.String("api", "dashboard"),
+			zap.String("handler", "eventarchives"),
+		),
+		queries:  queries,
+		archiver: archiver,
+	}
+}
+
+type EventArchiveResponse struct {
+	PeriodStart     string  `json:"PeriodStart"`
+	PeriodEnd       string  `json:"PeriodEnd"`
+	EventCount      int64   `json:"EventCount"`
+	SizeBytes       int64   `json:"SizeBytes"`
+	Sha256          string  `json:"Sha256"`
+	ArchivedAt      string  `json:"ArchivedAt"`
+	RehydratedUntil *string `json:"RehydratedUntil"`
+}
+
+func (r *EventArchiveResponse) FromDB(a *sqlcgen.EventArchive) *EventArchiveResponse {
+	if a == nil {
+		return nil
+	}
+	r.PeriodStart = a.PeriodStart.Time.Format(time.RFC3339)
+	r.PeriodEnd = a.PeriodEnd.Time.Format(time.RFC3339)
+	r.EventCount = a.EventCount
+	r.SizeBytes = a.SizeBytes
+	r.Sha256 = a.Sha256
+	r.ArchivedAt = a.ArchivedAt.Time.Format(time.RFC3339)
+	if a.RehydratedUntil.Valid {
+		rehydratedUntil := a.RehydratedUntil.Time.Format(time.RFC3339)
+		r.RehydratedUntil = &rehydratedUntil
+	}
+	return r
+}
+
+func (h *EventArchiveHandler) ListEventArchives(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("ListEventArchives: %w", err))
+	}
+
+	archives, err := h.queries.ListEventArchivesByMerchantID(c.Request().Context(), pgtype.UUID{Bytes: merchantID, Valid: true})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch event archives").
+			WithInternal(fmt.Errorf("queries.ListEventArchivesByMerchantID: %w", err))
+	}
+
+	resp := make([]*EventArchiveResponse, len(archives))
+	for i, a := range archives {
+		resp[i] = new(EventArchiveResponse).FromDB(a)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// RehydrateEventsRequest restores the archived events of the months
+// overlapping [from, to) into the database, where they are kept for a week,
+// e.g. to re-rate a period.
+type RehydrateEventsRequest struct {
+	From time.Time `json:"from" validate:"required"`
+	To   time.Time `json:"to" validate:"required,gtfield=From"`
+}
+
+type RehydrateEventsResponse struct {
+	RestoredEvents int64 `json:"RestoredEvents"`
+}
+
+func (h *EventArchiveHandler) RehydrateEvents(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("RehydrateEvents: %w", err))
+	}
+
+	var req RehydrateEventsRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	restored, err := h.archiver.Rehydrate(c.Request().Context(), merchantID, req.From, req.To)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to rehydrate events").
+			WithInternal(fmt.Errorf("archiver.Rehydrate: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, RehydrateEventsResponse{RestoredEvents: restored})
+}
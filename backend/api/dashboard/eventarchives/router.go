@@ -0,0 +1,8 @@
+package eventarchives
+
+import "github.com/labstack/echo/v4"
+
+func (h *EventArchiveHandler) Routes(e *echo.Group) {
+	e.GET("", h.ListEventArchives)
+	e.POST("/rehydrate", h.RehydrateEvents)
+}
@@ -0,0 +1,428 @@
+package invoices
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"billbo.com/backend/api/dashboard/auth"
+	"billbo.com/backend/billing"
+	"billbo.com/backend/billing/invoicepdf"
+	"billbo.com/backend/database/sqlcgen"
+	"billbo.com/backend/payments"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+type InvoiceHandler struct {
+	logger    *zap.Logger
+	queries   *sqlcgen.Queries
+	engine    *billing.Engine
+	collector *payments.Collector
+}
+
+func NewInvoiceHandler(
+	logger *zap.Logger,
+	queries *sqlcgen.Queries,
+	engine *billing.Engine,
+	collector *payments.Collector,
+) *InvoiceHandler {
+	return &InvoiceHandler{
+		logger: logger.With(
+			zap.String("api", "dashboard"),
+			zap.String("handler", "invoices"),
+		),
+		queries:   queries,
+		engine:    engine,
+		collector: collector,
+	}
+}
+
+type InvoiceLineResponse struct {
+	Kind               string   `json:"Kind"`
+	Description        string   `json:"Description"`
+	SkuID              *string  `json:"SkuID"`
+	Quantity           *float64 `json:"Quantity"`
+	UnitPrice          *float64 `json:"UnitPrice"`
+	Amount             float64  `json:"Amount"`
+	CouponRedemptionID *string  `json:"CouponRedemptionID"`
+	TaxRateID          *string  `json:"TaxRateID"`
+}
+
+func (r *InvoiceLineResponse) FromDB(row *sqlcgen.InvoiceLine) *InvoiceLineResponse {
+	if row == nil {
+		return nil
+	}
+	r.Kind = row.Kind
+	r.Description = row.Description
+	if row.SkuID.Valid {
+		s := row.SkuID.String()
+		r.SkuID = &s
+	}
+	if row.Quantity.Valid {
+		r.Quantity = &row.Quantity.Float64
+	}
+	if row.UnitPrice.Valid {
+		r.UnitPrice = &row.UnitPrice.Float64
+	}
+	r.Amount = row.Amount
+	if row.CouponRedemptionID.Valid {
+		s := row.CouponRedemptionID.String()
+		r.CouponRedemptionID = &s
+	}
+	if row.TaxRateID.Valid {
+		s := row.TaxRateID.String()
+		r.TaxRateID = &s
+	}
+	return r
+}
+
+type InvoiceResponse struct {
+	ID            string                 `json:"ID"`
+	Number        *string                `json:"Number"`
+	CustomerID    string                 `json:"CustomerID"`
+	Status        string                 `json:"Status"`
+	PeriodStart   string                 `json:"PeriodStart"`
+	PeriodEnd     string                 `json:"PeriodEnd"`
+	Subtotal      float64                `json:"Subtotal"`
+	DiscountTotal float64                `json:"DiscountTotal"`
+	TaxTotal      float64                `json:"TaxTotal"`
+	Total         float64                `json:"Total"`
+	FinalizedAt   *string                `json:"FinalizedAt"`
+	DueAt         *string                `json:"DueAt"`
+	PaymentID     *string                `json:"PaymentID"`
+	PaidAt        *string                `json:"PaidAt"`
+	CreatedAt     string                 `json:"CreatedAt"`
+	Lines         []*InvoiceLineResponse `json:"Lines,omitempty"`
+}
+
+func (r *InvoiceResponse) FromDB(row *sqlcgen.Invoice) *InvoiceResponse {
+	if row == nil {
+		return nil
+	}
+	r.ID = row.ID.String()
+	if row.Number.Valid {
+		r.Number = &row.Number.String
+	}
+	r.CustomerID = row.CustomerID.String()
+	r.Status = row.Status
+	r.PeriodStart = row.PeriodStart.Time.Format(time.RFC3339)
+	r.PeriodEnd = row.PeriodEnd.Time.Format(time.RFC3339)
+	r.Subtotal = row.Subtotal
+	r.DiscountTotal = row.DiscountTotal
+	r.TaxTotal = row.TaxTotal
+	r.Total = row.Total
+	if row.FinalizedAt.Valid {
+		s := row.FinalizedAt.Time.Format(time.RFC3339)
+		r.FinalizedAt = &s
+	}
+	if row.DueAt.Valid {
+		s := row.DueAt.Time.Format(time.RFC3339)
+		r.DueAt = &s
+	}
+	if row.PaymentID.Valid {
+		r.PaymentID = &row.PaymentID.String
+	}
+	if row.PaidAt.Valid {
+		s := row.PaidAt.Time.Format(time.RFC3339)
+		r.PaidAt = &s
+	}
+	r.CreatedAt = row.CreatedAt.Time.Format(time.RFC3339)
+	return r
+}
+
+func (r *InvoiceResponse) WithLines(rows []*sqlcgen.InvoiceLine) *InvoiceResponse {
+	r.Lines = make([]*InvoiceLineResponse, len(rows))
+	for i, row := range rows {
+		r.Lines[i] = new(InvoiceLineResponse).FromDB(row)
+	}
+	return r
+}
+
+type GenerateInvoiceRequest struct {
+	CustomerID  uuid.UUID `json:"customer_id" validate:"required"`
+	PeriodStart time.Time `json:"period_start" validate:"required"`
+	PeriodEnd   time.Time `json:"period_end" validate:"required,gtfield=PeriodStart"`
+}
+
+// GenerateInvoice (re)computes the draft invoice of a customer for a period.
+func (h *InvoiceHandler) GenerateInvoice(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("GenerateInvoice: %w", err))
+	}
+
+	var req GenerateInvoiceRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	invoice, err := h.engine.GenerateInvoice(c.Request().Context(), billing.GenerateInvoiceParams{
+		MerchantID:  merchantID,
+		CustomerID:  req.CustomerID,
+		PeriodStart: req.PeriodStart,
+		PeriodEnd:   req.PeriodEnd,
+	})
+	if err != nil {
+		if errors.Is(err, billing.ErrInvoiceFinalized) {
+			return echo.NewHTTPError(http.StatusConflict, "invoice already finalized for this period").
+				WithInternal(err)
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate invoice").
+			WithInternal(fmt.Errorf("engine.GenerateInvoice: %w", err))
+	}
+
+	lines, err := h.queries.ListInvoiceLines(c.Request().Context(), invoice.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list invoice lines").
+			WithInternal(fmt.Errorf("queries.ListInvoiceLines: %w", err))
+	}
+
+	return c.JSON(http.StatusCreated, new(InvoiceResponse).FromDB(invoice).WithLines(lines))
+}
+
+func (h *InvoiceHandler) ListInvoices(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("ListInvoices: %w", err))
+	}
+
+	rows, err := h.queries.ListInvoicesByMerchantID(c.Request().Context(), pgtype.UUID{Bytes: merchantID, Valid: true})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list invoices").
+			WithInternal(fmt.Errorf("queries.ListInvoicesByMerchantID: %w", err))
+	}
+
+	invoices := make([]*InvoiceResponse, len(rows))
+	for i, row := range rows {
+		invoices[i] = new(InvoiceResponse).FromDB(row)
+	}
+	return c.JSON(http.StatusOK, invoices)
+}
+
+type GetInvoiceRequest struct {
+	ID uuid.UUID `param:"id" validate:"required"`
+}
+
+func (h *InvoiceHandler) GetInvoice(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("GetInvoice: %w", err))
+	}
+
+	var req GetInvoiceRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid invoice ID").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	invoice, err := h.queries.GetInvoice(c.Request().Context(), sqlcgen.GetInvoiceParams{
+		ID:         pgtype.UUID{Bytes: req.ID, Valid: true},
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "invoice not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch invoice").
+			WithInternal(fmt.Errorf("queries.GetInvoice: %w", err))
+	}
+
+	lines, err := h.queries.ListInvoiceLines(c.Request().Context(), invoice.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list invoice lines").
+			WithInternal(fmt.Errorf("queries.ListInvoiceLines: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, new(InvoiceResponse).FromDB(invoice).WithLines(lines))
+}
+
+type FinalizeInvoiceRequest struct {
+	ID uuid.UUID `param:"id" validate:"required"`
+}
+
+func (h *InvoiceHandler) FinalizeInvoice(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("FinalizeInvoice: %w", err))
+	}
+
+	var req FinalizeInvoiceRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid invoice ID").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	invoice, err := h.engine.FinalizeInvoice(c.Request().Context(), merchantID, req.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, billing.ErrInvoiceNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "invoice not found")
+		case errors.Is(err, billing.ErrInvoiceNotDraft):
+			return echo.NewHTTPError(http.StatusConflict, "invoice is not a draft")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to finalize invoice").
+			WithInternal(fmt.Errorf("engine.FinalizeInvoice: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, new(InvoiceResponse).FromDB(invoice))
+}
+
+type CollectInvoiceRequest struct {
+	ID uuid.UUID `param:"id" validate:"required"`
+}
+
+// CollectInvoice pushes a finalized invoice to the payment provider. The
+// invoice status follows the payment outcome reported by the provider.
+func (h *InvoiceHandler) CollectInvoice(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("CollectInvoice: %w", err))
+	}
+
+	var req CollectInvoiceRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid invoice ID").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	invoice, err := h.collector.CollectInvoice(c.Request().Context(), merchantID, req.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, billing.ErrInvoiceNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "invoice not found")
+		case errors.Is(err, payments.ErrInvoiceNotCollectible):
+			return echo.NewHTTPError(http.StatusConflict, "invoice is not finalized or already sent for payment")
+		case errors.Is(err, payments.ErrCustomerNotFound):
+			return echo.NewHTTPError(http.StatusConflict, "customer has no billing details")
+		case errors.Is(err, payments.ErrTestMode):
+			return echo.NewHTTPError(http.StatusConflict, "test mode invoices are not sent for payment")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to collect invoice").
+			WithInternal(fmt.Errorf("collector.CollectInvoice: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, new(InvoiceResponse).FromDB(invoice))
+}
+
+type RefundInvoiceRequest struct {
+	ID uuid.UUID `param:"id" validate:"required"`
+}
+
+// RefundInvoice refunds a paid invoice in full. The invoice is marked
+// refunded once the payment provider confirms the refund.
+func (h *InvoiceHandler) RefundInvoice(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("RefundInvoice: %w", err))
+	}
+
+	var req RefundInvoiceRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid invoice ID").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	err = h.collector.RefundInvoice(c.Request().Context(), merchantID, req.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, billing.ErrInvoiceNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "invoice not found")
+		case errors.Is(err, payments.ErrInvoiceNotPaid):
+			return echo.NewHTTPError(http.StatusConflict, "invoice is not paid")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to refund invoice").
+			WithInternal(fmt.Errorf("collector.RefundInvoice: %w", err))
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+type GetInvoicePDFRequest struct {
+	ID uuid.UUID `param:"id" validate:"required"`
+}
+
+// GetInvoicePDF renders a finalized invoice as a PDF, using the merchant's
+// invoice settings.
+func (h *InvoiceHandler) GetInvoicePDF(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("GetInvoicePDF: %w", err))
+	}
+
+	var req GetInvoicePDFRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid invoice ID").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	ctx := c.Request().Context()
+	merchantUUID := pgtype.UUID{Bytes: merchantID, Valid: true}
+
+	invoice, err := h.queries.GetInvoice(ctx, sqlcgen.GetInvoiceParams{
+		ID:         pgtype.UUID{Bytes: req.ID, Valid: true},
+		MerchantID: merchantUUID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "invoice not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch invoice").
+			WithInternal(fmt.Errorf("queries.GetInvoice: %w", err))
+	}
+	if invoice.Status == billing.InvoiceStatusDraft {
+		return echo.NewHTTPError(http.StatusConflict, "draft invoices cannot be rendered")
+	}
+
+	lines, err := h.queries.ListInvoiceLines(ctx, invoice.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list invoice lines").
+			WithInternal(fmt.Errorf("queries.ListInvoiceLines: %w", err))
+	}
+
+	merchant, err := h.queries.GetMerchantByID(ctx, merchantUUID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch merchant").
+			WithInternal(fmt.Errorf("queries.GetMerchantByID: %w", err))
+	}
+
+	customer, err := h.queries.GetCustomer(ctx, sqlcgen.GetCustomerParams{
+		ID:         invoice.CustomerID,
+		MerchantID: merchantUUID,
+	})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch customer").
+			WithInternal(fmt.Errorf("queries.GetCustomer: %w", err))
+	}
+
+	settings, err := h.queries.GetInvoiceSettings(ctx, merchantUUID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		settings = invoicepdf.DefaultSettings(merchantUUID)
+	} else if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch invoice settings").
+			WithInternal(fmt.Errorf("queries.GetInvoiceSettings: %w", err))
+	}
+
+	var buf bytes.Buffer
+	doc := invoicepdf.NewDocument(merchant, customer, invoice, lines)
+	if err := invoicepdf.Render(&buf, doc, invoicepdf.TemplateFromDB(settings)); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to render invoice").
+			WithInternal(fmt.Errorf("invoicepdf.Render: %w", err))
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("inline; filename=%q", invoice.Number.String+".pdf"))
+	return c.Blob(http.StatusOK, "application/pdf", buf.Bytes())
+}
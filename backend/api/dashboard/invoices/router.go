@@ -0,0 +1,13 @@
+package invoices
+
+import "github.com/labstack/echo/v4"
+
+func (h *InvoiceHandler) Routes(e *echo.Group) {
+	e.POST("", h.GenerateInvoice)
+	e.GET("", h.ListInvoices)
+	e.GET("/:id", h.GetInvoice)
+	e.POST("/:id/finalize", h.FinalizeInvoice)
+	e.POST("/:id/collect", h.CollectInvoice)
+	e.POST("/:id/refund", h.RefundInvoice)
+	e.GET("/:id/pdf", h.GetInvoicePDF)
+}
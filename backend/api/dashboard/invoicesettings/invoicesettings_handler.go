@@ -0,0 +1,184 @@
+package invoicesettings
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"billbo.com/backend/api/dashboard/auth"
+	"billbo.com/backend/billing/invoicepdf"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const MAX_LOGO_SIZE = 512 << 10
+
+type InvoiceSettingsHandler struct {
+	logger  *zap.Logger
+	queries *sqlcgen.Queries
+}
+
+func NewInvoiceSettingsHandler(
+	logger *zap.Logger,
+	queries *sqlcgen.Queries,
+) *InvoiceSettingsHandler {
+	return &InvoiceSettingsHandler{
+		logger: logger.With(
+			zap.String("api", "dashboard"),
+			zap.String("handler", "invoicesettings"),
+		),
+		queries: queries,
+	}
+}
+
+type InvoiceSettingsResponse struct {
+	NumberPrefix     string `json:"NumberPrefix"`
+	PaymentTermsDays int32  `json:"PaymentTermsDays"`
+	AccentColor      string `json:"AccentColor"`
+	HeaderTemplate   string `json:"HeaderTemplate"`
+	FooterTemplate   string `json:"FooterTemplate"`
+	HasLogo          bool   `json:"HasLogo"`
+	UpdatedAt        string `json:"UpdatedAt"`
+}
+
+func (r *InvoiceSettingsResponse) FromDB(row *sqlcgen.InvoiceSetting) *InvoiceSettingsResponse {
+	if row == nil {
+		return nil
+	}
+	r.NumberPrefix = row.NumberPrefix
+	r.PaymentTermsDays = row.PaymentTermsDays
+	r.AccentColor = row.AccentColor
+	r.HeaderTemplate = row.HeaderTemplate
+	r.FooterTemplate = row.FooterTemplate
+	r.HasLogo = len(row.Logo) > 0
+	r.UpdatedAt = row.UpdatedAt.Time.Format(time.RFC3339)
+	return r
+}
+
+func (h *InvoiceSettingsHandler) GetInvoiceSettings(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("GetInvoiceSettings: %w", err))
+	}
+
+	merchantUUID := pgtype.UUID{Bytes: merchantID, Valid: true}
+	row, err := h.queries.GetInvoiceSettings(c.Request().Context(), merchantUUID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Saved by the first PUT.
+		row = invoicepdf.DefaultSettings(merchantUUID)
+	} else if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch invoice settings").
+			WithInternal(fmt.Errorf("queries.GetInvoiceSettings: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, new(InvoiceSettingsResponse).FromDB(row))
+}
+
+// PutInvoiceSettingsRequest customizes the merchant's invoices. The header
+// and footer templates are Go text/templates executed against the invoice
+// document (see invoicepdf.Document). The prefix only affects invoices
+// finalized afterwards.
+type PutInvoiceSettingsRequest struct {
+	NumberPrefix     string `json:"number_prefix" validate:"max=16"`
+	PaymentTermsDays int32  `json:"payment_terms_days" validate:"gte=0,lte=365"`
+	AccentColor      string `json:"accent_color" validate:"required"`
+	HeaderTemplate   string `json:"header_template" validate:"max=2000"`
+	FooterTemplate   string `json:"footer_template" validate:"max=2000"`
+}
+
+func (h *InvoiceSettingsHandler) PutInvoiceSettings(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("PutInvoiceSettings: %w", err))
+	}
+
+	var req PutInvoiceSettingsRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	tmpl := invoicepdf.Template{
+		AccentColor: req.AccentColor,
+		Header:      req.HeaderTemplate,
+		Footer:      req.FooterTemplate,
+	}
+	if err := tmpl.Validate(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	row, err := h.queries.UpdateInvoiceSettings(c.Request().Context(), sqlcgen.UpdateInvoiceSettingsParams{
+		MerchantID:       pgtype.UUID{Bytes: merchantID, Valid: true},
+		NumberPrefix:     req.NumberPrefix,
+		PaymentTermsDays: req.PaymentTermsDays,
+		AccentColor:      req.AccentColor,
+		HeaderTemplate:   req.HeaderTemplate,
+		FooterTemplate:   req.FooterTemplate,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update invoice settings").
+			WithInternal(fmt.Errorf("queries.UpdateInvoiceSettings: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, new(InvoiceSettingsResponse).FromDB(row))
+}
+
+// PutLogo stores the raw request body as the invoice logo. The body must be
+// a PNG or JPEG image of at most MAX_LOGO_SIZE bytes.
+func (h *InvoiceSettingsHandler) PutLogo(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("PutLogo: %w", err))
+	}
+
+	logo, err := io.ReadAll(io.LimitReader(c.Request().Body, MAX_LOGO_SIZE+1))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "failed to read logo").
+			WithInternal(fmt.Errorf("io.ReadAll: %w", err))
+	}
+	if len(logo) > MAX_LOGO_SIZE {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "logo is too large")
+	}
+	contentType := http.DetectContentType(logo)
+	if contentType != "image/png" && contentType != "image/jpeg" {
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, "logo must be a PNG or JPEG image")
+	}
+
+	err = h.queries.UpdateInvoiceLogo(c.Request().Context(), sqlcgen.UpdateInvoiceLogoParams{
+		MerchantID:      pgtype.UUID{Bytes: merchantID, Valid: true},
+		Logo:            logo,
+		LogoContentType: pgtype.Text{String: contentType, Valid: true},
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update logo").
+			WithInternal(fmt.Errorf("queries.UpdateInvoiceLogo: %w", err))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *InvoiceSettingsHandler) DeleteLogo(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("DeleteLogo: %w", err))
+	}
+
+	err = h.queries.UpdateInvoiceLogo(c.Request().Context(), sqlcgen.UpdateInvoiceLogoParams{
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete logo").
+			WithInternal(fmt.Errorf("queries.UpdateInvoiceLogo: %w", err))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,10 @@
+package invoicesettings
+
+import "github.com/labstack/echo/v4"
+
+func (h *InvoiceSettingsHandler) Routes(e *echo.Group) {
+	e.GET("", h.GetInvoiceSettings)
+	e.PUT("", h.PutInvoiceSettings)
+	e.PUT("/logo", h.PutLogo)
+	e.DELETE("/logo", h.DeleteLogo)
+}
@@ -0,0 +1,145 @@
+package ratelimits
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"billbo.com/backend/api/dashboard/auth"
+	"billbo.com/backend/database"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+type RateLimitHandler struct {
+	logger  *zap.Logger
+	queries *sqlcgen.Queries
+	pool    *pgxpool.Pool
+}
+
+func NewRateLimitHandler(
+	logger *zap.Logger,
+	queries *sqlcgen.Queries,
+	pool *pgxpool.Pool,
+) *RateLimitHandler {
+	return &RateLimitHandler{
+		logger: logger.With(
+			zap.String("api", "dashboard"),
+			zap.String("handler", "ratelimits"),
+		),
+		queries: queries,
+		pool:    pool,
+	}
+}
+
+type RateLimitResponse struct {
+	RateLimitPerSecond int32  `json:"RateLimitPerSecond"`
+	RateLimitBurst     int32  `json:"RateLimitBurst"`
+	UpdatedAt          string `json:"UpdatedAt"`
+}
+
+func (r *RateLimitResponse) FromDB(row *sqlcgen.MerchantRateLimit) *RateLimitResponse {
+	if row == nil {
+		return nil
+	}
+	r.RateLimitPerSecond = row.RateLimitPerSecond
+	r.RateLimitBurst = row.RateLimitBurst
+	r.UpdatedAt = row.UpdatedAt.Time.Format(time.RFC3339)
+	return r
+}
+
+func (h *RateLimitHandler) GetRateLimit(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("GetRateLimit: %w", err))
+	}
+
+	row, err := h.queries.GetMerchantRateLimit(c.Request().Context(), pgtype.UUID{Bytes: merchantID, Valid: true})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "rate limit not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch rate limit").
+			WithInternal(fmt.Errorf("queries.GetMerchantRateLimit: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, new(RateLimitResponse).FromDB(row))
+}
+
+// PutRateLimitRequest sets the ingest rate limit shared by all the
+// merchant's API keys, in requests per second with bursts of up to
+// RateLimitBurst requests.
+type PutRateLimitRequest struct {
+	RateLimitPerSecond int32 `json:"rate_limit_per_second" validate:"gt=0"`
+	RateLimitBurst     int32 `json:"rate_limit_burst" validate:"gt=0"`
+}
+
+func (h *RateLimitHandler) PutRateLimit(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("PutRateLimit: %w", err))
+	}
+
+	var req PutRateLimitRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	ctx := c.Request().Context()
+	var row *sqlcgen.MerchantRateLimit
+	err = database.InTx(ctx, h.pool, func(q *sqlcgen.Queries) error {
+		row, err = q.PutMerchantRateLimit(ctx, sqlcgen.PutMerchantRateLimitParams{
+			MerchantID:         pgtype.UUID{Bytes: merchantID, Valid: true},
+			RateLimitPerSecond: req.RateLimitPerSecond,
+			RateLimitBurst:     req.RateLimitBurst,
+		})
+		if err != nil {
+			return fmt.Errorf("queries.PutMerchantRateLimit: %w", err)
+		}
+		// The merchant's limits are cached along with each of its keys.
+		if err := q.NotifyMerchantAPIKeysChanged(ctx, row.MerchantID); err != nil {
+			return fmt.Errorf("queries.NotifyMerchantAPIKeysChanged: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save rate limit").
+			WithInternal(fmt.Errorf("PutRateLimit: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, new(RateLimitResponse).FromDB(row))
+}
+
+// DeleteRateLimit reverts the merchant to the ingest API default limit.
+func (h *RateLimitHandler) DeleteRateLimit(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("DeleteRateLimit: %w", err))
+	}
+
+	ctx := c.Request().Context()
+	err = database.InTx(ctx, h.pool, func(q *sqlcgen.Queries) error {
+		if err := q.DeleteMerchantRateLimit(ctx, pgtype.UUID{Bytes: merchantID, Valid: true}); err != nil {
+			return fmt.Errorf("queries.DeleteMerchantRateLimit: %w", err)
+		}
+		if err := q.NotifyMerchantAPIKeysChanged(ctx, pgtype.UUID{Bytes: merchantID, Valid: true}); err != nil {
+			return fmt.Errorf("queries.NotifyMerchantAPIKeysChanged: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete rate limit").
+			WithInternal(fmt.Errorf("DeleteRateLimit: %w", err))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,9 @@
+package ratelimits
+
+import "github.com/labstack/echo/v4"
+
+func (h *RateLimitHandler) Routes(e *echo.Group) {
+	e.GET("", h.GetRateLimit)
+	e.PUT("", h.PutRateLimit)
+	e.DELETE("", h.DeleteRateLimit)
+}
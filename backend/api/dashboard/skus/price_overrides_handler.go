@@ -0,0 +1,177 @@
+package skus
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"billbo.com/backend/api/dashboard/auth"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+type PriceOverrideResponse struct {
+	ID           string  `json:"ID"`
+	SkuID        string  `json:"SkuID"`
+	CustomerID   string  `json:"CustomerID"`
+	PricePerUnit float64 `json:"PricePerUnit"`
+	StartsAt     *string `json:"StartsAt"`
+	EndsAt       *string `json:"EndsAt"`
+	RevokedAt    *string `json:"RevokedAt"`
+	CreatedAt    string  `json:"CreatedAt"`
+}
+
+func (r *PriceOverrideResponse) FromDB(row *sqlcgen.PriceOverride) *PriceOverrideResponse {
+	if row == nil {
+		return nil
+	}
+	r.ID = row.ID.String()
+	r.SkuID = row.SkuID.String()
+	r.CustomerID = row.CustomerID.String()
+	r.PricePerUnit = row.PricePerUnit
+	if row.StartsAt.Valid {
+		s := row.StartsAt.Time.Format(time.RFC3339)
+		r.StartsAt = &s
+	}
+	if row.EndsAt.Valid {
+		s := row.EndsAt.Time.Format(time.RFC3339)
+		r.EndsAt = &s
+	}
+	if row.RevokedAt.Valid {
+		s := row.RevokedAt.Time.Format(time.RFC3339)
+		r.RevokedAt = &s
+	}
+	r.CreatedAt = row.CreatedAt.Time.Format(time.RFC3339)
+	return r
+}
+
+// CreatePriceOverrideRequest sets a negotiated price for one customer on a
+// SKU. starts_at and ends_at optionally bound the override in time; when
+// several overrides match an event, the most recently created one wins.
+type CreatePriceOverrideRequest struct {
+	ID           uuid.UUID  `param:"id" validate:"required"`
+	CustomerID   uuid.UUID  `json:"customer_id" validate:"required"`
+	PricePerUnit float64    `json:"price_per_unit" validate:"gte=0"`
+	StartsAt     *time.Time `json:"starts_at"`
+	EndsAt       *time.Time `json:"ends_at"`
+}
+
+func (h *SKUHandler) CreatePriceOverride(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("CreatePriceOverride: %w", err))
+	}
+
+	var req CreatePriceOverrideRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+	if req.StartsAt != nil && req.EndsAt != nil && !req.StartsAt.Before(*req.EndsAt) {
+		return echo.NewHTTPError(http.StatusBadRequest, "starts_at must be before ends_at")
+	}
+
+	sku, err := h.queries.GetSKU(c.Request().Context(), sqlcgen.GetSKUParams{
+		ID:         pgtype.UUID{Bytes: req.ID, Valid: true},
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "SKU not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch SKU").
+			WithInternal(fmt.Errorf("queries.GetSKU: %w", err))
+	}
+
+	var startsAt, endsAt pgtype.Timestamptz
+	if req.StartsAt != nil {
+		startsAt = pgtype.Timestamptz{Time: *req.StartsAt, Valid: true}
+	}
+	if req.EndsAt != nil {
+		endsAt = pgtype.Timestamptz{Time: *req.EndsAt, Valid: true}
+	}
+
+	row, err := h.queries.CreatePriceOverride(c.Request().Context(), sqlcgen.CreatePriceOverrideParams{
+		MerchantID:   pgtype.UUID{Bytes: merchantID, Valid: true},
+		SkuID:        sku.ID,
+		CustomerID:   pgtype.UUID{Bytes: req.CustomerID, Valid: true},
+		PricePerUnit: req.PricePerUnit,
+		StartsAt:     startsAt,
+		EndsAt:       endsAt,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create price override").
+			WithInternal(fmt.Errorf("queries.CreatePriceOverride: %w", err))
+	}
+
+	return c.JSON(http.StatusCreated, new(PriceOverrideResponse).FromDB(row))
+}
+
+type ListPriceOverridesRequest struct {
+	ID uuid.UUID `param:"id" validate:"required"`
+}
+
+func (h *SKUHandler) ListPriceOverrides(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("ListPriceOverrides: %w", err))
+	}
+
+	var req ListPriceOverridesRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid SKU ID").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	rows, err := h.queries.ListPriceOverridesBySKU(c.Request().Context(), sqlcgen.ListPriceOverridesBySKUParams{
+		SkuID:      pgtype.UUID{Bytes: req.ID, Valid: true},
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list price overrides").
+			WithInternal(fmt.Errorf("queries.ListPriceOverridesBySKU: %w", err))
+	}
+
+	overrides := make([]*PriceOverrideResponse, len(rows))
+	for i, row := range rows {
+		overrides[i] = new(PriceOverrideResponse).FromDB(row)
+	}
+	return c.JSON(http.StatusOK, overrides)
+}
+
+type RevokePriceOverrideRequest struct {
+	ID         uuid.UUID `param:"id" validate:"required"`
+	OverrideID uuid.UUID `param:"override_id" validate:"required"`
+}
+
+func (h *SKUHandler) RevokePriceOverride(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("RevokePriceOverride: %w", err))
+	}
+
+	var req RevokePriceOverrideRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid price override ID").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	err = h.queries.RevokePriceOverride(c.Request().Context(), sqlcgen.RevokePriceOverrideParams{
+		ID:         pgtype.UUID{Bytes: req.OverrideID, Valid: true},
+		SkuID:      pgtype.UUID{Bytes: req.ID, Valid: true},
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke price override").
+			WithInternal(fmt.Errorf("queries.RevokePriceOverride: %w", err))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
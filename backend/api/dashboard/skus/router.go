@@ -6,4 +6,7 @@ func (h *SKUHandler) Routes(e *echo.Group) {
 	e.POST("", h.CreateSKU)
 	e.GET("", h.ListSKUs)
 	e.DELETE("/:id", h.RevokeSKU)
+	e.POST("/:id/price-overrides", h.CreatePriceOverride)
+	e.GET("/:id/price-overrides", h.ListPriceOverrides)
+	e.DELETE("/:id/price-overrides/:override_id", h.RevokePriceOverride)
 }
@@ -1,14 +1,19 @@
 package skus
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"billbo.com/backend/api/dashboard/auth"
+	"billbo.com/backend/database"
 	"billbo.com/backend/database/sqlcgen"
+	"billbo.com/backend/outbox"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 )
@@ -16,12 +21,14 @@ import (
 type SKUHandler struct {
 	logger  *zap.Logger
 	queries *sqlcgen.Queries
+	pool    *pgxpool.Pool
 }
 
 // TODO: handle currencies: per sku? per merchant? per customer?
 func NewSKUHandler(
 	logger *zap.Logger,
 	queries *sqlcgen.Queries,
+	pool *pgxpool.Pool,
 ) *SKUHandler {
 	return &SKUHandler{
 		logger: logger.With(
@@ -29,6 +36,7 @@ func NewSKUHandler(
 			zap.String("handler", "skus"),
 		),
 		queries: queries,
+		pool:    pool,
 	}
 }
 
@@ -134,13 +142,24 @@ func (h *SKUHandler) RevokeSKU(c echo.Context) error {
 			WithInternal(fmt.Errorf("c.Bind: %w", err))
 	}
 
-	err = h.queries.RevokeSKU(c.Request().Context(), sqlcgen.RevokeSKUParams{
-		ID:         pgtype.UUID{Bytes: req.ID, Valid: true},
-		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+	ctx := c.Request().Context()
+	err = database.InTx(ctx, h.pool, func(q *sqlcgen.Queries) error {
+		row, err := q.RevokeSKU(ctx, sqlcgen.RevokeSKUParams{
+			ID:         pgtype.UUID{Bytes: req.ID, Valid: true},
+			MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Unknown or already revoked.
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("queries.RevokeSKU: %w", err)
+		}
+		return outbox.Emit(ctx, q, row.MerchantID, outbox.EventSKURevoked, outbox.NewSKUData(row))
 	})
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke SKU").
-			WithInternal(fmt.Errorf("queries.RevokeSKU: %w", err))
+			WithInternal(fmt.Errorf("RevokeSKU: %w", err))
 	}
 
 	return c.NoContent(http.StatusNoContent)
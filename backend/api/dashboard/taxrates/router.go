@@ -0,0 +1,9 @@
+package taxrates
+
+import "github.com/labstack/echo/v4"
+
+func (h *TaxRateHandler) Routes(e *echo.Group) {
+	e.POST("", h.CreateTaxRate)
+	e.GET("", h.ListTaxRates)
+	e.DELETE("/:id", h.RevokeTaxRate)
+}
@@ -0,0 +1,158 @@
+package taxrates
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"billbo.com/backend/api/dashboard/auth"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+type TaxRateHandler struct {
+	logger  *zap.Logger
+	queries *sqlcgen.Queries
+}
+
+func NewTaxRateHandler(
+	logger *zap.Logger,
+	queries *sqlcgen.Queries,
+) *TaxRateHandler {
+	return &TaxRateHandler{
+		logger: logger.With(
+			zap.String("api", "dashboard"),
+			zap.String("handler", "taxrates"),
+		),
+		queries: queries,
+	}
+}
+
+type TaxRateResponse struct {
+	ID            string  `json:"ID"`
+	Name          string  `json:"Name"`
+	Country       string  `json:"Country"`
+	Region        *string `json:"Region"`
+	Percentage    float64 `json:"Percentage"`
+	ReverseCharge bool    `json:"ReverseCharge"`
+	RevokedAt     *string `json:"RevokedAt"`
+	CreatedAt     string  `json:"CreatedAt"`
+}
+
+func (r *TaxRateResponse) FromDB(row *sqlcgen.TaxRate) *TaxRateResponse {
+	if row == nil {
+		return nil
+	}
+	r.ID = row.ID.String()
+	r.Name = row.Name
+	r.Country = row.Country
+	if row.Region.Valid {
+		r.Region = &row.Region.String
+	}
+	r.Percentage = row.Percentage
+	r.ReverseCharge = row.ReverseCharge
+	if row.RevokedAt.Valid {
+		s := row.RevokedAt.Time.Format(time.RFC3339)
+		r.RevokedAt = &s
+	}
+	r.CreatedAt = row.CreatedAt.Time.Format(time.RFC3339)
+	return r
+}
+
+// CreateTaxRateRequest defines a tax rate applied to customers billed in a
+// country, or only in one region of it when region is set. reverse_charge
+// rates are not charged to customers that have a tax ID.
+type CreateTaxRateRequest struct {
+	Name          string  `json:"name" validate:"required"`
+	Country       string  `json:"country" validate:"required,iso3166_1_alpha2"`
+	Region        *string `json:"region"`
+	Percentage    float64 `json:"percentage" validate:"gte=0,lte=100"`
+	ReverseCharge bool    `json:"reverse_charge"`
+}
+
+func (h *TaxRateHandler) CreateTaxRate(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("CreateTaxRate: %w", err))
+	}
+
+	var req CreateTaxRateRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	var region pgtype.Text
+	if req.Region != nil && *req.Region != "" {
+		region = pgtype.Text{String: strings.ToUpper(*req.Region), Valid: true}
+	}
+
+	row, err := h.queries.CreateTaxRate(c.Request().Context(), sqlcgen.CreateTaxRateParams{
+		MerchantID:    pgtype.UUID{Bytes: merchantID, Valid: true},
+		Name:          req.Name,
+		Country:       req.Country,
+		Region:        region,
+		Percentage:    req.Percentage,
+		ReverseCharge: req.ReverseCharge,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create tax rate").
+			WithInternal(fmt.Errorf("queries.CreateTaxRate: %w", err))
+	}
+
+	return c.JSON(http.StatusCreated, new(TaxRateResponse).FromDB(row))
+}
+
+func (h *TaxRateHandler) ListTaxRates(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("ListTaxRates: %w", err))
+	}
+
+	rows, err := h.queries.ListTaxRatesByMerchantID(c.Request().Context(), pgtype.UUID{Bytes: merchantID, Valid: true})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list tax rates").
+			WithInternal(fmt.Errorf("queries.ListTaxRatesByMerchantID: %w", err))
+	}
+
+	rates := make([]*TaxRateResponse, len(rows))
+	for i, row := range rows {
+		rates[i] = new(TaxRateResponse).FromDB(row)
+	}
+	return c.JSON(http.StatusOK, rates)
+}
+
+type RevokeTaxRateRequest struct {
+	ID uuid.UUID `param:"id" validate:"required"`
+}
+
+func (h *TaxRateHandler) RevokeTaxRate(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("RevokeTaxRate: %w", err))
+	}
+
+	var req RevokeTaxRateRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid tax rate ID").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	err = h.queries.RevokeTaxRate(c.Request().Context(), sqlcgen.RevokeTaxRateParams{
+		ID:         pgtype.UUID{Bytes: req.ID, Valid: true},
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke tax rate").
+			WithInternal(fmt.Errorf("queries.RevokeTaxRate: %w", err))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
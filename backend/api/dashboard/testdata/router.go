@@ -0,0 +1,7 @@
+package testdata
+
+import "github.com/labstack/echo/v4"
+
+func (h *TestDataHandler) Routes(e *echo.Group) {
+	e.DELETE("", h.WipeTestData)
+}
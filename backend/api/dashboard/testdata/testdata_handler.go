@@ -0,0 +1,120 @@
+package testdata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"billbo.com/backend/api/dashboard/auth"
+	"billbo.com/backend/archive"
+	"billbo.com/backend/database"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+type TestDataHandler struct {
+	logger  *zap.Logger
+	queries *sqlcgen.Queries
+	pool    *pgxpool.Pool
+	store   archive.BlobStore
+}
+
+func NewTestDataHandler(
+	logger *zap.Logger,
+	queries *sqlcgen.Queries,
+	pool *pgxpool.Pool,
+	store archive.BlobStore,
+) *TestDataHandler {
+	return &TestDataHandler{
+		logger: logger.With(
+			zap.String("api", "dashboard"),
+			zap.String("handler", "testdata"),
+		),
+		queries: queries,
+		pool:    pool,
+		store:   store,
+	}
+}
+
+// WipeTestData deletes the test mode events, customers and invoices of the
+// merchant, along with the data derived from them, whatever the mode of the
+// request. The test mode configuration, such as SKUs and API keys, is kept.
+func (h *TestDataHandler) WipeTestData(c echo.Context) error {
+	merchantID, err := auth.LiveMerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("WipeTestData: %w", err))
+	}
+
+	ctx := c.Request().Context()
+	testMerchantID, err := h.queries.GetTestMerchantID(ctx, pgtype.UUID{Bytes: merchantID, Valid: true})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Test mode was never used.
+		return c.NoContent(http.StatusNoContent)
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to wipe test data").
+			WithInternal(fmt.Errorf("queries.GetTestMerchantID: %w", err))
+	}
+
+	var blobKeys []string
+	var events, customers int64
+	err = database.InTx(ctx, h.pool, func(q *sqlcgen.Queries) error {
+		for _, del := range []struct {
+			name string
+			f    func(context.Context, pgtype.UUID) error
+		}{
+			{"DeleteMerchantUsageAlertCrossings", q.DeleteMerchantUsageAlertCrossings},
+			{"DeleteMerchantInvoices", q.DeleteMerchantInvoices},
+			{"DeleteMerchantCouponRedemptions", q.DeleteMerchantCouponRedemptions},
+			{"DeleteMerchantPriceOverrides", q.DeleteMerchantPriceOverrides},
+			{"DeleteMerchantSpendCaps", q.DeleteMerchantSpendCaps},
+			{"DeleteMerchantUsageCounters", q.DeleteMerchantUsageCounters},
+			{"DeleteMerchantHourlyRollups", q.DeleteMerchantHourlyRollups},
+			{"DeleteMerchantDailyRollups", q.DeleteMerchantDailyRollups},
+			{"DeleteMerchantRollupDirtyHours", q.DeleteMerchantRollupDirtyHours},
+		} {
+			if err := del.f(ctx, testMerchantID); err != nil {
+				return fmt.Errorf("queries.%s: %w", del.name, err)
+			}
+		}
+
+		blobKeys, err = q.DeleteMerchantEventArchives(ctx, testMerchantID)
+		if err != nil {
+			return fmt.Errorf("queries.DeleteMerchantEventArchives: %w", err)
+		}
+		events, err = q.DeleteMerchantEvents(ctx, testMerchantID)
+		if err != nil {
+			return fmt.Errorf("queries.DeleteMerchantEvents: %w", err)
+		}
+		customers, err = q.DeleteMerchantCustomers(ctx, testMerchantID)
+		if err != nil {
+			return fmt.Errorf("queries.DeleteMerchantCustomers: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to wipe test data").
+			WithInternal(fmt.Errorf("WipeTestData: %w", err))
+	}
+
+	// Once no archive refers to them.
+	for _, key := range blobKeys {
+		if err := h.store.Delete(ctx, key); err != nil && !errors.Is(err, archive.ErrBlobNotFound) {
+			h.logger.Error("failed to delete test archive", zap.String("blob_key", key), zap.Error(err))
+		}
+	}
+
+	h.logger.Info("wiped test data",
+		zap.String("merchant_id", merchantID.String()),
+		zap.Int64("events", events),
+		zap.Int64("customers", customers),
+		zap.Int("archives", len(blobKeys)),
+	)
+	return c.NoContent(http.StatusNoContent)
+}
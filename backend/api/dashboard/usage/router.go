@@ -0,0 +1,7 @@
+package usage
+
+import "github.com/labstack/echo/v4"
+
+func (h *UsageHandler) Routes(e *echo.Group) {
+	e.GET("", h.GetUsage)
+}
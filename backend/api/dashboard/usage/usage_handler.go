@@ -0,0 +1,95 @@
+package usage
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"billbo.com/backend/api/dashboard/auth"
+	"billbo.com/backend/database/sqlcgen"
+	"billbo.com/backend/rollups"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+type UsageHandler struct {
+	logger  *zap.Logger
+	queries *sqlcgen.Queries
+}
+
+func NewUsageHandler(
+	logger *zap.Logger,
+	queries *sqlcgen.Queries,
+) *UsageHandler {
+	return &UsageHandler{
+		logger: logger.With(
+			zap.String("api", "dashboard"),
+			zap.String("handler", "usage"),
+		),
+		queries: queries,
+	}
+}
+
+type UsageBucketResponse struct {
+	Start      string  `json:"Start"`
+	SkuID      string  `json:"SkuID"`
+	Quantity   float64 `json:"Quantity"`
+	EventCount int64   `json:"EventCount"`
+}
+
+func (r *UsageBucketResponse) FromBucket(b rollups.Bucket) *UsageBucketResponse {
+	r.Start = b.Start.Format(time.RFC3339)
+	r.SkuID = b.SkuID.String()
+	r.Quantity = b.Quantity
+	r.EventCount = b.EventCount
+	return r
+}
+
+// GetUsageRequest aggregates the merchant's usage over [from, to) per
+// granularity bucket (UTC) and SKU, optionally for a single customer or
+// SKU. Hours and days are served from the rollups.
+type GetUsageRequest struct {
+	Granularity string     `query:"granularity" validate:"required,oneof=minute hour day"`
+	From        time.Time  `query:"from" validate:"required"`
+	To          time.Time  `query:"to" validate:"required,gtfield=From"`
+	CustomerID  *uuid.UUID `query:"customer_id"`
+	SkuID       *uuid.UUID `query:"sku_id"`
+}
+
+func (h *UsageHandler) GetUsage(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("GetUsage: %w", err))
+	}
+
+	var req GetUsageRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	buckets, err := rollups.Query(c.Request().Context(), h.queries, rollups.QueryParams{
+		MerchantID:  merchantID,
+		Granularity: req.Granularity,
+		From:        req.From,
+		To:          req.To,
+		CustomerID:  req.CustomerID,
+		SkuID:       req.SkuID,
+	})
+	if errors.Is(err, rollups.ErrRangeTooLarge) {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to aggregate usage").
+			WithInternal(fmt.Errorf("rollups.Query: %w", err))
+	}
+
+	resp := make([]*UsageBucketResponse, len(buckets))
+	for i, b := range buckets {
+		resp[i] = new(UsageBucketResponse).FromBucket(b)
+	}
+	return c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,10 @@
+package usagealerts
+
+import "github.com/labstack/echo/v4"
+
+func (h *UsageAlertHandler) Routes(e *echo.Group) {
+	e.POST("", h.CreateUsageAlert)
+	e.GET("", h.ListUsageAlerts)
+	e.DELETE("/:id", h.RevokeUsageAlert)
+	e.GET("/:id/crossings", h.ListCrossings)
+}
@@ -0,0 +1,236 @@
+package usagealerts
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"billbo.com/backend/api/dashboard/auth"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+type UsageAlertHandler struct {
+	logger  *zap.Logger
+	queries *sqlcgen.Queries
+}
+
+func NewUsageAlertHandler(
+	logger *zap.Logger,
+	queries *sqlcgen.Queries,
+) *UsageAlertHandler {
+	return &UsageAlertHandler{
+		logger: logger.With(
+			zap.String("api", "dashboard"),
+			zap.String("handler", "usagealerts"),
+		),
+		queries: queries,
+	}
+}
+
+type UsageAlertResponse struct {
+	ID         string  `json:"ID"`
+	CustomerID string  `json:"CustomerID"`
+	SkuID      *string `json:"SkuID"`
+	Budget     float64 `json:"Budget"`
+	Thresholds []int32 `json:"Thresholds"`
+	CreatedAt  string  `json:"CreatedAt"`
+	RevokedAt  *string `json:"RevokedAt"`
+}
+
+func (r *UsageAlertResponse) FromDB(row *sqlcgen.UsageAlert) *UsageAlertResponse {
+	if row == nil {
+		return nil
+	}
+	r.ID = row.ID.String()
+	r.CustomerID = row.CustomerID.String()
+	if row.SkuID.Valid {
+		s := row.SkuID.String()
+		r.SkuID = &s
+	}
+	r.Budget = row.Budget
+	r.Thresholds = row.Thresholds
+	r.CreatedAt = row.CreatedAt.Time.Format(time.RFC3339)
+	if row.RevokedAt.Valid {
+		s := row.RevokedAt.Time.Format(time.RFC3339)
+		r.RevokedAt = &s
+	}
+	return r
+}
+
+type UsageAlertCrossingResponse struct {
+	PeriodStart string  `json:"PeriodStart"`
+	Threshold   int32   `json:"Threshold"`
+	Usage       float64 `json:"Usage"`
+	CrossedAt   string  `json:"CrossedAt"`
+}
+
+func (r *UsageAlertCrossingResponse) FromDB(row *sqlcgen.UsageAlertCrossing) *UsageAlertCrossingResponse {
+	if row == nil {
+		return nil
+	}
+	r.PeriodStart = row.PeriodStart.Time.Format(time.RFC3339)
+	r.Threshold = row.Threshold
+	r.Usage = row.Usage
+	r.CrossedAt = row.CrossedAt.Time.Format(time.RFC3339)
+	return r
+}
+
+// CreateUsageAlertRequest watches a customer's usage over each billing
+// period. With a SKU, the budget is a quantity of that SKU; without, it is
+// the customer's spend before discounts and taxes. Thresholds are
+// percentages of the budget: a usage.threshold_crossed event is sent the
+// first time in a period usage reaches each of them.
+type CreateUsageAlertRequest struct {
+	CustomerID uuid.UUID  `json:"customer_id" validate:"required"`
+	SkuID      *uuid.UUID `json:"sku_id"`
+	Budget     float64    `json:"budget" validate:"gt=0"`
+	Thresholds []int32    `json:"thresholds" validate:"max=10,dive,gte=1,lte=1000"`
+}
+
+func (h *UsageAlertHandler) CreateUsageAlert(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("CreateUsageAlert: %w", err))
+	}
+
+	var req CreateUsageAlertRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+	if len(req.Thresholds) == 0 {
+		req.Thresholds = []int32{80, 100}
+	}
+	slices.Sort(req.Thresholds)
+	req.Thresholds = slices.Compact(req.Thresholds)
+
+	var skuID pgtype.UUID
+	if req.SkuID != nil {
+		sku, err := h.queries.GetSKU(c.Request().Context(), sqlcgen.GetSKUParams{
+			ID:         pgtype.UUID{Bytes: *req.SkuID, Valid: true},
+			MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return echo.NewHTTPError(http.StatusNotFound, "SKU not found")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch SKU").
+				WithInternal(fmt.Errorf("queries.GetSKU: %w", err))
+		}
+		skuID = sku.ID
+	}
+
+	row, err := h.queries.CreateUsageAlert(c.Request().Context(), sqlcgen.CreateUsageAlertParams{
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+		CustomerID: pgtype.UUID{Bytes: req.CustomerID, Valid: true},
+		SkuID:      skuID,
+		Budget:     req.Budget,
+		Thresholds: req.Thresholds,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create usage alert").
+			WithInternal(fmt.Errorf("queries.CreateUsageAlert: %w", err))
+	}
+
+	return c.JSON(http.StatusCreated, new(UsageAlertResponse).FromDB(row))
+}
+
+func (h *UsageAlertHandler) ListUsageAlerts(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("ListUsageAlerts: %w", err))
+	}
+
+	rows, err := h.queries.ListUsageAlertsByMerchantID(c.Request().Context(), pgtype.UUID{Bytes: merchantID, Valid: true})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list usage alerts").
+			WithInternal(fmt.Errorf("queries.ListUsageAlertsByMerchantID: %w", err))
+	}
+
+	alerts := make([]*UsageAlertResponse, len(rows))
+	for i, row := range rows {
+		alerts[i] = new(UsageAlertResponse).FromDB(row)
+	}
+	return c.JSON(http.StatusOK, alerts)
+}
+
+type RevokeUsageAlertRequest struct {
+	ID uuid.UUID `param:"id" validate:"required"`
+}
+
+func (h *UsageAlertHandler) RevokeUsageAlert(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("RevokeUsageAlert: %w", err))
+	}
+
+	var req RevokeUsageAlertRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid usage alert ID").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	err = h.queries.RevokeUsageAlert(c.Request().Context(), sqlcgen.RevokeUsageAlertParams{
+		ID:         pgtype.UUID{Bytes: req.ID, Valid: true},
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke usage alert").
+			WithInternal(fmt.Errorf("queries.RevokeUsageAlert: %w", err))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+type ListCrossingsRequest struct {
+	ID uuid.UUID `param:"id" validate:"required"`
+}
+
+// ListCrossings returns the latest thresholds the alert fired for.
+func (h *UsageAlertHandler) ListCrossings(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("ListCrossings: %w", err))
+	}
+
+	var req ListCrossingsRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid usage alert ID").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	alert, err := h.queries.GetUsageAlert(c.Request().Context(), sqlcgen.GetUsageAlertParams{
+		ID:         pgtype.UUID{Bytes: req.ID, Valid: true},
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "usage alert not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch usage alert").
+			WithInternal(fmt.Errorf("queries.GetUsageAlert: %w", err))
+	}
+
+	rows, err := h.queries.ListUsageAlertCrossings(c.Request().Context(), alert.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list usage alert crossings").
+			WithInternal(fmt.Errorf("queries.ListUsageAlertCrossings: %w", err))
+	}
+
+	crossings := make([]*UsageAlertCrossingResponse, len(rows))
+	for i, row := range rows {
+		crossings[i] = new(UsageAlertCrossingResponse).FromDB(row)
+	}
+	return c.JSON(http.StatusOK, crossings)
+}
@@ -0,0 +1,12 @@
+package webhookendpoints
+
+import "github.com/labstack/echo/v4"
+
+func (h *WebhookEndpointHandler) Routes(e *echo.Group) {
+	e.POST("", h.CreateWebhookEndpoint)
+	e.GET("", h.ListWebhookEndpoints)
+	e.DELETE("/:id", h.RevokeWebhookEndpoint)
+	e.GET("/:id/deliveries", h.ListDeliveries)
+	e.GET("/:id/deliveries/:delivery_id", h.GetDelivery)
+	e.POST("/:id/deliveries/:delivery_id/resend", h.ResendDelivery)
+}
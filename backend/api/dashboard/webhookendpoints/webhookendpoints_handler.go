@@ -0,0 +1,385 @@
+package webhookendpoints
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"billbo.com/backend/api/dashboard/auth"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+type WebhookEndpointHandler struct {
+	logger  *zap.Logger
+	queries *sqlcgen.Queries
+}
+
+func NewWebhookEndpointHandler(
+	logger *zap.Logger,
+	queries *sqlcgen.Queries,
+) *WebhookEndpointHandler {
+	return &WebhookEndpointHandler{
+		logger: logger.With(
+			zap.String("api", "dashboard"),
+			zap.String("handler", "webhookendpoints"),
+		),
+		queries: queries,
+	}
+}
+
+type WebhookEndpointResponse struct {
+	ID         string   `json:"ID"`
+	URL        string   `json:"URL"`
+	EventTypes []string `json:"EventTypes"`
+	CreatedAt  string   `json:"CreatedAt"`
+	RevokedAt  *string  `json:"RevokedAt"`
+}
+
+func (r *WebhookEndpointResponse) FromDB(row *sqlcgen.WebhookEndpoint) *WebhookEndpointResponse {
+	if row == nil {
+		return nil
+	}
+	r.ID = row.ID.String()
+	r.URL = row.Url
+	r.EventTypes = row.EventTypes
+	r.CreatedAt = row.CreatedAt.Time.Format(time.RFC3339)
+	if row.RevokedAt.Valid {
+		s := row.RevokedAt.Time.Format(time.RFC3339)
+		r.RevokedAt = &s
+	}
+	return r
+}
+
+// CreateWebhookEndpointResponse is the only response holding the signing
+// secret of the endpoint.
+type CreateWebhookEndpointResponse struct {
+	WebhookEndpointResponse
+	Secret string `json:"Secret"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID                 string                            `json:"ID"`
+	EventID            string                            `json:"EventID"`
+	EventType          string                            `json:"EventType"`
+	Attempts           int32                             `json:"Attempts"`
+	LastResponseStatus *int32                            `json:"LastResponseStatus"`
+	NextAttemptAt      *string                           `json:"NextAttemptAt"`
+	DeliveredAt        *string                           `json:"DeliveredAt"`
+	FailedAt           *string                           `json:"FailedAt"`
+	CreatedAt          string                            `json:"CreatedAt"`
+	Log                []*WebhookDeliveryAttemptResponse `json:"Log,omitempty"`
+}
+
+func (r *WebhookDeliveryResponse) FromDB(row *sqlcgen.WebhookDelivery) *WebhookDeliveryResponse {
+	if row == nil {
+		return nil
+	}
+	r.ID = row.ID.String()
+	r.EventID = row.EventID.String()
+	r.EventType = row.EventType
+	r.Attempts = row.Attempts
+	if row.LastResponseStatus.Valid {
+		r.LastResponseStatus = &row.LastResponseStatus.Int32
+	}
+	r.NextAttemptAt = timePtr(row.NextAttemptAt)
+	r.DeliveredAt = timePtr(row.DeliveredAt)
+	r.FailedAt = timePtr(row.FailedAt)
+	r.CreatedAt = row.CreatedAt.Time.Format(time.RFC3339)
+	return r
+}
+
+func (r *WebhookDeliveryResponse) WithLog(rows []*sqlcgen.WebhookDeliveryAttempt) *WebhookDeliveryResponse {
+	r.Log = make([]*WebhookDeliveryAttemptResponse, len(rows))
+	for i, row := range rows {
+		r.Log[i] = new(WebhookDeliveryAttemptResponse).FromDB(row)
+	}
+	return r
+}
+
+type WebhookDeliveryAttemptResponse struct {
+	ResponseStatus *int32  `json:"ResponseStatus"`
+	Error          *string `json:"Error"`
+	DurationMs     int32   `json:"DurationMs"`
+	AttemptedAt    string  `json:"AttemptedAt"`
+}
+
+func (r *WebhookDeliveryAttemptResponse) FromDB(row *sqlcgen.WebhookDeliveryAttempt) *WebhookDeliveryAttemptResponse {
+	if row == nil {
+		return nil
+	}
+	if row.ResponseStatus.Valid {
+		r.ResponseStatus = &row.ResponseStatus.Int32
+	}
+	if row.Error.Valid {
+		r.Error = &row.Error.String
+	}
+	r.DurationMs = row.DurationMs
+	r.AttemptedAt = row.AttemptedAt.Time.Format(time.RFC3339)
+	return r
+}
+
+// CreateWebhookEndpointRequest registers an endpoint. Without event types,
+// the endpoint receives all of them. The URL must be https, and deliveries
+// are refused if it resolves to a non-public address.
+type CreateWebhookEndpointRequest struct {
+	URL        string   `json:"url" validate:"required,https_url"`
+	EventTypes []string `json:"event_types" validate:"dive,oneof=invoice.finalized invoice.paid usage.threshold_crossed api_key.revoked"`
+}
+
+func (h *WebhookEndpointHandler) CreateWebhookEndpoint(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("CreateWebhookEndpoint: %w", err))
+	}
+
+	var req CreateWebhookEndpointRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+	if req.EventTypes == nil {
+		req.EventTypes = []string{}
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate webhook secret").
+			WithInternal(fmt.Errorf("generateSecret: %w", err))
+	}
+
+	row, err := h.queries.CreateWebhookEndpoint(c.Request().Context(), sqlcgen.CreateWebhookEndpointParams{
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+		Url:        req.URL,
+		Secret:     secret,
+		EventTypes: req.EventTypes,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create webhook endpoint").
+			WithInternal(fmt.Errorf("queries.CreateWebhookEndpoint: %w", err))
+	}
+
+	return c.JSON(http.StatusCreated, &CreateWebhookEndpointResponse{
+		WebhookEndpointResponse: *new(WebhookEndpointResponse).FromDB(row),
+		Secret:                  row.Secret,
+	})
+}
+
+func (h *WebhookEndpointHandler) ListWebhookEndpoints(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("ListWebhookEndpoints: %w", err))
+	}
+
+	rows, err := h.queries.ListWebhookEndpointsByMerchantID(c.Request().Context(), pgtype.UUID{Bytes: merchantID, Valid: true})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list webhook endpoints").
+			WithInternal(fmt.Errorf("queries.ListWebhookEndpointsByMerchantID: %w", err))
+	}
+
+	endpoints := make([]*WebhookEndpointResponse, len(rows))
+	for i, row := range rows {
+		endpoints[i] = new(WebhookEndpointResponse).FromDB(row)
+	}
+	return c.JSON(http.StatusOK, endpoints)
+}
+
+type RevokeWebhookEndpointRequest struct {
+	ID uuid.UUID `param:"id" validate:"required"`
+}
+
+// RevokeWebhookEndpoint stops deliveries to the endpoint, including pending
+// retries.
+func (h *WebhookEndpointHandler) RevokeWebhookEndpoint(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("RevokeWebhookEndpoint: %w", err))
+	}
+
+	var req RevokeWebhookEndpointRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid webhook endpoint ID").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	err = h.queries.RevokeWebhookEndpoint(c.Request().Context(), sqlcgen.RevokeWebhookEndpointParams{
+		ID:         pgtype.UUID{Bytes: req.ID, Valid: true},
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke webhook endpoint").
+			WithInternal(fmt.Errorf("queries.RevokeWebhookEndpoint: %w", err))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+type ListDeliveriesRequest struct {
+	ID uuid.UUID `param:"id" validate:"required"`
+}
+
+// ListDeliveries returns the latest deliveries to the endpoint.
+func (h *WebhookEndpointHandler) ListDeliveries(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("ListDeliveries: %w", err))
+	}
+
+	var req ListDeliveriesRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid webhook endpoint ID").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	rows, err := h.queries.ListWebhookDeliveriesByEndpoint(c.Request().Context(), sqlcgen.ListWebhookDeliveriesByEndpointParams{
+		EndpointID: pgtype.UUID{Bytes: req.ID, Valid: true},
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list webhook deliveries").
+			WithInternal(fmt.Errorf("queries.ListWebhookDeliveriesByEndpoint: %w", err))
+	}
+
+	deliveries := make([]*WebhookDeliveryResponse, len(rows))
+	for i, row := range rows {
+		deliveries[i] = new(WebhookDeliveryResponse).FromDB(row)
+	}
+	return c.JSON(http.StatusOK, deliveries)
+}
+
+type GetDeliveryRequest struct {
+	ID         uuid.UUID `param:"id" validate:"required"`
+	DeliveryID uuid.UUID `param:"delivery_id" validate:"required"`
+}
+
+// GetDelivery returns a delivery with the log of its attempts.
+func (h *WebhookEndpointHandler) GetDelivery(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("GetDelivery: %w", err))
+	}
+
+	var req GetDeliveryRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid delivery ID").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	ctx := c.Request().Context()
+	row, err := h.queries.GetWebhookDelivery(ctx, sqlcgen.GetWebhookDeliveryParams{
+		ID:         pgtype.UUID{Bytes: req.DeliveryID, Valid: true},
+		EndpointID: pgtype.UUID{Bytes: req.ID, Valid: true},
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "delivery not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch delivery").
+			WithInternal(fmt.Errorf("queries.GetWebhookDelivery: %w", err))
+	}
+
+	attempts, err := h.queries.ListWebhookDeliveryAttempts(ctx, row.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list delivery attempts").
+			WithInternal(fmt.Errorf("queries.ListWebhookDeliveryAttempts: %w", err))
+	}
+
+	return c.JSON(http.StatusOK, new(WebhookDeliveryResponse).FromDB(row).WithLog(attempts))
+}
+
+type ResendDeliveryRequest struct {
+	ID         uuid.UUID `param:"id" validate:"required"`
+	DeliveryID uuid.UUID `param:"delivery_id" validate:"required"`
+}
+
+// ResendDelivery queues a new delivery of the same event to the endpoint.
+// The event ID is unchanged so that receivers can deduplicate.
+func (h *WebhookEndpointHandler) ResendDelivery(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("ResendDelivery: %w", err))
+	}
+
+	var req ResendDeliveryRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid delivery ID").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	ctx := c.Request().Context()
+	merchantUUID := pgtype.UUID{Bytes: merchantID, Valid: true}
+
+	endpoint, err := h.queries.GetWebhookEndpoint(ctx, sqlcgen.GetWebhookEndpointParams{
+		ID:         pgtype.UUID{Bytes: req.ID, Valid: true},
+		MerchantID: merchantUUID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "webhook endpoint not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch webhook endpoint").
+			WithInternal(fmt.Errorf("queries.GetWebhookEndpoint: %w", err))
+	}
+	if endpoint.RevokedAt.Valid {
+		return echo.NewHTTPError(http.StatusConflict, "webhook endpoint is revoked")
+	}
+
+	delivery, err := h.queries.GetWebhookDelivery(ctx, sqlcgen.GetWebhookDeliveryParams{
+		ID:         pgtype.UUID{Bytes: req.DeliveryID, Valid: true},
+		EndpointID: endpoint.ID,
+		MerchantID: merchantUUID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "delivery not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch delivery").
+			WithInternal(fmt.Errorf("queries.GetWebhookDelivery: %w", err))
+	}
+
+	row, err := h.queries.CreateWebhookDelivery(ctx, sqlcgen.CreateWebhookDeliveryParams{
+		EndpointID: endpoint.ID,
+		MerchantID: merchantUUID,
+		EventID:    delivery.EventID,
+		EventType:  delivery.EventType,
+		Payload:    delivery.Payload,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to resend delivery").
+			WithInternal(fmt.Errorf("queries.CreateWebhookDelivery: %w", err))
+	}
+
+	return c.JSON(http.StatusCreated, new(WebhookDeliveryResponse).FromDB(row))
+}
+
+// generateSecret creates a random signing secret with the format
+// "whsec_<64 hex chars>".
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generateSecret: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func timePtr(t pgtype.Timestamptz) *string {
+	if !t.Valid {
+		return nil
+	}
+	s := t.Time.Format(time.RFC3339)
+	return &s
+}
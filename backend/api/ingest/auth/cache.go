@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"billbo.com/backend/cache"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// API_KEY_CHANGED_CHANNEL is the Postgres notification channel API key
+// changes are published on, with the lookup key as payload: a key hash, or
+// "id:<key ID>".
+const API_KEY_CHANGED_CHANNEL = "api_key_changed"
+
+// APIKeyCache caches the API keys looked up by hash or by ID, revoked ones
+// included, for up to ttl and at most size lookups, evicting the least
+// recently used.
+// Entries are invalidated as soon as the key changes, by listening to
+// API_KEY_CHANGED_CHANNEL. Hits and misses are published with expvar.
+type APIKeyCache struct {
+	cache *cache.Cache[*sqlcgen.GetAPIKeyByHashRow]
+}
+
+func NewAPIKeyCache(logger *zap.Logger, ttl time.Duration, size int) *APIKeyCache {
+	return &APIKeyCache{
+		cache: cache.New[*sqlcgen.GetAPIKeyByHashRow](logger, "api_key_cache", ttl, size),
+	}
+}
+
+// Get returns the API key of the given hash, from the cache or else from
+// queries.
+func (c *APIKeyCache) Get(ctx context.Context, queries *sqlcgen.Queries, keyHash string) (*sqlcgen.GetAPIKeyByHashRow, error) {
+	return c.cache.Get(keyHash, func() (*sqlcgen.GetAPIKeyByHashRow, error) {
+		return queries.GetAPIKeyByHash(ctx, keyHash)
+	})
+}
+
+// GetByID returns the API key of the given ID, from the cache or else from
+// queries.
+func (c *APIKeyCache) GetByID(ctx context.Context, queries *sqlcgen.Queries, id uuid.UUID) (*sqlcgen.GetAPIKeyByHashRow, error) {
+	return c.cache.Get("id:"+id.String(), func() (*sqlcgen.GetAPIKeyByHashRow, error) {
+		row, err := queries.GetAPIKeyByID(ctx, pgtype.UUID{Bytes: id, Valid: true})
+		if err != nil {
+			return nil, err
+		}
+		return (*sqlcgen.GetAPIKeyByHashRow)(row), nil
+	})
+}
+
+// Invalidate removes the API key of the given lookup key, a hash or
+// "id:<key ID>", from the cache.
+func (c *APIKeyCache) Invalidate(lookupKey string) {
+	c.cache.Invalidate(lookupKey)
+}
+
+// Clear empties the cache.
+func (c *APIKeyCache) Clear() {
+	c.cache.Clear()
+}
+
+// Listen invalidates the keys notified on API_KEY_CHANGED_CHANNEL until ctx
+// is canceled.
+func (c *APIKeyCache) Listen(ctx context.Context, pool *pgxpool.Pool) error {
+	return c.cache.Listen(ctx, pool, API_KEY_CHANGED_CHANNEL, c.cache.Invalidate)
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+)
+
+// LastUsedTracker records when API keys are used, in memory, and writes the
+// latest use of each key to last_used_at every flush interval rather than
+// on every request.
+type LastUsedTracker struct {
+	logger   *zap.Logger
+	queries  *sqlcgen.Queries
+	interval time.Duration
+
+	mu       sync.Mutex
+	lastUsed map[uuid.UUID]time.Time
+}
+
+func NewLastUsedTracker(logger *zap.Logger, queries *sqlcgen.Queries, interval time.Duration) *LastUsedTracker {
+	return &LastUsedTracker{
+		logger:   logger.With(zap.String("component", "api_key_last_used")),
+		queries:  queries,
+		interval: interval,
+		lastUsed: make(map[uuid.UUID]time.Time),
+	}
+}
+
+// Record notes that the key was used at t.
+func (t *LastUsedTracker) Record(keyID uuid.UUID, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if at.After(t.lastUsed[keyID]) {
+		t.lastUsed[keyID] = at
+	}
+}
+
+// Run flushes the recorded uses every interval until ctx is canceled, then
+// flushes those left.
+func (t *LastUsedTracker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := t.flush(context.Background()); err != nil {
+				t.logger.Error("failed to flush API key uses", zap.Error(err))
+			}
+			return ctx.Err()
+		case <-ticker.C:
+			if err := t.flush(ctx); err != nil {
+				t.logger.Error("failed to flush API key uses", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (t *LastUsedTracker) flush(ctx context.Context) error {
+	t.mu.Lock()
+	lastUsed := t.lastUsed
+	t.lastUsed = make(map[uuid.UUID]time.Time)
+	t.mu.Unlock()
+
+	if len(lastUsed) == 0 {
+		return nil
+	}
+
+	params := sqlcgen.TouchAPIKeysParams{
+		Ids:         make([]pgtype.UUID, 0, len(lastUsed)),
+		LastUsedAts: make([]pgtype.Timestamptz, 0, len(lastUsed)),
+	}
+	for id, at := range lastUsed {
+		params.Ids = append(params.Ids, pgtype.UUID{Bytes: id, Valid: true})
+		params.LastUsedAts = append(params.LastUsedAts, pgtype.Timestamptz{Time: at, Valid: true})
+	}
+	if err := t.queries.TouchAPIKeys(ctx, params); err != nil {
+		// Put the uses back, to be flushed with the next ones.
+		for id, at := range lastUsed {
+			t.Record(id, at)
+		}
+		return fmt.Errorf("queries.TouchAPIKeys: %w", err)
+	}
+	return nil
+}
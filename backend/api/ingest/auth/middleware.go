@@ -1,21 +1,42 @@
 package auth
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
+	"billbo.com/backend/apikey"
 	"billbo.com/backend/database/sqlcgen"
 	"github.com/labstack/echo/v4"
 )
 
-// APIKeyMiddleware validates API keys from the Authorization header
-// and sets the merchant_id in the Echo context.
-func APIKeyMiddleware(queries *sqlcgen.Queries) echo.MiddlewareFunc {
+// APIKey returns the API key the request was authenticated with.
+func APIKey(c echo.Context) (*sqlcgen.GetAPIKeyByHashRow, error) {
+	row, ok := c.Get("api_key").(*sqlcgen.GetAPIKeyByHashRow)
+	if !ok {
+		return nil, fmt.Errorf("APIKey: missing API key in context")
+	}
+	return row, nil
+}
+
+// APIKeyMiddleware validates API keys from the Authorization header and sets
+// the merchant_id and the api_key in the Echo context. Keys are looked up
+// through cache by the ID they embed and verified with hasher, or by hash for
+// legacy keys, and their uses recorded with lastUsed. Requests already
+// authenticated by SignatureMiddleware are passed through.
+func APIKeyMiddleware(
+	queries *sqlcgen.Queries,
+	cache *APIKeyCache,
+	lastUsed *LastUsedTracker,
+	hasher *apikey.Hasher,
+) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
+			if c.Get("api_key") != nil {
+				return next(c)
+			}
+
 			header := c.Request().Header.Get("Authorization")
 			if header == "" {
 				return echo.NewHTTPError(http.StatusUnauthorized, "missing Authorization header")
@@ -26,22 +47,64 @@ func APIKeyMiddleware(queries *sqlcgen.Queries) echo.MiddlewareFunc {
 				return echo.NewHTTPError(http.StatusUnauthorized, "invalid Authorization header format")
 			}
 
-			h := sha256.Sum256([]byte(rawKey))
-			keyHash := hex.EncodeToString(h[:])
-
-			row, err := queries.GetAPIKeyByHash(c.Request().Context(), keyHash)
-			if err != nil {
-				return echo.NewHTTPError(http.StatusUnauthorized, "invalid API key").
-					WithInternal(fmt.Errorf("queries.GetAPIKeyByHash: %w", err))
+			var row *sqlcgen.GetAPIKeyByHashRow
+			if keyID, ok := apikey.ParseID(rawKey); ok {
+				byID, err := cache.GetByID(c.Request().Context(), queries, keyID)
+				if err != nil {
+					return echo.NewHTTPError(http.StatusUnauthorized, "invalid API key").
+						WithInternal(fmt.Errorf("cache.GetByID: %w", err))
+				}
+				if row, ok = verify(byID, rawKey, hasher); !ok {
+					return echo.NewHTTPError(http.StatusUnauthorized, "invalid API key")
+				}
+			} else {
+				var err error
+				row, err = cache.Get(c.Request().Context(), queries, apikey.LegacyHash(rawKey))
+				if err != nil {
+					return echo.NewHTTPError(http.StatusUnauthorized, "invalid API key").
+						WithInternal(fmt.Errorf("cache.Get: %w", err))
+				}
 			}
 
-			if row.RevokedAt.Valid {
-				return echo.NewHTTPError(http.StatusUnauthorized, "API key has been revoked")
+			if err := authenticate(c, row, lastUsed); err != nil {
+				return err
 			}
-
-			c.Set("merchant_id", row.MerchantID.String())
-
 			return next(c)
 		}
 	}
 }
+
+// verify checks the key against the current and previous hashes of the row,
+// in constant time. A key matching the previous hash gets its expiry, without
+// altering the cached row.
+func verify(row *sqlcgen.GetAPIKeyByHashRow, rawKey string, hasher *apikey.Hasher) (*sqlcgen.GetAPIKeyByHashRow, bool) {
+	if hasher.Verify(rawKey, row.KeyHash) {
+		return row, true
+	}
+	if !row.PreviousKeyHash.Valid || !hasher.Verify(rawKey, row.PreviousKeyHash.String) {
+		return nil, false
+	}
+	previous := *row
+	if !previous.ExpiresAt.Valid || previous.PreviousKeyExpiresAt.Time.Before(previous.ExpiresAt.Time) {
+		previous.ExpiresAt = previous.PreviousKeyExpiresAt
+	}
+	return &previous, true
+}
+
+// authenticate checks that the key is still valid, then sets it in the Echo
+// context.
+func authenticate(c echo.Context, row *sqlcgen.GetAPIKeyByHashRow, lastUsed *LastUsedTracker) error {
+	if row.RevokedAt.Valid {
+		return echo.NewHTTPError(http.StatusUnauthorized, "API key has been revoked")
+	}
+
+	now := time.Now()
+	if row.ExpiresAt.Valid && !now.Before(row.ExpiresAt.Time) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "API key has expired")
+	}
+	lastUsed.Record(row.ID.Bytes, now)
+
+	c.Set("merchant_id", row.MerchantID.String())
+	c.Set("api_key", row)
+	return nil
+}
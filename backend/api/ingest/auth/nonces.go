@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// NonceStore remembers the nonces of signed requests until their timestamp
+// falls out of tolerance, after which SignatureMiddleware rejects them
+// anyway. Nonces are kept in Postgres, shared by all ingest instances, and
+// swept by the worker once expired.
+type NonceStore struct {
+	queries *sqlcgen.Queries
+}
+
+func NewNonceStore(queries *sqlcgen.Queries) *NonceStore {
+	return &NonceStore{queries: queries}
+}
+
+// Use records the nonce of the key until expiresAt. It returns false if the
+// nonce was already used.
+func (s *NonceStore) Use(ctx context.Context, keyID uuid.UUID, nonce string, expiresAt time.Time) (bool, error) {
+	n, err := s.queries.UseSignatureNonce(ctx, sqlcgen.UseSignatureNonceParams{
+		KeyID:     pgtype.UUID{Bytes: keyID, Valid: true},
+		Nonce:     nonce,
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	})
+	if err != nil {
+		return false, fmt.Errorf("queries.UseSignatureNonce: %w", err)
+	}
+	return n == 1, nil
+}
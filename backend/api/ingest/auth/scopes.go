@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/labstack/echo/v4"
+)
+
+// The scopes an API key can be granted, chosen when it is created.
+const (
+	SCOPE_EVENTS_WRITE = "events:write"
+	SCOPE_EVENTS_READ  = "events:read"
+	SCOPE_USAGE_READ   = "usage:read"
+)
+
+// RequireScope rejects the requests authenticated by APIKeyMiddleware with
+// a key lacking scope. It is set on each route.
+func RequireScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key, err := APIKey(c)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid API key").
+					WithInternal(fmt.Errorf("RequireScope: %w", err))
+			}
+			if !slices.Contains(key.Scopes, scope) {
+				return echo.NewHTTPError(http.StatusForbidden, "API key lacks the "+scope+" scope")
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	SIGNATURE_HEADER = "X-BillBo-Signature"
+	KEY_ID_HEADER    = "X-BillBo-Key-ID"
+
+	MIN_NONCE_LENGTH = 16
+	MAX_NONCE_LENGTH = 64
+	// MAX_SIGNED_BODY_SIZE bounds the bodies read to verify signatures.
+	MAX_SIGNED_BODY_SIZE = 1 << 20
+)
+
+// Sign computes the X-BillBo-Signature header of a request sent at timestamp
+// with a unique nonce: "t=<unix seconds>,nonce=<nonce>,v1=<hex HMAC-SHA256
+// of "<t>.<nonce>.<method>.<request URI>.<body>">", keyed with the signing
+// secret of the API key.
+func Sign(secret string, timestamp time.Time, nonce string, method string, requestURI string, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + t + ",nonce=" + nonce + ",v1=" + hex.EncodeToString(signature(secret, t, nonce, method, requestURI, body))
+}
+
+func signature(secret string, t string, nonce string, method string, requestURI string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	for _, part := range []string{t, nonce, method, requestURI} {
+		mac.Write([]byte(part))
+		mac.Write([]byte("."))
+	}
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+type signatureHeader struct {
+	timestamp time.Time
+	t         string
+	nonce     string
+	v1        []byte
+}
+
+func parseSignatureHeader(header string) (*signatureHeader, error) {
+	var sig signatureHeader
+	for _, part := range strings.Split(header, ",") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("parseSignatureHeader: invalid part %q", part)
+		}
+		switch name {
+		case "t":
+			unix, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("strconv.ParseInt: %w", err)
+			}
+			sig.timestamp = time.Unix(unix, 0)
+			sig.t = value
+		case "nonce":
+			sig.nonce = value
+		case "v1":
+			v1, err := hex.DecodeString(value)
+			if err != nil {
+				return nil, fmt.Errorf("hex.DecodeString: %w", err)
+			}
+			sig.v1 = v1
+		}
+	}
+	if sig.t == "" || sig.v1 == nil {
+		return nil, errors.New("parseSignatureHeader: missing timestamp or signature")
+	}
+	if len(sig.nonce) < MIN_NONCE_LENGTH || len(sig.nonce) > MAX_NONCE_LENGTH {
+		return nil, fmt.Errorf("parseSignatureHeader: nonce must be %d to %d characters", MIN_NONCE_LENGTH, MAX_NONCE_LENGTH)
+	}
+	return &sig, nil
+}
+
+// SignatureMiddleware authenticates requests signed with the signing secret
+// of an API key, named by X-BillBo-Key-ID, rather than bearing the key: the
+// key itself never travels. Requests are rejected if their timestamp is more
+// than tolerance away from now, or if their nonce was already used with the
+// key. Like APIKeyMiddleware, it sets the merchant_id and the api_key in the
+// Echo context. Requests without X-BillBo-Signature are left to
+// APIKeyMiddleware.
+func SignatureMiddleware(
+	queries *sqlcgen.Queries,
+	cache *APIKeyCache,
+	lastUsed *LastUsedTracker,
+	nonces *NonceStore,
+	tolerance time.Duration,
+) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			header := req.Header.Get(SIGNATURE_HEADER)
+			if header == "" {
+				return next(c)
+			}
+
+			sig, err := parseSignatureHeader(header)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid "+SIGNATURE_HEADER+" header").
+					WithInternal(fmt.Errorf("parseSignatureHeader: %w", err))
+			}
+			if d := time.Since(sig.timestamp); d > tolerance || d < -tolerance {
+				return echo.NewHTTPError(http.StatusUnauthorized, "signature timestamp outside tolerance")
+			}
+
+			keyID, err := uuid.Parse(req.Header.Get(KEY_ID_HEADER))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid "+KEY_ID_HEADER+" header").
+					WithInternal(fmt.Errorf("uuid.Parse: %w", err))
+			}
+			row, err := cache.GetByID(req.Context(), queries, keyID)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid API key").
+					WithInternal(fmt.Errorf("cache.GetByID: %w", err))
+			}
+			if !row.SigningSecret.Valid {
+				return echo.NewHTTPError(http.StatusUnauthorized, "API key has no signing secret")
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(c.Response(), req.Body, MAX_SIGNED_BODY_SIZE))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "request body too large").
+					WithInternal(fmt.Errorf("io.ReadAll: %w", err))
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+
+			expected := signature(row.SigningSecret.String, sig.t, sig.nonce, req.Method, req.RequestURI, body)
+			if !hmac.Equal(sig.v1, expected) {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid signature")
+			}
+			// Only once the signature is verified, so that forged requests
+			// cannot burn nonces.
+			unused, err := nonces.Use(req.Context(), keyID, sig.nonce, sig.timestamp.Add(tolerance))
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to check nonce").
+					WithInternal(fmt.Errorf("nonces.Use: %w", err))
+			}
+			if !unused {
+				return echo.NewHTTPError(http.StatusUnauthorized, "nonce already used")
+			}
+
+			if err := authenticate(c, row, lastUsed); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
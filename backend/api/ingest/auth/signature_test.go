@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"billbo.com/backend/database/dbtest"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+func TestParseSignatureHeader(t *testing.T) {
+	nonce := strings.Repeat("n", MIN_NONCE_LENGTH)
+	for _, tt := range []struct {
+		header string
+		valid  bool
+	}{
+		{"t=1700000000,nonce=" + nonce + ",v1=abcd", true},
+		{"v1=abcd,nonce=" + nonce + ",t=1700000000", true},
+		{"t=1700000000,nonce=" + nonce, false},
+		{"nonce=" + nonce + ",v1=abcd", false},
+		{"t=soon,nonce=" + nonce + ",v1=abcd", false},
+		{"t=1700000000,nonce=" + nonce + ",v1=not-hex", false},
+		{"t=1700000000,nonce=short,v1=abcd", false},
+		{"t=1700000000,nonce=" + strings.Repeat("n", MAX_NONCE_LENGTH+1) + ",v1=abcd", false},
+		{"t=1700000000;nonce=" + nonce + ";v1=abcd", false},
+	} {
+		_, err := parseSignatureHeader(tt.header)
+		if valid := err == nil; valid != tt.valid {
+			t.Errorf("parseSignatureHeader(%q): got error %v, want valid %t", tt.header, err, tt.valid)
+		}
+	}
+}
+
+func TestSignatureMiddleware(t *testing.T) {
+	pool := dbtest.NewPool(t)
+	ctx := context.Background()
+	queries := sqlcgen.New(pool)
+
+	merchantID := dbtest.CreateMerchant(t, pool)
+	keyID := uuid.New()
+	const secret = "signing-secret"
+	_, err := pool.Exec(ctx,
+		`INSERT INTO api_keys (id, merchant_id, name, key_prefix, key_hash, scopes, signing_secret)
+		VALUES ($1, $2, 'Signed', 'bb_test_', $3, '{events:write}', $4)`,
+		keyID, merchantID, uuid.NewString(), secret,
+	)
+	if err != nil {
+		t.Fatalf("insert API key: %v", err)
+	}
+
+	logger := zap.NewNop()
+	e := echo.New()
+	e.POST("/api/v1/events", func(c echo.Context) error {
+		// The body was read to verify the signature, and is still there.
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, string(body))
+	}, SignatureMiddleware(
+		queries,
+		NewAPIKeyCache(logger, time.Minute, 10),
+		NewLastUsedTracker(logger, queries, time.Minute),
+		NewNonceStore(queries),
+		5*time.Minute,
+	))
+
+	send := func(timestamp time.Time, nonce string, signingSecret string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/events", bytes.NewReader(body))
+		req.Header.Set(KEY_ID_HEADER, keyID.String())
+		req.Header.Set(SIGNATURE_HEADER, Sign(signingSecret, timestamp, nonce, http.MethodPost, "/api/v1/events", body))
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	body := []byte(`{"amount":1}`)
+	nonce := func() string { return strings.ReplaceAll(uuid.NewString(), "-", "") }
+
+	for _, tt := range []struct {
+		name          string
+		timestamp     time.Time
+		nonce         string
+		signingSecret string
+		body          []byte
+		want          int
+	}{
+		{"valid", time.Now(), "nonce-0000000001", secret, body, http.StatusOK},
+		{"reused nonce", time.Now(), "nonce-0000000001", secret, body, http.StatusUnauthorized},
+		{"too old", time.Now().Add(-6 * time.Minute), nonce(), secret, body, http.StatusUnauthorized},
+		{"in the future", time.Now().Add(6 * time.Minute), nonce(), secret, body, http.StatusUnauthorized},
+		// The nonce of a forged request is not burnt: the valid request
+		// reusing it goes through.
+		{"forged", time.Now(), "nonce-0000000002", "forged secret", body, http.StatusUnauthorized},
+		{"valid after forged", time.Now(), "nonce-0000000002", secret, body, http.StatusOK},
+		{"body too large", time.Now(), nonce(), secret, bytes.Repeat([]byte("a"), MAX_SIGNED_BODY_SIZE+1), http.StatusRequestEntityTooLarge},
+	} {
+		rec := send(tt.timestamp, tt.nonce, tt.signingSecret, tt.body)
+		if rec.Code != tt.want {
+			t.Errorf("%s: got %d %s, want %d", tt.name, rec.Code, rec.Body, tt.want)
+			continue
+		}
+		if tt.want == http.StatusOK && rec.Body.String() != string(tt.body) {
+			t.Errorf("%s: handler read %q, want %q", tt.name, rec.Body, tt.body)
+		}
+	}
+}
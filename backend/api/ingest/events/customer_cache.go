@@ -0,0 +1,73 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"billbo.com/backend/billing"
+	"billbo.com/backend/cache"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// CUSTOMER_CHANGED_CHANNEL is the Postgres notification channel customer
+// changes are published on, with "<merchant ID>:<customer ID>" as payload,
+// or "<merchant ID>:" when all the merchant's customers changed.
+const CUSTOMER_CHANGED_CHANNEL = "customer_changed"
+
+// CustomerSettings is what ingesting an event needs to know of its customer.
+type CustomerSettings struct {
+	IngestSuspended bool
+	Schedule        billing.PeriodSchedule
+}
+
+// CustomerCache caches the settings of the customers events are ingested
+// for, for up to ttl and at most size customers, evicting the least recently
+// used. Entries are invalidated as soon as the customer or the merchant's
+// billing settings change, by listening to CUSTOMER_CHANGED_CHANNEL. Hits
+// and misses are published with expvar.
+type CustomerCache struct {
+	cache *cache.Cache[*CustomerSettings]
+}
+
+func NewCustomerCache(logger *zap.Logger, ttl time.Duration, size int) *CustomerCache {
+	return &CustomerCache{
+		cache: cache.New[*CustomerSettings](logger, "customer_cache", ttl, size),
+	}
+}
+
+// Get returns the settings of the customer, from the cache or else from
+// queries.
+func (c *CustomerCache) Get(ctx context.Context, queries *sqlcgen.Queries, merchantID, customerID uuid.UUID) (*CustomerSettings, error) {
+	return c.cache.Get(merchantID.String()+":"+customerID.String(), func() (*CustomerSettings, error) {
+		row, err := queries.GetCustomerIngestSettings(ctx, sqlcgen.GetCustomerIngestSettingsParams{
+			CustomerID: pgtype.UUID{Bytes: customerID, Valid: true},
+			MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("queries.GetCustomerIngestSettings: %w", err)
+		}
+		schedule, err := billing.NewPeriodSchedule(row.Timezone, int(row.AnchorDay))
+		if err != nil {
+			return nil, fmt.Errorf("billing.NewPeriodSchedule: %w", err)
+		}
+		return &CustomerSettings{IngestSuspended: row.IngestSuspended, Schedule: schedule}, nil
+	})
+}
+
+// Listen invalidates the customers notified on CUSTOMER_CHANGED_CHANNEL until
+// ctx is canceled.
+func (c *CustomerCache) Listen(ctx context.Context, pool *pgxpool.Pool) error {
+	return c.cache.Listen(ctx, pool, CUSTOMER_CHANGED_CHANNEL, func(payload string) {
+		if strings.HasSuffix(payload, ":") {
+			c.cache.InvalidatePrefix(payload)
+			return
+		}
+		c.cache.Invalidate(payload)
+	})
+}
@@ -1,21 +1,33 @@
 package events
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"billbo.com/backend/api/dashboard/auth"
+	"billbo.com/backend/billing"
+	"billbo.com/backend/database"
 	"billbo.com/backend/database/sqlcgen"
+	"billbo.com/backend/rollups"
+	"billbo.com/backend/writebehind"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 )
 
 type EventHandler struct {
-	logger  *zap.Logger
-	queries *sqlcgen.Queries
+	logger    *zap.Logger
+	queries   *sqlcgen.Queries
+	pool      *pgxpool.Pool
+	customers *CustomerCache
+	// pipeline, if set, accepts events asynchronously.
+	pipeline *writebehind.Pipeline
 }
 
 type EventResponse struct {
@@ -43,13 +55,19 @@ func (r *EventResponse) FromDB(row *sqlcgen.Event) *EventResponse {
 func NewEventHandler(
 	logger *zap.Logger,
 	queries *sqlcgen.Queries,
+	pool *pgxpool.Pool,
+	customers *CustomerCache,
+	pipeline *writebehind.Pipeline,
 ) *EventHandler {
 	return &EventHandler{
 		logger: logger.With(
 			zap.String("api", "ingest"),
 			zap.String("handler", "event"),
 		),
-		queries: queries,
+		queries:   queries,
+		pool:      pool,
+		customers: customers,
+		pipeline:  pipeline,
 	}
 }
 
@@ -72,20 +90,143 @@ func (h *EventHandler) PostEvent(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid request").
 			WithInternal(fmt.Errorf("c.Bind: %w", err))
 	}
-	err = h.queries.InsertEvent(c.Request().Context(), sqlcgen.InsertEventParams{
-		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
-		CustomerID: pgtype.UUID{Bytes: event.CustomerID, Valid: true},
-		SkuID:      pgtype.UUID{Bytes: event.SKU_ID, Valid: true},
-		Amount:     event.Amount,
-		SentAt:     pgtype.Timestamptz{Time: event.SentAt, Valid: true},
+
+	customer, err := h.customers.Get(c.Request().Context(), h.queries, merchantID, event.CustomerID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to check customer").
+			WithInternal(fmt.Errorf("customers.Get: %w", err))
+	}
+	if customer.IngestSuspended {
+		return echo.NewHTTPError(http.StatusForbidden, "customer ingest suspended for unpaid invoices")
+	}
+
+	if h.pipeline != nil {
+		return h.acceptEvent(c, merchantID, event)
+	}
+
+	ctx := c.Request().Context()
+	err = database.InTx(ctx, h.pool, func(q *sqlcgen.Queries) error {
+		err := q.InsertEvent(ctx, sqlcgen.InsertEventParams{
+			MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+			CustomerID: pgtype.UUID{Bytes: event.CustomerID, Valid: true},
+			SkuID:      pgtype.UUID{Bytes: event.SKU_ID, Valid: true},
+			Amount:     event.Amount,
+			SentAt:     pgtype.Timestamptz{Time: event.SentAt, Valid: true},
+		})
+		if err != nil {
+			return fmt.Errorf("queries.InsertEvent: %w", err)
+		}
+
+		err = billing.RecordUsage(ctx, q, billing.RecordUsageParams{
+			MerchantID: merchantID,
+			CustomerID: event.CustomerID,
+			SkuID:      event.SKU_ID,
+			Amount:     event.Amount,
+			SentAt:     event.SentAt,
+			Schedule:   &customer.Schedule,
+		})
+		if err != nil {
+			return fmt.Errorf("billing.RecordUsage: %w", err)
+		}
+		if err := rollups.MarkDirty(ctx, q, merchantID, event.SentAt); err != nil {
+			return fmt.Errorf("rollups.MarkDirty: %w", err)
+		}
+
+		// Last, so that the lock on the customer's spend cap, which
+		// serializes their events, is held for as short as possible.
+		_, err = billing.ChargeSpendCap(ctx, q, billing.ChargeSpendCapParams{
+			MerchantID: merchantID,
+			CustomerID: event.CustomerID,
+			SkuID:      event.SKU_ID,
+			Amount:     event.Amount,
+			SentAt:     event.SentAt,
+		})
+		if err != nil {
+			return fmt.Errorf("billing.ChargeSpendCap: %w", err)
+		}
+		return nil
 	})
+	if errors.Is(err, billing.ErrSpendCapReached) {
+		return echo.NewHTTPError(http.StatusPaymentRequired, "customer spend cap reached")
+	}
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to insert event").
-			WithInternal(fmt.Errorf("queries.InsertEvent: %w", err))
+			WithInternal(fmt.Errorf("PostEvent: %w", err))
 	}
 	return c.NoContent(http.StatusCreated)
 }
 
+// acceptEvent charges the event to the customer's spend cap and hands it to
+// the write-behind pipeline, which acknowledges it once in its log. The cap
+// is charged first, so that a rejected event is never ingested, and refunded
+// if the pipeline does not accept the event. The SKU is checked beforehand,
+// as the flush could not insert the event of an unknown SKU after it was
+// acknowledged.
+func (h *EventHandler) acceptEvent(c echo.Context, merchantID uuid.UUID, event PostEventRequest) error {
+	ctx := c.Request().Context()
+	_, err := h.queries.GetSKU(ctx, sqlcgen.GetSKUParams{
+		ID:         pgtype.UUID{Bytes: event.SKU_ID, Valid: true},
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return echo.NewHTTPError(http.StatusBadRequest, "unknown SKU")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to check SKU").
+			WithInternal(fmt.Errorf("queries.GetSKU: %w", err))
+	}
+
+	var charge *billing.SpendCapCharge
+	err = database.InTx(ctx, h.pool, func(q *sqlcgen.Queries) error {
+		var err error
+		charge, err = billing.ChargeSpendCap(ctx, q, billing.ChargeSpendCapParams{
+			MerchantID: merchantID,
+			CustomerID: event.CustomerID,
+			SkuID:      event.SKU_ID,
+			Amount:     event.Amount,
+			SentAt:     event.SentAt,
+		})
+		return err
+	})
+	if errors.Is(err, billing.ErrSpendCapReached) {
+		return echo.NewHTTPError(http.StatusPaymentRequired, "customer spend cap reached")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to charge spend cap").
+			WithInternal(fmt.Errorf("billing.ChargeSpendCap: %w", err))
+	}
+
+	err = h.pipeline.Accept(writebehind.Event{
+		ID:         uuid.New(),
+		MerchantID: merchantID,
+		CustomerID: event.CustomerID,
+		SkuID:      event.SKU_ID,
+		Amount:     event.Amount,
+		SentAt:     event.SentAt,
+		Charge:     charge,
+	})
+	if err != nil {
+		// The client retries an event it was told failed, so the charge
+		// must not stay. The request context may be canceled by now.
+		if err := billing.RefundSpendCap(context.WithoutCancel(ctx), h.queries, charge); err != nil {
+			h.logger.Error("failed to refund spend cap",
+				zap.String("customer_id", event.CustomerID.String()),
+				zap.Float64("cost", charge.Cost),
+				zap.Error(err),
+			)
+		}
+	}
+	if errors.Is(err, writebehind.ErrBufferFull) {
+		c.Response().Header().Set("Retry-After", "1")
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "ingest buffer full, retry later")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to accept event").
+			WithInternal(fmt.Errorf("pipeline.Accept: %w", err))
+	}
+	return c.NoContent(http.StatusAccepted)
+}
+
 func (h *EventHandler) GetEvents(c echo.Context) error {
 	merchantID, err := auth.MerchantID(c)
 	if err != nil {
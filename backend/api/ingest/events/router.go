@@ -1,8 +1,11 @@
 package events
 
-import "github.com/labstack/echo/v4"
+import (
+	ingestauth "billbo.com/backend/api/ingest/auth"
+	"github.com/labstack/echo/v4"
+)
 
 func (h *EventHandler) Routes(e *echo.Group) {
-	e.GET("", h.GetEvents)
-	e.POST("", h.PostEvent)
+	e.GET("", h.GetEvents, ingestauth.RequireScope(ingestauth.SCOPE_EVENTS_READ))
+	e.POST("", h.PostEvent, ingestauth.RequireScope(ingestauth.SCOPE_EVENTS_WRITE))
 }
@@ -0,0 +1,121 @@
+// Package ratelimit limits the ingest requests per API key and per merchant
+// with token buckets.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// IDLE_BUCKET_TTL is how long a bucket is kept unused. Idle buckets are
+	// full again long before then.
+	IDLE_BUCKET_TTL = 10 * time.Minute
+	SWEEP_INTERVAL  = time.Minute
+)
+
+// Limit allows PerSecond requests per second, in bursts of up to Burst.
+type Limit struct {
+	PerSecond int
+	Burst     int
+}
+
+// Request asks for a token from the bucket of Key, created with Limit.
+type Request struct {
+	Key   string
+	Limit Limit
+}
+
+// Result describes the most depleted bucket of the requests.
+type Result struct {
+	Allowed   bool
+	Limit     Limit
+	Remaining int
+	// RetryAfter is the time until the request would be allowed, if not.
+	RetryAfter time.Duration
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+// Limiter holds the token buckets, in memory: each ingest instance limits
+// the requests it serves.
+type Limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{buckets: make(map[string]*bucket)}
+}
+
+// Allow takes a token from the bucket of every request, or from none of
+// them if any is empty.
+func (l *Limiter) Allow(now time.Time, requests ...Request) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > SWEEP_INTERVAL {
+		l.sweep(now)
+	}
+
+	limiters := make([]*rate.Limiter, len(requests))
+	reservations := make([]*rate.Reservation, len(requests))
+	var retryAfter time.Duration
+	for i, req := range requests {
+		limiters[i] = l.bucket(now, req)
+		reservations[i] = limiters[i].ReserveN(now, 1)
+		retryAfter = max(retryAfter, reservations[i].DelayFrom(now))
+	}
+	if retryAfter > 0 {
+		for _, r := range reservations {
+			r.CancelAt(now)
+		}
+	}
+
+	result := Result{Allowed: retryAfter == 0, RetryAfter: retryAfter, Remaining: math.MaxInt}
+	for i, req := range requests {
+		tokens := limiters[i].TokensAt(now)
+		remaining := max(0, int(math.Floor(tokens)))
+		if remaining < result.Remaining {
+			result.Limit = req.Limit
+			result.Remaining = remaining
+			result.Reset = time.Duration((float64(req.Limit.Burst) - tokens) / float64(req.Limit.PerSecond) * float64(time.Second))
+		}
+	}
+	return result
+}
+
+// bucket returns the bucket of the request, with its current limit.
+func (l *Limiter) bucket(now time.Time, req Request) *rate.Limiter {
+	b, ok := l.buckets[req.Key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(req.Limit.PerSecond), req.Limit.Burst)}
+		l.buckets[req.Key] = b
+	}
+	if b.limiter.Limit() != rate.Limit(req.Limit.PerSecond) {
+		b.limiter.SetLimitAt(now, rate.Limit(req.Limit.PerSecond))
+	}
+	if b.limiter.Burst() != req.Limit.Burst {
+		b.limiter.SetBurstAt(now, req.Limit.Burst)
+	}
+	b.lastUsed = now
+	return b.limiter
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.lastUsed) > IDLE_BUCKET_TTL {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	key := Request{Key: "key:1", Limit: Limit{PerSecond: 1, Burst: 5}}
+	merchant := Request{Key: "merchant:1", Limit: Limit{PerSecond: 2, Burst: 2}}
+
+	limiter := NewLimiter()
+	for i, tt := range []struct {
+		at       time.Duration
+		requests []Request
+		want     Result
+	}{
+		// The merchant bucket, the most depleted, is reported.
+		{0, []Request{key, merchant}, Result{Allowed: true, Limit: merchant.Limit, Remaining: 1, Reset: 500 * time.Millisecond}},
+		{0, []Request{key, merchant}, Result{Allowed: true, Limit: merchant.Limit, Remaining: 0, Reset: time.Second}},
+		// Rejected by the merchant bucket: the key bucket is not charged.
+		{0, []Request{key, merchant}, Result{Allowed: false, Limit: merchant.Limit, Remaining: 0, RetryAfter: 500 * time.Millisecond, Reset: time.Second}},
+		{0, []Request{key}, Result{Allowed: true, Limit: key.Limit, Remaining: 2, Reset: 3 * time.Second}},
+		// Half a second later, the merchant bucket has a token again and
+		// the key bucket half a token more.
+		{500 * time.Millisecond, []Request{key, merchant}, Result{Allowed: true, Limit: merchant.Limit, Remaining: 0, Reset: time.Second}},
+		{500 * time.Millisecond, []Request{key}, Result{Allowed: true, Limit: key.Limit, Remaining: 0, Reset: 4500 * time.Millisecond}},
+		// Rejected by the key bucket.
+		{500 * time.Millisecond, []Request{key, merchant}, Result{Allowed: false, Limit: key.Limit, Remaining: 0, RetryAfter: 500 * time.Millisecond, Reset: 4500 * time.Millisecond}},
+	} {
+		got := limiter.Allow(now.Add(tt.at), tt.requests...)
+		if got != tt.want {
+			t.Errorf("request %d: got %+v, want %+v", i, got, tt.want)
+		}
+	}
+}
+
+func TestLimiterAppliesLimitChanges(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewLimiter()
+
+	limiter.Allow(now, Request{Key: "key:1", Limit: Limit{PerSecond: 1, Burst: 1}})
+	// The bucket is empty: raising the limit refills it faster, not at once.
+	raised := Request{Key: "key:1", Limit: Limit{PerSecond: 10, Burst: 10}}
+	got := limiter.Allow(now, raised)
+	want := Result{Allowed: false, Limit: raised.Limit, Remaining: 0, RetryAfter: 100 * time.Millisecond, Reset: time.Second}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got := limiter.Allow(now.Add(100*time.Millisecond), raised); !got.Allowed {
+		t.Errorf("got %+v, want allowed after 100ms", got)
+	}
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	ingestauth "billbo.com/backend/api/ingest/auth"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+)
+
+// Defaults are the limits of the keys and merchants without limits of
+// their own.
+type Defaults struct {
+	Key      Limit
+	Merchant Limit
+}
+
+// Middleware rate limits the requests authenticated by APIKeyMiddleware,
+// per key and per merchant, and describes the most depleted of both limits
+// in X-RateLimit-* headers. Rejected requests get 429 with Retry-After.
+func Middleware(limiter *Limiter, defaults Defaults) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key, err := ingestauth.APIKey(c)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid API key").
+					WithInternal(fmt.Errorf("RateLimitMiddleware: %w", err))
+			}
+
+			result := limiter.Allow(time.Now(),
+				Request{
+					Key:   "key:" + key.ID.String(),
+					Limit: limitOrDefault(key.RateLimitPerSecond, key.RateLimitBurst, defaults.Key),
+				},
+				Request{
+					Key:   "merchant:" + key.MerchantID.String(),
+					Limit: limitOrDefault(key.MerchantRateLimitPerSecond, key.MerchantRateLimitBurst, defaults.Merchant),
+				},
+			)
+
+			header := c.Response().Header()
+			header.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit.PerSecond))
+			header.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			header.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+			if !result.Allowed {
+				header.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				return echo.NewHTTPError(http.StatusTooManyRequests, "rate limit exceeded")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func limitOrDefault(perSecond, burst pgtype.Int4, fallback Limit) Limit {
+	limit := fallback
+	if perSecond.Valid {
+		limit.PerSecond = int(perSecond.Int32)
+	}
+	if burst.Valid {
+		limit.Burst = int(burst.Int32)
+	}
+	return limit
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,10 @@
+package usage
+
+import (
+	ingestauth "billbo.com/backend/api/ingest/auth"
+	"github.com/labstack/echo/v4"
+)
+
+func (h *UsageHandler) Routes(e *echo.Group) {
+	e.GET("/:customer_id", h.GetCurrentUsage, ingestauth.RequireScope(ingestauth.SCOPE_USAGE_READ))
+}
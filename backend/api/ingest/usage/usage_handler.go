@@ -0,0 +1,96 @@
+package usage
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"billbo.com/backend/api/dashboard/auth"
+	"billbo.com/backend/billing"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+type UsageHandler struct {
+	logger  *zap.Logger
+	queries *sqlcgen.Queries
+}
+
+func NewUsageHandler(
+	logger *zap.Logger,
+	queries *sqlcgen.Queries,
+) *UsageHandler {
+	return &UsageHandler{
+		logger: logger.With(
+			zap.String("api", "ingest"),
+			zap.String("handler", "usage"),
+		),
+		queries: queries,
+	}
+}
+
+type CurrentUsageResponse struct {
+	CustomerID  string              `json:"CustomerID"`
+	PeriodStart string              `json:"PeriodStart"`
+	PeriodEnd   string              `json:"PeriodEnd"`
+	SKUs        []*SKUUsageResponse `json:"SKUs"`
+}
+
+type SKUUsageResponse struct {
+	SkuID      string  `json:"SkuID"`
+	Name       string  `json:"Name"`
+	Quantity   float64 `json:"Quantity"`
+	EventCount int64   `json:"EventCount"`
+	UpdatedAt  string  `json:"UpdatedAt"`
+}
+
+func (r *SKUUsageResponse) FromDB(row *sqlcgen.ListUsageCountersRow) *SKUUsageResponse {
+	if row == nil {
+		return nil
+	}
+	r.SkuID = row.SkuID.String()
+	r.Name = row.Name
+	r.Quantity = row.Quantity
+	r.EventCount = row.EventCount
+	r.UpdatedAt = row.UpdatedAt.Time.Format(time.RFC3339)
+	return r
+}
+
+type GetCurrentUsageRequest struct {
+	CustomerID uuid.UUID `param:"customer_id" validate:"required"`
+}
+
+// GetCurrentUsage returns the customer's usage of each SKU over their
+// current billing period, as counted on ingest.
+func (h *UsageHandler) GetCurrentUsage(c echo.Context) error {
+	merchantID, err := auth.MerchantID(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid merchant ID in token").
+			WithInternal(fmt.Errorf("GetCurrentUsage: %w", err))
+	}
+
+	var req GetCurrentUsageRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid customer ID").
+			WithInternal(fmt.Errorf("c.Bind: %w", err))
+	}
+
+	period, rows, err := billing.CurrentUsage(c.Request().Context(), h.queries, merchantID, req.CustomerID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch current usage").
+			WithInternal(fmt.Errorf("billing.CurrentUsage: %w", err))
+	}
+
+	skus := make([]*SKUUsageResponse, len(rows))
+	for i, row := range rows {
+		skus[i] = new(SKUUsageResponse).FromDB(row)
+	}
+	return c.JSON(http.StatusOK, &CurrentUsageResponse{
+		CustomerID:  req.CustomerID.String(),
+		PeriodStart: period.Start.Format(time.RFC3339),
+		PeriodEnd:   period.End.Format(time.RFC3339),
+		SKUs:        skus,
+	})
+}
@@ -0,0 +1,7 @@
+package webhooks
+
+import "github.com/labstack/echo/v4"
+
+func (h *WebhookHandler) Routes(e *echo.Group) {
+	e.POST("/payments", h.PaymentWebhook)
+}
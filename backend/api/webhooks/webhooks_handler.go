@@ -0,0 +1,64 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"billbo.com/backend/payments"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// MAX_PAYLOAD_SIZE bounds webhook request bodies. Provider events are a few
+// kilobytes at most.
+const MAX_PAYLOAD_SIZE = 256 << 10
+
+// WebhookHandler receives webhooks sent by third-party services. Its routes
+// are public: requests are authenticated by their signature.
+type WebhookHandler struct {
+	logger    *zap.Logger
+	processor *payments.WebhookProcessor
+}
+
+func NewWebhookHandler(
+	logger *zap.Logger,
+	processor *payments.WebhookProcessor,
+) *WebhookHandler {
+	return &WebhookHandler{
+		logger: logger.With(
+			zap.String("api", "webhooks"),
+			zap.String("handler", "webhooks"),
+		),
+		processor: processor,
+	}
+}
+
+// PaymentWebhook stores a payment provider event. Invoices are updated in the
+// background so that the provider gets a quick acknowledgement.
+func (h *WebhookHandler) PaymentWebhook(c echo.Context) error {
+	payload, err := io.ReadAll(io.LimitReader(c.Request().Body, MAX_PAYLOAD_SIZE+1))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "failed to read payload").
+			WithInternal(fmt.Errorf("io.ReadAll: %w", err))
+	}
+	if len(payload) > MAX_PAYLOAD_SIZE {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "payload too large")
+	}
+
+	err = h.processor.Receive(c.Request().Context(), payload, c.Request().Header)
+	if err != nil {
+		switch {
+		case errors.Is(err, payments.ErrInvalidSignature):
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid signature").
+				WithInternal(err)
+		case errors.Is(err, payments.ErrUnhandledEvent):
+			return c.NoContent(http.StatusOK)
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to store webhook").
+			WithInternal(fmt.Errorf("processor.Receive: %w", err))
+	}
+
+	return c.NoContent(http.StatusOK)
+}
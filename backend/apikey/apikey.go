@@ -0,0 +1,101 @@
+// Package apikey generates, parses and hashes the API keys merchants
+// authenticate to the ingest API with.
+//
+// Keys have the format "bb_<mode>_<key ID>_<secret>", with the ID and the
+// secret in hex. They are looked up by ID and verified against their
+// HMAC-SHA256, keyed with a server-side pepper, so that leaked hashes cannot
+// be brute-forced without it. Legacy keys, "bb_<secret>" and
+// "bb_<mode>_<secret>", embed no ID: they are still stored as their unsalted
+// SHA-256 and looked up by hash, until rotated.
+package apikey
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	PREFIX = "bb_"
+
+	SECRET_SIZE = 16
+	// DISPLAYED_ID_LENGTH is how many hex chars of the ID the displayed
+	// prefix of a key shows.
+	DISPLAYED_ID_LENGTH = 8
+)
+
+// Generate creates a random key of the given mode for the API key of the
+// given ID.
+func Generate(mode string, id uuid.UUID) (string, error) {
+	b := make([]byte, SECRET_SIZE)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("Generate: %w", err)
+	}
+	return PREFIX + mode + "_" + hex.EncodeToString(id[:]) + "_" + hex.EncodeToString(b), nil
+}
+
+// ParseID returns the ID a key embeds. ok is false for legacy keys and
+// malformed ones.
+func ParseID(raw string) (id uuid.UUID, ok bool) {
+	rest, ok := strings.CutPrefix(raw, PREFIX)
+	if !ok {
+		return uuid.UUID{}, false
+	}
+	parts := strings.Split(rest, "_")
+	if len(parts) != 3 || len(parts[2]) != 2*SECRET_SIZE {
+		return uuid.UUID{}, false
+	}
+	b, err := hex.DecodeString(parts[1])
+	if err != nil || len(b) != len(id) {
+		return uuid.UUID{}, false
+	}
+	return uuid.UUID(b), true
+}
+
+// Prefix returns the public part of a key displayed to identify it: its mode
+// and the start of its ID.
+func Prefix(raw string) string {
+	parts := strings.Split(strings.TrimPrefix(raw, PREFIX), "_")
+	return PREFIX + parts[0] + "_" + parts[1][:DISPLAYED_ID_LENGTH]
+}
+
+// Hasher hashes keys with HMAC-SHA256 keyed with the pepper.
+type Hasher struct {
+	pepper []byte
+}
+
+func NewHasher(pepper []byte) *Hasher {
+	return &Hasher{pepper: pepper}
+}
+
+// Hash returns the hex HMAC-SHA256 of the key, as stored.
+func (h *Hasher) Hash(raw string) string {
+	return hex.EncodeToString(h.sum(raw))
+}
+
+// Verify reports whether hash is the hash of the key, in constant time.
+func (h *Hasher) Verify(raw string, hash string) bool {
+	b, err := hex.DecodeString(hash)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(h.sum(raw), b)
+}
+
+func (h *Hasher) sum(raw string) []byte {
+	mac := hmac.New(sha256.New, h.pepper)
+	mac.Write([]byte(raw))
+	return mac.Sum(nil)
+}
+
+// LegacyHash returns the hex SHA-256 of a legacy key, under which it is
+// looked up.
+func LegacyHash(raw string) string {
+	h := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(h[:])
+}
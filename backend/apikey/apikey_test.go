@@ -0,0 +1,84 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestGenerateAndParseID(t *testing.T) {
+	id := uuid.New()
+	raw, err := Generate("live", id)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if !strings.HasPrefix(raw, "bb_live_") {
+		t.Errorf("Generate: got %q, want a bb_live_ key", raw)
+	}
+	got, ok := ParseID(raw)
+	if !ok || got != id {
+		t.Errorf("ParseID(%q) = %s, %t, want %s, true", raw, got, ok, id)
+	}
+	if want := "bb_live_" + strings.ReplaceAll(id.String(), "-", "")[:DISPLAYED_ID_LENGTH]; Prefix(raw) != want {
+		t.Errorf("Prefix(%q) = %q, want %q", raw, Prefix(raw), want)
+	}
+}
+
+func TestParseIDRejectsLegacyAndMalformedKeys(t *testing.T) {
+	id := strings.ReplaceAll(uuid.NewString(), "-", "")
+	secret := strings.Repeat("ab", SECRET_SIZE)
+	for _, raw := range []string{
+		// Legacy keys embed no ID.
+		"bb_" + secret,
+		"bb_live_" + secret,
+		"bb_test_" + secret,
+		// Malformed keys.
+		"xx_live_" + id + "_" + secret,
+		"bb_live_" + id[:30] + "_" + secret,
+		"bb_live_" + strings.Repeat("zz", 16) + "_" + secret,
+		"bb_live_" + id + "_" + secret[:30],
+		"bb_live_" + id + "_" + secret + "_extra",
+		"",
+	} {
+		if _, ok := ParseID(raw); ok {
+			t.Errorf("ParseID(%q) succeeded", raw)
+		}
+	}
+}
+
+func TestHasherVerify(t *testing.T) {
+	raw, err := Generate("live", uuid.New())
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	hasher := NewHasher([]byte("pepper"))
+	hash := hasher.Hash(raw)
+
+	for _, tt := range []struct {
+		name   string
+		hasher *Hasher
+		raw    string
+		hash   string
+		want   bool
+	}{
+		{"same key and pepper", hasher, raw, hash, true},
+		{"other pepper", NewHasher([]byte("other pepper")), raw, hash, false},
+		{"other key", hasher, raw + "0", hash, false},
+		{"legacy hash", hasher, raw, LegacyHash(raw), false},
+		{"invalid hash", hasher, raw, "not hex", false},
+	} {
+		if got := tt.hasher.Verify(tt.raw, tt.hash); got != tt.want {
+			t.Errorf("%s: Verify = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLegacyHash(t *testing.T) {
+	// The unsalted SHA-256, whatever the pepper: legacy keys are looked up
+	// under it.
+	const want = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := LegacyHash("abc"); got != want {
+		t.Errorf("LegacyHash(%q) = %q, want %q", "abc", got, want)
+	}
+}
@@ -0,0 +1,337 @@
+// Package archive exports the events of past, fully invoiced months to
+// Parquet files on a blob store, so that their partition can be dropped, and
+// restores them for re-rating.
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"billbo.com/backend/database"
+	"billbo.com/backend/database/sqlcgen"
+	"billbo.com/backend/partitions"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/parquet-go/parquet-go"
+	"go.uber.org/zap"
+)
+
+const (
+	ARCHIVE_BATCH_SIZE = 10000
+
+	// REHYDRATION_HOLD is how long rehydrated events are kept in the
+	// database before their partition can be dropped again.
+	REHYDRATION_HOLD = 7 * 24 * time.Hour
+)
+
+var ErrChecksumMismatch = errors.New("archive checksum mismatch")
+
+// ArchivedEvent is an event, as stored in the Parquet files.
+type ArchivedEvent struct {
+	ID         uuid.UUID `parquet:"id,uuid"`
+	MerchantID uuid.UUID `parquet:"merchant_id,uuid"`
+	CustomerID uuid.UUID `parquet:"customer_id,uuid"`
+	SkuID      uuid.UUID `parquet:"sku_id,uuid"`
+	Amount     float64   `parquet:"amount"`
+	SentAt     time.Time `parquet:"sent_at,timestamp(microsecond)"`
+}
+
+// Archiver archives the events of a merchant and month to a Parquet file,
+// recorded with its checksum in the event_archives manifest.
+type Archiver struct {
+	logger *zap.Logger
+	pool   *pgxpool.Pool
+	store  BlobStore
+}
+
+func NewArchiver(logger *zap.Logger, pool *pgxpool.Pool, store BlobStore) *Archiver {
+	return &Archiver{
+		logger: logger.With(zap.String("component", "archive")),
+		pool:   pool,
+		store:  store,
+	}
+}
+
+// ArchiveAll archives the events of past months, per merchant, once they are
+// all invoiced, and again when events were added since.
+func (a *Archiver) ArchiveAll(ctx context.Context) error {
+	queries := sqlcgen.New(a.pool)
+
+	names, err := queries.ListEventPartitions(ctx)
+	if err != nil {
+		return fmt.Errorf("queries.ListEventPartitions: %w", err)
+	}
+
+	current := partitions.MonthPartition(time.Now())
+	for _, name := range names {
+		p, err := partitions.ParsePartition(name)
+		if err != nil || p.End.After(current.Start) {
+			continue
+		}
+
+		rows, err := queries.ListArchivableMerchants(ctx, sqlcgen.ListArchivableMerchantsParams{
+			RangeStart: pgtype.Timestamptz{Time: p.Start, Valid: true},
+			RangeEnd:   pgtype.Timestamptz{Time: p.End, Valid: true},
+		})
+		if err != nil {
+			return fmt.Errorf("queries.ListArchivableMerchants: %w", err)
+		}
+		for _, row := range rows {
+			archive, err := a.archive(ctx, row.MerchantID.Bytes, p)
+			if err != nil {
+				return fmt.Errorf("archive: %w", err)
+			}
+			a.logger.Info("archived events",
+				zap.String("merchant_id", row.MerchantID.String()),
+				zap.String("partition", p.Name),
+				zap.Int64("event_count", archive.EventCount),
+			)
+		}
+	}
+	return nil
+}
+
+// archive writes the merchant's events of the month p to a new Parquet file,
+// merged with the events of the previous archive of the month if any, and
+// records it in the manifest.
+func (a *Archiver) archive(ctx context.Context, merchantID uuid.UUID, p partitions.Partition) (*sqlcgen.EventArchive, error) {
+	queries := sqlcgen.New(a.pool)
+
+	previous, err := queries.GetEventArchive(ctx, sqlcgen.GetEventArchiveParams{
+		MerchantID:  pgtype.UUID{Bytes: merchantID, Valid: true},
+		PeriodStart: pgtype.Timestamptz{Time: p.Start, Valid: true},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		previous = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("queries.GetEventArchive: %w", err)
+	}
+
+	f, err := os.CreateTemp("", "events-*.parquet")
+	if err != nil {
+		return nil, fmt.Errorf("os.CreateTemp: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	w := parquet.NewGenericWriter[ArchivedEvent](f, parquet.Compression(&parquet.Zstd))
+	eventCount, databaseEventCount, err := a.writeEvents(ctx, w, merchantID, p, previous)
+	if err != nil {
+		return nil, fmt.Errorf("writeEvents: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("w.Close: %w", err)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("f.Seek: %w", err)
+	}
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return nil, fmt.Errorf("io.Copy: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("f.Seek: %w", err)
+	}
+
+	// Archives are never overwritten: the previous one is only deleted once
+	// the manifest points to the new one.
+	key := fmt.Sprintf("events/%s/%s-%d.parquet", merchantID, p.Name, time.Now().Unix())
+	if err := a.store.Put(ctx, key, f); err != nil {
+		return nil, fmt.Errorf("store.Put: %w", err)
+	}
+
+	archive, err := queries.PutEventArchive(ctx, sqlcgen.PutEventArchiveParams{
+		MerchantID:         pgtype.UUID{Bytes: merchantID, Valid: true},
+		PeriodStart:        pgtype.Timestamptz{Time: p.Start, Valid: true},
+		PeriodEnd:          pgtype.Timestamptz{Time: p.End, Valid: true},
+		BlobKey:            key,
+		EventCount:         eventCount,
+		DatabaseEventCount: databaseEventCount,
+		SizeBytes:          size,
+		Sha256:             hex.EncodeToString(hash.Sum(nil)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("queries.PutEventArchive: %w", err)
+	}
+
+	if previous != nil && previous.BlobKey != key {
+		if err := a.store.Delete(ctx, previous.BlobKey); err != nil {
+			a.logger.Warn("failed to delete previous archive",
+				zap.String("blob_key", previous.BlobKey),
+				zap.Error(err),
+			)
+		}
+	}
+	return archive, nil
+}
+
+// writeEvents writes the merchant's events of the month p in the database,
+// then those of the previous archive no longer in it. It returns the number
+// of events written and of events in the database. Events are read from a
+// snapshot, so that their count matches what was written.
+func (a *Archiver) writeEvents(
+	ctx context.Context,
+	w *parquet.GenericWriter[ArchivedEvent],
+	merchantID uuid.UUID,
+	p partitions.Partition,
+	previous *sqlcgen.EventArchive,
+) (int64, int64, error) {
+	tx, err := a.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return 0, 0, fmt.Errorf("pool.BeginTx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	queries := sqlcgen.New(a.pool).WithTx(tx)
+
+	written := make(map[uuid.UUID]bool)
+	after := ArchivedEvent{SentAt: p.Start.Add(-time.Microsecond)}
+	for {
+		events, err := queries.ListEventsToArchive(ctx, sqlcgen.ListEventsToArchiveParams{
+			MerchantID:  pgtype.UUID{Bytes: merchantID, Valid: true},
+			RangeStart:  pgtype.Timestamptz{Time: p.Start, Valid: true},
+			RangeEnd:    pgtype.Timestamptz{Time: p.End, Valid: true},
+			AfterSentAt: pgtype.Timestamptz{Time: after.SentAt, Valid: true},
+			AfterID:     pgtype.UUID{Bytes: after.ID, Valid: true},
+			BatchSize:   ARCHIVE_BATCH_SIZE,
+		})
+		if err != nil {
+			return 0, 0, fmt.Errorf("queries.ListEventsToArchive: %w", err)
+		}
+
+		rows := make([]ArchivedEvent, len(events))
+		for i, e := range events {
+			rows[i] = ArchivedEvent{
+				ID:         e.ID.Bytes,
+				MerchantID: e.MerchantID.Bytes,
+				CustomerID: e.CustomerID.Bytes,
+				SkuID:      e.SkuID.Bytes,
+				Amount:     e.Amount,
+				SentAt:     e.SentAt.Time.UTC(),
+			}
+			written[rows[i].ID] = true
+		}
+		if _, err := w.Write(rows); err != nil {
+			return 0, 0, fmt.Errorf("w.Write: %w", err)
+		}
+
+		if len(events) < ARCHIVE_BATCH_SIZE {
+			break
+		}
+		after = rows[len(rows)-1]
+	}
+	databaseEventCount := int64(len(written))
+
+	if previous != nil {
+		archived, err := a.read(ctx, previous)
+		if err != nil {
+			return 0, 0, fmt.Errorf("read: %w", err)
+		}
+		var rows []ArchivedEvent
+		for _, row := range archived {
+			if !written[row.ID] {
+				rows = append(rows, row)
+				written[row.ID] = true
+			}
+		}
+		if _, err := w.Write(rows); err != nil {
+			return 0, 0, fmt.Errorf("w.Write: %w", err)
+		}
+	}
+
+	return int64(len(written)), databaseEventCount, nil
+}
+
+// read reads the events of an archive, after checking its checksum.
+func (a *Archiver) read(ctx context.Context, archive *sqlcgen.EventArchive) ([]ArchivedEvent, error) {
+	r, err := a.store.Get(ctx, archive.BlobKey)
+	if err != nil {
+		return nil, fmt.Errorf("store.Get: %w", err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != archive.Sha256 {
+		return nil, fmt.Errorf("%s: %w", archive.BlobKey, ErrChecksumMismatch)
+	}
+
+	pr := parquet.NewGenericReader[ArchivedEvent](bytes.NewReader(data))
+	defer pr.Close()
+	rows := make([]ArchivedEvent, pr.NumRows())
+	n, err := pr.Read(rows)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("pr.Read: %w", err)
+	}
+	return rows[:n], nil
+}
+
+// Rehydrate restores the merchant's archived events of the months
+// overlapping [from, to) into the database, e.g. to re-rate a period, and
+// holds them there for REHYDRATION_HOLD. Events still in the database are
+// skipped. It returns the number of events restored.
+func (a *Archiver) Rehydrate(ctx context.Context, merchantID uuid.UUID, from, to time.Time) (int64, error) {
+	archives, err := sqlcgen.New(a.pool).ListEventArchivesOverlapping(ctx, sqlcgen.ListEventArchivesOverlappingParams{
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+		RangeStart: pgtype.Timestamptz{Time: from, Valid: true},
+		RangeEnd:   pgtype.Timestamptz{Time: to, Valid: true},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("queries.ListEventArchivesOverlapping: %w", err)
+	}
+
+	var restored int64
+	for _, archive := range archives {
+		rows, err := a.read(ctx, archive)
+		if err != nil {
+			return 0, fmt.Errorf("read: %w", err)
+		}
+
+		err = database.InTx(ctx, a.pool, func(q *sqlcgen.Queries) error {
+			// Held first, so that the partition is not dropped while restored.
+			err := q.SetEventArchiveRehydratedUntil(ctx, sqlcgen.SetEventArchiveRehydratedUntilParams{
+				MerchantID:      archive.MerchantID,
+				PeriodStart:     archive.PeriodStart,
+				RehydratedUntil: pgtype.Timestamptz{Time: time.Now().Add(REHYDRATION_HOLD), Valid: true},
+			})
+			if err != nil {
+				return fmt.Errorf("queries.SetEventArchiveRehydratedUntil: %w", err)
+			}
+
+			for batch := range slices.Chunk(rows, ARCHIVE_BATCH_SIZE) {
+				params := sqlcgen.InsertArchivedEventsParams{MerchantID: archive.MerchantID}
+				for _, row := range batch {
+					params.Ids = append(params.Ids, pgtype.UUID{Bytes: row.ID, Valid: true})
+					params.CustomerIds = append(params.CustomerIds, pgtype.UUID{Bytes: row.CustomerID, Valid: true})
+					params.SkuIds = append(params.SkuIds, pgtype.UUID{Bytes: row.SkuID, Valid: true})
+					params.Amounts = append(params.Amounts, row.Amount)
+					params.SentAts = append(params.SentAts, pgtype.Timestamptz{Time: row.SentAt, Valid: true})
+				}
+				n, err := q.InsertArchivedEvents(ctx, params)
+				if err != nil {
+					return fmt.Errorf("queries.InsertArchivedEvents: %w", err)
+				}
+				restored += n
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return restored, nil
+}
@@ -0,0 +1,81 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore stores the archive files, by key. Keys are slash-separated paths.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// FSBlobStore is a BlobStore on the local filesystem, under a root directory.
+type FSBlobStore struct {
+	root string
+}
+
+func NewFSBlobStore(root string) *FSBlobStore {
+	return &FSBlobStore{root: root}
+}
+
+func (s *FSBlobStore) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
+
+// Put writes the blob to a temporary file renamed once complete, so that a
+// blob is never partially written.
+func (s *FSBlobStore) Put(ctx context.Context, key string, r io.Reader) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("os.MkdirAll: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("os.CreateTemp: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return fmt.Errorf("io.Copy: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("f.Sync: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("f.Close: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("os.Rename: %w", err)
+	}
+	return nil
+}
+
+func (s *FSBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("os.Open: %w", err)
+	}
+	return f, nil
+}
+
+func (s *FSBlobStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("os.Remove: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package archive
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+const ARCHIVE_BACKEND_FS = "fs"
+
+// BlobStoreConfig is the archive blob store configuration, shared by the
+// commands that archive and rehydrate events.
+type BlobStoreConfig struct {
+	// ArchiveBackend is "fs", files under ArchiveDir. S3-compatible stores
+	// are not supported yet.
+	ArchiveBackend string `env:"ARCHIVE_BACKEND,default=fs"`
+	// ArchiveDir must be an absolute path, the same directory for the
+	// worker, which archives, and the dashboard API, which rehydrates and
+	// deletes archives: a shared volume when they run on different hosts.
+	ArchiveDir string `env:"ARCHIVE_DIR"`
+}
+
+func (cfg BlobStoreConfig) NewBlobStore() (BlobStore, error) {
+	switch cfg.ArchiveBackend {
+	case ARCHIVE_BACKEND_FS:
+		if !filepath.IsAbs(cfg.ArchiveDir) {
+			return nil, fmt.Errorf("NewBlobStore: ARCHIVE_DIR must be an absolute path, got %q", cfg.ArchiveDir)
+		}
+		return NewFSBlobStore(cfg.ArchiveDir), nil
+	}
+	return nil, fmt.Errorf("NewBlobStore: unknown archive backend %q", cfg.ArchiveBackend)
+}
@@ -0,0 +1,102 @@
+package billing
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Discount is a coupon attached to a customer, as seen by the engine.
+// Exactly one of PercentOff and AmountOff is set. An empty SkuIDs applies the
+// discount to the whole invoice.
+type Discount struct {
+	RedemptionID pgtype.UUID
+	Name         string
+	PercentOff   pgtype.Float8
+	AmountOff    pgtype.Float8
+	SkuIDs       []pgtype.UUID
+}
+
+// ApplyDiscounts computes one discount line per applicable discount for the
+// given usage lines. Discounts are applied in a fixed order:
+//  1. SKU-scoped discounts before invoice-wide ones,
+//  2. within each scope, percentages before fixed amounts,
+//  3. then in the order they were attached.
+//
+// Each discount applies to what is left of its eligible lines after the
+// previous ones, so a fixed amount can never take a line below zero.
+func ApplyDiscounts(lines []Line, discounts []Discount) []Line {
+	ordered := slices.Clone(discounts)
+	slices.SortStableFunc(ordered, func(a, b Discount) int {
+		return discountRank(a) - discountRank(b)
+	})
+
+	remaining := make([]float64, len(lines))
+	for i, line := range lines {
+		remaining[i] = line.Amount
+	}
+
+	var out []Line
+	for _, d := range ordered {
+		var eligible float64
+		for i, line := range lines {
+			if d.appliesTo(line) {
+				eligible += remaining[i]
+			}
+		}
+		if eligible <= 0 {
+			continue
+		}
+
+		var amount float64
+		if d.PercentOff.Valid {
+			amount = roundCents(eligible * d.PercentOff.Float64 / 100)
+		} else {
+			amount = roundCents(min(d.AmountOff.Float64, eligible))
+		}
+		if amount <= 0 {
+			continue
+		}
+
+		// Spread the discount over the eligible lines pro rata.
+		for i, line := range lines {
+			if d.appliesTo(line) {
+				remaining[i] -= remaining[i] / eligible * amount
+			}
+		}
+
+		out = append(out, Line{
+			Kind:               LineKindDiscount,
+			Description:        d.description(),
+			Amount:             -amount,
+			CouponRedemptionID: d.RedemptionID,
+		})
+	}
+	return out
+}
+
+func discountRank(d Discount) int {
+	rank := 0
+	if len(d.SkuIDs) == 0 {
+		rank += 2
+	}
+	if !d.PercentOff.Valid {
+		rank++
+	}
+	return rank
+}
+
+func (d Discount) appliesTo(line Line) bool {
+	if line.Kind != LineKindUsage {
+		return false
+	}
+	return len(d.SkuIDs) == 0 || slices.Contains(d.SkuIDs, line.SkuID)
+}
+
+func (d Discount) description() string {
+	if d.PercentOff.Valid {
+		return fmt.Sprintf("%s (%g%% off)", d.Name, d.PercentOff.Float64)
+	}
+	return fmt.Sprintf("%s (%.2f off)", d.Name, d.AmountOff.Float64)
+}
@@ -0,0 +1,95 @@
+package billing
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	skuA = pgtype.UUID{Bytes: uuid.New(), Valid: true}
+	skuB = pgtype.UUID{Bytes: uuid.New(), Valid: true}
+)
+
+func usageLine(sku pgtype.UUID, amount float64) Line {
+	return Line{Kind: LineKindUsage, SkuID: sku, Amount: amount}
+}
+
+func percentOff(percent float64, skus ...pgtype.UUID) Discount {
+	return Discount{Name: "percent", PercentOff: pgtype.Float8{Float64: percent, Valid: true}, SkuIDs: skus}
+}
+
+func amountOff(amount float64, skus ...pgtype.UUID) Discount {
+	return Discount{Name: "fixed", AmountOff: pgtype.Float8{Float64: amount, Valid: true}, SkuIDs: skus}
+}
+
+func TestApplyDiscounts(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		lines     []Line
+		discounts []Discount
+		// want are the amounts of the discount lines, in order.
+		want []float64
+	}{
+		{
+			// 30 off A leaves 170, of which 10% is 17: applied first, the
+			// 10% would have been 20.
+			name:      "SKU-scoped before invoice-wide",
+			lines:     []Line{usageLine(skuA, 100), usageLine(skuB, 100)},
+			discounts: []Discount{percentOff(10), amountOff(30, skuA)},
+			want:      []float64{-30, -17},
+		},
+		{
+			// 50% of 100, then 10 off the 50 left: applied first, the 10
+			// off would have left 45 off.
+			name:      "percent before fixed",
+			lines:     []Line{usageLine(skuA, 100)},
+			discounts: []Discount{amountOff(10), percentOff(50)},
+			want:      []float64{-50, -10},
+		},
+		{
+			name:      "attach order within a rank",
+			lines:     []Line{usageLine(skuA, 100)},
+			discounts: []Discount{percentOff(10), percentOff(20)},
+			want:      []float64{-10, -18},
+		},
+		{
+			name:      "clamped at zero",
+			lines:     []Line{usageLine(skuA, 100)},
+			discounts: []Discount{amountOff(80), amountOff(50), amountOff(10)},
+			want:      []float64{-80, -20},
+		},
+		{
+			name:      "SKU-scoped clamped to its SKU",
+			lines:     []Line{usageLine(skuA, 20), usageLine(skuB, 100)},
+			discounts: []Discount{amountOff(50, skuA), percentOff(50)},
+			want:      []float64{-20, -50},
+		},
+		{
+			name:      "SKU not invoiced",
+			lines:     []Line{usageLine(skuA, 100)},
+			discounts: []Discount{percentOff(50, skuB)},
+			want:      nil,
+		},
+		{
+			name:      "rounded to the cent",
+			lines:     []Line{usageLine(skuA, 10)},
+			discounts: []Discount{percentOff(33.333)},
+			want:      []float64{-3.33},
+		},
+	} {
+		lines := ApplyDiscounts(tt.lines, tt.discounts)
+		var got []float64
+		for _, line := range lines {
+			if line.Kind != LineKindDiscount {
+				t.Errorf("%s: got a %s line", tt.name, line.Kind)
+			}
+			got = append(got, line.Amount)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
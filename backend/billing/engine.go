@@ -0,0 +1,265 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"billbo.com/backend/database"
+	"billbo.com/backend/database/sqlcgen"
+	"billbo.com/backend/outbox"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// Engine turns ingested usage into invoices.
+type Engine struct {
+	logger *zap.Logger
+	pool   *pgxpool.Pool
+	tax    TaxCalculator
+}
+
+func NewEngine(
+	logger *zap.Logger,
+	pool *pgxpool.Pool,
+	tax TaxCalculator,
+) *Engine {
+	return &Engine{
+		logger: logger.With(zap.String("component", "billing")),
+		pool:   pool,
+		tax:    tax,
+	}
+}
+
+type GenerateInvoiceParams struct {
+	MerchantID  uuid.UUID
+	CustomerID  uuid.UUID
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+}
+
+// GenerateInvoice rates the customer's usage over [PeriodStart, PeriodEnd),
+// using the customer's price overrides where they apply, and stores it as a
+// draft invoice with the customer's active coupons applied, then taxed
+// according to the customer's billing address.
+// An existing draft for the same period is replaced; if the period has
+// already been finalized, ErrInvoiceFinalized is returned.
+func (e *Engine) GenerateInvoice(ctx context.Context, p GenerateInvoiceParams) (*sqlcgen.Invoice, error) {
+	merchantID := pgtype.UUID{Bytes: p.MerchantID, Valid: true}
+	customerID := pgtype.UUID{Bytes: p.CustomerID, Valid: true}
+	periodStart := pgtype.Timestamptz{Time: p.PeriodStart, Valid: true}
+	periodEnd := pgtype.Timestamptz{Time: p.PeriodEnd, Valid: true}
+
+	var invoice *sqlcgen.Invoice
+	err := database.InTx(ctx, e.pool, func(q *sqlcgen.Queries) error {
+		err := q.DeleteDraftInvoice(ctx, sqlcgen.DeleteDraftInvoiceParams{
+			MerchantID:  merchantID,
+			CustomerID:  customerID,
+			PeriodStart: periodStart,
+			PeriodEnd:   periodEnd,
+		})
+		if err != nil {
+			return fmt.Errorf("queries.DeleteDraftInvoice: %w", err)
+		}
+
+		usage, err := q.ListUsageBySKU(ctx, sqlcgen.ListUsageBySKUParams{
+			MerchantID:  merchantID,
+			CustomerID:  customerID,
+			PeriodStart: periodStart,
+			PeriodEnd:   periodEnd,
+		})
+		if err != nil {
+			return fmt.Errorf("queries.ListUsageBySKU: %w", err)
+		}
+
+		lines := make([]Line, len(usage))
+		var subtotal float64
+		for i, u := range usage {
+			lines[i] = Line{
+				Kind:        LineKindUsage,
+				Description: u.Name,
+				SkuID:       u.SkuID,
+				Quantity:    pgtype.Float8{Float64: u.Quantity, Valid: true},
+				UnitPrice:   pgtype.Float8{Float64: u.UnitPrice, Valid: true},
+				Amount:      roundCents(u.Quantity * u.UnitPrice),
+			}
+			subtotal += lines[i].Amount
+		}
+
+		redemptions, err := q.ListActiveCouponRedemptionsByCustomer(ctx, sqlcgen.ListActiveCouponRedemptionsByCustomerParams{
+			MerchantID: merchantID,
+			CustomerID: customerID,
+		})
+		if err != nil {
+			return fmt.Errorf("queries.ListActiveCouponRedemptionsByCustomer: %w", err)
+		}
+		discounts := make([]Discount, len(redemptions))
+		for i, r := range redemptions {
+			discounts[i] = Discount{
+				RedemptionID: r.ID,
+				Name:         r.Name,
+				PercentOff:   r.PercentOff,
+				AmountOff:    r.AmountOff,
+				SkuIDs:       r.SkuIds,
+			}
+		}
+		discountLines := ApplyDiscounts(lines, discounts)
+		var discountTotal float64
+		for _, line := range discountLines {
+			discountTotal -= line.Amount
+		}
+		lines = append(lines, discountLines...)
+
+		customer, err := q.GetCustomer(ctx, sqlcgen.GetCustomerParams{
+			ID:         customerID,
+			MerchantID: merchantID,
+		})
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("queries.GetCustomer: %w", err)
+		}
+		taxLines, err := e.tax.CalculateTax(ctx, q, TaxInput{
+			MerchantID: p.MerchantID,
+			CustomerID: p.CustomerID,
+			Address:    customerAddress(customer),
+			TaxID:      customer.TaxID.String,
+			Lines:      lines,
+		})
+		if err != nil {
+			return fmt.Errorf("tax.CalculateTax: %w", err)
+		}
+		var taxTotal float64
+		for _, line := range taxLines {
+			taxTotal += line.Amount
+		}
+		lines = append(lines, taxLines...)
+
+		subtotal = roundCents(subtotal)
+		discountTotal = roundCents(discountTotal)
+		taxTotal = roundCents(taxTotal)
+		invoice, err = q.CreateInvoice(ctx, sqlcgen.CreateInvoiceParams{
+			MerchantID:    merchantID,
+			CustomerID:    customerID,
+			PeriodStart:   periodStart,
+			PeriodEnd:     periodEnd,
+			Subtotal:      subtotal,
+			DiscountTotal: discountTotal,
+			TaxTotal:      taxTotal,
+			Total:         roundCents(subtotal - discountTotal + taxTotal),
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return ErrInvoiceFinalized
+			}
+			return fmt.Errorf("queries.CreateInvoice: %w", err)
+		}
+
+		for i, line := range lines {
+			err := q.CreateInvoiceLine(ctx, sqlcgen.CreateInvoiceLineParams{
+				InvoiceID:          invoice.ID,
+				Position:           int32(i),
+				Kind:               line.Kind,
+				Description:        line.Description,
+				SkuID:              line.SkuID,
+				Quantity:           line.Quantity,
+				UnitPrice:          line.UnitPrice,
+				Amount:             line.Amount,
+				CouponRedemptionID: line.CouponRedemptionID,
+				TaxRateID:          line.TaxRateID,
+			})
+			if err != nil {
+				return fmt.Errorf("queries.CreateInvoiceLine: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GenerateInvoice: %w", err)
+	}
+	return invoice, nil
+}
+
+// FinalizeInvoice locks a draft invoice, gives it the merchant's next invoice
+// number and a due date, and counts one billing period against every coupon
+// redemption it applied.
+func (e *Engine) FinalizeInvoice(ctx context.Context, merchantID, invoiceID uuid.UUID) (*sqlcgen.Invoice, error) {
+	var invoice *sqlcgen.Invoice
+	err := database.InTx(ctx, e.pool, func(q *sqlcgen.Queries) error {
+		var err error
+		invoice, err = q.FinalizeInvoice(ctx, sqlcgen.FinalizeInvoiceParams{
+			ID:         pgtype.UUID{Bytes: invoiceID, Valid: true},
+			MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			_, err := q.GetInvoice(ctx, sqlcgen.GetInvoiceParams{
+				ID:         pgtype.UUID{Bytes: invoiceID, Valid: true},
+				MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+			})
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrInvoiceNotFound
+			}
+			if err != nil {
+				return fmt.Errorf("queries.GetInvoice: %w", err)
+			}
+			return ErrInvoiceNotDraft
+		}
+		if err != nil {
+			return fmt.Errorf("queries.FinalizeInvoice: %w", err)
+		}
+
+		next, err := q.NextInvoiceNumber(ctx, invoice.MerchantID)
+		if err != nil {
+			return fmt.Errorf("queries.NextInvoiceNumber: %w", err)
+		}
+		invoice, err = q.SetInvoiceNumber(ctx, sqlcgen.SetInvoiceNumberParams{
+			ID: invoice.ID,
+			Number: pgtype.Text{
+				String: fmt.Sprintf("%s%06d", next.NumberPrefix, next.LastInvoiceNumber),
+				Valid:  true,
+			},
+			DueAt: pgtype.Timestamptz{
+				Time:  invoice.FinalizedAt.Time.AddDate(0, 0, int(next.PaymentTermsDays)),
+				Valid: true,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("queries.SetInvoiceNumber: %w", err)
+		}
+
+		if err := q.IncrementCouponRedemptionPeriods(ctx, invoice.ID); err != nil {
+			return fmt.Errorf("queries.IncrementCouponRedemptionPeriods: %w", err)
+		}
+
+		err = outbox.Emit(ctx, q, invoice.MerchantID, outbox.EventInvoiceFinalized, outbox.NewInvoiceData(invoice))
+		if err != nil {
+			return fmt.Errorf("outbox.Emit: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("FinalizeInvoice: %w", err)
+	}
+
+	e.logger.Info("invoice finalized",
+		zap.String("invoice_id", invoice.ID.String()),
+		zap.String("merchant_id", merchantID.String()),
+		zap.Float64("total", invoice.Total),
+	)
+	return invoice, nil
+}
+
+func customerAddress(c *sqlcgen.Customer) Address {
+	return Address{
+		Line1:      c.AddressLine1.String,
+		Line2:      c.AddressLine2.String,
+		City:       c.City.String,
+		PostalCode: c.PostalCode.String,
+		Region:     c.Region.String,
+		Country:    c.Country.String,
+	}
+}
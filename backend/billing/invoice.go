@@ -0,0 +1,53 @@
+package billing
+
+import (
+	"errors"
+	"math"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Invoice statuses. Finalized invoices move to paid, payment_failed or
+// refunded as payment events come back from the payment provider. Invoices
+// still unpaid at the end of dunning are uncollectible.
+const (
+	InvoiceStatusDraft         = "draft"
+	InvoiceStatusFinalized     = "finalized"
+	InvoiceStatusPaid          = "paid"
+	InvoiceStatusPaymentFailed = "payment_failed"
+	InvoiceStatusRefunded      = "refunded"
+	InvoiceStatusUncollectible = "uncollectible"
+)
+
+// Invoice line kinds.
+const (
+	LineKindUsage    = "usage"
+	LineKindDiscount = "discount"
+	LineKindTax      = "tax"
+)
+
+var (
+	ErrInvoiceNotFound  = errors.New("invoice not found")
+	ErrInvoiceNotDraft  = errors.New("invoice is not a draft")
+	ErrInvoiceFinalized = errors.New("invoice already finalized for this period")
+)
+
+// Line is an invoice line computed by the engine before it is persisted.
+// Usage lines carry a SKU, quantity and unit price; discount lines carry the
+// coupon redemption they come from and a negative amount; tax lines carry the
+// tax rate they were computed from.
+type Line struct {
+	Kind               string
+	Description        string
+	SkuID              pgtype.UUID
+	Quantity           pgtype.Float8
+	UnitPrice          pgtype.Float8
+	Amount             float64
+	CouponRedemptionID pgtype.UUID
+	TaxRateID          pgtype.UUID
+}
+
+// roundCents rounds an amount to the nearest cent.
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
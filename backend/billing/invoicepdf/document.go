@@ -0,0 +1,94 @@
+package invoicepdf
+
+import (
+	"time"
+
+	"billbo.com/backend/billing"
+	"billbo.com/backend/database/sqlcgen"
+)
+
+// Document is everything printed on an invoice.
+type Document struct {
+	MerchantName    string
+	MerchantEmail   string
+	CustomerName    string
+	CustomerEmail   string
+	CustomerAddress billing.Address
+	CustomerTaxID   string
+	Number          string
+	IssuedAt        time.Time
+	DueAt           time.Time
+	PeriodStart     time.Time
+	PeriodEnd       time.Time
+	// Lines holds usage and discount lines, Taxes the tax lines.
+	Lines         []Line
+	Taxes         []Line
+	Subtotal      float64
+	DiscountTotal float64
+	TaxTotal      float64
+	Total         float64
+}
+
+type Line struct {
+	Description string
+	Quantity    *float64
+	UnitPrice   *float64
+	Amount      float64
+}
+
+// NewDocument assembles a Document from a finalized invoice and its lines.
+// customer may be a zero value if the merchant never registered the
+// customer's billing details.
+func NewDocument(
+	merchant *sqlcgen.GetMerchantByIDRow,
+	customer *sqlcgen.Customer,
+	invoice *sqlcgen.Invoice,
+	lines []*sqlcgen.InvoiceLine,
+) *Document {
+	doc := &Document{
+		MerchantName:  merchant.Name,
+		MerchantEmail: merchant.Email,
+		CustomerName:  customer.Name,
+		CustomerEmail: customer.Email.String,
+		CustomerAddress: billing.Address{
+			Line1:      customer.AddressLine1.String,
+			Line2:      customer.AddressLine2.String,
+			City:       customer.City.String,
+			PostalCode: customer.PostalCode.String,
+			Region:     customer.Region.String,
+			Country:    customer.Country.String,
+		},
+		CustomerTaxID: customer.TaxID.String,
+		Number:        invoice.Number.String,
+		IssuedAt:      invoice.FinalizedAt.Time,
+		DueAt:         invoice.DueAt.Time,
+		PeriodStart:   invoice.PeriodStart.Time,
+		PeriodEnd:     invoice.PeriodEnd.Time,
+		Subtotal:      invoice.Subtotal,
+		DiscountTotal: invoice.DiscountTotal,
+		TaxTotal:      invoice.TaxTotal,
+		Total:         invoice.Total,
+	}
+	if doc.CustomerName == "" {
+		doc.CustomerName = invoice.CustomerID.String()
+	}
+
+	for _, row := range lines {
+		line := Line{
+			Description: row.Description,
+			Amount:      row.Amount,
+		}
+		if row.Quantity.Valid {
+			line.Quantity = &row.Quantity.Float64
+		}
+		if row.UnitPrice.Valid {
+			line.UnitPrice = &row.UnitPrice.Float64
+		}
+		if row.Kind == billing.LineKindTax {
+			doc.Taxes = append(doc.Taxes, line)
+		} else {
+			doc.Lines = append(doc.Lines, line)
+		}
+	}
+	return doc
+}
@@ -0,0 +1,201 @@
+package invoicepdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+)
+
+const (
+	pageMargin = 15.0
+	lineHeight = 6.0
+	dateLayout = "2006-01-02"
+)
+
+var logoImageTypes = map[string]string{
+	"image/png":  "PNG",
+	"image/jpeg": "JPG",
+}
+
+// Render writes doc as an A4 PDF, styled with tmpl.
+func Render(w io.Writer, doc *Document, tmpl Template) error {
+	header, err := execute("header", tmpl.Header, doc)
+	if err != nil {
+		return fmt.Errorf("Render: %w", err)
+	}
+	footer, err := execute("footer", tmpl.Footer, doc)
+	if err != nil {
+		return fmt.Errorf("Render: %w", err)
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	pdf.SetAutoPageBreak(true, 25)
+	pdf.SetTitle("Invoice "+doc.Number, true)
+	pdf.SetAuthor(doc.MerchantName, true)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	ar, ag, ab := tmpl.accentRGB()
+
+	if footer != "" {
+		pdf.SetFooterFunc(func() {
+			pdf.SetY(-20)
+			pdf.SetFont("Helvetica", "", 8)
+			pdf.SetTextColor(110, 110, 110)
+			pdf.MultiCell(0, 4, tr(footer), "", "C", false)
+		})
+	}
+
+	pdf.AddPage()
+	pageWidth, _ := pdf.GetPageSize()
+	contentWidth := pageWidth - 2*pageMargin
+
+	// Merchant block, with the logo above the name when there is one.
+	top := pdf.GetY()
+	if imageType, ok := logoImageTypes[tmpl.LogoContentType]; ok && len(tmpl.Logo) > 0 {
+		opts := fpdf.ImageOptions{ImageType: imageType, ReadDpi: true}
+		pdf.RegisterImageOptionsReader("logo", opts, bytes.NewReader(tmpl.Logo))
+		pdf.ImageOptions("logo", pageMargin, top, 0, 18, false, opts, 0, "")
+		pdf.SetY(top + 20)
+	}
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.CellFormat(contentWidth/2, lineHeight, tr(doc.MerchantName), "", 2, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	if doc.MerchantEmail != "" {
+		pdf.CellFormat(contentWidth/2, lineHeight-1, tr(doc.MerchantEmail), "", 2, "L", false, 0, "")
+	}
+	merchantBottom := pdf.GetY()
+
+	// Invoice metadata, right-aligned.
+	pdf.SetXY(pageMargin+contentWidth/2, top)
+	pdf.SetFont("Helvetica", "B", 20)
+	pdf.SetTextColor(ar, ag, ab)
+	pdf.CellFormat(contentWidth/2, 10, "INVOICE", "", 2, "R", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.SetTextColor(0, 0, 0)
+	for _, kv := range [][2]string{
+		{"Invoice number", doc.Number},
+		{"Issued", doc.IssuedAt.Format(dateLayout)},
+		{"Due", doc.DueAt.Format(dateLayout)},
+		{"Period", doc.PeriodStart.Format(dateLayout) + " - " + doc.PeriodEnd.Format(dateLayout)},
+	} {
+		pdf.CellFormat(contentWidth/2, lineHeight-1, tr(kv[0]+": "+kv[1]), "", 2, "R", false, 0, "")
+	}
+	pdf.SetY(max(merchantBottom, pdf.GetY()) + 8)
+
+	// Bill to.
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetTextColor(ar, ag, ab)
+	pdf.CellFormat(contentWidth, lineHeight, "BILL TO", "", 1, "L", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(contentWidth, lineHeight, tr(doc.CustomerName), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	for _, l := range customerLines(doc) {
+		pdf.CellFormat(contentWidth, lineHeight-1, tr(l), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	if header != "" {
+		pdf.MultiCell(contentWidth, lineHeight-1, tr(header), "", "L", false)
+		pdf.Ln(4)
+	}
+
+	// Lines table.
+	widths := []float64{contentWidth * 0.46, contentWidth * 0.16, contentWidth * 0.19, contentWidth * 0.19}
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetFillColor(ar, ag, ab)
+	pdf.SetTextColor(255, 255, 255)
+	for i, title := range []string{"Description", "Quantity", "Unit price", "Amount"} {
+		align := "R"
+		if i == 0 {
+			align = "L"
+		}
+		pdf.CellFormat(widths[i], lineHeight+1, title, "", 0, align, true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetDrawColor(220, 220, 220)
+	for _, line := range doc.Lines {
+		pdf.CellFormat(widths[0], lineHeight, tr(line.Description), "B", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[1], lineHeight, formatOptional(line.Quantity), "B", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[2], lineHeight, formatOptional(line.UnitPrice), "B", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[3], lineHeight, formatAmount(line.Amount), "B", 1, "R", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	// Totals.
+	labelX := pageMargin + widths[0] + widths[1]
+	totalRow := func(label, value string, bold bool) {
+		style := ""
+		if bold {
+			style = "B"
+		}
+		pdf.SetX(labelX)
+		pdf.SetFont("Helvetica", style, 9)
+		pdf.CellFormat(widths[2], lineHeight, tr(label), "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[3], lineHeight, value, "", 1, "R", false, 0, "")
+	}
+	totalRow("Subtotal", formatAmount(doc.Subtotal), false)
+	if doc.DiscountTotal != 0 {
+		totalRow("Discounts", formatAmount(-doc.DiscountTotal), false)
+	}
+	for _, tax := range doc.Taxes {
+		totalRow(tax.Description, formatAmount(tax.Amount), false)
+	}
+	pdf.SetDrawColor(ar, ag, ab)
+	pdf.Line(labelX, pdf.GetY(), pageMargin+contentWidth, pdf.GetY())
+	totalRow("Total due", formatAmount(doc.Total), true)
+	totalRow("Due date", doc.DueAt.Format(dateLayout), false)
+
+	if err := pdf.Output(w); err != nil {
+		return fmt.Errorf("pdf.Output: %w", err)
+	}
+	return nil
+}
+
+func customerLines(doc *Document) []string {
+	a := doc.CustomerAddress
+	var lines []string
+	for _, l := range []string{
+		a.Line1,
+		a.Line2,
+		strings.TrimSpace(a.PostalCode + " " + a.City),
+		strings.TrimSpace(strings.Join(nonEmpty(a.Region, a.Country), ", ")),
+		doc.CustomerEmail,
+	} {
+		if l != "" {
+			lines = append(lines, l)
+		}
+	}
+	if doc.CustomerTaxID != "" {
+		lines = append(lines, "Tax ID: "+doc.CustomerTaxID)
+	}
+	return lines
+}
+
+func nonEmpty(values ...string) []string {
+	var out []string
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+func formatOptional(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
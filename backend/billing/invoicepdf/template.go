@@ -0,0 +1,83 @@
+package invoicepdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"text/template"
+
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var accentColorRe = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// Template holds what a merchant can customize on their invoices. Header
+// and Footer are text/template sources executed against the Document, e.g.
+// "Thank you {{.CustomerName}}! Please pay by {{.DueAt.Format \"Jan 2\"}}.".
+type Template struct {
+	Logo            []byte
+	LogoContentType string
+	AccentColor     string
+	Header          string
+	Footer          string
+}
+
+// DefaultSettings returns the settings of a merchant who never saved any,
+// matching the column defaults of invoice_settings. They are not stored.
+func DefaultSettings(merchantID pgtype.UUID) *sqlcgen.InvoiceSetting {
+	return &sqlcgen.InvoiceSetting{
+		MerchantID:       merchantID,
+		NumberPrefix:     "INV-",
+		PaymentTermsDays: 30,
+		AccentColor:      "#1F2937",
+	}
+}
+
+func TemplateFromDB(row *sqlcgen.InvoiceSetting) Template {
+	return Template{
+		Logo:            row.Logo,
+		LogoContentType: row.LogoContentType.String,
+		AccentColor:     row.AccentColor,
+		Header:          row.HeaderTemplate,
+		Footer:          row.FooterTemplate,
+	}
+}
+
+// Validate checks the accent color and that Header and Footer parse and
+// execute against a sample document.
+func (t Template) Validate() error {
+	if !accentColorRe.MatchString(t.AccentColor) {
+		return fmt.Errorf("accent color %q is not a #RRGGBB color", t.AccentColor)
+	}
+	sample := &Document{Lines: []Line{{}}, Taxes: []Line{{}}}
+	if _, err := execute("header", t.Header, sample); err != nil {
+		return err
+	}
+	if _, err := execute("footer", t.Footer, sample); err != nil {
+		return err
+	}
+	return nil
+}
+
+func execute(name, src string, doc *Document) (string, error) {
+	if src == "" {
+		return "", nil
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(src)
+	if err != nil {
+		return "", fmt.Errorf("parse %s template: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, doc); err != nil {
+		return "", fmt.Errorf("execute %s template: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+func (t Template) accentRGB() (r, g, b int) {
+	if _, err := fmt.Sscanf(t.AccentColor, "#%02x%02x%02x", &r, &g, &b); err != nil {
+		return 0x1F, 0x29, 0x37
+	}
+	return r, g, b
+}
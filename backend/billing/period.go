@@ -0,0 +1,65 @@
+package billing
+
+import (
+	"fmt"
+	"time"
+
+	// Embeds the timezone database so that merchant timezones resolve on
+	// hosts without one.
+	_ "time/tzdata"
+)
+
+// Period is a billing period, [Start, End).
+type Period struct {
+	Start time.Time
+	End   time.Time
+}
+
+// PeriodSchedule splits time into monthly billing periods starting at
+// midnight, in Location, on AnchorDay. In months shorter than AnchorDay the
+// period starts on the last day of the month instead: with an anchor on the
+// 31st, periods start on January 31, February 28 (or 29), March 31...
+type PeriodSchedule struct {
+	Location  *time.Location
+	AnchorDay int
+}
+
+// NewPeriodSchedule returns the schedule of the given IANA timezone and
+// anchor day.
+func NewPeriodSchedule(timezone string, anchorDay int) (PeriodSchedule, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return PeriodSchedule{}, fmt.Errorf("NewPeriodSchedule: %w", err)
+	}
+	if anchorDay < 1 || anchorDay > 31 {
+		return PeriodSchedule{}, fmt.Errorf("NewPeriodSchedule: invalid anchor day %d", anchorDay)
+	}
+	return PeriodSchedule{Location: loc, AnchorDay: anchorDay}, nil
+}
+
+// boundary is the start of the period anchored in the given month. Month
+// overflows are normalized as by time.Date.
+func (s PeriodSchedule) boundary(year int, month time.Month) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, s.Location)
+	daysInMonth := first.AddDate(0, 1, -1).Day()
+	// Boundaries are computed from the calendar date rather than by adding
+	// durations, so that they stay at local midnight across DST transitions.
+	return time.Date(first.Year(), first.Month(), min(s.AnchorDay, daysInMonth), 0, 0, 0, 0, s.Location)
+}
+
+// PeriodContaining returns the period t falls in.
+func (s PeriodSchedule) PeriodContaining(t time.Time) Period {
+	local := t.In(s.Location)
+	year, month := local.Year(), local.Month()
+	start := s.boundary(year, month)
+	if t.Before(start) {
+		return Period{Start: s.boundary(year, month-1), End: start}
+	}
+	return Period{Start: start, End: s.boundary(year, month+1)}
+}
+
+// LastClosedPeriod returns the latest period that ended at or before t.
+func (s PeriodSchedule) LastClosedPeriod(t time.Time) Period {
+	current := s.PeriodContaining(t)
+	return s.PeriodContaining(current.Start.Add(-time.Nanosecond))
+}
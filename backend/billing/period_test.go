@@ -0,0 +1,149 @@
+package billing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"billbo.com/backend/database/dbtest"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+)
+
+func mustParse(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatalf("time.Parse: %v", err)
+	}
+	return parsed
+}
+
+func mustSchedule(t *testing.T, timezone string, anchorDay int) PeriodSchedule {
+	t.Helper()
+	schedule, err := NewPeriodSchedule(timezone, anchorDay)
+	if err != nil {
+		t.Fatalf("NewPeriodSchedule: %v", err)
+	}
+	return schedule
+}
+
+func TestPeriodContaining(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		timezone  string
+		anchorDay int
+		t         string
+		start     string
+		end       string
+	}{
+		{"anchor 31 in February of a leap year", "UTC", 31, "2024-02-15T12:00:00Z", "2024-01-31T00:00:00Z", "2024-02-29T00:00:00Z"},
+		{"anchor 31 from February 29", "UTC", 31, "2024-02-29T12:00:00Z", "2024-02-29T00:00:00Z", "2024-03-31T00:00:00Z"},
+		{"anchor 31 in February of a non-leap year", "UTC", 31, "2023-02-15T12:00:00Z", "2023-01-31T00:00:00Z", "2023-02-28T00:00:00Z"},
+		{"anchor 31 from February 28", "UTC", 31, "2023-03-30T12:00:00Z", "2023-02-28T00:00:00Z", "2023-03-31T00:00:00Z"},
+		{"anchor 31 in a 30-day month", "UTC", 31, "2023-04-30T12:00:00Z", "2023-04-30T00:00:00Z", "2023-05-31T00:00:00Z"},
+		{"anchor 30 across February", "UTC", 30, "2023-03-01T12:00:00Z", "2023-02-28T00:00:00Z", "2023-03-30T00:00:00Z"},
+		{"exactly at a boundary", "UTC", 31, "2023-02-28T00:00:00Z", "2023-02-28T00:00:00Z", "2023-03-31T00:00:00Z"},
+		{"just before a boundary", "UTC", 31, "2023-02-27T23:59:59Z", "2023-01-31T00:00:00Z", "2023-02-28T00:00:00Z"},
+		// Periods start at local midnight, EST (-05:00) or EDT (-04:00).
+		{"New York over spring forward", "America/New_York", 1, "2024-03-15T12:00:00Z", "2024-03-01T05:00:00Z", "2024-04-01T04:00:00Z"},
+		{"New York anchored on spring forward", "America/New_York", 10, "2024-03-10T12:00:00Z", "2024-03-10T05:00:00Z", "2024-04-10T04:00:00Z"},
+		{"New York over fall back", "America/New_York", 1, "2024-11-15T12:00:00Z", "2024-11-01T04:00:00Z", "2024-12-01T05:00:00Z"},
+		{"New York anchored on fall back", "America/New_York", 3, "2024-11-03T12:00:00Z", "2024-11-03T04:00:00Z", "2024-12-03T05:00:00Z"},
+		{"New York exactly at a boundary", "America/New_York", 1, "2024-04-01T04:00:00Z", "2024-04-01T04:00:00Z", "2024-05-01T04:00:00Z"},
+		{"New York just before a boundary", "America/New_York", 1, "2024-04-01T03:59:59Z", "2024-03-01T05:00:00Z", "2024-04-01T04:00:00Z"},
+		{"New York on the UTC day after a boundary", "America/New_York", 1, "2024-04-01T02:00:00Z", "2024-03-01T05:00:00Z", "2024-04-01T04:00:00Z"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			period := mustSchedule(t, tt.timezone, tt.anchorDay).PeriodContaining(mustParse(t, tt.t))
+			if start := mustParse(t, tt.start); !period.Start.Equal(start) {
+				t.Errorf("got start %v, want %v", period.Start.UTC(), start)
+			}
+			if end := mustParse(t, tt.end); !period.End.Equal(end) {
+				t.Errorf("got end %v, want %v", period.End.UTC(), end)
+			}
+		})
+	}
+}
+
+func TestLastClosedPeriod(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		timezone  string
+		anchorDay int
+		t         string
+		start     string
+		end       string
+	}{
+		{"anchor 31 after February", "UTC", 31, "2024-03-05T00:00:00Z", "2024-01-31T00:00:00Z", "2024-02-29T00:00:00Z"},
+		{"exactly at a boundary", "UTC", 31, "2024-02-29T00:00:00Z", "2024-01-31T00:00:00Z", "2024-02-29T00:00:00Z"},
+		{"New York after spring forward", "America/New_York", 1, "2024-04-01T04:00:00Z", "2024-03-01T05:00:00Z", "2024-04-01T04:00:00Z"},
+		{"New York across the year", "America/New_York", 1, "2024-01-01T04:59:59Z", "2023-11-01T04:00:00Z", "2023-12-01T05:00:00Z"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			period := mustSchedule(t, tt.timezone, tt.anchorDay).LastClosedPeriod(mustParse(t, tt.t))
+			if start := mustParse(t, tt.start); !period.Start.Equal(start) {
+				t.Errorf("got start %v, want %v", period.Start.UTC(), start)
+			}
+			if end := mustParse(t, tt.end); !period.End.Equal(end) {
+				t.Errorf("got end %v, want %v", period.End.UTC(), end)
+			}
+		})
+	}
+}
+
+func TestNewPeriodScheduleInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		timezone  string
+		anchorDay int
+	}{
+		{"UTC", 0},
+		{"UTC", 32},
+		{"Mars/Olympus_Mons", 1},
+	} {
+		if _, err := NewPeriodSchedule(tt.timezone, tt.anchorDay); err == nil {
+			t.Errorf("NewPeriodSchedule(%q, %d) succeeded", tt.timezone, tt.anchorDay)
+		}
+	}
+}
+
+func TestCustomerPeriodScheduleTimezone(t *testing.T) {
+	pool := dbtest.NewPool(t)
+	ctx := context.Background()
+	queries := sqlcgen.New(pool)
+
+	merchantID := dbtest.CreateMerchant(t, pool)
+	_, err := pool.Exec(ctx,
+		`INSERT INTO billing_settings (merchant_id, timezone, anchor_day) VALUES ($1, 'America/New_York', 15)`,
+		merchantID,
+	)
+	if err != nil {
+		t.Fatalf("insert billing settings: %v", err)
+	}
+	withTimezone, withoutTimezone := uuid.New(), uuid.New()
+	_, err = pool.Exec(ctx,
+		`INSERT INTO customers (id, merchant_id, name, timezone) VALUES ($1, $3, 'Tokyo customer', 'Asia/Tokyo'), ($2, $3, 'Customer', NULL)`,
+		withTimezone, withoutTimezone, merchantID,
+	)
+	if err != nil {
+		t.Fatalf("insert customers: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name       string
+		customerID uuid.UUID
+		timezone   string
+	}{
+		{"customer timezone", withTimezone, "Asia/Tokyo"},
+		{"merchant timezone", withoutTimezone, "America/New_York"},
+		{"unknown customer", uuid.New(), "America/New_York"},
+	} {
+		schedule, err := CustomerPeriodSchedule(ctx, queries, merchantID, tt.customerID)
+		if err != nil {
+			t.Fatalf("%s: CustomerPeriodSchedule: %v", tt.name, err)
+		}
+		if schedule.Location.String() != tt.timezone || schedule.AnchorDay != 15 {
+			t.Errorf("%s: got %s on day %d, want %s on day 15", tt.name, schedule.Location, schedule.AnchorDay, tt.timezone)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var ErrSpendCapReached = errors.New("customer spend cap reached")
+
+type ChargeSpendCapParams struct {
+	MerchantID uuid.UUID
+	CustomerID uuid.UUID
+	SkuID      uuid.UUID
+	Amount     float64
+	SentAt     time.Time
+}
+
+// SpendCapCharge is what ChargeSpendCap consumed, to refund it.
+type SpendCapCharge struct {
+	MerchantID  uuid.UUID
+	CustomerID  uuid.UUID
+	PeriodStart time.Time
+	Cost        float64
+}
+
+// ChargeSpendCap adds the cost of a usage event to the customer's spend cap,
+// if they have one, and returns ErrSpendCapReached when that would exceed
+// it. A per-period cap starts over with each billing period; other caps are
+// a prepaid balance the merchant tops up by raising the limit. Events of
+// past periods are not charged against per-period caps.
+// queries must be bound to the transaction inserting the event so that a
+// rejected or failed insert does not consume the cap. Otherwise, the
+// returned charge, nil if nothing was charged, is refunded with
+// RefundSpendCap when the event is not ingested.
+func ChargeSpendCap(ctx context.Context, queries *sqlcgen.Queries, p ChargeSpendCapParams) (*SpendCapCharge, error) {
+	merchantID := pgtype.UUID{Bytes: p.MerchantID, Valid: true}
+	customerID := pgtype.UUID{Bytes: p.CustomerID, Valid: true}
+
+	spendCap, err := queries.GetSpendCapForEvent(ctx, sqlcgen.GetSpendCapForEventParams{
+		SentAt:     pgtype.Timestamptz{Time: p.SentAt, Valid: true},
+		SkuID:      pgtype.UUID{Bytes: p.SkuID, Valid: true},
+		MerchantID: merchantID,
+		CustomerID: customerID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// No cap, or an unknown SKU that is not billed.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("queries.GetSpendCapForEvent: %w", err)
+	}
+
+	schedule, err := NewPeriodSchedule(spendCap.Timezone, int(spendCap.AnchorDay))
+	if err != nil {
+		return nil, fmt.Errorf("ChargeSpendCap: %w", err)
+	}
+	period := schedule.PeriodContaining(time.Now())
+	if spendCap.PerPeriod && p.SentAt.Before(period.Start) {
+		return nil, nil
+	}
+
+	cost := p.Amount * spendCap.UnitPrice
+	n, err := queries.ConsumeSpendCap(ctx, sqlcgen.ConsumeSpendCapParams{
+		PeriodStart: pgtype.Timestamptz{Time: period.Start, Valid: true},
+		Cost:        cost,
+		MerchantID:  merchantID,
+		CustomerID:  customerID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("queries.ConsumeSpendCap: %w", err)
+	}
+	if n == 0 {
+		return nil, ErrSpendCapReached
+	}
+	return &SpendCapCharge{
+		MerchantID:  p.MerchantID,
+		CustomerID:  p.CustomerID,
+		PeriodStart: period.Start,
+		Cost:        cost,
+	}, nil
+}
+
+// RefundSpendCap gives back a charge whose event ended up not ingested.
+func RefundSpendCap(ctx context.Context, queries *sqlcgen.Queries, charge *SpendCapCharge) error {
+	if charge == nil {
+		return nil
+	}
+	err := queries.RefundSpendCap(ctx, sqlcgen.RefundSpendCapParams{
+		Cost:        charge.Cost,
+		MerchantID:  pgtype.UUID{Bytes: charge.MerchantID, Valid: true},
+		CustomerID:  pgtype.UUID{Bytes: charge.CustomerID, Valid: true},
+		PeriodStart: pgtype.Timestamptz{Time: charge.PeriodStart, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("queries.RefundSpendCap: %w", err)
+	}
+	return nil
+}
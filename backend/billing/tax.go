@@ -0,0 +1,97 @@
+package billing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// TaxCalculator computes the tax lines of an invoice. The engine calls it
+// once usage and discount lines are known, with the queries of the
+// transaction building the invoice.
+type TaxCalculator interface {
+	CalculateTax(ctx context.Context, q *sqlcgen.Queries, in TaxInput) ([]Line, error)
+}
+
+// Address is a customer's billing address. Country is an ISO 3166-1 alpha-2
+// code; Region is free-form (state, province...).
+type Address struct {
+	Line1      string
+	Line2      string
+	City       string
+	PostalCode string
+	Region     string
+	Country    string
+}
+
+type TaxInput struct {
+	MerchantID uuid.UUID
+	CustomerID uuid.UUID
+	Address    Address
+	// TaxID is the customer's business tax identifier (e.g. a VAT number).
+	// Customers with a tax ID are considered B2B.
+	TaxID string
+	Lines []Line
+}
+
+// RuleBasedTaxCalculator applies the merchant's tax rates matching the
+// customer's country, and region if any. Country-wide and regional rates
+// stack. Reverse-charge rates are not collected from B2B customers: they
+// yield a zero-amount line instead, so the invoice states it.
+type RuleBasedTaxCalculator struct{}
+
+func NewRuleBasedTaxCalculator() *RuleBasedTaxCalculator {
+	return &RuleBasedTaxCalculator{}
+}
+
+func (c *RuleBasedTaxCalculator) CalculateTax(ctx context.Context, q *sqlcgen.Queries, in TaxInput) ([]Line, error) {
+	if in.Address.Country == "" {
+		return nil, nil
+	}
+
+	// Tax applies to the amount due after discounts.
+	var base float64
+	for _, line := range in.Lines {
+		base += line.Amount
+	}
+	if base <= 0 {
+		return nil, nil
+	}
+
+	var region pgtype.Text
+	if in.Address.Region != "" {
+		region = pgtype.Text{String: strings.ToUpper(in.Address.Region), Valid: true}
+	}
+	rates, err := q.ListApplicableTaxRates(ctx, sqlcgen.ListApplicableTaxRatesParams{
+		MerchantID: pgtype.UUID{Bytes: in.MerchantID, Valid: true},
+		Country:    in.Address.Country,
+		Region:     region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("queries.ListApplicableTaxRates: %w", err)
+	}
+
+	lines := make([]Line, len(rates))
+	for i, rate := range rates {
+		if rate.ReverseCharge && in.TaxID != "" {
+			lines[i] = Line{
+				Kind:        LineKindTax,
+				Description: fmt.Sprintf("%s (reverse charge)", rate.Name),
+				Amount:      0,
+				TaxRateID:   rate.ID,
+			}
+			continue
+		}
+		lines[i] = Line{
+			Kind:        LineKindTax,
+			Description: fmt.Sprintf("%s (%g%%)", rate.Name, rate.Percentage),
+			Amount:      roundCents(base * rate.Percentage / 100),
+			TaxRateID:   rate.ID,
+		}
+	}
+	return lines, nil
+}
@@ -0,0 +1,87 @@
+package billing
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"billbo.com/backend/database/dbtest"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestRuleBasedTaxCalculator(t *testing.T) {
+	pool := dbtest.NewPool(t)
+	ctx := context.Background()
+	queries := sqlcgen.New(pool)
+
+	merchantID := dbtest.CreateMerchant(t, pool)
+	for _, rate := range []sqlcgen.CreateTaxRateParams{
+		{Name: "FR VAT", Country: "FR", Percentage: 20},
+		{Name: "DE VAT", Country: "DE", Percentage: 19, ReverseCharge: true},
+		{Name: "US", Country: "US", Percentage: 5},
+		{Name: "US CA", Country: "US", Region: pgtype.Text{String: "CA", Valid: true}, Percentage: 7.25},
+	} {
+		rate.MerchantID = pgtype.UUID{Bytes: merchantID, Valid: true}
+		if _, err := queries.CreateTaxRate(ctx, rate); err != nil {
+			t.Fatalf("queries.CreateTaxRate: %v", err)
+		}
+	}
+	revoked, err := queries.CreateTaxRate(ctx, sqlcgen.CreateTaxRateParams{
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+		Name:       "FR revoked",
+		Country:    "FR",
+		Percentage: 5,
+	})
+	if err != nil {
+		t.Fatalf("queries.CreateTaxRate: %v", err)
+	}
+	err = queries.RevokeTaxRate(ctx, sqlcgen.RevokeTaxRateParams{ID: revoked.ID, MerchantID: revoked.MerchantID})
+	if err != nil {
+		t.Fatalf("queries.RevokeTaxRate: %v", err)
+	}
+
+	// 100 due after discounts.
+	lines := []Line{
+		{Kind: LineKindUsage, Amount: 120},
+		{Kind: LineKindDiscount, Amount: -20},
+	}
+	calculator := NewRuleBasedTaxCalculator()
+	for _, tt := range []struct {
+		name    string
+		address Address
+		taxID   string
+		lines   []Line
+		// want are the descriptions of the tax lines and their amounts.
+		want []string
+	}{
+		{name: "country", address: Address{Country: "FR"}, want: []string{"FR VAT (20%) 20"}},
+		{name: "no rate in the country", address: Address{Country: "GB"}, want: nil},
+		{name: "no country", address: Address{}, want: nil},
+		{name: "regional rates stack", address: Address{Country: "US", Region: "ca"}, want: []string{"US (5%) 5", "US CA (7.25%) 7.25"}},
+		{name: "other region", address: Address{Country: "US", Region: "NY"}, want: []string{"US (5%) 5"}},
+		{name: "reverse charge, B2C", address: Address{Country: "DE"}, want: []string{"DE VAT (19%) 19"}},
+		{name: "reverse charge, B2B", address: Address{Country: "DE"}, taxID: "DE123456789", want: []string{"DE VAT (reverse charge) 0"}},
+		{name: "nothing due", address: Address{Country: "FR"}, lines: []Line{{Kind: LineKindUsage, Amount: 10}, {Kind: LineKindDiscount, Amount: -10}}, want: nil},
+	} {
+		in := TaxInput{MerchantID: merchantID, Address: tt.address, TaxID: tt.taxID, Lines: lines}
+		if tt.lines != nil {
+			in.Lines = tt.lines
+		}
+		taxLines, err := calculator.CalculateTax(ctx, queries, in)
+		if err != nil {
+			t.Fatalf("%s: CalculateTax: %v", tt.name, err)
+		}
+		var got []string
+		for _, line := range taxLines {
+			if line.Kind != LineKindTax {
+				t.Errorf("%s: got a %s line", tt.name, line.Kind)
+			}
+			got = append(got, fmt.Sprintf("%s %g", line.Description, line.Amount))
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
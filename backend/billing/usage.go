@@ -0,0 +1,173 @@
+package billing
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"billbo.com/backend/database"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+const USAGE_DRIFT_TOLERANCE = 1e-6
+
+// CustomerPeriodSchedule returns the schedule of the customer's billing periods.
+func CustomerPeriodSchedule(ctx context.Context, queries *sqlcgen.Queries, merchantID, customerID uuid.UUID) (PeriodSchedule, error) {
+	settings, err := queries.GetBillingPeriodSettings(ctx, sqlcgen.GetBillingPeriodSettingsParams{
+		CustomerID: pgtype.UUID{Bytes: customerID, Valid: true},
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+	})
+	if err != nil {
+		return PeriodSchedule{}, fmt.Errorf("queries.GetBillingPeriodSettings: %w", err)
+	}
+	return NewPeriodSchedule(settings.Timezone, int(settings.AnchorDay))
+}
+
+type RecordUsageParams struct {
+	MerchantID uuid.UUID
+	CustomerID uuid.UUID
+	SkuID      uuid.UUID
+	Amount     float64
+	SentAt     time.Time
+	// Schedule is the schedule of the customer's billing periods, looked up
+	// if nil.
+	Schedule *PeriodSchedule
+}
+
+// RecordUsage adds a usage event to the running counter of its customer,
+// SKU and billing period. queries must be bound to the transaction inserting
+// the event.
+func RecordUsage(ctx context.Context, queries *sqlcgen.Queries, p RecordUsageParams) error {
+	if p.Schedule == nil {
+		schedule, err := CustomerPeriodSchedule(ctx, queries, p.MerchantID, p.CustomerID)
+		if err != nil {
+			return fmt.Errorf("CustomerPeriodSchedule: %w", err)
+		}
+		p.Schedule = &schedule
+	}
+	period := p.Schedule.PeriodContaining(p.SentAt)
+
+	err := queries.IncrementUsageCounter(ctx, sqlcgen.IncrementUsageCounterParams{
+		MerchantID:  pgtype.UUID{Bytes: p.MerchantID, Valid: true},
+		CustomerID:  pgtype.UUID{Bytes: p.CustomerID, Valid: true},
+		SkuID:       pgtype.UUID{Bytes: p.SkuID, Valid: true},
+		PeriodStart: pgtype.Timestamptz{Time: period.Start, Valid: true},
+		PeriodEnd:   pgtype.Timestamptz{Time: period.End, Valid: true},
+		Quantity:    p.Amount,
+	})
+	if err != nil {
+		return fmt.Errorf("queries.IncrementUsageCounter: %w", err)
+	}
+	return nil
+}
+
+// CurrentUsage returns the customer's current billing period and their usage
+// of each SKU over it, read from the running counters.
+func CurrentUsage(ctx context.Context, queries *sqlcgen.Queries, merchantID, customerID uuid.UUID) (Period, []*sqlcgen.ListUsageCountersRow, error) {
+	schedule, err := CustomerPeriodSchedule(ctx, queries, merchantID, customerID)
+	if err != nil {
+		return Period{}, nil, fmt.Errorf("CustomerPeriodSchedule: %w", err)
+	}
+	period := schedule.PeriodContaining(time.Now())
+
+	rows, err := queries.ListUsageCounters(ctx, sqlcgen.ListUsageCountersParams{
+		MerchantID:  pgtype.UUID{Bytes: merchantID, Valid: true},
+		CustomerID:  pgtype.UUID{Bytes: customerID, Valid: true},
+		PeriodStart: pgtype.Timestamptz{Time: period.Start, Valid: true},
+	})
+	if err != nil {
+		return Period{}, nil, fmt.Errorf("queries.ListUsageCounters: %w", err)
+	}
+	return period, rows, nil
+}
+
+// UsageReconciler corrects running usage counters that drifted from the raw
+// events, e.g. after events were deleted.
+type UsageReconciler struct {
+	logger  *zap.Logger
+	pool    *pgxpool.Pool
+	queries *sqlcgen.Queries
+}
+
+func NewUsageReconciler(logger *zap.Logger, pool *pgxpool.Pool) *UsageReconciler {
+	return &UsageReconciler{
+		logger:  logger.With(zap.String("component", "billing")),
+		pool:    pool,
+		queries: sqlcgen.New(pool),
+	}
+}
+
+// ReconcileRecent recomputes the counters of current periods from the
+// events. The counters of closed periods are not reconciled, as the events
+// of their months may have been dropped once archived.
+func (r *UsageReconciler) ReconcileRecent(ctx context.Context) error {
+	counters, err := r.queries.ListUsageCountersToReconcile(ctx)
+	if err != nil {
+		return fmt.Errorf("queries.ListUsageCountersToReconcile: %w", err)
+	}
+
+	for _, counter := range counters {
+		if err := r.reconcile(ctx, counter); err != nil {
+			return fmt.Errorf("reconcile: %w", err)
+		}
+	}
+	return nil
+}
+
+// reconcile locks the counter before summing the events: ingest transactions
+// increment the counter after inserting their event, so an event is either
+// committed and summed, or its increment waits for the lock and applies on
+// top of the reconciled value.
+func (r *UsageReconciler) reconcile(ctx context.Context, key *sqlcgen.ListUsageCountersToReconcileRow) error {
+	return database.InTx(ctx, r.pool, func(q *sqlcgen.Queries) error {
+		counter, err := q.LockUsageCounter(ctx, sqlcgen.LockUsageCounterParams{
+			MerchantID:  key.MerchantID,
+			CustomerID:  key.CustomerID,
+			PeriodStart: key.PeriodStart,
+			SkuID:       key.SkuID,
+		})
+		if err != nil {
+			return fmt.Errorf("queries.LockUsageCounter: %w", err)
+		}
+
+		usage, err := q.AggregateUsage(ctx, sqlcgen.AggregateUsageParams{
+			MerchantID:  counter.MerchantID,
+			CustomerID:  counter.CustomerID,
+			SkuID:       counter.SkuID,
+			PeriodStart: counter.PeriodStart,
+			PeriodEnd:   counter.PeriodEnd,
+		})
+		if err != nil {
+			return fmt.Errorf("queries.AggregateUsage: %w", err)
+		}
+		// Sums of floats depend on their order: only report real drifts.
+		if math.Abs(usage.Quantity-counter.Quantity) > USAGE_DRIFT_TOLERANCE || usage.EventCount != counter.EventCount {
+			r.logger.Warn("usage counter drifted",
+				zap.String("merchant_id", counter.MerchantID.String()),
+				zap.String("customer_id", counter.CustomerID.String()),
+				zap.String("sku_id", counter.SkuID.String()),
+				zap.Time("period_start", counter.PeriodStart.Time),
+				zap.Float64("counted", counter.Quantity),
+				zap.Float64("actual", usage.Quantity),
+			)
+		}
+
+		err = q.SetUsageCounter(ctx, sqlcgen.SetUsageCounterParams{
+			MerchantID:  counter.MerchantID,
+			CustomerID:  counter.CustomerID,
+			PeriodStart: counter.PeriodStart,
+			SkuID:       counter.SkuID,
+			Quantity:    usage.Quantity,
+			EventCount:  usage.EventCount,
+		})
+		if err != nil {
+			return fmt.Errorf("queries.SetUsageCounter: %w", err)
+		}
+		return nil
+	})
+}
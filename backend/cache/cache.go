@@ -0,0 +1,215 @@
+// Package cache caches database lookups in memory, invalidated through
+// Postgres LISTEN/NOTIFY.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"expvar"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+const RECONNECT_DELAY = 5 * time.Second
+
+type entry[V any] struct {
+	key      string
+	value    V
+	cachedAt time.Time
+}
+
+// Cache caches values for up to ttl and at most size keys, evicting the
+// least recently used. Hits, misses and evictions are published with expvar
+// as <name>_hits, <name>_misses and <name>_evictions, along with
+// <name>_hit_rate.
+type Cache[V any] struct {
+	logger *zap.Logger
+	ttl    time.Duration
+	size   int
+
+	hits      *expvar.Int
+	misses    *expvar.Int
+	evictions *expvar.Int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// lru lists the entries, most recently used first.
+	lru *list.List
+	// generation is incremented by invalidations, so that a value looked up
+	// before one is not cached after it.
+	generation uint64
+}
+
+func New[V any](logger *zap.Logger, name string, ttl time.Duration, size int) *Cache[V] {
+	c := &Cache[V]{
+		logger:    logger.With(zap.String("component", name)),
+		ttl:       ttl,
+		size:      size,
+		hits:      counter(name + "_hits"),
+		misses:    counter(name + "_misses"),
+		evictions: counter(name + "_evictions"),
+		entries:   make(map[string]*list.Element),
+		lru:       list.New(),
+	}
+	if expvar.Get(name+"_hit_rate") == nil {
+		expvar.Publish(name+"_hit_rate", expvar.Func(func() any {
+			hits, misses := c.hits.Value(), c.misses.Value()
+			if hits+misses == 0 {
+				return 0.0
+			}
+			return float64(hits) / float64(hits+misses)
+		}))
+	}
+	return c
+}
+
+// counter returns the expvar counter of the given name, shared by the caches
+// of that name.
+func counter(name string) *expvar.Int {
+	if v, ok := expvar.Get(name).(*expvar.Int); ok {
+		return v
+	}
+	return expvar.NewInt(name)
+}
+
+// Get returns the value of key, from the cache or else from fetch. Errors
+// are not cached.
+func (c *Cache[V]) Get(key string, fetch func() (V, error)) (V, error) {
+	value, generation, ok := c.lookup(key)
+	if ok {
+		c.hits.Add(1)
+		return value, nil
+	}
+	c.misses.Add(1)
+
+	value, err := fetch()
+	if err != nil {
+		return value, err
+	}
+	c.store(key, value, generation)
+	return value, nil
+}
+
+func (c *Cache[V]) lookup(key string) (V, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	elem, ok := c.entries[key]
+	if !ok {
+		return zero, c.generation, false
+	}
+	e := elem.Value.(*entry[V])
+	if time.Since(e.cachedAt) > c.ttl {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+		return zero, c.generation, false
+	}
+	c.lru.MoveToFront(elem)
+	return e.value, c.generation, true
+}
+
+func (c *Cache[V]) store(key string, value V, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	if elem, ok := c.entries[key]; ok {
+		c.lru.Remove(elem)
+	}
+	c.entries[key] = c.lru.PushFront(&entry[V]{key: key, value: value, cachedAt: time.Now()})
+
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry[V]).key)
+		c.evictions.Add(1)
+	}
+}
+
+// Invalidate removes key from the cache.
+func (c *Cache[V]) Invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	if elem, ok := c.entries[key]; ok {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+	}
+}
+
+// InvalidatePrefix removes the keys starting with prefix from the cache.
+func (c *Cache[V]) InvalidatePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for key, elem := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.lru.Remove(elem)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Clear empties the cache.
+func (c *Cache[V]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+}
+
+// Listen calls invalidate with the payload of every notification on channel
+// until ctx is canceled. Notifications may be missed while disconnected, so
+// the cache is cleared on every (re)connection: the ttl only bounds
+// staleness while the listener is disconnected.
+func (c *Cache[V]) Listen(ctx context.Context, pool *pgxpool.Pool, channel string, invalidate func(payload string)) error {
+	for {
+		err := c.listen(ctx, pool, channel, invalidate)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		c.logger.Error("cache listener disconnected", zap.String("channel", channel), zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(RECONNECT_DELAY):
+		}
+	}
+}
+
+func (c *Cache[V]) listen(ctx context.Context, pool *pgxpool.Pool, channel string, invalidate func(payload string)) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("pool.Acquire: %w", err)
+	}
+	// The connection is left in LISTEN state, so it is closed rather than
+	// returned to the pool.
+	listener := conn.Hijack()
+	defer listener.Close(context.Background())
+
+	if _, err := listener.Exec(ctx, "LISTEN "+channel); err != nil {
+		return fmt.Errorf("listener.Exec: %w", err)
+	}
+	c.Clear()
+
+	for {
+		notification, err := listener.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("listener.WaitForNotification: %w", err)
+		}
+		invalidate(notification.Payload)
+	}
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func fetchValue(value int) func() (int, error) {
+	return func() (int, error) { return value, nil }
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := New[int](zap.NewNop(), "test_cache", time.Hour, 2)
+	c.Get("a", fetchValue(1))
+	c.Get("b", fetchValue(2))
+	// a is used again, so b is evicted by c.
+	c.Get("a", fetchValue(-1))
+	c.Get("c", fetchValue(3))
+
+	if v, _ := c.Get("a", fetchValue(-1)); v != 1 {
+		t.Errorf("a = %d, want cached 1", v)
+	}
+	if v, _ := c.Get("b", fetchValue(-2)); v != -2 {
+		t.Errorf("b = %d, want fetched -2", v)
+	}
+}
+
+func TestCacheExpires(t *testing.T) {
+	c := New[int](zap.NewNop(), "test_cache", time.Millisecond, 10)
+	c.Get("a", fetchValue(1))
+	time.Sleep(2 * time.Millisecond)
+
+	if v, _ := c.Get("a", fetchValue(2)); v != 2 {
+		t.Errorf("a = %d, want fetched 2", v)
+	}
+}
+
+func TestCacheDoesNotCacheErrors(t *testing.T) {
+	c := New[int](zap.NewNop(), "test_cache", time.Hour, 10)
+	if _, err := c.Get("a", func() (int, error) { return 0, errors.New("failed") }); err == nil {
+		t.Fatal("Get: got no error")
+	}
+	if v, _ := c.Get("a", fetchValue(1)); v != 1 {
+		t.Errorf("a = %d, want fetched 1", v)
+	}
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	c := New[int](zap.NewNop(), "test_cache", time.Hour, 10)
+	c.Get("m1:a", fetchValue(1))
+	c.Get("m1:b", fetchValue(2))
+	c.Get("m2:a", fetchValue(3))
+
+	c.Invalidate("m1:a")
+	if v, _ := c.Get("m1:a", fetchValue(10)); v != 10 {
+		t.Errorf("m1:a = %d, want fetched 10", v)
+	}
+
+	c.InvalidatePrefix("m1:")
+	if v, _ := c.Get("m1:b", fetchValue(20)); v != 20 {
+		t.Errorf("m1:b = %d, want fetched 20", v)
+	}
+	if v, _ := c.Get("m2:a", fetchValue(30)); v != 3 {
+		t.Errorf("m2:a = %d, want cached 3", v)
+	}
+}
+
+func TestCacheDoesNotStoreValuesFetchedBeforeAnInvalidation(t *testing.T) {
+	c := New[int](zap.NewNop(), "test_cache", time.Hour, 10)
+	c.Get("a", func() (int, error) {
+		// The value changes while it is being fetched.
+		c.Invalidate("a")
+		return 1, nil
+	})
+
+	if v, _ := c.Get("a", fetchValue(2)); v != 2 {
+		t.Errorf("a = %d, want fetched 2", v)
+	}
+}
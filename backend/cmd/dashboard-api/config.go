@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 
+	"billbo.com/backend/archive"
+	"billbo.com/backend/payments/providers"
 	"github.com/sethvargo/go-envconfig"
 )
 
@@ -11,6 +13,12 @@ type Config struct {
 	DatabaseURL string `env:"DATABASE_URL,required"`
 	JWTSecret   string `env:"JWT_SECRET,required"`
 	Port        int    `env:"PORT,default=8080"`
+	// APIKeyPepper keys the HMAC API keys are stored as. It must match the
+	// ingest API's.
+	APIKeyPepper string `env:"API_KEY_PEPPER,required"`
+
+	providers.Config
+	archive.BlobStoreConfig
 }
 
 func NewConfig(ctx context.Context) (Config, error) {
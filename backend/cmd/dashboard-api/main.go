@@ -13,10 +13,27 @@ import (
 	"billbo.com/backend/api"
 	"billbo.com/backend/api/dashboard/apikeys"
 	"billbo.com/backend/api/dashboard/auth"
+	"billbo.com/backend/api/dashboard/billingsettings"
+	"billbo.com/backend/api/dashboard/coupons"
+	"billbo.com/backend/api/dashboard/customers"
+	"billbo.com/backend/api/dashboard/dunningsettings"
+	"billbo.com/backend/api/dashboard/eventarchives"
 	"billbo.com/backend/api/dashboard/events"
+	"billbo.com/backend/api/dashboard/invoices"
+	"billbo.com/backend/api/dashboard/invoicesettings"
+	"billbo.com/backend/api/dashboard/ratelimits"
 	"billbo.com/backend/api/dashboard/skus"
+	"billbo.com/backend/api/dashboard/taxrates"
+	"billbo.com/backend/api/dashboard/testdata"
+	"billbo.com/backend/api/dashboard/usage"
+	"billbo.com/backend/api/dashboard/usagealerts"
+	"billbo.com/backend/api/dashboard/webhookendpoints"
+	"billbo.com/backend/api/webhooks"
+	"billbo.com/backend/archive"
+	"billbo.com/backend/billing"
 	"billbo.com/backend/database"
 	"billbo.com/backend/database/sqlcgen"
+	"billbo.com/backend/payments"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"go.uber.org/zap"
@@ -58,6 +75,8 @@ func main() {
 
 	// API
 	v1 := e.Group("/api/v1")
+	// Requests in test mode are served the data of the test merchant.
+	modeMiddleware := auth.ModeMiddleware(queries)
 
 	// Auth API
 	authHandler := auth.NewAuthHandler(logger, queries, []byte(cfg.JWTSecret))
@@ -65,19 +84,101 @@ func main() {
 
 	// Events API
 	eventHandler := events.NewEventHandler(logger, queries)
-	eventsGroup := v1.Group("/events", auth.JWTMiddleware([]byte(cfg.JWTSecret)))
+	eventsGroup := v1.Group("/events", auth.JWTMiddleware([]byte(cfg.JWTSecret)), modeMiddleware)
 	eventHandler.Routes(eventsGroup)
 
+	// Usage API
+	usageHandler := usage.NewUsageHandler(logger, queries)
+	usageGroup := v1.Group("/usage", auth.JWTMiddleware([]byte(cfg.JWTSecret)), modeMiddleware)
+	usageHandler.Routes(usageGroup)
+
 	// API Keys API
-	apiKeyHandler := apikeys.NewAPIKeyHandler(logger, queries)
-	apiKeysGroup := v1.Group("/api-keys", auth.JWTMiddleware([]byte(cfg.JWTSecret)))
+	apiKeyHandler := apikeys.NewAPIKeyHandler(logger, queries, pool, []byte(cfg.APIKeyPepper))
+	apiKeysGroup := v1.Group("/api-keys", auth.JWTMiddleware([]byte(cfg.JWTSecret)), modeMiddleware)
 	apiKeyHandler.Routes(apiKeysGroup)
 
+	// Rate limits API
+	rateLimitHandler := ratelimits.NewRateLimitHandler(logger, queries, pool)
+	rateLimitsGroup := v1.Group("/rate-limits", auth.JWTMiddleware([]byte(cfg.JWTSecret)), modeMiddleware)
+	rateLimitHandler.Routes(rateLimitsGroup)
+
 	// SKUs API
-	skuHandler := skus.NewSKUHandler(logger, queries)
-	skusGroup := v1.Group("/skus", auth.JWTMiddleware([]byte(cfg.JWTSecret)))
+	skuHandler := skus.NewSKUHandler(logger, queries, pool)
+	skusGroup := v1.Group("/skus", auth.JWTMiddleware([]byte(cfg.JWTSecret)), modeMiddleware)
 	skuHandler.Routes(skusGroup)
 
+	// Customers API
+	customerHandler := customers.NewCustomerHandler(logger, queries, pool)
+	customersGroup := v1.Group("/customers", auth.JWTMiddleware([]byte(cfg.JWTSecret)), modeMiddleware)
+	customerHandler.Routes(customersGroup)
+
+	// Tax rates API
+	taxRateHandler := taxrates.NewTaxRateHandler(logger, queries)
+	taxRatesGroup := v1.Group("/tax-rates", auth.JWTMiddleware([]byte(cfg.JWTSecret)), modeMiddleware)
+	taxRateHandler.Routes(taxRatesGroup)
+
+	// Coupons API
+	couponHandler := coupons.NewCouponHandler(logger, queries)
+	couponsGroup := v1.Group("/coupons", auth.JWTMiddleware([]byte(cfg.JWTSecret)), modeMiddleware)
+	couponHandler.Routes(couponsGroup)
+
+	// Payments
+	paymentProvider, err := cfg.NewPaymentProvider()
+	if err != nil {
+		logger.Fatal("cfg.NewPaymentProvider", zap.Error(err))
+	}
+	collector := payments.NewCollector(logger, queries, paymentProvider, cfg.PaymentCurrency)
+	webhookProcessor := payments.NewWebhookProcessor(logger, pool, collector)
+
+	// Webhooks API, public: requests are authenticated by their signature
+	webhookHandler := webhooks.NewWebhookHandler(logger, webhookProcessor)
+	webhookHandler.Routes(v1.Group("/webhooks"))
+
+	// Invoices API
+	engine := billing.NewEngine(logger, pool, billing.NewRuleBasedTaxCalculator())
+	invoiceHandler := invoices.NewInvoiceHandler(logger, queries, engine, collector)
+	invoicesGroup := v1.Group("/invoices", auth.JWTMiddleware([]byte(cfg.JWTSecret)), modeMiddleware)
+	invoiceHandler.Routes(invoicesGroup)
+
+	// Invoice settings API
+	invoiceSettingsHandler := invoicesettings.NewInvoiceSettingsHandler(logger, queries)
+	invoiceSettingsGroup := v1.Group("/invoice-settings", auth.JWTMiddleware([]byte(cfg.JWTSecret)), modeMiddleware)
+	invoiceSettingsHandler.Routes(invoiceSettingsGroup)
+
+	// Billing settings API
+	billingSettingsHandler := billingsettings.NewBillingSettingsHandler(logger, queries, pool)
+	billingSettingsGroup := v1.Group("/billing-settings", auth.JWTMiddleware([]byte(cfg.JWTSecret)), modeMiddleware)
+	billingSettingsHandler.Routes(billingSettingsGroup)
+
+	// Dunning settings API
+	dunningSettingsHandler := dunningsettings.NewDunningSettingsHandler(logger, queries)
+	dunningSettingsGroup := v1.Group("/dunning-settings", auth.JWTMiddleware([]byte(cfg.JWTSecret)), modeMiddleware)
+	dunningSettingsHandler.Routes(dunningSettingsGroup)
+
+	// Usage alerts API
+	usageAlertHandler := usagealerts.NewUsageAlertHandler(logger, queries)
+	usageAlertsGroup := v1.Group("/usage-alerts", auth.JWTMiddleware([]byte(cfg.JWTSecret)), modeMiddleware)
+	usageAlertHandler.Routes(usageAlertsGroup)
+
+	// Webhook endpoints API
+	webhookEndpointHandler := webhookendpoints.NewWebhookEndpointHandler(logger, queries)
+	webhookEndpointsGroup := v1.Group("/webhook-endpoints", auth.JWTMiddleware([]byte(cfg.JWTSecret)), modeMiddleware)
+	webhookEndpointHandler.Routes(webhookEndpointsGroup)
+
+	// Event archives API
+	archiveStore, err := cfg.NewBlobStore()
+	if err != nil {
+		logger.Fatal("cfg.NewBlobStore", zap.Error(err))
+	}
+	eventArchiveHandler := eventarchives.NewEventArchiveHandler(logger, queries, archive.NewArchiver(logger, pool, archiveStore))
+	eventArchivesGroup := v1.Group("/event-archives", auth.JWTMiddleware([]byte(cfg.JWTSecret)), modeMiddleware)
+	eventArchiveHandler.Routes(eventArchivesGroup)
+
+	// Test data API
+	testDataHandler := testdata.NewTestDataHandler(logger, queries, pool, archiveStore)
+	testDataGroup := v1.Group("/test-data", auth.JWTMiddleware([]byte(cfg.JWTSecret)), modeMiddleware)
+	testDataHandler.Routes(testDataGroup)
+
 	// Start server
 	errGrp, ctx := errgroup.WithContext(ctx)
 
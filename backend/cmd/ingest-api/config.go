@@ -3,13 +3,54 @@ package main
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/sethvargo/go-envconfig"
 )
 
+const (
+	INGEST_MODE_SYNC  = "sync"
+	INGEST_MODE_ASYNC = "async"
+)
+
 type Config struct {
 	DatabaseURL string `env:"DATABASE_URL,required"`
 	Port        int    `env:"PORT,default=9876"`
+	// DebugAddr is the internal address metrics are served on, at
+	// /debug/vars, apart from the public API. Empty disables it.
+	DebugAddr string `env:"DEBUG_ADDR,default=localhost:9877"`
+
+	// IngestMode is either "sync", events are inserted before responding
+	// 201, or "async", events are appended to a write-ahead log in
+	// IngestWALDir, acknowledged with 202 and flushed in batches.
+	IngestMode          string        `env:"INGEST_MODE,default=sync"`
+	IngestWALDir        string        `env:"INGEST_WAL_DIR,default=wal"`
+	IngestBufferSize    int           `env:"INGEST_BUFFER_SIZE,default=10000"`
+	IngestBatchSize     int           `env:"INGEST_BATCH_SIZE,default=1000"`
+	IngestFlushInterval time.Duration `env:"INGEST_FLUSH_INTERVAL,default=200ms"`
+
+	// APIKeyPepper keys the HMAC API keys are stored as. It must match the
+	// dashboard API's.
+	APIKeyPepper    string        `env:"API_KEY_PEPPER,required"`
+	APIKeyCacheTTL  time.Duration `env:"API_KEY_CACHE_TTL,default=5m"`
+	APIKeyCacheSize int           `env:"API_KEY_CACHE_SIZE,default=10000"`
+	// The customer cache holds whether the customers' ingestion is suspended
+	// and their billing period schedule.
+	CustomerCacheTTL  time.Duration `env:"CUSTOMER_CACHE_TTL,default=5m"`
+	CustomerCacheSize int           `env:"CUSTOMER_CACHE_SIZE,default=10000"`
+	// APIKeyLastUsedFlushInterval is how often the last use of the keys is
+	// written to the database.
+	APIKeyLastUsedFlushInterval time.Duration `env:"API_KEY_LAST_USED_FLUSH_INTERVAL,default=1m"`
+	// SignatureTolerance is how far from now the timestamp of signed
+	// requests may be. Their nonces are remembered for as long.
+	SignatureTolerance time.Duration `env:"SIGNATURE_TOLERANCE,default=5m"`
+
+	// Rate limits of the keys and merchants without limits of their own, in
+	// requests per second.
+	KeyRateLimit      int `env:"KEY_RATE_LIMIT,default=100"`
+	KeyRateBurst      int `env:"KEY_RATE_BURST,default=200"`
+	MerchantRateLimit int `env:"MERCHANT_RATE_LIMIT,default=1000"`
+	MerchantRateBurst int `env:"MERCHANT_RATE_BURST,default=2000"`
 }
 
 func NewConfig(ctx context.Context) (Config, error) {
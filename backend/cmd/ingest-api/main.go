@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"errors"
+	"expvar"
 	"fmt"
 	"net/http"
 	"os"
@@ -13,8 +14,12 @@ import (
 	"billbo.com/backend/api"
 	ingestauth "billbo.com/backend/api/ingest/auth"
 	"billbo.com/backend/api/ingest/events"
+	"billbo.com/backend/api/ingest/ratelimit"
+	"billbo.com/backend/api/ingest/usage"
+	"billbo.com/backend/apikey"
 	"billbo.com/backend/database"
 	"billbo.com/backend/database/sqlcgen"
+	"billbo.com/backend/writebehind"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"go.uber.org/zap"
@@ -46,6 +51,51 @@ func main() {
 	defer pool.Close()
 	queries := sqlcgen.New(pool)
 
+	errGrp, ctx := errgroup.WithContext(ctx)
+
+	// Write-behind pipeline
+	var pipeline *writebehind.Pipeline
+	switch cfg.IngestMode {
+	case INGEST_MODE_SYNC:
+	case INGEST_MODE_ASYNC:
+		wal, records, err := writebehind.OpenWAL(cfg.IngestWALDir)
+		if err != nil {
+			logger.Fatal("writebehind.OpenWAL", zap.Error(err))
+		}
+		defer wal.Close()
+		pipeline = writebehind.NewPipeline(logger, pool, wal, writebehind.Options{
+			BufferSize:    cfg.IngestBufferSize,
+			BatchSize:     cfg.IngestBatchSize,
+			FlushInterval: cfg.IngestFlushInterval,
+		})
+		if err := pipeline.Replay(ctx, records); err != nil {
+			logger.Fatal("pipeline.Replay", zap.Error(err))
+		}
+		errGrp.Go(func() error {
+			return pipeline.Run(ctx)
+		})
+	default:
+		logger.Fatal("unknown ingest mode", zap.String("ingest_mode", cfg.IngestMode))
+	}
+
+	// API key cache
+	apiKeyCache := ingestauth.NewAPIKeyCache(logger, cfg.APIKeyCacheTTL, cfg.APIKeyCacheSize)
+	errGrp.Go(func() error {
+		return apiKeyCache.Listen(ctx, pool)
+	})
+
+	// Customer cache
+	customerCache := events.NewCustomerCache(logger, cfg.CustomerCacheTTL, cfg.CustomerCacheSize)
+	errGrp.Go(func() error {
+		return customerCache.Listen(ctx, pool)
+	})
+
+	// API key last use tracking
+	apiKeyLastUsed := ingestauth.NewLastUsedTracker(logger, queries, cfg.APIKeyLastUsedFlushInterval)
+	errGrp.Go(func() error {
+		return apiKeyLastUsed.Run(ctx)
+	})
+
 	// Echo instance
 	e := echo.New()
 	e.Binder = api.NewValidatingBinder()
@@ -56,15 +106,26 @@ func main() {
 
 	// API
 	v1 := e.Group("/api/v1")
+	// Signed requests are authenticated by signatureMiddleware, the others
+	// by apiKeyMiddleware.
+	signatureMiddleware := ingestauth.SignatureMiddleware(queries, apiKeyCache, apiKeyLastUsed, ingestauth.NewNonceStore(queries), cfg.SignatureTolerance)
+	apiKeyMiddleware := ingestauth.APIKeyMiddleware(queries, apiKeyCache, apiKeyLastUsed, apikey.NewHasher([]byte(cfg.APIKeyPepper)))
+	rateLimitMiddleware := ratelimit.Middleware(ratelimit.NewLimiter(), ratelimit.Defaults{
+		Key:      ratelimit.Limit{PerSecond: cfg.KeyRateLimit, Burst: cfg.KeyRateBurst},
+		Merchant: ratelimit.Limit{PerSecond: cfg.MerchantRateLimit, Burst: cfg.MerchantRateBurst},
+	})
 
 	// Events API
-	eventHandler := events.NewEventHandler(logger, queries)
-	eventsGroup := v1.Group("/events", ingestauth.APIKeyMiddleware(queries))
+	eventHandler := events.NewEventHandler(logger, queries, pool, customerCache, pipeline)
+	eventsGroup := v1.Group("/events", signatureMiddleware, apiKeyMiddleware, rateLimitMiddleware)
 	eventHandler.Routes(eventsGroup)
 
-	// Start server
-	errGrp, ctx := errgroup.WithContext(ctx)
+	// Usage API
+	usageHandler := usage.NewUsageHandler(logger, queries)
+	usageGroup := v1.Group("/usage", signatureMiddleware, apiKeyMiddleware, rateLimitMiddleware)
+	usageHandler.Routes(usageGroup)
 
+	// Start server
 	errGrp.Go(func() error {
 		err := e.Start("localhost:" + fmt.Sprint(cfg.Port))
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -73,6 +134,24 @@ func main() {
 		return nil
 	})
 
+	// Metrics, such as the cache hit rates, on an internal listener
+	if cfg.DebugAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/debug/vars", expvar.Handler())
+		debugServer := &http.Server{Addr: cfg.DebugAddr, Handler: mux}
+		errGrp.Go(func() error {
+			err := debugServer.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return fmt.Errorf("debugServer.ListenAndServe: %w", err)
+			}
+			return nil
+		})
+		errGrp.Go(func() error {
+			<-ctx.Done()
+			return debugServer.Close()
+		})
+	}
+
 	errGrp.Go(func() error {
 		<-ctx.Done()
 		gracePeriod := time.Minute
@@ -4,8 +4,6 @@ import (
 	"bytes"
 	"context"
 	"crypto/rand"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -16,6 +14,9 @@ import (
 	"syscall"
 	"time"
 
+	"billbo.com/backend/api/dashboard/auth"
+	ingestauth "billbo.com/backend/api/ingest/auth"
+	"billbo.com/backend/apikey"
 	"billbo.com/backend/database"
 	"billbo.com/backend/database/sqlcgen"
 	"github.com/google/uuid"
@@ -25,7 +26,8 @@ import (
 )
 
 type Config struct {
-	DatabaseURL string `env:"DATABASE_URL,required"`
+	DatabaseURL  string `env:"DATABASE_URL,required"`
+	APIKeyPepper string `env:"API_KEY_PEPPER,required"`
 }
 
 type Event struct {
@@ -76,18 +78,20 @@ func main() {
 	}
 
 	// Create a temporary API key
-	rawKey, err := generateAPIKey()
+	keyID := uuid.New()
+	rawKey, err := apikey.Generate(auth.MODE_LIVE, keyID)
 	if err != nil {
 		log.Fatalf("generate API key: %v", err)
 	}
-	keyHash := hashKey(rawKey)
-	keyPrefix := rawKey[:11]
+	keyPrefix := apikey.Prefix(rawKey)
 
 	apiKey, err := queries.CreateAPIKey(ctx, sqlcgen.CreateAPIKeyParams{
+		ID:         pgtype.UUID{Bytes: keyID, Valid: true},
 		MerchantID: merchantID,
 		Name:       "playground-temp",
 		KeyPrefix:  keyPrefix,
-		KeyHash:    keyHash,
+		KeyHash:    apikey.NewHasher([]byte(cfg.APIKeyPepper)).Hash(rawKey),
+		Scopes:     []string{ingestauth.SCOPE_EVENTS_WRITE},
 	})
 	if err != nil {
 		log.Fatalf("create API key: %v", err)
@@ -96,7 +100,7 @@ func main() {
 
 	// Revoke the temp key on exit
 	defer func() {
-		err := queries.RevokeAPIKey(ctx, sqlcgen.RevokeAPIKeyParams{
+		_, err := queries.RevokeAPIKey(ctx, sqlcgen.RevokeAPIKeyParams{
 			ID:         apiKey.ID,
 			MerchantID: merchantID,
 		})
@@ -163,19 +167,6 @@ func main() {
 	}
 }
 
-func generateAPIKey() (string, error) {
-	b := make([]byte, 16)
-	if _, err := rand.Read(b); err != nil {
-		return "", fmt.Errorf("generateAPIKey: %w", err)
-	}
-	return "bb_" + hex.EncodeToString(b), nil
-}
-
-func hashKey(rawKey string) string {
-	h := sha256.Sum256([]byte(rawKey))
-	return hex.EncodeToString(h[:])
-}
-
 func randIntn(n int) int {
 	v, _ := rand.Int(rand.Reader, big.NewInt(int64(n)))
 	return int(v.Int64())
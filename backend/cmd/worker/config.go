@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"billbo.com/backend/archive"
+	"billbo.com/backend/database/sqlcgen"
+	"billbo.com/backend/jobs"
+	"billbo.com/backend/jobs/temporal"
+	"billbo.com/backend/payments/providers"
+	"github.com/sethvargo/go-envconfig"
+	"go.temporal.io/sdk/client"
+	"go.uber.org/zap"
+)
+
+const (
+	JOB_BACKEND_POSTGRES = "postgres"
+	JOB_BACKEND_TEMPORAL = "temporal"
+)
+
+type Config struct {
+	DatabaseURL string `env:"DATABASE_URL,required"`
+
+	// JobBackend is either "postgres", a job queue in the application
+	// database, or "temporal".
+	JobBackend        string `env:"JOB_BACKEND,default=postgres"`
+	WorkerConcurrency int    `env:"WORKER_CONCURRENCY,default=4"`
+	TemporalHostPort  string `env:"TEMPORAL_HOST_PORT,default=localhost:7233"`
+	TemporalNamespace string `env:"TEMPORAL_NAMESPACE,default=default"`
+	TemporalTaskQueue string `env:"TEMPORAL_TASK_QUEUE,default=billbo"`
+
+	providers.Config
+	archive.BlobStoreConfig
+}
+
+func NewConfig(ctx context.Context) (Config, error) {
+	var cfg Config
+	if err := envconfig.Process(ctx, &cfg); err != nil {
+		return Config{}, fmt.Errorf("NewConfig: %w", err)
+	}
+	return cfg, nil
+}
+
+// NewJobRunner returns the configured runner, and a function releasing its
+// resources.
+func (cfg Config) NewJobRunner(logger *zap.Logger, queries *sqlcgen.Queries) (jobs.Runner, func(), error) {
+	switch cfg.JobBackend {
+	case JOB_BACKEND_POSTGRES:
+		return jobs.NewPostgresRunner(logger, queries, cfg.WorkerConcurrency), func() {}, nil
+	case JOB_BACKEND_TEMPORAL:
+		c, err := client.Dial(client.Options{
+			HostPort:  cfg.TemporalHostPort,
+			Namespace: cfg.TemporalNamespace,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("NewJobRunner: client.Dial: %w", err)
+		}
+		return temporal.NewRunner(logger, c, cfg.TemporalTaskQueue), c.Close, nil
+	}
+	return nil, nil, fmt.Errorf("NewJobRunner: unknown job backend %q", cfg.JobBackend)
+}
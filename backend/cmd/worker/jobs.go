@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"billbo.com/backend/alerts"
+	"billbo.com/backend/archive"
+	"billbo.com/backend/billing"
+	"billbo.com/backend/database/sqlcgen"
+	"billbo.com/backend/jobs"
+	"billbo.com/backend/notify"
+	"billbo.com/backend/outbox"
+	"billbo.com/backend/partitions"
+	"billbo.com/backend/payments"
+	"billbo.com/backend/rollups"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+)
+
+const (
+	KIND_RELAY_OUTBOX          = "outbox.relay"
+	KIND_PROCESS_WEBHOOKS      = "payments.process_webhooks"
+	KIND_DELIVER_WEBHOOKS      = "notify.deliver_webhooks"
+	KIND_DUNNING               = "payments.dunning"
+	KIND_SCHEDULE_PERIOD_CLOSE = "billing.schedule_period_close"
+	KIND_CLOSE_PERIOD          = "billing.close_period"
+	KIND_COLLECT_INVOICE       = "payments.collect_invoice"
+	KIND_EVALUATE_ALERTS       = "alerts.evaluate"
+	KIND_RECONCILE_USAGE       = "billing.reconcile_usage"
+	KIND_ROLL_UP_USAGE         = "rollups.roll_up"
+	KIND_MAINTAIN_PARTITIONS   = "events.maintain_partitions"
+	KIND_ARCHIVE_EVENTS        = "events.archive"
+	KIND_SWEEP_NONCES          = "ingest.sweep_nonces"
+
+	// PERIOD_CLOSE_LOOKBACK covers the end of the last closed period,
+	// whatever the anchor day and timezone.
+	PERIOD_CLOSE_LOOKBACK = 32 * 24 * time.Hour
+)
+
+// Jobs holds the handlers of the jobs the worker runs.
+type Jobs struct {
+	logger  *zap.Logger
+	queries *sqlcgen.Queries
+	queue   jobs.Queue
+	engine  *billing.Engine
+	// collector, webhookProcessor and dunningProcessor are nil without a
+	// payment provider, and their jobs are not registered.
+	collector        *payments.Collector
+	webhookProcessor *payments.WebhookProcessor
+	dunningProcessor *payments.DunningProcessor
+	relay            *outbox.Relay
+	deliverer        *notify.Deliverer
+	alertEvaluator   *alerts.Evaluator
+	usageReconciler  *billing.UsageReconciler
+	rollupAggregator *rollups.Aggregator
+	partitions       *partitions.Maintainer
+	archiver         *archive.Archiver
+}
+
+// Register registers the handlers and schedules on runner.
+func (j *Jobs) Register(runner jobs.Runner) {
+	runner.Handle(KIND_RELAY_OUTBOX, periodic(j.relay.RelayPending))
+	runner.Schedule(KIND_RELAY_OUTBOX, time.Second)
+
+	runner.Handle(KIND_DELIVER_WEBHOOKS, periodic(j.deliverer.DeliverDue))
+	runner.Schedule(KIND_DELIVER_WEBHOOKS, 5*time.Second)
+
+	runner.Handle(KIND_EVALUATE_ALERTS, periodic(j.alertEvaluator.EvaluateAll))
+	runner.Schedule(KIND_EVALUATE_ALERTS, time.Minute)
+
+	runner.Handle(KIND_RECONCILE_USAGE, periodic(j.usageReconciler.ReconcileRecent))
+	runner.Schedule(KIND_RECONCILE_USAGE, time.Hour)
+
+	runner.Handle(KIND_ROLL_UP_USAGE, periodic(j.rollupAggregator.RollUpDirty))
+	runner.Schedule(KIND_ROLL_UP_USAGE, time.Minute)
+
+	runner.Handle(KIND_MAINTAIN_PARTITIONS, periodic(j.partitions.Maintain))
+	runner.Schedule(KIND_MAINTAIN_PARTITIONS, time.Hour)
+
+	runner.Handle(KIND_ARCHIVE_EVENTS, periodic(j.archiver.ArchiveAll))
+	runner.Schedule(KIND_ARCHIVE_EVENTS, time.Hour)
+
+	runner.Handle(KIND_SWEEP_NONCES, periodic(j.sweepNonces))
+	runner.Schedule(KIND_SWEEP_NONCES, 10*time.Minute)
+
+	runner.Handle(KIND_SCHEDULE_PERIOD_CLOSE, periodic(j.schedulePeriodClose))
+	runner.Schedule(KIND_SCHEDULE_PERIOD_CLOSE, 15*time.Minute)
+
+	runner.Handle(KIND_CLOSE_PERIOD, j.closePeriod)
+
+	if j.collector != nil {
+		runner.Handle(KIND_PROCESS_WEBHOOKS, periodic(j.webhookProcessor.ProcessDue))
+		runner.Schedule(KIND_PROCESS_WEBHOOKS, 5*time.Second)
+
+		runner.Handle(KIND_DUNNING, periodic(j.dunningProcessor.ProcessDue))
+		runner.Schedule(KIND_DUNNING, time.Minute)
+
+		runner.Handle(KIND_COLLECT_INVOICE, j.collectInvoice)
+	}
+}
+
+// periodic adapts a function run on a schedule, without args, to a handler.
+func periodic(f func(ctx context.Context) error) jobs.Handler {
+	return func(ctx context.Context, _ json.RawMessage) error {
+		return f(ctx)
+	}
+}
+
+// sweepNonces deletes the nonces of signed ingest requests whose timestamp
+// is out of tolerance, which the ingest API rejects anyway.
+func (j *Jobs) sweepNonces(ctx context.Context) error {
+	n, err := j.queries.DeleteExpiredSignatureNonces(ctx)
+	if err != nil {
+		return fmt.Errorf("queries.DeleteExpiredSignatureNonces: %w", err)
+	}
+	if n > 0 {
+		j.logger.Debug("swept signature nonces", zap.Int64("nonces", n))
+	}
+	return nil
+}
+
+type ClosePeriodArgs struct {
+	MerchantID  uuid.UUID `json:"merchant_id"`
+	CustomerID  uuid.UUID `json:"customer_id"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+}
+
+type CollectInvoiceArgs struct {
+	MerchantID uuid.UUID `json:"merchant_id"`
+	InvoiceID  uuid.UUID `json:"invoice_id"`
+}
+
+// schedulePeriodClose enqueues the invoicing of the last closed period of
+// every customer with usage over it and no finalized invoice yet. Periods
+// follow the customer's (or else the merchant's) timezone and the merchant's
+// anchor day; the unique key makes sure each is closed once.
+func (j *Jobs) schedulePeriodClose(ctx context.Context) error {
+	now := time.Now()
+	rows, err := j.queries.ListBillableCustomers(ctx, pgtype.Timestamptz{Time: now.Add(-PERIOD_CLOSE_LOOKBACK), Valid: true})
+	if err != nil {
+		return fmt.Errorf("queries.ListBillableCustomers: %w", err)
+	}
+
+	for _, row := range rows {
+		schedule, err := billing.NewPeriodSchedule(row.Timezone, int(row.AnchorDay))
+		if err != nil {
+			j.logger.Error("invalid billing period schedule",
+				zap.String("merchant_id", row.MerchantID.String()),
+				zap.String("customer_id", row.CustomerID.String()),
+				zap.Error(err),
+			)
+			continue
+		}
+		period := schedule.LastClosedPeriod(now)
+
+		uninvoiced, err := j.queries.HasUninvoicedUsage(ctx, sqlcgen.HasUninvoicedUsageParams{
+			MerchantID:  row.MerchantID,
+			CustomerID:  row.CustomerID,
+			PeriodStart: pgtype.Timestamptz{Time: period.Start, Valid: true},
+			PeriodEnd:   pgtype.Timestamptz{Time: period.End, Valid: true},
+		})
+		if err != nil {
+			return fmt.Errorf("queries.HasUninvoicedUsage: %w", err)
+		}
+		if !uninvoiced {
+			continue
+		}
+
+		args := ClosePeriodArgs{
+			MerchantID:  row.MerchantID.Bytes,
+			CustomerID:  row.CustomerID.Bytes,
+			PeriodStart: period.Start,
+			PeriodEnd:   period.End,
+		}
+		err = j.queue.Enqueue(ctx, KIND_CLOSE_PERIOD, args, jobs.EnqueueOptions{
+			UniqueKey: fmt.Sprintf("%s:%s:%s:%s", KIND_CLOSE_PERIOD, args.MerchantID, args.CustomerID, period.Start.UTC().Format(time.RFC3339)),
+		})
+		if err != nil {
+			return fmt.Errorf("queue.Enqueue: %w", err)
+		}
+	}
+	return nil
+}
+
+// closePeriod generates and finalizes the customer's invoice for the period,
+// then enqueues its collection if payments are collected.
+func (j *Jobs) closePeriod(ctx context.Context, payload json.RawMessage) error {
+	var args ClosePeriodArgs
+	if err := json.Unmarshal(payload, &args); err != nil {
+		return fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	draft, err := j.engine.GenerateInvoice(ctx, billing.GenerateInvoiceParams{
+		MerchantID:  args.MerchantID,
+		CustomerID:  args.CustomerID,
+		PeriodStart: args.PeriodStart,
+		PeriodEnd:   args.PeriodEnd,
+	})
+	if errors.Is(err, billing.ErrInvoiceFinalized) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("engine.GenerateInvoice: %w", err)
+	}
+
+	invoice, err := j.engine.FinalizeInvoice(ctx, args.MerchantID, draft.ID.Bytes)
+	if errors.Is(err, billing.ErrInvoiceNotDraft) {
+		// Finalized concurrently, e.g. from the dashboard.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("engine.FinalizeInvoice: %w", err)
+	}
+	if j.collector == nil {
+		return nil
+	}
+
+	err = j.queue.Enqueue(ctx, KIND_COLLECT_INVOICE, CollectInvoiceArgs{
+		MerchantID: args.MerchantID,
+		InvoiceID:  invoice.ID.Bytes,
+	}, jobs.EnqueueOptions{
+		UniqueKey: KIND_COLLECT_INVOICE + ":" + invoice.ID.String(),
+	})
+	if err != nil {
+		return fmt.Errorf("queue.Enqueue: %w", err)
+	}
+	return nil
+}
+
+// collectInvoice charges a finalized invoice. Invoices that cannot be
+// collected automatically are left to the merchant.
+func (j *Jobs) collectInvoice(ctx context.Context, payload json.RawMessage) error {
+	var args CollectInvoiceArgs
+	if err := json.Unmarshal(payload, &args); err != nil {
+		return fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	_, err := j.collector.CollectInvoice(ctx, args.MerchantID, args.InvoiceID)
+	if errors.Is(err, payments.ErrCustomerNotFound) ||
+		errors.Is(err, payments.ErrInvoiceNotCollectible) ||
+		errors.Is(err, payments.ErrTestMode) {
+		j.logger.Info("invoice not collected",
+			zap.String("invoice_id", args.InvoiceID.String()),
+			zap.Error(err),
+		)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("collector.CollectInvoice: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"billbo.com/backend/alerts"
+	"billbo.com/backend/archive"
+	"billbo.com/backend/billing"
+	"billbo.com/backend/database"
+	"billbo.com/backend/database/sqlcgen"
+	"billbo.com/backend/notify"
+	"billbo.com/backend/outbox"
+	"billbo.com/backend/partitions"
+	"billbo.com/backend/payments"
+	"billbo.com/backend/payments/fake"
+	"billbo.com/backend/rollups"
+	"go.uber.org/zap"
+)
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		zap.L().Fatal("zap.NewDevelopment", zap.Error(err))
+	}
+
+	go OnSignal(cancel, logger)
+
+	// Config
+	cfg, err := NewConfig(ctx)
+	if err != nil {
+		logger.Fatal("NewConfig", zap.Error(err))
+	}
+
+	// Database
+	pool, err := database.NewPostgresPool(cfg.DatabaseURL)
+	if err != nil {
+		logger.Fatal("database.NewPostgresPool", zap.Error(err))
+	}
+	defer pool.Close()
+	queries := sqlcgen.New(pool)
+
+	// Job runner
+	runner, closeRunner, err := cfg.NewJobRunner(logger, queries)
+	if err != nil {
+		logger.Fatal("cfg.NewJobRunner", zap.Error(err))
+	}
+	defer closeRunner()
+
+	// Payments, only collected with a real provider: the fake one keeps its
+	// customers and payments in the memory of one process, so the worker
+	// would not see the dashboard API's.
+	var (
+		collector        *payments.Collector
+		webhookProcessor *payments.WebhookProcessor
+		dunningProcessor *payments.DunningProcessor
+	)
+	if cfg.PaymentProvider == "" || cfg.PaymentProvider == fake.PROVIDER_NAME {
+		logger.Warn("no payment provider, invoices are not collected", zap.String("provider", cfg.PaymentProvider))
+	} else {
+		paymentProvider, err := cfg.NewPaymentProvider()
+		if err != nil {
+			logger.Fatal("cfg.NewPaymentProvider", zap.Error(err))
+		}
+		collector = payments.NewCollector(logger, queries, paymentProvider, cfg.PaymentCurrency)
+		webhookProcessor = payments.NewWebhookProcessor(logger, pool, collector)
+		dunningProcessor = payments.NewDunningProcessor(logger, pool, collector)
+	}
+
+	// Outbox
+	relay := outbox.NewRelay(logger, pool)
+	relay.Subscribe("webhooks", notify.EventTypes, notify.Subscriber())
+
+	// Archive
+	archiveStore, err := cfg.NewBlobStore()
+	if err != nil {
+		logger.Fatal("cfg.NewBlobStore", zap.Error(err))
+	}
+
+	// Jobs
+	workerJobs := &Jobs{
+		logger:           logger.With(zap.String("component", "worker")),
+		queries:          queries,
+		queue:            runner,
+		engine:           billing.NewEngine(logger, pool, billing.NewRuleBasedTaxCalculator()),
+		collector:        collector,
+		webhookProcessor: webhookProcessor,
+		dunningProcessor: dunningProcessor,
+		relay:            relay,
+		deliverer:        notify.NewDeliverer(logger, queries),
+		alertEvaluator:   alerts.NewEvaluator(logger, pool),
+		usageReconciler:  billing.NewUsageReconciler(logger, pool),
+		rollupAggregator: rollups.NewAggregator(logger, pool),
+		partitions:       partitions.NewMaintainer(logger, pool),
+		archiver:         archive.NewArchiver(logger, pool, archiveStore),
+	}
+	workerJobs.Register(runner)
+
+	err = runner.Run(ctx)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			logger.Info("shutting down")
+			return
+		}
+		logger.Fatal("runner.Run", zap.Error(err))
+	}
+}
+
+func OnSignal(f func(), logger *zap.Logger) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-sigs
+	logger.Info("signal received", zap.String("signal", sig.String()))
+	f()
+}
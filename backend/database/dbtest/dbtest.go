@@ -0,0 +1,43 @@
+// Package dbtest holds the fixtures of the tests that run against a
+// database.
+package dbtest
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"billbo.com/backend/database"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NewPool connects to the migrated database of TEST_DATABASE_URL. The test
+// is skipped without it.
+func NewPool(t testing.TB) *pgxpool.Pool {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	pool, err := database.NewPostgresPool(url)
+	if err != nil {
+		t.Fatalf("database.NewPostgresPool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+// CreateMerchant creates a merchant with a unique email, and returns its ID.
+func CreateMerchant(t testing.TB, pool *pgxpool.Pool) uuid.UUID {
+	t.Helper()
+	merchantID := uuid.New()
+	_, err := pool.Exec(context.Background(),
+		`INSERT INTO merchants (id, email, password_hash, name) VALUES ($1, $2, 'x', 'Test merchant')`,
+		merchantID, merchantID.String()+"@example.com",
+	)
+	if err != nil {
+		t.Fatalf("insert merchant: %v", err)
+	}
+	return merchantID
+}
@@ -0,0 +1,31 @@
+-- migrate:up
+CREATE TABLE invoices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    customer_id UUID NOT NULL,
+    status TEXT NOT NULL DEFAULT 'draft',
+    period_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    period_end TIMESTAMP WITH TIME ZONE NOT NULL,
+    subtotal DOUBLE PRECISION NOT NULL,
+    discount_total DOUBLE PRECISION NOT NULL,
+    total DOUBLE PRECISION NOT NULL,
+    finalized_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    UNIQUE (merchant_id, customer_id, period_start, period_end)
+);
+
+CREATE TABLE invoice_lines (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    invoice_id UUID NOT NULL REFERENCES invoices(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    kind TEXT NOT NULL,
+    description TEXT NOT NULL,
+    sku_id UUID REFERENCES skus(id),
+    quantity DOUBLE PRECISION,
+    unit_price DOUBLE PRECISION,
+    amount DOUBLE PRECISION NOT NULL
+);
+
+-- migrate:down
+DROP TABLE invoice_lines;
+DROP TABLE invoices;
@@ -0,0 +1,32 @@
+-- migrate:up
+CREATE TABLE coupons (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    name TEXT NOT NULL,
+    percent_off DOUBLE PRECISION,
+    amount_off DOUBLE PRECISION,
+    duration TEXT NOT NULL,
+    duration_periods INTEGER,
+    sku_ids UUID[] NOT NULL DEFAULT '{}',
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CHECK ((percent_off IS NULL) <> (amount_off IS NULL))
+);
+
+CREATE TABLE coupon_redemptions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    coupon_id UUID NOT NULL REFERENCES coupons(id),
+    customer_id UUID NOT NULL,
+    periods_applied INTEGER NOT NULL DEFAULT 0,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+ALTER TABLE invoice_lines
+    ADD COLUMN coupon_redemption_id UUID REFERENCES coupon_redemptions(id);
+
+-- migrate:down
+ALTER TABLE invoice_lines DROP COLUMN coupon_redemption_id;
+DROP TABLE coupon_redemptions;
+DROP TABLE coupons;
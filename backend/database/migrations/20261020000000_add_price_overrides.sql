@@ -0,0 +1,18 @@
+-- migrate:up
+CREATE TABLE price_overrides (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    sku_id UUID NOT NULL REFERENCES skus(id),
+    customer_id UUID NOT NULL,
+    price_per_unit DOUBLE PRECISION NOT NULL,
+    starts_at TIMESTAMP WITH TIME ZONE,
+    ends_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    CHECK (starts_at IS NULL OR ends_at IS NULL OR starts_at < ends_at)
+);
+
+CREATE INDEX price_overrides_sku_id_customer_id_idx ON price_overrides (sku_id, customer_id);
+
+-- migrate:down
+DROP TABLE price_overrides;
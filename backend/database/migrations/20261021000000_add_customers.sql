@@ -0,0 +1,20 @@
+-- migrate:up
+CREATE TABLE customers (
+    id UUID NOT NULL,
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    name TEXT NOT NULL,
+    email TEXT,
+    address_line1 TEXT,
+    address_line2 TEXT,
+    city TEXT,
+    postal_code TEXT,
+    region TEXT,
+    country TEXT,
+    tax_id TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY (merchant_id, id)
+);
+
+-- migrate:down
+DROP TABLE customers;
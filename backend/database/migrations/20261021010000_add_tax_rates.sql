@@ -0,0 +1,23 @@
+-- migrate:up
+CREATE TABLE tax_rates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    name TEXT NOT NULL,
+    country TEXT NOT NULL,
+    region TEXT,
+    percentage DOUBLE PRECISION NOT NULL,
+    reverse_charge BOOLEAN NOT NULL DEFAULT false,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+ALTER TABLE invoices
+    ADD COLUMN tax_total DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+ALTER TABLE invoice_lines
+    ADD COLUMN tax_rate_id UUID REFERENCES tax_rates(id);
+
+-- migrate:down
+ALTER TABLE invoice_lines DROP COLUMN tax_rate_id;
+ALTER TABLE invoices DROP COLUMN tax_total;
+DROP TABLE tax_rates;
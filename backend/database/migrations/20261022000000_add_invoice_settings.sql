@@ -0,0 +1,25 @@
+-- migrate:up
+CREATE TABLE invoice_settings (
+    merchant_id UUID PRIMARY KEY REFERENCES merchants(id),
+    number_prefix TEXT NOT NULL DEFAULT 'INV-',
+    last_invoice_number INTEGER NOT NULL DEFAULT 0,
+    payment_terms_days INTEGER NOT NULL DEFAULT 30,
+    accent_color TEXT NOT NULL DEFAULT '#1F2937',
+    header_template TEXT NOT NULL DEFAULT '',
+    footer_template TEXT NOT NULL DEFAULT '',
+    logo BYTEA,
+    logo_content_type TEXT,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+ALTER TABLE invoices
+    ADD COLUMN number TEXT,
+    ADD COLUMN due_at TIMESTAMP WITH TIME ZONE,
+    ADD CONSTRAINT invoices_merchant_id_number_key UNIQUE (merchant_id, number);
+
+-- migrate:down
+ALTER TABLE invoices
+    DROP CONSTRAINT invoices_merchant_id_number_key,
+    DROP COLUMN due_at,
+    DROP COLUMN number;
+DROP TABLE invoice_settings;
@@ -0,0 +1,17 @@
+-- migrate:up
+ALTER TABLE customers
+    ADD COLUMN payment_customer_id TEXT;
+
+ALTER TABLE invoices
+    ADD COLUMN payment_provider TEXT,
+    ADD COLUMN payment_id TEXT,
+    ADD COLUMN paid_at TIMESTAMP WITH TIME ZONE,
+    ADD CONSTRAINT invoices_payment_provider_payment_id_key UNIQUE (payment_provider, payment_id);
+
+-- migrate:down
+ALTER TABLE invoices
+    DROP CONSTRAINT invoices_payment_provider_payment_id_key,
+    DROP COLUMN paid_at,
+    DROP COLUMN payment_id,
+    DROP COLUMN payment_provider;
+ALTER TABLE customers DROP COLUMN payment_customer_id;
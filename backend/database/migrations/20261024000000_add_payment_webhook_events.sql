@@ -0,0 +1,20 @@
+-- migrate:up
+CREATE TABLE payment_webhook_events (
+    provider TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    payment_id TEXT NOT NULL,
+    payload BYTEA NOT NULL,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    processed_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (provider, event_id)
+);
+
+CREATE INDEX payment_webhook_events_pending_idx ON payment_webhook_events (next_attempt_at) WHERE processed_at IS NULL;
+
+-- migrate:down
+DROP TABLE payment_webhook_events;
@@ -0,0 +1,29 @@
+-- migrate:up
+CREATE TABLE dunning_settings (
+    merchant_id UUID PRIMARY KEY REFERENCES merchants(id),
+    retry_days INTEGER[] NOT NULL DEFAULT '{3,5,7}',
+    suspend_ingest BOOLEAN NOT NULL DEFAULT false,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE TABLE invoice_dunnings (
+    invoice_id UUID PRIMARY KEY REFERENCES invoices(id) ON DELETE CASCADE,
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    retry_days INTEGER[] NOT NULL,
+    step INTEGER NOT NULL DEFAULT 0,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    next_attempt_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+    outcome TEXT,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX invoice_dunnings_next_attempt_at_idx ON invoice_dunnings (next_attempt_at) WHERE completed_at IS NULL;
+
+ALTER TABLE customers
+    ADD COLUMN ingest_suspended_at TIMESTAMP WITH TIME ZONE;
+
+-- migrate:down
+ALTER TABLE customers DROP COLUMN ingest_suspended_at;
+DROP TABLE invoice_dunnings;
+DROP TABLE dunning_settings;
@@ -0,0 +1,29 @@
+-- migrate:up
+CREATE TABLE jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind TEXT NOT NULL,
+    args JSONB NOT NULL DEFAULT '{}',
+    unique_key TEXT UNIQUE,
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 10,
+    last_error TEXT,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    failed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX jobs_run_at_idx ON jobs (run_at) WHERE completed_at IS NULL AND failed_at IS NULL;
+
+CREATE TABLE job_schedules (
+    kind TEXT PRIMARY KEY,
+    every INTERVAL NOT NULL,
+    next_run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    last_run_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT
+);
+
+-- migrate:down
+DROP TABLE job_schedules;
+DROP TABLE jobs;
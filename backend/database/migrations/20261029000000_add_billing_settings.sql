@@ -0,0 +1,14 @@
+-- migrate:up
+CREATE TABLE billing_settings (
+    merchant_id UUID PRIMARY KEY REFERENCES merchants(id),
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    anchor_day INTEGER NOT NULL DEFAULT 1 CHECK (anchor_day BETWEEN 1 AND 31),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+ALTER TABLE customers
+    ADD COLUMN timezone TEXT;
+
+-- migrate:down
+ALTER TABLE customers DROP COLUMN timezone;
+DROP TABLE billing_settings;
@@ -0,0 +1,26 @@
+-- migrate:up
+CREATE TABLE usage_alerts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    customer_id UUID NOT NULL,
+    sku_id UUID REFERENCES skus(id),
+    budget DOUBLE PRECISION NOT NULL CHECK (budget > 0),
+    thresholds INTEGER[] NOT NULL DEFAULT '{80,100}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX usage_alerts_merchant_id_customer_id_idx ON usage_alerts (merchant_id, customer_id) WHERE revoked_at IS NULL;
+
+CREATE TABLE usage_alert_crossings (
+    alert_id UUID NOT NULL REFERENCES usage_alerts(id),
+    period_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    threshold INTEGER NOT NULL,
+    usage DOUBLE PRECISION NOT NULL,
+    crossed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY (alert_id, period_start, threshold)
+);
+
+-- migrate:down
+DROP TABLE usage_alert_crossings;
+DROP TABLE usage_alerts;
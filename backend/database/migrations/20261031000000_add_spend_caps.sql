@@ -0,0 +1,15 @@
+-- migrate:up
+CREATE TABLE spend_caps (
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    customer_id UUID NOT NULL,
+    amount_limit DOUBLE PRECISION NOT NULL CHECK (amount_limit >= 0),
+    per_period BOOLEAN NOT NULL DEFAULT true,
+    spent DOUBLE PRECISION NOT NULL DEFAULT 0,
+    period_start TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY (merchant_id, customer_id)
+);
+
+-- migrate:down
+DROP TABLE spend_caps;
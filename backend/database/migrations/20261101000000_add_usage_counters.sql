@@ -0,0 +1,21 @@
+-- migrate:up
+CREATE TABLE usage_counters (
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    customer_id UUID NOT NULL,
+    sku_id UUID NOT NULL REFERENCES skus(id),
+    period_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    period_end TIMESTAMP WITH TIME ZONE NOT NULL,
+    quantity DOUBLE PRECISION NOT NULL DEFAULT 0,
+    event_count BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    reconciled_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (merchant_id, customer_id, period_start, sku_id)
+);
+
+CREATE INDEX usage_counters_period_end_idx ON usage_counters (period_end);
+
+CREATE INDEX events_merchant_id_customer_id_sent_at_idx ON events (merchant_id, customer_id, sent_at);
+
+-- migrate:down
+DROP INDEX events_merchant_id_customer_id_sent_at_idx;
+DROP TABLE usage_counters;
@@ -0,0 +1,38 @@
+-- migrate:up
+CREATE TABLE usage_rollups_hourly (
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    bucket_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    customer_id UUID NOT NULL,
+    sku_id UUID NOT NULL REFERENCES skus(id),
+    quantity DOUBLE PRECISION NOT NULL,
+    event_count BIGINT NOT NULL,
+    PRIMARY KEY (merchant_id, bucket_start, customer_id, sku_id)
+);
+
+CREATE TABLE usage_rollups_daily (
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    bucket_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    customer_id UUID NOT NULL,
+    sku_id UUID NOT NULL REFERENCES skus(id),
+    quantity DOUBLE PRECISION NOT NULL,
+    event_count BIGINT NOT NULL,
+    PRIMARY KEY (merchant_id, bucket_start, customer_id, sku_id)
+);
+
+-- Hours with events not rolled up yet. Ingest marks the hour of each event,
+-- so late events re-aggregate the buckets they fall in.
+CREATE TABLE usage_rollup_dirty_hours (
+    merchant_id UUID NOT NULL,
+    hour TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (merchant_id, hour)
+);
+
+-- Events ingested before rollups existed.
+INSERT INTO usage_rollup_dirty_hours (merchant_id, hour)
+SELECT DISTINCT merchant_id, date_trunc('hour', sent_at, 'UTC')
+FROM events;
+
+-- migrate:down
+DROP TABLE usage_rollup_dirty_hours;
+DROP TABLE usage_rollups_daily;
+DROP TABLE usage_rollups_hourly;
@@ -0,0 +1,79 @@
+-- migrate:up
+ALTER TABLE events RENAME TO events_unpartitioned;
+ALTER TABLE events_unpartitioned DROP CONSTRAINT events_pkey;
+DROP INDEX events_merchant_id_customer_id_sent_at_idx;
+
+-- Events are partitioned by month of sent_at (in UTC). The worker creates
+-- the partitions of the coming months ahead of time; events outside of any
+-- monthly partition land in the default one, and move out of it when their
+-- month's partition is created.
+CREATE TABLE events (
+    id UUID NOT NULL DEFAULT gen_random_uuid(),
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    customer_id UUID NOT NULL,
+    sku_id UUID NOT NULL REFERENCES skus(id),
+    amount DOUBLE PRECISION NOT NULL,
+    sent_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (id, sent_at)
+) PARTITION BY RANGE (sent_at);
+
+CREATE INDEX events_merchant_id_sent_at_idx ON events (merchant_id, sent_at);
+CREATE INDEX events_merchant_id_customer_id_sent_at_idx ON events (merchant_id, customer_id, sent_at);
+
+CREATE TABLE events_default PARTITION OF events DEFAULT;
+
+-- Monthly partitions for the existing events and the current month.
+DO $$
+DECLARE
+    month TIMESTAMP;
+BEGIN
+    FOR month IN
+        SELECT generate_series(
+            date_trunc('month', least(min(sent_at), now()), 'UTC') AT TIME ZONE 'UTC',
+            date_trunc('month', now(), 'UTC') AT TIME ZONE 'UTC',
+            INTERVAL '1 month'
+        )
+        FROM events_unpartitioned
+    LOOP
+        EXECUTE format(
+            'CREATE TABLE %I PARTITION OF events FOR VALUES FROM (%L) TO (%L)',
+            'events_' || to_char(month, 'YYYY_MM'),
+            month AT TIME ZONE 'UTC',
+            (month + INTERVAL '1 month') AT TIME ZONE 'UTC'
+        );
+    END LOOP;
+END
+$$;
+
+INSERT INTO events (id, merchant_id, customer_id, sku_id, amount, sent_at)
+SELECT id, merchant_id, customer_id, sku_id, amount, sent_at
+FROM events_unpartitioned;
+
+DROP TABLE events_unpartitioned;
+
+-- Number of months the merchant's events are kept after their month ends,
+-- once invoiced. Events are kept forever if NULL.
+ALTER TABLE billing_settings
+ADD COLUMN event_retention_months INTEGER CHECK (event_retention_months >= 1);
+
+-- migrate:down
+ALTER TABLE billing_settings DROP COLUMN event_retention_months;
+
+ALTER TABLE events RENAME TO events_partitioned;
+
+CREATE TABLE events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    customer_id UUID NOT NULL,
+    sku_id UUID NOT NULL REFERENCES skus(id),
+    amount DOUBLE PRECISION NOT NULL,
+    sent_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+INSERT INTO events (id, merchant_id, customer_id, sku_id, amount, sent_at)
+SELECT id, merchant_id, customer_id, sku_id, amount, sent_at
+FROM events_partitioned;
+
+DROP TABLE events_partitioned;
+
+CREATE INDEX events_merchant_id_customer_id_sent_at_idx ON events (merchant_id, customer_id, sent_at);
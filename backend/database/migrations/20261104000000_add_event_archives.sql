@@ -0,0 +1,23 @@
+-- migrate:up
+-- Manifest of the events archived to the blob store, one Parquet file per
+-- merchant and month.
+CREATE TABLE event_archives (
+    merchant_id UUID NOT NULL REFERENCES merchants(id),
+    period_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    period_end TIMESTAMP WITH TIME ZONE NOT NULL,
+    blob_key TEXT NOT NULL,
+    event_count BIGINT NOT NULL,
+    -- Events of the merchant and month in the database when archived. Events
+    -- are only ever added to a month until its partition is dropped, so the
+    -- archive covers them all as long as their count is unchanged.
+    database_event_count BIGINT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    sha256 TEXT NOT NULL,
+    archived_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    -- Rehydrated events are kept in the database until then.
+    rehydrated_until TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (merchant_id, period_start)
+);
+
+-- migrate:down
+DROP TABLE event_archives;
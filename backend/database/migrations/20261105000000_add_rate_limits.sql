@@ -0,0 +1,20 @@
+-- migrate:up
+-- Ingest rate limits of the key, in requests per second with bursts of up
+-- to rate_limit_burst requests. The ingest API defaults apply if NULL.
+ALTER TABLE api_keys
+ADD COLUMN rate_limit_per_second INTEGER CHECK (rate_limit_per_second > 0),
+ADD COLUMN rate_limit_burst INTEGER CHECK (rate_limit_burst > 0);
+
+-- Ingest rate limits shared by all the keys of a merchant.
+CREATE TABLE merchant_rate_limits (
+    merchant_id UUID PRIMARY KEY REFERENCES merchants(id),
+    rate_limit_per_second INTEGER NOT NULL CHECK (rate_limit_per_second > 0),
+    rate_limit_burst INTEGER NOT NULL CHECK (rate_limit_burst > 0),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- migrate:down
+DROP TABLE merchant_rate_limits;
+ALTER TABLE api_keys
+DROP COLUMN rate_limit_burst,
+DROP COLUMN rate_limit_per_second;
@@ -0,0 +1,11 @@
+-- migrate:up
+-- What the key grants access to on the ingest API. Existing keys had full
+-- access, so they keep every scope.
+ALTER TABLE api_keys
+ADD COLUMN scopes TEXT[] NOT NULL DEFAULT '{events:write,events:read,usage:read}';
+ALTER TABLE api_keys
+ALTER COLUMN scopes DROP DEFAULT;
+
+-- migrate:down
+ALTER TABLE api_keys
+DROP COLUMN scopes;
@@ -0,0 +1,19 @@
+-- migrate:up
+-- The key stops authenticating at expires_at, if set. Once rotated, its
+-- previous hash still authenticates until previous_key_expires_at.
+-- last_used_at is updated by the ingest API in batches, so it lags behind
+-- by up to its flush interval.
+ALTER TABLE api_keys
+ADD COLUMN expires_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN previous_key_hash TEXT UNIQUE,
+ADD COLUMN previous_key_expires_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN rotated_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN last_used_at TIMESTAMP WITH TIME ZONE;
+
+-- migrate:down
+ALTER TABLE api_keys
+DROP COLUMN last_used_at,
+DROP COLUMN rotated_at,
+DROP COLUMN previous_key_expires_at,
+DROP COLUMN previous_key_hash,
+DROP COLUMN expires_at;
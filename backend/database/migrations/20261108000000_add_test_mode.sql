@@ -0,0 +1,16 @@
+-- migrate:up
+-- Test mode data belongs to a test merchant linked to the live one, so that
+-- it is isolated from live data like any other merchant's. Test merchants
+-- share the email of their live merchant but cannot log in.
+ALTER TABLE merchants
+ADD COLUMN live_merchant_id UUID UNIQUE REFERENCES merchants(id);
+ALTER TABLE merchants
+DROP CONSTRAINT merchants_email_key;
+CREATE UNIQUE INDEX merchants_email_key ON merchants (email) WHERE live_merchant_id IS NULL;
+
+-- migrate:down
+DROP INDEX merchants_email_key;
+ALTER TABLE merchants
+ADD CONSTRAINT merchants_email_key UNIQUE (email);
+ALTER TABLE merchants
+DROP COLUMN live_merchant_id;
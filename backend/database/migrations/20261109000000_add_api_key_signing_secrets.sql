@@ -0,0 +1,11 @@
+-- migrate:up
+-- The secret signed ingest requests are authenticated with, instead of the
+-- key itself. It is stored as is, like webhook endpoint secrets, as it is
+-- needed to verify signatures. Keys created before have none until one is
+-- generated.
+ALTER TABLE api_keys
+ADD COLUMN signing_secret TEXT;
+
+-- migrate:down
+ALTER TABLE api_keys
+DROP COLUMN signing_secret;
@@ -0,0 +1,15 @@
+-- migrate:up
+-- The consecutive provider errors of the current dunning step, which is
+-- given up as failed past a limit.
+ALTER TABLE invoice_dunnings
+ADD COLUMN error_count INTEGER NOT NULL DEFAULT 0;
+
+-- Dunnings waiting for the outcome of their last retry now end at a
+-- deadline, after which the invoice is marked uncollectible.
+UPDATE invoice_dunnings
+SET next_attempt_at = now() + interval '3 days'
+WHERE completed_at IS NULL AND next_attempt_at IS NULL;
+
+-- migrate:down
+ALTER TABLE invoice_dunnings
+DROP COLUMN error_count;
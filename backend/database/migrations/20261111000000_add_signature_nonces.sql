@@ -0,0 +1,15 @@
+-- migrate:up
+-- The nonces of signed ingest requests, shared by all ingest instances so
+-- that a request served by one cannot be replayed on another. They are kept
+-- until the request timestamp falls out of tolerance.
+CREATE TABLE signature_nonces (
+    key_id UUID NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+    nonce TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (key_id, nonce)
+);
+
+CREATE INDEX signature_nonces_expires_at_idx ON signature_nonces (expires_at);
+
+-- migrate:down
+DROP TABLE signature_nonces;
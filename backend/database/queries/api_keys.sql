@@ -1,6 +1,7 @@
 -- name: CreateAPIKey :one
-INSERT INTO api_keys (merchant_id, name, key_prefix, key_hash)
-VALUES ($1, $2, $3, $4)
+-- The ID is generated by the caller, as keys embed it.
+INSERT INTO api_keys (id, merchant_id, name, key_prefix, key_hash, scopes, expires_at, signing_secret)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 RETURNING *;
 
 -- name: ListAPIKeysByMerchantID :many
@@ -8,12 +9,97 @@ SELECT * FROM api_keys
 WHERE merchant_id = $1
 ORDER BY created_at DESC;
 
--- name: RevokeAPIKey :exec
+-- name: RevokeAPIKey :one
 UPDATE api_keys
 SET revoked_at = now()
-WHERE id = $1 AND merchant_id = $2 AND revoked_at IS NULL;
+WHERE id = $1 AND merchant_id = $2 AND revoked_at IS NULL
+RETURNING *;
 
 -- name: GetAPIKeyByHash :one
-SELECT id, merchant_id, revoked_at
-FROM api_keys
-WHERE key_hash = $1;
+-- The legacy key, which embeds no ID, of the given current or previous hash,
+-- with its scopes, its rate limits and its merchant's. expires_at is when the
+-- given hash stops authenticating, if ever.
+SELECT k.id, k.merchant_id, k.revoked_at, k.scopes,
+       (CASE WHEN k.key_hash = sqlc.arg(key_hash)::text THEN k.expires_at
+             ELSE LEAST(k.previous_key_expires_at, k.expires_at)
+        END)::timestamptz AS expires_at,
+       k.key_hash, k.previous_key_hash, k.previous_key_expires_at,
+       k.signing_secret,
+       k.rate_limit_per_second, k.rate_limit_burst,
+       mrl.rate_limit_per_second AS merchant_rate_limit_per_second,
+       mrl.rate_limit_burst AS merchant_rate_limit_burst
+FROM api_keys k
+LEFT JOIN merchant_rate_limits mrl ON mrl.merchant_id = k.merchant_id
+WHERE k.key_hash = sqlc.arg(key_hash)::text
+   OR k.previous_key_hash = sqlc.arg(key_hash)::text;
+
+-- name: GetAPIKeyByID :one
+-- Same as GetAPIKeyByHash, for keys embedding their ID and for signed
+-- requests, which name their key by ID. The caller verifies the key against
+-- its hashes.
+SELECT k.id, k.merchant_id, k.revoked_at, k.scopes,
+       k.expires_at,
+       k.key_hash, k.previous_key_hash, k.previous_key_expires_at,
+       k.signing_secret,
+       k.rate_limit_per_second, k.rate_limit_burst,
+       mrl.rate_limit_per_second AS merchant_rate_limit_per_second,
+       mrl.rate_limit_burst AS merchant_rate_limit_burst
+FROM api_keys k
+LEFT JOIN merchant_rate_limits mrl ON mrl.merchant_id = k.merchant_id
+WHERE k.id = $1;
+
+-- name: SetAPIKeySigningSecret :one
+UPDATE api_keys
+SET signing_secret = $3
+WHERE id = $1 AND merchant_id = $2 AND revoked_at IS NULL
+RETURNING *;
+
+-- name: RotateAPIKey :one
+-- Replaces the hash of an active key. The replaced hash still authenticates
+-- until previous_key_expires_at, and the one it replaced, if any, no longer
+-- does.
+UPDATE api_keys
+SET previous_key_hash = key_hash,
+    previous_key_expires_at = sqlc.arg(previous_key_expires_at),
+    key_prefix = sqlc.arg(key_prefix),
+    key_hash = sqlc.arg(key_hash),
+    rotated_at = now()
+WHERE id = sqlc.arg(id)
+  AND merchant_id = sqlc.arg(merchant_id)
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > now())
+RETURNING *;
+
+-- name: TouchAPIKeys :exec
+-- Records when the keys were last used, as batched by the ingest API.
+UPDATE api_keys k
+SET last_used_at = u.last_used_at
+FROM (
+    SELECT unnest(sqlc.arg(ids)::uuid[]) AS id,
+           unnest(sqlc.arg(last_used_ats)::timestamptz[]) AS last_used_at
+) u
+WHERE k.id = u.id
+  AND (k.last_used_at IS NULL OR k.last_used_at < u.last_used_at);
+
+-- name: UpdateAPIKeyRateLimit :one
+UPDATE api_keys
+SET rate_limit_per_second = $3,
+    rate_limit_burst = $4
+WHERE id = $1 AND merchant_id = $2
+RETURNING *;
+
+-- name: NotifyAPIKeyChanged :exec
+-- Notifies the ingest API key caches, once the transaction commits, of the
+-- current and previous hashes of the key, and of its ID.
+SELECT pg_notify('api_key_changed', h.lookup_key)
+FROM api_keys k
+CROSS JOIN LATERAL unnest(ARRAY[k.key_hash, k.previous_key_hash, 'id:' || k.id::text]) AS h(lookup_key)
+WHERE k.id = $1
+  AND h.lookup_key IS NOT NULL;
+
+-- name: NotifyMerchantAPIKeysChanged :exec
+SELECT pg_notify('api_key_changed', h.lookup_key)
+FROM api_keys k
+CROSS JOIN LATERAL unnest(ARRAY[k.key_hash, k.previous_key_hash, 'id:' || k.id::text]) AS h(lookup_key)
+WHERE k.merchant_id = $1
+  AND h.lookup_key IS NOT NULL;
@@ -0,0 +1,16 @@
+-- name: GetOrCreateBillingSettings :one
+INSERT INTO billing_settings (merchant_id)
+VALUES ($1)
+ON CONFLICT (merchant_id) DO UPDATE
+SET merchant_id = EXCLUDED.merchant_id
+RETURNING *;
+
+-- name: UpdateBillingSettings :one
+INSERT INTO billing_settings (merchant_id, timezone, anchor_day, event_retention_months)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (merchant_id) DO UPDATE
+SET timezone = EXCLUDED.timezone,
+    anchor_day = EXCLUDED.anchor_day,
+    event_retention_months = EXCLUDED.event_retention_months,
+    updated_at = now()
+RETURNING *;
@@ -0,0 +1,52 @@
+-- name: CreateCoupon :one
+INSERT INTO coupons (merchant_id, name, percent_off, amount_off, duration, duration_periods, sku_ids)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: GetCoupon :one
+SELECT * FROM coupons
+WHERE id = $1 AND merchant_id = $2;
+
+-- name: ListCouponsByMerchantID :many
+SELECT * FROM coupons
+WHERE merchant_id = $1
+ORDER BY created_at DESC;
+
+-- name: RevokeCoupon :exec
+UPDATE coupons
+SET revoked_at = now()
+WHERE id = $1 AND merchant_id = $2 AND revoked_at IS NULL;
+
+-- name: CreateCouponRedemption :one
+INSERT INTO coupon_redemptions (merchant_id, coupon_id, customer_id)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: ListCouponRedemptionsByCouponID :many
+SELECT * FROM coupon_redemptions
+WHERE coupon_id = $1 AND merchant_id = $2
+ORDER BY created_at DESC;
+
+-- name: RevokeCouponRedemption :exec
+UPDATE coupon_redemptions
+SET revoked_at = now()
+WHERE id = $1 AND coupon_id = $2 AND merchant_id = $3 AND revoked_at IS NULL;
+
+-- name: ListActiveCouponRedemptionsByCustomer :many
+SELECT r.id, c.name, c.percent_off, c.amount_off, c.sku_ids
+FROM coupon_redemptions r
+JOIN coupons c ON c.id = r.coupon_id
+WHERE r.merchant_id = $1
+  AND r.customer_id = $2
+  AND r.revoked_at IS NULL
+  AND (c.duration = 'forever' OR r.periods_applied < COALESCE(c.duration_periods, 1))
+ORDER BY r.created_at;
+
+-- name: IncrementCouponRedemptionPeriods :exec
+UPDATE coupon_redemptions
+SET periods_applied = periods_applied + 1
+WHERE id IN (
+    SELECT DISTINCT coupon_redemption_id
+    FROM invoice_lines
+    WHERE invoice_id = $1 AND coupon_redemption_id IS NOT NULL
+);
@@ -0,0 +1,63 @@
+-- name: UpsertCustomer :one
+INSERT INTO customers (id, merchant_id, name, email, address_line1, address_line2, city, postal_code, region, country, tax_id, timezone)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+ON CONFLICT (merchant_id, id) DO UPDATE
+SET name = EXCLUDED.name,
+    email = EXCLUDED.email,
+    address_line1 = EXCLUDED.address_line1,
+    address_line2 = EXCLUDED.address_line2,
+    city = EXCLUDED.city,
+    postal_code = EXCLUDED.postal_code,
+    region = EXCLUDED.region,
+    country = EXCLUDED.country,
+    tax_id = EXCLUDED.tax_id,
+    timezone = EXCLUDED.timezone,
+    updated_at = now()
+RETURNING *;
+
+-- name: GetCustomer :one
+SELECT * FROM customers
+WHERE id = $1 AND merchant_id = $2;
+
+-- name: ListCustomersByMerchantID :many
+SELECT * FROM customers
+WHERE merchant_id = $1
+ORDER BY created_at DESC;
+
+-- name: SetCustomerPaymentCustomerID :exec
+UPDATE customers
+SET payment_customer_id = $3, updated_at = now()
+WHERE id = $1 AND merchant_id = $2;
+
+-- name: SuspendCustomerIngest :exec
+UPDATE customers
+SET ingest_suspended_at = now(), updated_at = now()
+WHERE id = $1 AND merchant_id = $2 AND ingest_suspended_at IS NULL;
+
+-- name: ResumeCustomerIngest :one
+UPDATE customers
+SET ingest_suspended_at = NULL, updated_at = now()
+WHERE id = $1 AND merchant_id = $2
+RETURNING *;
+
+-- name: GetCustomerIngestSettings :one
+-- What the ingest API needs to know of a customer, customer row or not:
+-- whether their ingestion is suspended, and the timezone and anchor day of
+-- their billing periods.
+SELECT (c.ingest_suspended_at IS NOT NULL)::boolean AS ingest_suspended,
+    COALESCE(c.timezone, bs.timezone, 'UTC')::text AS timezone,
+    COALESCE(bs.anchor_day, 1)::integer AS anchor_day
+FROM merchants m
+LEFT JOIN customers c ON c.merchant_id = m.id AND c.id = sqlc.arg(customer_id)
+LEFT JOIN billing_settings bs ON bs.merchant_id = m.id
+WHERE m.id = sqlc.arg(merchant_id);
+
+-- name: NotifyCustomerChanged :exec
+-- Notifies the ingest customer caches, once the transaction commits, that
+-- the customer changed.
+SELECT pg_notify('customer_changed', sqlc.arg(merchant_id)::uuid::text || ':' || sqlc.arg(customer_id)::uuid::text);
+
+-- name: NotifyMerchantCustomersChanged :exec
+-- Notifies the ingest customer caches, once the transaction commits, that
+-- all the customers of the merchant changed.
+SELECT pg_notify('customer_changed', sqlc.arg(merchant_id)::uuid::text || ':');
@@ -0,0 +1,41 @@
+-- name: GetDunningSettings :one
+SELECT * FROM dunning_settings
+WHERE merchant_id = $1;
+
+-- name: UpdateDunningSettings :one
+INSERT INTO dunning_settings (merchant_id, retry_days, suspend_ingest)
+VALUES ($1, $2, $3)
+ON CONFLICT (merchant_id) DO UPDATE
+SET retry_days = EXCLUDED.retry_days,
+    suspend_ingest = EXCLUDED.suspend_ingest,
+    updated_at = now()
+RETURNING *;
+
+-- name: StartInvoiceDunning :exec
+-- The retry schedule is copied from the merchant's settings so that later
+-- changes only affect new dunnings.
+INSERT INTO invoice_dunnings (invoice_id, merchant_id, retry_days, next_attempt_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (invoice_id) DO NOTHING;
+
+-- name: GetInvoiceDunning :one
+SELECT * FROM invoice_dunnings
+WHERE invoice_id = $1;
+
+-- name: ClaimDueInvoiceDunning :one
+-- The row stays locked until the claiming transaction ends.
+SELECT * FROM invoice_dunnings
+WHERE completed_at IS NULL AND next_attempt_at <= now()
+ORDER BY next_attempt_at
+LIMIT 1
+FOR UPDATE SKIP LOCKED;
+
+-- name: AdvanceInvoiceDunning :exec
+UPDATE invoice_dunnings
+SET step = $2, next_attempt_at = $3, last_error = $4, error_count = $5
+WHERE invoice_id = $1;
+
+-- name: CompleteInvoiceDunning :exec
+UPDATE invoice_dunnings
+SET outcome = $2, next_attempt_at = NULL, completed_at = now()
+WHERE invoice_id = $1 AND completed_at IS NULL;
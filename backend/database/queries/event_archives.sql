@@ -0,0 +1,85 @@
+-- name: ListArchivableMerchants :many
+-- The merchants with events over [range_start, range_end) all covered by a
+-- finalized invoice, and not archived yet or with events added since.
+-- Late events of a month whose partition was dropped make the counts differ
+-- too: they are merged into the existing archive.
+SELECT m.merchant_id, m.event_count::bigint AS event_count
+FROM (
+    SELECT e.merchant_id, count(*) AS event_count
+    FROM events e
+    WHERE e.sent_at >= sqlc.arg(range_start)
+      AND e.sent_at < sqlc.arg(range_end)
+    GROUP BY e.merchant_id
+) m
+LEFT JOIN event_archives a ON a.merchant_id = m.merchant_id AND a.period_start = sqlc.arg(range_start)
+WHERE a.database_event_count IS DISTINCT FROM m.event_count
+  AND NOT EXISTS (
+    SELECT 1 FROM events e
+    WHERE e.merchant_id = m.merchant_id
+      AND e.sent_at >= sqlc.arg(range_start)
+      AND e.sent_at < sqlc.arg(range_end)
+      AND NOT EXISTS (
+        SELECT 1 FROM invoices i
+        WHERE i.merchant_id = e.merchant_id
+          AND i.customer_id = e.customer_id
+          AND i.period_start <= e.sent_at
+          AND e.sent_at < i.period_end
+          AND i.status <> 'draft'
+      )
+  )
+ORDER BY m.merchant_id;
+
+-- name: ListEventsToArchive :many
+-- Keyset pagination over the merchant's events of the range, by (sent_at, id).
+SELECT * FROM events
+WHERE merchant_id = sqlc.arg(merchant_id)
+  AND sent_at >= sqlc.arg(range_start)
+  AND sent_at < sqlc.arg(range_end)
+  AND (sent_at, id) > (sqlc.arg(after_sent_at)::timestamptz, sqlc.arg(after_id)::uuid)
+ORDER BY sent_at, id
+LIMIT sqlc.arg(batch_size);
+
+-- name: PutEventArchive :one
+INSERT INTO event_archives (merchant_id, period_start, period_end, blob_key, event_count, database_event_count, size_bytes, sha256)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (merchant_id, period_start) DO UPDATE
+SET period_end = EXCLUDED.period_end,
+    blob_key = EXCLUDED.blob_key,
+    event_count = EXCLUDED.event_count,
+    database_event_count = EXCLUDED.database_event_count,
+    size_bytes = EXCLUDED.size_bytes,
+    sha256 = EXCLUDED.sha256,
+    archived_at = now()
+RETURNING *;
+
+-- name: ListEventArchivesByMerchantID :many
+SELECT * FROM event_archives
+WHERE merchant_id = $1
+ORDER BY period_start DESC;
+
+-- name: ListEventArchivesOverlapping :many
+SELECT * FROM event_archives
+WHERE merchant_id = sqlc.arg(merchant_id)
+  AND period_start < sqlc.arg(range_end)
+  AND period_end > sqlc.arg(range_start)
+ORDER BY period_start;
+
+-- name: SetEventArchiveRehydratedUntil :exec
+UPDATE event_archives
+SET rehydrated_until = $3
+WHERE merchant_id = $1 AND period_start = $2;
+
+-- name: InsertArchivedEvents :execrows
+-- Restores archived events, skipping those still in the database.
+INSERT INTO events (id, merchant_id, customer_id, sku_id, amount, sent_at)
+SELECT unnest(sqlc.arg(ids)::uuid[]),
+       sqlc.arg(merchant_id)::uuid,
+       unnest(sqlc.arg(customer_ids)::uuid[]),
+       unnest(sqlc.arg(sku_ids)::uuid[]),
+       unnest(sqlc.arg(amounts)::double precision[]),
+       unnest(sqlc.arg(sent_ats)::timestamptz[])
+ON CONFLICT (id, sent_at) DO NOTHING;
+
+-- name: GetEventArchive :one
+SELECT * FROM event_archives
+WHERE merchant_id = $1 AND period_start = $2;
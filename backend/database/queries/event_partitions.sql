@@ -0,0 +1,69 @@
+-- name: ListEventPartitions :many
+-- The monthly partitions of events, i.e. all but the default one.
+SELECT c.relname::text AS name
+FROM pg_catalog.pg_inherits i
+JOIN pg_catalog.pg_class c ON c.oid = i.inhrelid
+JOIN pg_catalog.pg_class p ON p.oid = i.inhparent
+WHERE p.relname = 'events'
+  AND c.relname <> 'events_default'
+ORDER BY c.relname;
+
+-- name: ListDefaultPartitionMonths :many
+-- The months of the events that fell in the default partition, for lack of a
+-- monthly partition.
+SELECT DISTINCT date_trunc('month', sent_at, 'UTC')::timestamptz AS month
+FROM events_default
+ORDER BY month;
+
+-- name: IsEventRangeExpired :one
+-- Whether the events over [range_start, range_end) can be dropped: every
+-- merchant with events in the range has a retention window the range ended
+-- before, all their events are archived and covered by a finalized invoice,
+-- and none are held by a rehydration.
+SELECT (NOT EXISTS (
+    SELECT 1
+    FROM (
+        SELECT e.merchant_id, count(*) AS event_count
+        FROM events e
+        WHERE e.sent_at >= sqlc.arg(range_start)
+          AND e.sent_at < sqlc.arg(range_end)
+        GROUP BY e.merchant_id
+    ) m
+    LEFT JOIN billing_settings bs ON bs.merchant_id = m.merchant_id
+    LEFT JOIN event_archives a ON a.merchant_id = m.merchant_id AND a.period_start = sqlc.arg(range_start)
+    WHERE bs.event_retention_months IS NULL
+       OR sqlc.arg(range_end)::timestamptz > now() - make_interval(months => bs.event_retention_months)
+       OR a.database_event_count IS DISTINCT FROM m.event_count
+       OR a.rehydrated_until > now()
+       OR EXISTS (
+        SELECT 1 FROM events e
+        WHERE e.merchant_id = m.merchant_id
+          AND e.sent_at >= sqlc.arg(range_start)
+          AND e.sent_at < sqlc.arg(range_end)
+          AND NOT EXISTS (
+            SELECT 1 FROM invoices i
+            WHERE i.merchant_id = e.merchant_id
+              AND i.customer_id = e.customer_id
+              AND i.period_start <= e.sent_at
+              AND e.sent_at < i.period_end
+              AND i.status <> 'draft'
+          )
+       )
+))::boolean;
+
+-- name: ListUnarchivedExpiredEventMerchants :many
+-- The merchants with events over [range_start, range_end) past their
+-- retention window but not (fully) archived, which hold the range back.
+SELECT m.merchant_id
+FROM (
+    SELECT e.merchant_id, count(*) AS event_count
+    FROM events e
+    WHERE e.sent_at >= sqlc.arg(range_start)
+      AND e.sent_at < sqlc.arg(range_end)
+    GROUP BY e.merchant_id
+) m
+JOIN billing_settings bs ON bs.merchant_id = m.merchant_id
+LEFT JOIN event_archives a ON a.merchant_id = m.merchant_id AND a.period_start = sqlc.arg(range_start)
+WHERE sqlc.arg(range_end)::timestamptz <= now() - make_interval(months => bs.event_retention_months)
+  AND a.database_event_count IS DISTINCT FROM m.event_count
+ORDER BY m.merchant_id;
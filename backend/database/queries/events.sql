@@ -4,3 +4,29 @@ VALUES ($1, $2, $3, $4, $5);
 
 -- name: ListEventsByMerchantID :many
 SELECT * FROM events WHERE merchant_id = $1;
+
+-- name: ListUsageBySKU :many
+-- Usage is rated per event: a customer's price override on the SKU takes
+-- precedence over the SKU list price when the event falls within its window.
+-- A SKU therefore yields one row per distinct unit price over the period.
+SELECT e.sku_id, s.name, COALESCE(o.price_per_unit, s.price_per_unit)::double precision AS unit_price, SUM(e.amount)::double precision AS quantity
+FROM events e
+JOIN skus s ON s.id = e.sku_id
+LEFT JOIN LATERAL (
+    SELECT po.price_per_unit
+    FROM price_overrides po
+    WHERE po.merchant_id = e.merchant_id
+      AND po.sku_id = e.sku_id
+      AND po.customer_id = e.customer_id
+      AND po.revoked_at IS NULL
+      AND (po.starts_at IS NULL OR po.starts_at <= e.sent_at)
+      AND (po.ends_at IS NULL OR e.sent_at < po.ends_at)
+    ORDER BY po.created_at DESC
+    LIMIT 1
+) o ON true
+WHERE e.merchant_id = sqlc.arg(merchant_id)
+  AND e.customer_id = sqlc.arg(customer_id)
+  AND e.sent_at >= sqlc.arg(period_start)
+  AND e.sent_at < sqlc.arg(period_end)
+GROUP BY e.sku_id, s.name, unit_price
+ORDER BY s.name, unit_price;
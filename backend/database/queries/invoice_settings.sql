@@ -0,0 +1,32 @@
+-- name: GetInvoiceSettings :one
+SELECT * FROM invoice_settings
+WHERE merchant_id = $1;
+
+-- name: UpdateInvoiceSettings :one
+INSERT INTO invoice_settings (merchant_id, number_prefix, payment_terms_days, accent_color, header_template, footer_template)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (merchant_id) DO UPDATE
+SET number_prefix = EXCLUDED.number_prefix,
+    payment_terms_days = EXCLUDED.payment_terms_days,
+    accent_color = EXCLUDED.accent_color,
+    header_template = EXCLUDED.header_template,
+    footer_template = EXCLUDED.footer_template,
+    updated_at = now()
+RETURNING *;
+
+-- name: UpdateInvoiceLogo :exec
+INSERT INTO invoice_settings (merchant_id, logo, logo_content_type)
+VALUES ($1, $2, $3)
+ON CONFLICT (merchant_id) DO UPDATE
+SET logo = EXCLUDED.logo,
+    logo_content_type = EXCLUDED.logo_content_type,
+    updated_at = now();
+
+-- name: NextInvoiceNumber :one
+-- Hands out the merchant's next invoice number. The row lock taken by the
+-- upsert serializes concurrent finalizations.
+INSERT INTO invoice_settings (merchant_id, last_invoice_number)
+VALUES ($1, 1)
+ON CONFLICT (merchant_id) DO UPDATE
+SET last_invoice_number = invoice_settings.last_invoice_number + 1
+RETURNING number_prefix, last_invoice_number, payment_terms_days;
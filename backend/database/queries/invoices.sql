@@ -0,0 +1,97 @@
+-- name: CreateInvoice :one
+INSERT INTO invoices (merchant_id, customer_id, period_start, period_end, subtotal, discount_total, tax_total, total)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: CreateInvoiceLine :exec
+INSERT INTO invoice_lines (invoice_id, position, kind, description, sku_id, quantity, unit_price, amount, coupon_redemption_id, tax_rate_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);
+
+-- name: DeleteDraftInvoice :exec
+DELETE FROM invoices
+WHERE merchant_id = $1
+  AND customer_id = $2
+  AND period_start = $3
+  AND period_end = $4
+  AND status = 'draft';
+
+-- name: GetInvoice :one
+SELECT * FROM invoices
+WHERE id = $1 AND merchant_id = $2;
+
+-- name: ListInvoicesByMerchantID :many
+SELECT * FROM invoices
+WHERE merchant_id = $1
+ORDER BY period_start DESC, created_at DESC;
+
+-- name: ListInvoiceLines :many
+SELECT * FROM invoice_lines
+WHERE invoice_id = $1
+ORDER BY position;
+
+-- name: FinalizeInvoice :one
+UPDATE invoices
+SET status = 'finalized', finalized_at = now()
+WHERE id = $1 AND merchant_id = $2 AND status = 'draft'
+RETURNING *;
+
+-- name: SetInvoiceNumber :one
+UPDATE invoices
+SET number = $2, due_at = $3
+WHERE id = $1
+RETURNING *;
+
+-- name: SetInvoicePayment :one
+UPDATE invoices
+SET payment_provider = $2, payment_id = $3
+WHERE id = $1 AND payment_id IS NULL
+RETURNING *;
+
+-- name: GetInvoiceByPayment :one
+SELECT * FROM invoices
+WHERE payment_provider = $1 AND payment_id = $2;
+
+-- name: UpdateInvoicePaymentStatus :one
+-- Moves the invoice into a payment status, only from the statuses that lead
+-- to it, so that late, duplicate or out of order provider events match no
+-- row.
+UPDATE invoices
+SET status = sqlc.arg(status),
+    paid_at = CASE WHEN sqlc.arg(status) = 'paid' THEN now() ELSE paid_at END
+WHERE id = sqlc.arg(id)
+  AND status = ANY(CASE sqlc.arg(status)::text
+      WHEN 'paid' THEN ARRAY['finalized', 'payment_failed', 'uncollectible']
+      WHEN 'payment_failed' THEN ARRAY['finalized', 'payment_failed']
+      WHEN 'refunded' THEN ARRAY['paid']
+      WHEN 'uncollectible' THEN ARRAY['finalized', 'payment_failed']
+  END)
+RETURNING *;
+
+-- name: ListBillableCustomers :many
+-- Customers with usage counters of periods ending since the given time, with
+-- the timezone and anchor day their billing periods follow: the customer's
+-- timezone, if set, takes precedence over the merchant's.
+SELECT DISTINCT uc.merchant_id, uc.customer_id,
+    COALESCE(c.timezone, bs.timezone, 'UTC')::text AS timezone,
+    COALESCE(bs.anchor_day, 1)::integer AS anchor_day
+FROM usage_counters uc
+LEFT JOIN customers c ON c.merchant_id = uc.merchant_id AND c.id = uc.customer_id
+LEFT JOIN billing_settings bs ON bs.merchant_id = uc.merchant_id
+WHERE uc.period_end >= sqlc.arg(since);
+
+-- name: HasUninvoicedUsage :one
+-- Whether the customer has usage over the period and no finalized invoice for it.
+SELECT (EXISTS (
+    SELECT 1 FROM events e
+    WHERE e.merchant_id = sqlc.arg(merchant_id)
+      AND e.customer_id = sqlc.arg(customer_id)
+      AND e.sent_at >= sqlc.arg(period_start)
+      AND e.sent_at < sqlc.arg(period_end)
+) AND NOT EXISTS (
+    SELECT 1 FROM invoices i
+    WHERE i.merchant_id = sqlc.arg(merchant_id)
+      AND i.customer_id = sqlc.arg(customer_id)
+      AND i.period_start = sqlc.arg(period_start)
+      AND i.period_end = sqlc.arg(period_end)
+      AND i.status <> 'draft'
+))::boolean;
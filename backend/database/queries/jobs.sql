@@ -0,0 +1,68 @@
+-- name: EnqueueJob :execrows
+-- A job with the unique key of a previous job is not enqueued again.
+INSERT INTO jobs (kind, args, unique_key, run_at, max_attempts)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (unique_key) DO NOTHING;
+
+-- name: ClaimJob :one
+-- Leases the next due job: it is handed out again if the lease expires, e.g.
+-- because the worker running it died.
+UPDATE jobs
+SET attempts = attempts + 1, locked_until = now() + sqlc.arg(lease)::interval
+WHERE id = (
+    SELECT j.id FROM jobs j
+    WHERE j.completed_at IS NULL
+      AND j.failed_at IS NULL
+      AND j.run_at <= now()
+      AND (j.locked_until IS NULL OR j.locked_until < now())
+      AND j.kind = ANY(sqlc.arg(kinds)::text[])
+    ORDER BY j.run_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteJob :exec
+UPDATE jobs
+SET completed_at = now(), locked_until = NULL, last_error = NULL
+WHERE id = $1;
+
+-- name: RetryJob :exec
+UPDATE jobs
+SET run_at = $2, locked_until = NULL, last_error = $3
+WHERE id = $1;
+
+-- name: FailJob :exec
+UPDATE jobs
+SET failed_at = now(), locked_until = NULL, last_error = $2
+WHERE id = $1;
+
+-- name: DeleteCompletedJobs :execrows
+DELETE FROM jobs
+WHERE completed_at < $1;
+
+-- name: UpsertJobSchedule :exec
+-- Registering a schedule again only updates its interval.
+INSERT INTO job_schedules (kind, every)
+VALUES ($1, $2)
+ON CONFLICT (kind) DO UPDATE
+SET every = EXCLUDED.every;
+
+-- name: ClaimJobSchedule :one
+-- Picks a due schedule among kinds and pushes its next run. Schedules do not
+-- catch up on missed runs.
+UPDATE job_schedules
+SET next_run_at = now() + every, last_run_at = now()
+WHERE kind = (
+    SELECT s.kind FROM job_schedules s
+    WHERE s.next_run_at <= now() AND s.kind = ANY(sqlc.arg(kinds)::text[])
+    ORDER BY s.next_run_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: SetJobScheduleError :exec
+UPDATE job_schedules
+SET last_error = $2
+WHERE kind = $1;
@@ -0,0 +1,16 @@
+-- name: GetMerchantRateLimit :one
+SELECT * FROM merchant_rate_limits
+WHERE merchant_id = $1;
+
+-- name: PutMerchantRateLimit :one
+INSERT INTO merchant_rate_limits (merchant_id, rate_limit_per_second, rate_limit_burst)
+VALUES ($1, $2, $3)
+ON CONFLICT (merchant_id) DO UPDATE
+SET rate_limit_per_second = EXCLUDED.rate_limit_per_second,
+    rate_limit_burst = EXCLUDED.rate_limit_burst,
+    updated_at = now()
+RETURNING *;
+
+-- name: DeleteMerchantRateLimit :exec
+DELETE FROM merchant_rate_limits
+WHERE merchant_id = $1;
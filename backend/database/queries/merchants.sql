@@ -6,9 +6,25 @@ RETURNING id, email, name, created_at, updated_at;
 -- name: GetMerchantByEmail :one
 SELECT id, email, password_hash, name, created_at, updated_at
 FROM merchants
-WHERE email = $1;
+WHERE email = $1 AND live_merchant_id IS NULL;
 
 -- name: GetMerchantByID :one
 SELECT id, email, name, created_at, updated_at
 FROM merchants
 WHERE id = $1;
+
+-- name: GetTestMerchantID :one
+SELECT id FROM merchants
+WHERE live_merchant_id = $1;
+
+-- name: CreateTestMerchant :exec
+-- Test merchants have no password, and only exist for live ones.
+INSERT INTO merchants (email, password_hash, name, live_merchant_id)
+SELECT m.email, '', m.name, m.id
+FROM merchants m
+WHERE m.id = $1 AND m.live_merchant_id IS NULL
+ON CONFLICT (live_merchant_id) DO NOTHING;
+
+-- name: IsTestMerchant :one
+SELECT (live_merchant_id IS NOT NULL)::boolean FROM merchants
+WHERE id = $1;
@@ -0,0 +1,23 @@
+-- name: InsertOutboxEvent :one
+INSERT INTO outbox_events (merchant_id, event_type, payload)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: ClaimOutboxEvents :many
+-- Events are relayed in the order they were written. Claimed rows stay locked
+-- until the relaying transaction ends.
+SELECT * FROM outbox_events
+WHERE published_at IS NULL AND next_attempt_at <= now()
+ORDER BY created_at
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: MarkOutboxEventPublished :exec
+UPDATE outbox_events
+SET published_at = now(), attempts = attempts + 1, last_error = NULL
+WHERE id = $1;
+
+-- name: MarkOutboxEventFailed :exec
+UPDATE outbox_events
+SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+WHERE id = $1;
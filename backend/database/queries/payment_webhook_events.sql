@@ -0,0 +1,24 @@
+-- name: InsertPaymentWebhookEvent :execrows
+-- Providers deliver webhooks at least once: a redelivered event is a no-op.
+INSERT INTO payment_webhook_events (provider, event_id, event_type, payment_id, payload, occurred_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (provider, event_id) DO NOTHING;
+
+-- name: ClaimPaymentWebhookEvent :one
+-- Events are applied in the order they occurred at the provider. The row stays
+-- locked until the claiming transaction ends.
+SELECT * FROM payment_webhook_events
+WHERE processed_at IS NULL AND next_attempt_at <= now() AND attempts < sqlc.arg(max_attempts)::integer
+ORDER BY occurred_at
+LIMIT 1
+FOR UPDATE SKIP LOCKED;
+
+-- name: MarkPaymentWebhookEventProcessed :exec
+UPDATE payment_webhook_events
+SET processed_at = now(), attempts = attempts + 1, last_error = NULL
+WHERE provider = $1 AND event_id = $2;
+
+-- name: MarkPaymentWebhookEventFailed :exec
+UPDATE payment_webhook_events
+SET attempts = attempts + 1, last_error = $3, next_attempt_at = $4
+WHERE provider = $1 AND event_id = $2;
@@ -0,0 +1,14 @@
+-- name: CreatePriceOverride :one
+INSERT INTO price_overrides (merchant_id, sku_id, customer_id, price_per_unit, starts_at, ends_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: ListPriceOverridesBySKU :many
+SELECT * FROM price_overrides
+WHERE sku_id = $1 AND merchant_id = $2
+ORDER BY created_at DESC;
+
+-- name: RevokePriceOverride :exec
+UPDATE price_overrides
+SET revoked_at = now()
+WHERE id = $1 AND sku_id = $2 AND merchant_id = $3 AND revoked_at IS NULL;
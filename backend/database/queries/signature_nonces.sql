@@ -0,0 +1,9 @@
+-- name: UseSignatureNonce :execrows
+-- Records the nonce of the key, unless it was already used.
+INSERT INTO signature_nonces (key_id, nonce, expires_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (key_id, nonce) DO NOTHING;
+
+-- name: DeleteExpiredSignatureNonces :execrows
+DELETE FROM signature_nonces
+WHERE expires_at < now();
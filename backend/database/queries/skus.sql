@@ -8,7 +8,17 @@ SELECT * FROM skus
 WHERE merchant_id = $1
 ORDER BY created_at DESC;
 
--- name: RevokeSKU :exec
+-- name: RevokeSKU :one
 UPDATE skus
 SET revoked_at = now()
-WHERE id = $1 AND merchant_id = $2 AND revoked_at IS NULL;
+WHERE id = $1 AND merchant_id = $2 AND revoked_at IS NULL
+RETURNING *;
+
+-- name: GetSKU :one
+SELECT * FROM skus
+WHERE id = $1 AND merchant_id = $2;
+
+-- name: CountMerchantSKUs :one
+-- How many of the given SKUs belong to the merchant.
+SELECT count(*) FROM skus
+WHERE merchant_id = $1 AND id = ANY(sqlc.arg(ids)::uuid[]);
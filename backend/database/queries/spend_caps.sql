@@ -0,0 +1,64 @@
+-- name: PutSpendCap :one
+-- Changing the limit keeps the spend recorded so far.
+INSERT INTO spend_caps (merchant_id, customer_id, amount_limit, per_period)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (merchant_id, customer_id) DO UPDATE
+SET amount_limit = EXCLUDED.amount_limit,
+    per_period = EXCLUDED.per_period,
+    updated_at = now()
+RETURNING *;
+
+-- name: GetSpendCap :one
+SELECT * FROM spend_caps
+WHERE merchant_id = $1 AND customer_id = $2;
+
+-- name: DeleteSpendCap :exec
+DELETE FROM spend_caps
+WHERE merchant_id = $1 AND customer_id = $2;
+
+-- name: GetSpendCapForEvent :one
+-- The customer's cap, with what is needed to charge an event against it in
+-- a single round trip: the billing period settings of the customer and the
+-- unit price of the event's SKU, a price override taking precedence.
+SELECT sc.*,
+    COALESCE(c.timezone, bs.timezone, 'UTC')::text AS timezone,
+    COALESCE(bs.anchor_day, 1)::integer AS anchor_day,
+    COALESCE((
+        SELECT po.price_per_unit
+        FROM price_overrides po
+        WHERE po.merchant_id = sc.merchant_id
+          AND po.sku_id = s.id
+          AND po.customer_id = sc.customer_id
+          AND po.revoked_at IS NULL
+          AND (po.starts_at IS NULL OR po.starts_at <= sqlc.arg(sent_at))
+          AND (po.ends_at IS NULL OR sqlc.arg(sent_at) < po.ends_at)
+        ORDER BY po.created_at DESC
+        LIMIT 1
+    ), s.price_per_unit)::double precision AS unit_price
+FROM spend_caps sc
+JOIN skus s ON s.id = sqlc.arg(sku_id) AND s.merchant_id = sc.merchant_id
+LEFT JOIN customers c ON c.merchant_id = sc.merchant_id AND c.id = sc.customer_id
+LEFT JOIN billing_settings bs ON bs.merchant_id = sc.merchant_id
+WHERE sc.merchant_id = sqlc.arg(merchant_id) AND sc.customer_id = sqlc.arg(customer_id);
+
+-- name: ConsumeSpendCap :execrows
+-- Adds cost to the spend, starting over when a per-period cap enters a new
+-- period, unless that exceeds the limit. The row lock serializes concurrent
+-- events of the customer.
+UPDATE spend_caps
+SET spent = CASE WHEN per_period AND period_start IS DISTINCT FROM sqlc.arg(period_start) THEN 0 ELSE spent END + sqlc.arg(cost)::double precision,
+    period_start = sqlc.arg(period_start),
+    updated_at = now()
+WHERE merchant_id = sqlc.arg(merchant_id)
+  AND customer_id = sqlc.arg(customer_id)
+  AND CASE WHEN per_period AND period_start IS DISTINCT FROM sqlc.arg(period_start) THEN 0 ELSE spent END + sqlc.arg(cost)::double precision <= amount_limit;
+
+-- name: RefundSpendCap :exec
+-- Gives back cost charged in the given period, unless a per-period cap has
+-- started over since.
+UPDATE spend_caps
+SET spent = GREATEST(spent - sqlc.arg(cost)::double precision, 0),
+    updated_at = now()
+WHERE merchant_id = sqlc.arg(merchant_id)
+  AND customer_id = sqlc.arg(customer_id)
+  AND (NOT per_period OR period_start = sqlc.arg(period_start));
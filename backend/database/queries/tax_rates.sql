@@ -0,0 +1,22 @@
+-- name: CreateTaxRate :one
+INSERT INTO tax_rates (merchant_id, name, country, region, percentage, reverse_charge)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: ListTaxRatesByMerchantID :many
+SELECT * FROM tax_rates
+WHERE merchant_id = $1
+ORDER BY country, region NULLS FIRST, created_at;
+
+-- name: RevokeTaxRate :exec
+UPDATE tax_rates
+SET revoked_at = now()
+WHERE id = $1 AND merchant_id = $2 AND revoked_at IS NULL;
+
+-- name: ListApplicableTaxRates :many
+SELECT * FROM tax_rates
+WHERE merchant_id = sqlc.arg(merchant_id)
+  AND country = sqlc.arg(country)
+  AND (region IS NULL OR region = sqlc.narg(region))
+  AND revoked_at IS NULL
+ORDER BY region NULLS FIRST, created_at;
@@ -0,0 +1,55 @@
+-- Wiping the data of a test merchant. The queries are run in this order, so
+-- that rows are deleted before those they reference. Configuration, such as
+-- SKUs, settings and API keys, is kept.
+
+-- name: DeleteMerchantUsageAlertCrossings :exec
+DELETE FROM usage_alert_crossings
+WHERE alert_id IN (SELECT id FROM usage_alerts WHERE merchant_id = $1);
+
+-- name: DeleteMerchantInvoices :exec
+-- Their lines and dunnings are deleted along.
+DELETE FROM invoices
+WHERE merchant_id = $1;
+
+-- name: DeleteMerchantCouponRedemptions :exec
+DELETE FROM coupon_redemptions
+WHERE merchant_id = $1;
+
+-- name: DeleteMerchantPriceOverrides :exec
+DELETE FROM price_overrides
+WHERE merchant_id = $1;
+
+-- name: DeleteMerchantSpendCaps :exec
+DELETE FROM spend_caps
+WHERE merchant_id = $1;
+
+-- name: DeleteMerchantUsageCounters :exec
+DELETE FROM usage_counters
+WHERE merchant_id = $1;
+
+-- name: DeleteMerchantHourlyRollups :exec
+DELETE FROM usage_rollups_hourly
+WHERE merchant_id = $1;
+
+-- name: DeleteMerchantDailyRollups :exec
+DELETE FROM usage_rollups_daily
+WHERE merchant_id = $1;
+
+-- name: DeleteMerchantRollupDirtyHours :exec
+DELETE FROM usage_rollup_dirty_hours
+WHERE merchant_id = $1;
+
+-- name: DeleteMerchantEventArchives :many
+-- Returns the blob keys of the archives, to delete them from the blob store
+-- once committed.
+DELETE FROM event_archives
+WHERE merchant_id = $1
+RETURNING blob_key;
+
+-- name: DeleteMerchantEvents :execrows
+DELETE FROM events
+WHERE merchant_id = $1;
+
+-- name: DeleteMerchantCustomers :execrows
+DELETE FROM customers
+WHERE merchant_id = $1;
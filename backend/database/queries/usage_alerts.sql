@@ -0,0 +1,72 @@
+-- name: CreateUsageAlert :one
+INSERT INTO usage_alerts (merchant_id, customer_id, sku_id, budget, thresholds)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: ListUsageAlertsByMerchantID :many
+SELECT * FROM usage_alerts
+WHERE merchant_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetUsageAlert :one
+SELECT * FROM usage_alerts
+WHERE id = $1 AND merchant_id = $2;
+
+-- name: RevokeUsageAlert :exec
+UPDATE usage_alerts
+SET revoked_at = now()
+WHERE id = $1 AND merchant_id = $2 AND revoked_at IS NULL;
+
+-- name: ListUsageAlertsToEvaluate :many
+-- Active alerts, with the timezone and anchor day of their customer's
+-- billing periods.
+SELECT a.*,
+    COALESCE(c.timezone, bs.timezone, 'UTC')::text AS timezone,
+    COALESCE(bs.anchor_day, 1)::integer AS anchor_day
+FROM usage_alerts a
+LEFT JOIN customers c ON c.merchant_id = a.merchant_id AND c.id = a.customer_id
+LEFT JOIN billing_settings bs ON bs.merchant_id = a.merchant_id
+WHERE a.revoked_at IS NULL;
+
+-- name: GetSKUUsage :one
+SELECT COALESCE(SUM(quantity), 0)::double precision AS quantity
+FROM usage_counters
+WHERE merchant_id = sqlc.arg(merchant_id)
+  AND customer_id = sqlc.arg(customer_id)
+  AND sku_id = sqlc.arg(sku_id)
+  AND period_start = sqlc.arg(period_start);
+
+-- name: GetCustomerSpend :one
+-- The customer's spend over the period, at the current prices: the
+-- customer's active price override on a SKU takes precedence over the SKU
+-- list price.
+SELECT COALESCE(SUM(uc.quantity * COALESCE(o.price_per_unit, s.price_per_unit)), 0)::double precision AS spend
+FROM usage_counters uc
+JOIN skus s ON s.id = uc.sku_id
+LEFT JOIN LATERAL (
+    SELECT po.price_per_unit
+    FROM price_overrides po
+    WHERE po.merchant_id = uc.merchant_id
+      AND po.sku_id = uc.sku_id
+      AND po.customer_id = uc.customer_id
+      AND po.revoked_at IS NULL
+      AND (po.starts_at IS NULL OR po.starts_at <= now())
+      AND (po.ends_at IS NULL OR now() < po.ends_at)
+    ORDER BY po.created_at DESC
+    LIMIT 1
+) o ON true
+WHERE uc.merchant_id = sqlc.arg(merchant_id)
+  AND uc.customer_id = sqlc.arg(customer_id)
+  AND uc.period_start = sqlc.arg(period_start);
+
+-- name: RecordUsageAlertCrossing :execrows
+-- Records a crossing once per alert, period and threshold.
+INSERT INTO usage_alert_crossings (alert_id, period_start, threshold, usage)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT DO NOTHING;
+
+-- name: ListUsageAlertCrossings :many
+SELECT * FROM usage_alert_crossings
+WHERE alert_id = $1
+ORDER BY crossed_at DESC
+LIMIT 100;
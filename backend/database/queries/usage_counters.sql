@@ -0,0 +1,53 @@
+-- name: GetBillingPeriodSettings :one
+-- The timezone and anchor day of the customer's billing periods: the
+-- customer's timezone, if set, takes precedence over the merchant's.
+SELECT COALESCE(c.timezone, bs.timezone, 'UTC')::text AS timezone,
+    COALESCE(bs.anchor_day, 1)::integer AS anchor_day
+FROM merchants m
+LEFT JOIN customers c ON c.merchant_id = m.id AND c.id = sqlc.arg(customer_id)
+LEFT JOIN billing_settings bs ON bs.merchant_id = m.id
+WHERE m.id = sqlc.arg(merchant_id);
+
+-- name: IncrementUsageCounter :exec
+INSERT INTO usage_counters (merchant_id, customer_id, sku_id, period_start, period_end, quantity, event_count)
+VALUES ($1, $2, $3, $4, $5, $6, 1)
+ON CONFLICT (merchant_id, customer_id, period_start, sku_id) DO UPDATE
+SET quantity = usage_counters.quantity + EXCLUDED.quantity,
+    event_count = usage_counters.event_count + 1,
+    updated_at = now();
+
+-- name: ListUsageCounters :many
+SELECT uc.sku_id, s.name, uc.quantity, uc.event_count, uc.updated_at
+FROM usage_counters uc
+JOIN skus s ON s.id = uc.sku_id
+WHERE uc.merchant_id = $1
+  AND uc.customer_id = $2
+  AND uc.period_start = $3
+ORDER BY s.name;
+
+-- name: ListUsageCountersToReconcile :many
+-- The counters of current periods. Those of closed periods are left as is:
+-- their events may be archived and dropped, and summing what remains would
+-- undercount them.
+SELECT merchant_id, customer_id, sku_id, period_start
+FROM usage_counters
+WHERE period_end > now() AND period_start <= now();
+
+-- name: LockUsageCounter :one
+SELECT * FROM usage_counters
+WHERE merchant_id = $1 AND customer_id = $2 AND period_start = $3 AND sku_id = $4
+FOR UPDATE;
+
+-- name: AggregateUsage :one
+SELECT COALESCE(SUM(amount), 0)::double precision AS quantity, COUNT(*) AS event_count
+FROM events
+WHERE merchant_id = sqlc.arg(merchant_id)
+  AND customer_id = sqlc.arg(customer_id)
+  AND sku_id = sqlc.arg(sku_id)
+  AND sent_at >= sqlc.arg(period_start)
+  AND sent_at < sqlc.arg(period_end);
+
+-- name: SetUsageCounter :exec
+UPDATE usage_counters
+SET quantity = $5, event_count = $6, reconciled_at = now()
+WHERE merchant_id = $1 AND customer_id = $2 AND period_start = $3 AND sku_id = $4;
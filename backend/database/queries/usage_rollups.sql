@@ -0,0 +1,74 @@
+-- name: MarkRollupHourDirty :exec
+INSERT INTO usage_rollup_dirty_hours (merchant_id, hour)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: ClaimRollupDirtyHours :many
+DELETE FROM usage_rollup_dirty_hours
+WHERE (merchant_id, hour) IN (
+    SELECT d.merchant_id, d.hour FROM usage_rollup_dirty_hours d
+    ORDER BY d.hour
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: DeleteHourlyRollups :exec
+DELETE FROM usage_rollups_hourly
+WHERE merchant_id = $1 AND bucket_start = $2;
+
+-- name: InsertHourlyRollups :exec
+INSERT INTO usage_rollups_hourly (merchant_id, bucket_start, customer_id, sku_id, quantity, event_count)
+SELECT e.merchant_id, sqlc.arg(bucket_start)::timestamptz, e.customer_id, e.sku_id, SUM(e.amount), COUNT(*)
+FROM events e
+WHERE e.merchant_id = sqlc.arg(merchant_id)
+  AND e.sent_at >= sqlc.arg(bucket_start)::timestamptz
+  AND e.sent_at < sqlc.arg(bucket_start)::timestamptz + interval '1 hour'
+GROUP BY e.merchant_id, e.customer_id, e.sku_id;
+
+-- name: DeleteDailyRollups :exec
+DELETE FROM usage_rollups_daily
+WHERE merchant_id = $1 AND bucket_start = $2;
+
+-- name: InsertDailyRollups :exec
+INSERT INTO usage_rollups_daily (merchant_id, bucket_start, customer_id, sku_id, quantity, event_count)
+SELECT h.merchant_id, sqlc.arg(bucket_start)::timestamptz, h.customer_id, h.sku_id, SUM(h.quantity), SUM(h.event_count)
+FROM usage_rollups_hourly h
+WHERE h.merchant_id = sqlc.arg(merchant_id)
+  AND h.bucket_start >= sqlc.arg(bucket_start)::timestamptz
+  AND h.bucket_start < sqlc.arg(bucket_start)::timestamptz + interval '1 day'
+GROUP BY h.merchant_id, h.customer_id, h.sku_id;
+
+-- name: ListHourlyUsage :many
+SELECT bucket_start, sku_id, SUM(quantity)::double precision AS quantity, SUM(event_count)::bigint AS event_count
+FROM usage_rollups_hourly
+WHERE merchant_id = sqlc.arg(merchant_id)
+  AND bucket_start >= sqlc.arg(from_time)
+  AND bucket_start < sqlc.arg(to_time)
+  AND (sqlc.narg(customer_id)::uuid IS NULL OR customer_id = sqlc.narg(customer_id))
+  AND (sqlc.narg(sku_id)::uuid IS NULL OR sku_id = sqlc.narg(sku_id))
+GROUP BY bucket_start, sku_id
+ORDER BY bucket_start, sku_id;
+
+-- name: ListDailyUsage :many
+SELECT bucket_start, sku_id, SUM(quantity)::double precision AS quantity, SUM(event_count)::bigint AS event_count
+FROM usage_rollups_daily
+WHERE merchant_id = sqlc.arg(merchant_id)
+  AND bucket_start >= sqlc.arg(from_time)
+  AND bucket_start < sqlc.arg(to_time)
+  AND (sqlc.narg(customer_id)::uuid IS NULL OR customer_id = sqlc.narg(customer_id))
+  AND (sqlc.narg(sku_id)::uuid IS NULL OR sku_id = sqlc.narg(sku_id))
+GROUP BY bucket_start, sku_id
+ORDER BY bucket_start, sku_id;
+
+-- name: ListMinuteUsage :many
+-- Aggregates raw events, for granularities finer than the rollups.
+SELECT date_trunc('minute', sent_at)::timestamptz AS bucket_start, sku_id, SUM(amount)::double precision AS quantity, COUNT(*)::bigint AS event_count
+FROM events
+WHERE merchant_id = sqlc.arg(merchant_id)
+  AND sent_at >= sqlc.arg(from_time)
+  AND sent_at < sqlc.arg(to_time)
+  AND (sqlc.narg(customer_id)::uuid IS NULL OR customer_id = sqlc.narg(customer_id))
+  AND (sqlc.narg(sku_id)::uuid IS NULL OR sku_id = sqlc.narg(sku_id))
+GROUP BY 1, sku_id
+ORDER BY 1, sku_id;
@@ -0,0 +1,77 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (merchant_id, url, secret, event_types)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: ListWebhookEndpointsByMerchantID :many
+SELECT * FROM webhook_endpoints
+WHERE merchant_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints
+WHERE id = $1 AND merchant_id = $2;
+
+-- name: RevokeWebhookEndpoint :exec
+UPDATE webhook_endpoints
+SET revoked_at = now()
+WHERE id = $1 AND merchant_id = $2 AND revoked_at IS NULL;
+
+-- name: ListWebhookEndpointsForEvent :many
+-- An endpoint without event types subscribes to all of them.
+SELECT * FROM webhook_endpoints
+WHERE merchant_id = $1
+  AND revoked_at IS NULL
+  AND (cardinality(event_types) = 0 OR sqlc.arg(event_type)::text = ANY(event_types));
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (endpoint_id, merchant_id, event_id, event_type, payload)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: ClaimWebhookDelivery :one
+-- Leases the next due delivery: it is not handed out again before the lease
+-- expires, even if the claiming process dies mid-delivery.
+UPDATE webhook_deliveries
+SET next_attempt_at = now() + sqlc.arg(lease)::interval
+WHERE id = (
+    SELECT d.id FROM webhook_deliveries d
+    WHERE d.next_attempt_at <= now()
+    ORDER BY d.next_attempt_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: RecordWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (delivery_id, response_status, error, duration_ms)
+VALUES ($1, $2, $3, $4);
+
+-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET attempts = attempts + 1, last_response_status = $2, next_attempt_at = NULL, delivered_at = now()
+WHERE id = $1;
+
+-- name: MarkWebhookDeliveryFailed :exec
+-- A delivery without a next attempt has exhausted its retries.
+UPDATE webhook_deliveries
+SET attempts = attempts + 1,
+    last_response_status = $2,
+    next_attempt_at = sqlc.narg(next_attempt_at),
+    failed_at = CASE WHEN sqlc.narg(next_attempt_at)::timestamptz IS NULL THEN now() END
+WHERE id = $1;
+
+-- name: ListWebhookDeliveriesByEndpoint :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = $1 AND merchant_id = $2
+ORDER BY created_at DESC
+LIMIT 100;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = $1 AND endpoint_id = $2 AND merchant_id = $3;
+
+-- name: ListWebhookDeliveryAttempts :many
+SELECT * FROM webhook_delivery_attempts
+WHERE delivery_id = $1
+ORDER BY attempted_at;
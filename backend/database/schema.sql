@@ -29,10 +29,124 @@ CREATE TABLE public.api_keys (
     key_prefix text NOT NULL,
     key_hash text NOT NULL,
     revoked_at timestamp with time zone,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    rate_limit_per_second integer,
+    rate_limit_burst integer,
+    scopes text[] NOT NULL,
+    expires_at timestamp with time zone,
+    previous_key_hash text,
+    previous_key_expires_at timestamp with time zone,
+    rotated_at timestamp with time zone,
+    last_used_at timestamp with time zone,
+    signing_secret text,
+    CONSTRAINT api_keys_rate_limit_burst_check CHECK ((rate_limit_burst > 0)),
+    CONSTRAINT api_keys_rate_limit_per_second_check CHECK ((rate_limit_per_second > 0))
+);
+
+
+--
+-- Name: billing_settings; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.billing_settings (
+    merchant_id uuid NOT NULL,
+    timezone text DEFAULT 'UTC'::text NOT NULL,
+    anchor_day integer DEFAULT 1 NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    event_retention_months integer,
+    CONSTRAINT billing_settings_anchor_day_check CHECK (((anchor_day >= 1) AND (anchor_day <= 31))),
+    CONSTRAINT billing_settings_event_retention_months_check CHECK ((event_retention_months >= 1))
+);
+
+
+--
+-- Name: coupon_redemptions; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.coupon_redemptions (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    merchant_id uuid NOT NULL,
+    coupon_id uuid NOT NULL,
+    customer_id uuid NOT NULL,
+    periods_applied integer DEFAULT 0 NOT NULL,
+    revoked_at timestamp with time zone,
     created_at timestamp with time zone DEFAULT now() NOT NULL
 );
 
 
+--
+-- Name: coupons; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.coupons (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    merchant_id uuid NOT NULL,
+    name text NOT NULL,
+    percent_off double precision,
+    amount_off double precision,
+    duration text NOT NULL,
+    duration_periods integer,
+    sku_ids uuid[] DEFAULT '{}'::uuid[] NOT NULL,
+    revoked_at timestamp with time zone,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT coupons_check CHECK (((percent_off IS NULL) <> (amount_off IS NULL)))
+);
+
+
+--
+-- Name: customers; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.customers (
+    id uuid NOT NULL,
+    merchant_id uuid NOT NULL,
+    name text NOT NULL,
+    email text,
+    address_line1 text,
+    address_line2 text,
+    city text,
+    postal_code text,
+    region text,
+    country text,
+    tax_id text,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    payment_customer_id text,
+    ingest_suspended_at timestamp with time zone,
+    timezone text
+);
+
+
+--
+-- Name: dunning_settings; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.dunning_settings (
+    merchant_id uuid NOT NULL,
+    retry_days integer[] DEFAULT '{3,5,7}'::integer[] NOT NULL,
+    suspend_ingest boolean DEFAULT false NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: event_archives; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.event_archives (
+    merchant_id uuid NOT NULL,
+    period_start timestamp with time zone NOT NULL,
+    period_end timestamp with time zone NOT NULL,
+    blob_key text NOT NULL,
+    event_count bigint NOT NULL,
+    database_event_count bigint NOT NULL,
+    size_bytes bigint NOT NULL,
+    sha256 text NOT NULL,
+    archived_at timestamp with time zone DEFAULT now() NOT NULL,
+    rehydrated_until timestamp with time zone
+);
+
+
 --
 -- Name: events; Type: TABLE; Schema: public; Owner: -
 --
@@ -44,6 +158,148 @@ CREATE TABLE public.events (
     sku_id uuid NOT NULL,
     amount double precision NOT NULL,
     sent_at timestamp with time zone NOT NULL
+)
+PARTITION BY RANGE (sent_at);
+
+
+--
+-- Name: events_default; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.events_default (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    merchant_id uuid NOT NULL,
+    customer_id uuid NOT NULL,
+    sku_id uuid NOT NULL,
+    amount double precision NOT NULL,
+    sent_at timestamp with time zone NOT NULL
+);
+
+
+--
+-- Name: invoice_dunnings; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.invoice_dunnings (
+    invoice_id uuid NOT NULL,
+    merchant_id uuid NOT NULL,
+    retry_days integer[] NOT NULL,
+    step integer DEFAULT 0 NOT NULL,
+    started_at timestamp with time zone DEFAULT now() NOT NULL,
+    next_attempt_at timestamp with time zone,
+    last_error text,
+    outcome text,
+    completed_at timestamp with time zone,
+    error_count integer DEFAULT 0 NOT NULL
+);
+
+
+--
+-- Name: invoice_lines; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.invoice_lines (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    invoice_id uuid NOT NULL,
+    "position" integer NOT NULL,
+    kind text NOT NULL,
+    description text NOT NULL,
+    sku_id uuid,
+    quantity double precision,
+    unit_price double precision,
+    amount double precision NOT NULL,
+    coupon_redemption_id uuid,
+    tax_rate_id uuid
+);
+
+
+--
+-- Name: invoice_settings; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.invoice_settings (
+    merchant_id uuid NOT NULL,
+    number_prefix text DEFAULT 'INV-'::text NOT NULL,
+    last_invoice_number integer DEFAULT 0 NOT NULL,
+    payment_terms_days integer DEFAULT 30 NOT NULL,
+    accent_color text DEFAULT '#1F2937'::text NOT NULL,
+    header_template text DEFAULT ''::text NOT NULL,
+    footer_template text DEFAULT ''::text NOT NULL,
+    logo bytea,
+    logo_content_type text,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: invoices; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.invoices (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    merchant_id uuid NOT NULL,
+    customer_id uuid NOT NULL,
+    status text DEFAULT 'draft'::text NOT NULL,
+    period_start timestamp with time zone NOT NULL,
+    period_end timestamp with time zone NOT NULL,
+    subtotal double precision NOT NULL,
+    discount_total double precision NOT NULL,
+    total double precision NOT NULL,
+    finalized_at timestamp with time zone,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    tax_total double precision DEFAULT 0 NOT NULL,
+    number text,
+    due_at timestamp with time zone,
+    payment_provider text,
+    payment_id text,
+    paid_at timestamp with time zone
+);
+
+
+--
+-- Name: job_schedules; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.job_schedules (
+    kind text NOT NULL,
+    every interval NOT NULL,
+    next_run_at timestamp with time zone DEFAULT now() NOT NULL,
+    last_run_at timestamp with time zone,
+    last_error text
+);
+
+
+--
+-- Name: jobs; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.jobs (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    kind text NOT NULL,
+    args jsonb DEFAULT '{}'::jsonb NOT NULL,
+    unique_key text,
+    run_at timestamp with time zone DEFAULT now() NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    max_attempts integer DEFAULT 10 NOT NULL,
+    last_error text,
+    locked_until timestamp with time zone,
+    completed_at timestamp with time zone,
+    failed_at timestamp with time zone,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: merchant_rate_limits; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.merchant_rate_limits (
+    merchant_id uuid NOT NULL,
+    rate_limit_per_second integer NOT NULL,
+    rate_limit_burst integer NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT merchant_rate_limits_rate_limit_burst_check CHECK ((rate_limit_burst > 0)),
+    CONSTRAINT merchant_rate_limits_rate_limit_per_second_check CHECK ((rate_limit_per_second > 0))
 );
 
 
@@ -57,7 +313,62 @@ CREATE TABLE public.merchants (
     password_hash text NOT NULL,
     name text NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
-    updated_at timestamp with time zone DEFAULT now() NOT NULL
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    live_merchant_id uuid
+);
+
+
+--
+-- Name: outbox_events; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.outbox_events (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    merchant_id uuid NOT NULL,
+    event_type text NOT NULL,
+    payload jsonb NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    last_error text,
+    next_attempt_at timestamp with time zone DEFAULT now() NOT NULL,
+    published_at timestamp with time zone,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: payment_webhook_events; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.payment_webhook_events (
+    provider text NOT NULL,
+    event_id text NOT NULL,
+    event_type text NOT NULL,
+    payment_id text NOT NULL,
+    payload bytea NOT NULL,
+    occurred_at timestamp with time zone NOT NULL,
+    received_at timestamp with time zone DEFAULT now() NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    last_error text,
+    next_attempt_at timestamp with time zone DEFAULT now() NOT NULL,
+    processed_at timestamp with time zone
+);
+
+
+--
+-- Name: price_overrides; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.price_overrides (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    merchant_id uuid NOT NULL,
+    sku_id uuid NOT NULL,
+    customer_id uuid NOT NULL,
+    price_per_unit double precision NOT NULL,
+    starts_at timestamp with time zone,
+    ends_at timestamp with time zone,
+    revoked_at timestamp with time zone,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT price_overrides_check CHECK (((starts_at IS NULL) OR (ends_at IS NULL) OR (starts_at < ends_at)))
 );
 
 
@@ -70,6 +381,17 @@ CREATE TABLE public.schema_migrations (
 );
 
 
+--
+-- Name: signature_nonces; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.signature_nonces (
+    key_id uuid NOT NULL,
+    nonce text NOT NULL,
+    expires_at timestamp with time zone NOT NULL
+);
+
+
 --
 -- Name: skus; Type: TABLE; Schema: public; Owner: -
 --
@@ -86,91 +408,959 @@ CREATE TABLE public.skus (
 
 
 --
--- Name: api_keys api_keys_key_hash_key; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: spend_caps; Type: TABLE; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY public.api_keys
-    ADD CONSTRAINT api_keys_key_hash_key UNIQUE (key_hash);
+CREATE TABLE public.spend_caps (
+    merchant_id uuid NOT NULL,
+    customer_id uuid NOT NULL,
+    amount_limit double precision NOT NULL,
+    per_period boolean DEFAULT true NOT NULL,
+    spent double precision DEFAULT 0 NOT NULL,
+    period_start timestamp with time zone,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT spend_caps_amount_limit_check CHECK ((amount_limit >= (0)::double precision))
+);
 
 
 --
--- Name: api_keys api_keys_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: tax_rates; Type: TABLE; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY public.api_keys
-    ADD CONSTRAINT api_keys_pkey PRIMARY KEY (id);
+CREATE TABLE public.tax_rates (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    merchant_id uuid NOT NULL,
+    name text NOT NULL,
+    country text NOT NULL,
+    region text,
+    percentage double precision NOT NULL,
+    reverse_charge boolean DEFAULT false NOT NULL,
+    revoked_at timestamp with time zone,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
 
 
 --
--- Name: events events_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: usage_alert_crossings; Type: TABLE; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY public.events
-    ADD CONSTRAINT events_pkey PRIMARY KEY (id);
+CREATE TABLE public.usage_alert_crossings (
+    alert_id uuid NOT NULL,
+    period_start timestamp with time zone NOT NULL,
+    threshold integer NOT NULL,
+    usage double precision NOT NULL,
+    crossed_at timestamp with time zone DEFAULT now() NOT NULL
+);
 
 
 --
--- Name: merchants merchants_email_key; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: usage_alerts; Type: TABLE; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY public.merchants
-    ADD CONSTRAINT merchants_email_key UNIQUE (email);
+CREATE TABLE public.usage_alerts (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    merchant_id uuid NOT NULL,
+    customer_id uuid NOT NULL,
+    sku_id uuid,
+    budget double precision NOT NULL,
+    thresholds integer[] DEFAULT '{80,100}'::integer[] NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    revoked_at timestamp with time zone,
+    CONSTRAINT usage_alerts_budget_check CHECK ((budget > (0)::double precision))
+);
 
 
 --
--- Name: merchants merchants_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: usage_counters; Type: TABLE; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY public.merchants
-    ADD CONSTRAINT merchants_pkey PRIMARY KEY (id);
+CREATE TABLE public.usage_counters (
+    merchant_id uuid NOT NULL,
+    customer_id uuid NOT NULL,
+    sku_id uuid NOT NULL,
+    period_start timestamp with time zone NOT NULL,
+    period_end timestamp with time zone NOT NULL,
+    quantity double precision DEFAULT 0 NOT NULL,
+    event_count bigint DEFAULT 0 NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    reconciled_at timestamp with time zone
+);
 
 
 --
--- Name: schema_migrations schema_migrations_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: usage_rollup_dirty_hours; Type: TABLE; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY public.schema_migrations
-    ADD CONSTRAINT schema_migrations_pkey PRIMARY KEY (version);
+CREATE TABLE public.usage_rollup_dirty_hours (
+    merchant_id uuid NOT NULL,
+    hour timestamp with time zone NOT NULL
+);
 
 
 --
--- Name: skus skus_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: usage_rollups_daily; Type: TABLE; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY public.skus
-    ADD CONSTRAINT skus_pkey PRIMARY KEY (id);
+CREATE TABLE public.usage_rollups_daily (
+    merchant_id uuid NOT NULL,
+    bucket_start timestamp with time zone NOT NULL,
+    customer_id uuid NOT NULL,
+    sku_id uuid NOT NULL,
+    quantity double precision NOT NULL,
+    event_count bigint NOT NULL
+);
 
 
 --
--- Name: api_keys api_keys_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+-- Name: usage_rollups_hourly; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.usage_rollups_hourly (
+    merchant_id uuid NOT NULL,
+    bucket_start timestamp with time zone NOT NULL,
+    customer_id uuid NOT NULL,
+    sku_id uuid NOT NULL,
+    quantity double precision NOT NULL,
+    event_count bigint NOT NULL
+);
+
+
+--
+-- Name: webhook_deliveries; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.webhook_deliveries (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    endpoint_id uuid NOT NULL,
+    merchant_id uuid NOT NULL,
+    event_id uuid NOT NULL,
+    event_type text NOT NULL,
+    payload jsonb NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    last_response_status integer,
+    next_attempt_at timestamp with time zone DEFAULT now(),
+    delivered_at timestamp with time zone,
+    failed_at timestamp with time zone,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: webhook_delivery_attempts; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.webhook_delivery_attempts (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    delivery_id uuid NOT NULL,
+    response_status integer,
+    error text,
+    duration_ms integer NOT NULL,
+    attempted_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: webhook_endpoints; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.webhook_endpoints (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    merchant_id uuid NOT NULL,
+    url text NOT NULL,
+    secret text NOT NULL,
+    event_types text[] DEFAULT '{}'::text[] NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    revoked_at timestamp with time zone
+);
+
+
+--
+-- Name: events_default; Type: TABLE ATTACH; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.events ATTACH PARTITION public.events_default DEFAULT;
+
+
+--
+-- Name: api_keys api_keys_key_hash_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
 ALTER TABLE ONLY public.api_keys
-    ADD CONSTRAINT api_keys_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+    ADD CONSTRAINT api_keys_key_hash_key UNIQUE (key_hash);
 
 
 --
--- Name: events events_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+-- Name: api_keys api_keys_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY public.events
-    ADD CONSTRAINT events_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+ALTER TABLE ONLY public.api_keys
+    ADD CONSTRAINT api_keys_pkey PRIMARY KEY (id);
 
 
 --
--- Name: events events_sku_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+-- Name: api_keys api_keys_previous_key_hash_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY public.events
-    ADD CONSTRAINT events_sku_id_fkey FOREIGN KEY (sku_id) REFERENCES public.skus(id);
+ALTER TABLE ONLY public.api_keys
+    ADD CONSTRAINT api_keys_previous_key_hash_key UNIQUE (previous_key_hash);
 
 
 --
--- Name: skus skus_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+-- Name: billing_settings billing_settings_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY public.skus
-    ADD CONSTRAINT skus_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+ALTER TABLE ONLY public.billing_settings
+    ADD CONSTRAINT billing_settings_pkey PRIMARY KEY (merchant_id);
+
+
+--
+-- Name: coupon_redemptions coupon_redemptions_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.coupon_redemptions
+    ADD CONSTRAINT coupon_redemptions_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: coupons coupons_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.coupons
+    ADD CONSTRAINT coupons_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: customers customers_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.customers
+    ADD CONSTRAINT customers_pkey PRIMARY KEY (merchant_id, id);
+
+
+--
+-- Name: dunning_settings dunning_settings_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.dunning_settings
+    ADD CONSTRAINT dunning_settings_pkey PRIMARY KEY (merchant_id);
+
+
+--
+-- Name: event_archives event_archives_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.event_archives
+    ADD CONSTRAINT event_archives_pkey PRIMARY KEY (merchant_id, period_start);
+
+
+--
+-- Name: events events_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.events
+    ADD CONSTRAINT events_pkey PRIMARY KEY (id, sent_at);
+
+
+--
+-- Name: events_default events_default_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.events_default
+    ADD CONSTRAINT events_default_pkey PRIMARY KEY (id, sent_at);
+
+
+--
+-- Name: invoice_dunnings invoice_dunnings_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.invoice_dunnings
+    ADD CONSTRAINT invoice_dunnings_pkey PRIMARY KEY (invoice_id);
+
+
+--
+-- Name: invoice_lines invoice_lines_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.invoice_lines
+    ADD CONSTRAINT invoice_lines_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: invoice_settings invoice_settings_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.invoice_settings
+    ADD CONSTRAINT invoice_settings_pkey PRIMARY KEY (merchant_id);
+
+
+--
+-- Name: invoices invoices_merchant_id_customer_id_period_start_period_end_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.invoices
+    ADD CONSTRAINT invoices_merchant_id_customer_id_period_start_period_end_key UNIQUE (merchant_id, customer_id, period_start, period_end);
+
+
+--
+-- Name: invoices invoices_merchant_id_number_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.invoices
+    ADD CONSTRAINT invoices_merchant_id_number_key UNIQUE (merchant_id, number);
+
+
+--
+-- Name: invoices invoices_payment_provider_payment_id_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.invoices
+    ADD CONSTRAINT invoices_payment_provider_payment_id_key UNIQUE (payment_provider, payment_id);
+
+
+--
+-- Name: invoices invoices_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.invoices
+    ADD CONSTRAINT invoices_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: job_schedules job_schedules_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.job_schedules
+    ADD CONSTRAINT job_schedules_pkey PRIMARY KEY (kind);
+
+
+--
+-- Name: jobs jobs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.jobs
+    ADD CONSTRAINT jobs_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: jobs jobs_unique_key_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.jobs
+    ADD CONSTRAINT jobs_unique_key_key UNIQUE (unique_key);
+
+
+--
+-- Name: merchant_rate_limits merchant_rate_limits_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.merchant_rate_limits
+    ADD CONSTRAINT merchant_rate_limits_pkey PRIMARY KEY (merchant_id);
+
+
+--
+-- Name: merchants merchants_live_merchant_id_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.merchants
+    ADD CONSTRAINT merchants_live_merchant_id_key UNIQUE (live_merchant_id);
+
+
+--
+-- Name: merchants merchants_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.merchants
+    ADD CONSTRAINT merchants_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: outbox_events outbox_events_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.outbox_events
+    ADD CONSTRAINT outbox_events_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: payment_webhook_events payment_webhook_events_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.payment_webhook_events
+    ADD CONSTRAINT payment_webhook_events_pkey PRIMARY KEY (provider, event_id);
+
+
+--
+-- Name: price_overrides price_overrides_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.price_overrides
+    ADD CONSTRAINT price_overrides_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: schema_migrations schema_migrations_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.schema_migrations
+    ADD CONSTRAINT schema_migrations_pkey PRIMARY KEY (version);
+
+
+--
+-- Name: signature_nonces signature_nonces_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.signature_nonces
+    ADD CONSTRAINT signature_nonces_pkey PRIMARY KEY (key_id, nonce);
+
+
+--
+-- Name: skus skus_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.skus
+    ADD CONSTRAINT skus_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: spend_caps spend_caps_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.spend_caps
+    ADD CONSTRAINT spend_caps_pkey PRIMARY KEY (merchant_id, customer_id);
+
+
+--
+-- Name: tax_rates tax_rates_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.tax_rates
+    ADD CONSTRAINT tax_rates_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: usage_alert_crossings usage_alert_crossings_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.usage_alert_crossings
+    ADD CONSTRAINT usage_alert_crossings_pkey PRIMARY KEY (alert_id, period_start, threshold);
+
+
+--
+-- Name: usage_alerts usage_alerts_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.usage_alerts
+    ADD CONSTRAINT usage_alerts_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: usage_counters usage_counters_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.usage_counters
+    ADD CONSTRAINT usage_counters_pkey PRIMARY KEY (merchant_id, customer_id, period_start, sku_id);
+
+
+--
+-- Name: usage_rollup_dirty_hours usage_rollup_dirty_hours_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.usage_rollup_dirty_hours
+    ADD CONSTRAINT usage_rollup_dirty_hours_pkey PRIMARY KEY (merchant_id, hour);
+
+
+--
+-- Name: usage_rollups_daily usage_rollups_daily_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.usage_rollups_daily
+    ADD CONSTRAINT usage_rollups_daily_pkey PRIMARY KEY (merchant_id, bucket_start, customer_id, sku_id);
+
+
+--
+-- Name: usage_rollups_hourly usage_rollups_hourly_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.usage_rollups_hourly
+    ADD CONSTRAINT usage_rollups_hourly_pkey PRIMARY KEY (merchant_id, bucket_start, customer_id, sku_id);
+
+
+--
+-- Name: webhook_deliveries webhook_deliveries_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.webhook_deliveries
+    ADD CONSTRAINT webhook_deliveries_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: webhook_delivery_attempts webhook_delivery_attempts_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.webhook_delivery_attempts
+    ADD CONSTRAINT webhook_delivery_attempts_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: webhook_endpoints webhook_endpoints_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.webhook_endpoints
+    ADD CONSTRAINT webhook_endpoints_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: events_default_merchant_id_customer_id_sent_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX events_default_merchant_id_customer_id_sent_at_idx ON public.events_default USING btree (merchant_id, customer_id, sent_at);
+
+
+--
+-- Name: events_default_merchant_id_sent_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX events_default_merchant_id_sent_at_idx ON public.events_default USING btree (merchant_id, sent_at);
+
+
+--
+-- Name: events_merchant_id_customer_id_sent_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX events_merchant_id_customer_id_sent_at_idx ON ONLY public.events USING btree (merchant_id, customer_id, sent_at);
+
+
+--
+-- Name: events_merchant_id_sent_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX events_merchant_id_sent_at_idx ON ONLY public.events USING btree (merchant_id, sent_at);
+
+
+--
+-- Name: invoice_dunnings_next_attempt_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX invoice_dunnings_next_attempt_at_idx ON public.invoice_dunnings USING btree (next_attempt_at) WHERE (completed_at IS NULL);
+
+
+--
+-- Name: jobs_run_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX jobs_run_at_idx ON public.jobs USING btree (run_at) WHERE ((completed_at IS NULL) AND (failed_at IS NULL));
+
+
+--
+-- Name: merchants_email_key; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX merchants_email_key ON public.merchants USING btree (email) WHERE (live_merchant_id IS NULL);
+
+
+--
+-- Name: outbox_events_pending_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX outbox_events_pending_idx ON public.outbox_events USING btree (next_attempt_at) WHERE (published_at IS NULL);
+
+
+--
+-- Name: payment_webhook_events_pending_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX payment_webhook_events_pending_idx ON public.payment_webhook_events USING btree (next_attempt_at) WHERE (processed_at IS NULL);
+
+
+--
+-- Name: price_overrides_sku_id_customer_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX price_overrides_sku_id_customer_id_idx ON public.price_overrides USING btree (sku_id, customer_id);
+
+
+--
+-- Name: signature_nonces_expires_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX signature_nonces_expires_at_idx ON public.signature_nonces USING btree (expires_at);
+
+
+--
+-- Name: usage_alerts_merchant_id_customer_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX usage_alerts_merchant_id_customer_id_idx ON public.usage_alerts USING btree (merchant_id, customer_id) WHERE (revoked_at IS NULL);
+
+
+--
+-- Name: usage_counters_period_end_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX usage_counters_period_end_idx ON public.usage_counters USING btree (period_end);
+
+
+--
+-- Name: webhook_deliveries_endpoint_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX webhook_deliveries_endpoint_id_idx ON public.webhook_deliveries USING btree (endpoint_id, created_at);
+
+
+--
+-- Name: webhook_deliveries_next_attempt_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX webhook_deliveries_next_attempt_at_idx ON public.webhook_deliveries USING btree (next_attempt_at) WHERE (next_attempt_at IS NOT NULL);
+
+
+--
+-- Name: webhook_delivery_attempts_delivery_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX webhook_delivery_attempts_delivery_id_idx ON public.webhook_delivery_attempts USING btree (delivery_id);
+
+
+--
+-- Name: events_default_merchant_id_customer_id_sent_at_idx; Type: INDEX ATTACH; Schema: public; Owner: -
+--
+
+ALTER INDEX public.events_merchant_id_customer_id_sent_at_idx ATTACH PARTITION public.events_default_merchant_id_customer_id_sent_at_idx;
+
+
+--
+-- Name: events_default_merchant_id_sent_at_idx; Type: INDEX ATTACH; Schema: public; Owner: -
+--
+
+ALTER INDEX public.events_merchant_id_sent_at_idx ATTACH PARTITION public.events_default_merchant_id_sent_at_idx;
+
+
+--
+-- Name: events_default_pkey; Type: INDEX ATTACH; Schema: public; Owner: -
+--
+
+ALTER INDEX public.events_pkey ATTACH PARTITION public.events_default_pkey;
+
+
+--
+-- Name: api_keys api_keys_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.api_keys
+    ADD CONSTRAINT api_keys_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+
+
+--
+-- Name: billing_settings billing_settings_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.billing_settings
+    ADD CONSTRAINT billing_settings_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+
+
+--
+-- Name: coupon_redemptions coupon_redemptions_coupon_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.coupon_redemptions
+    ADD CONSTRAINT coupon_redemptions_coupon_id_fkey FOREIGN KEY (coupon_id) REFERENCES public.coupons(id);
+
+
+--
+-- Name: coupon_redemptions coupon_redemptions_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.coupon_redemptions
+    ADD CONSTRAINT coupon_redemptions_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+
+
+--
+-- Name: coupons coupons_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.coupons
+    ADD CONSTRAINT coupons_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+
+
+--
+-- Name: customers customers_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.customers
+    ADD CONSTRAINT customers_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+
+
+--
+-- Name: dunning_settings dunning_settings_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.dunning_settings
+    ADD CONSTRAINT dunning_settings_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+
+
+--
+-- Name: event_archives event_archives_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.event_archives
+    ADD CONSTRAINT event_archives_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+
+
+--
+-- Name: events events_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE public.events
+    ADD CONSTRAINT events_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+
+
+--
+-- Name: events events_sku_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE public.events
+    ADD CONSTRAINT events_sku_id_fkey FOREIGN KEY (sku_id) REFERENCES public.skus(id);
+
+
+--
+-- Name: invoice_dunnings invoice_dunnings_invoice_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.invoice_dunnings
+    ADD CONSTRAINT invoice_dunnings_invoice_id_fkey FOREIGN KEY (invoice_id) REFERENCES public.invoices(id) ON DELETE CASCADE;
+
+
+--
+-- Name: invoice_dunnings invoice_dunnings_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.invoice_dunnings
+    ADD CONSTRAINT invoice_dunnings_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+
+
+--
+-- Name: invoice_lines invoice_lines_coupon_redemption_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.invoice_lines
+    ADD CONSTRAINT invoice_lines_coupon_redemption_id_fkey FOREIGN KEY (coupon_redemption_id) REFERENCES public.coupon_redemptions(id);
+
+
+--
+-- Name: invoice_lines invoice_lines_invoice_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.invoice_lines
+    ADD CONSTRAINT invoice_lines_invoice_id_fkey FOREIGN KEY (invoice_id) REFERENCES public.invoices(id) ON DELETE CASCADE;
+
+
+--
+-- Name: invoice_lines invoice_lines_sku_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.invoice_lines
+    ADD CONSTRAINT invoice_lines_sku_id_fkey FOREIGN KEY (sku_id) REFERENCES public.skus(id);
+
+
+--
+-- Name: invoice_lines invoice_lines_tax_rate_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.invoice_lines
+    ADD CONSTRAINT invoice_lines_tax_rate_id_fkey FOREIGN KEY (tax_rate_id) REFERENCES public.tax_rates(id);
+
+
+--
+-- Name: invoice_settings invoice_settings_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.invoice_settings
+    ADD CONSTRAINT invoice_settings_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+
+
+--
+-- Name: invoices invoices_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.invoices
+    ADD CONSTRAINT invoices_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+
+
+--
+-- Name: merchant_rate_limits merchant_rate_limits_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.merchant_rate_limits
+    ADD CONSTRAINT merchant_rate_limits_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+
+
+--
+-- Name: merchants merchants_live_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.merchants
+    ADD CONSTRAINT merchants_live_merchant_id_fkey FOREIGN KEY (live_merchant_id) REFERENCES public.merchants(id);
+
+
+--
+-- Name: outbox_events outbox_events_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.outbox_events
+    ADD CONSTRAINT outbox_events_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+
+
+--
+-- Name: price_overrides price_overrides_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.price_overrides
+    ADD CONSTRAINT price_overrides_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+
+
+--
+-- Name: price_overrides price_overrides_sku_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.price_overrides
+    ADD CONSTRAINT price_overrides_sku_id_fkey FOREIGN KEY (sku_id) REFERENCES public.skus(id);
+
+
+--
+-- Name: signature_nonces signature_nonces_key_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.signature_nonces
+    ADD CONSTRAINT signature_nonces_key_id_fkey FOREIGN KEY (key_id) REFERENCES public.api_keys(id) ON DELETE CASCADE;
+
+
+--
+-- Name: skus skus_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.skus
+    ADD CONSTRAINT skus_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+
+
+--
+-- Name: spend_caps spend_caps_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.spend_caps
+    ADD CONSTRAINT spend_caps_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+
+
+--
+-- Name: tax_rates tax_rates_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.tax_rates
+    ADD CONSTRAINT tax_rates_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+
+
+--
+-- Name: usage_alert_crossings usage_alert_crossings_alert_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.usage_alert_crossings
+    ADD CONSTRAINT usage_alert_crossings_alert_id_fkey FOREIGN KEY (alert_id) REFERENCES public.usage_alerts(id);
+
+
+--
+-- Name: usage_alerts usage_alerts_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.usage_alerts
+    ADD CONSTRAINT usage_alerts_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+
+
+--
+-- Name: usage_alerts usage_alerts_sku_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.usage_alerts
+    ADD CONSTRAINT usage_alerts_sku_id_fkey FOREIGN KEY (sku_id) REFERENCES public.skus(id);
+
+
+--
+-- Name: usage_counters usage_counters_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.usage_counters
+    ADD CONSTRAINT usage_counters_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+
+
+--
+-- Name: usage_counters usage_counters_sku_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.usage_counters
+    ADD CONSTRAINT usage_counters_sku_id_fkey FOREIGN KEY (sku_id) REFERENCES public.skus(id);
+
+
+--
+-- Name: usage_rollups_daily usage_rollups_daily_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.usage_rollups_daily
+    ADD CONSTRAINT usage_rollups_daily_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+
+
+--
+-- Name: usage_rollups_daily usage_rollups_daily_sku_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.usage_rollups_daily
+    ADD CONSTRAINT usage_rollups_daily_sku_id_fkey FOREIGN KEY (sku_id) REFERENCES public.skus(id);
+
+
+--
+-- Name: usage_rollups_hourly usage_rollups_hourly_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.usage_rollups_hourly
+    ADD CONSTRAINT usage_rollups_hourly_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+
+
+--
+-- Name: usage_rollups_hourly usage_rollups_hourly_sku_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.usage_rollups_hourly
+    ADD CONSTRAINT usage_rollups_hourly_sku_id_fkey FOREIGN KEY (sku_id) REFERENCES public.skus(id);
+
+
+--
+-- Name: webhook_deliveries webhook_deliveries_endpoint_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.webhook_deliveries
+    ADD CONSTRAINT webhook_deliveries_endpoint_id_fkey FOREIGN KEY (endpoint_id) REFERENCES public.webhook_endpoints(id);
+
+
+--
+-- Name: webhook_deliveries webhook_deliveries_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.webhook_deliveries
+    ADD CONSTRAINT webhook_deliveries_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
+
+
+--
+-- Name: webhook_delivery_attempts webhook_delivery_attempts_delivery_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.webhook_delivery_attempts
+    ADD CONSTRAINT webhook_delivery_attempts_delivery_id_fkey FOREIGN KEY (delivery_id) REFERENCES public.webhook_deliveries(id) ON DELETE CASCADE;
+
+
+--
+-- Name: webhook_endpoints webhook_endpoints_merchant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.webhook_endpoints
+    ADD CONSTRAINT webhook_endpoints_merchant_id_fkey FOREIGN KEY (merchant_id) REFERENCES public.merchants(id);
 
 
 --
@@ -187,4 +1377,30 @@ INSERT INTO public.schema_migrations (version) VALUES
     ('20260208000000'),
     ('20260208010000'),
     ('20260208020000'),
-    ('20260208030000');
+    ('20260208030000'),
+    ('20261019000000'),
+    ('20261019010000'),
+    ('20261020000000'),
+    ('20261021000000'),
+    ('20261021010000'),
+    ('20261022000000'),
+    ('20261023000000'),
+    ('20261024000000'),
+    ('20261025000000'),
+    ('20261026000000'),
+    ('20261027000000'),
+    ('20261028000000'),
+    ('20261029000000'),
+    ('20261030000000'),
+    ('20261031000000'),
+    ('20261101000000'),
+    ('20261102000000'),
+    ('20261103000000'),
+    ('20261104000000'),
+    ('20261105000000'),
+    ('20261106000000'),
+    ('20261107000000'),
+    ('20261108000000'),
+    ('20261109000000'),
+    ('20261110000000'),
+    ('20261111000000');
@@ -12,24 +12,33 @@ import (
 )
 
 const createAPIKey = `-- name: CreateAPIKey :one
-INSERT INTO api_keys (merchant_id, name, key_prefix, key_hash)
-VALUES ($1, $2, $3, $4)
-RETURNING id, merchant_id, name, key_prefix, key_hash, revoked_at, created_at
+INSERT INTO api_keys (id, merchant_id, name, key_prefix, key_hash, scopes, expires_at, signing_secret)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, merchant_id, name, key_prefix, key_hash, revoked_at, created_at, rate_limit_per_second, rate_limit_burst, scopes, expires_at, previous_key_hash, previous_key_expires_at, rotated_at, last_used_at, signing_secret
 `
 
 type CreateAPIKeyParams struct {
-	MerchantID pgtype.UUID
-	Name       string
-	KeyPrefix  string
-	KeyHash    string
+	ID            pgtype.UUID
+	MerchantID    pgtype.UUID
+	Name          string
+	KeyPrefix     string
+	KeyHash       string
+	Scopes        []string
+	ExpiresAt     pgtype.Timestamptz
+	SigningSecret pgtype.Text
 }
 
+// The ID is generated by the caller, as keys embed it.
 func (q *Queries) CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (*ApiKey, error) {
 	row := q.db.QueryRow(ctx, createAPIKey,
+		arg.ID,
 		arg.MerchantID,
 		arg.Name,
 		arg.KeyPrefix,
 		arg.KeyHash,
+		arg.Scopes,
+		arg.ExpiresAt,
+		arg.SigningSecret,
 	)
 	var i ApiKey
 	err := row.Scan(
@@ -40,31 +49,130 @@ func (q *Queries) CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (*Ap
 		&i.KeyHash,
 		&i.RevokedAt,
 		&i.CreatedAt,
+		&i.RateLimitPerSecond,
+		&i.RateLimitBurst,
+		&i.Scopes,
+		&i.ExpiresAt,
+		&i.PreviousKeyHash,
+		&i.PreviousKeyExpiresAt,
+		&i.RotatedAt,
+		&i.LastUsedAt,
+		&i.SigningSecret,
 	)
 	return &i, err
 }
 
 const getAPIKeyByHash = `-- name: GetAPIKeyByHash :one
-SELECT id, merchant_id, revoked_at
-FROM api_keys
-WHERE key_hash = $1
+SELECT k.id, k.merchant_id, k.revoked_at, k.scopes,
+       (CASE WHEN k.key_hash = $1::text THEN k.expires_at
+             ELSE LEAST(k.previous_key_expires_at, k.expires_at)
+        END)::timestamptz AS expires_at,
+       k.key_hash, k.previous_key_hash, k.previous_key_expires_at,
+       k.signing_secret,
+       k.rate_limit_per_second, k.rate_limit_burst,
+       mrl.rate_limit_per_second AS merchant_rate_limit_per_second,
+       mrl.rate_limit_burst AS merchant_rate_limit_burst
+FROM api_keys k
+LEFT JOIN merchant_rate_limits mrl ON mrl.merchant_id = k.merchant_id
+WHERE k.key_hash = $1::text
+   OR k.previous_key_hash = $1::text
 `
 
 type GetAPIKeyByHashRow struct {
-	ID         pgtype.UUID
-	MerchantID pgtype.UUID
-	RevokedAt  pgtype.Timestamptz
+	ID                         pgtype.UUID
+	MerchantID                 pgtype.UUID
+	RevokedAt                  pgtype.Timestamptz
+	Scopes                     []string
+	ExpiresAt                  pgtype.Timestamptz
+	KeyHash                    string
+	PreviousKeyHash            pgtype.Text
+	PreviousKeyExpiresAt       pgtype.Timestamptz
+	SigningSecret              pgtype.Text
+	RateLimitPerSecond         pgtype.Int4
+	RateLimitBurst             pgtype.Int4
+	MerchantRateLimitPerSecond pgtype.Int4
+	MerchantRateLimitBurst     pgtype.Int4
 }
 
+// The legacy key, which embeds no ID, of the given current or previous hash,
+// with its scopes, its rate limits and its merchant's. expires_at is when the
+// given hash stops authenticating, if ever.
 func (q *Queries) GetAPIKeyByHash(ctx context.Context, keyHash string) (*GetAPIKeyByHashRow, error) {
 	row := q.db.QueryRow(ctx, getAPIKeyByHash, keyHash)
 	var i GetAPIKeyByHashRow
-	err := row.Scan(&i.ID, &i.MerchantID, &i.RevokedAt)
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.RevokedAt,
+		&i.Scopes,
+		&i.ExpiresAt,
+		&i.KeyHash,
+		&i.PreviousKeyHash,
+		&i.PreviousKeyExpiresAt,
+		&i.SigningSecret,
+		&i.RateLimitPerSecond,
+		&i.RateLimitBurst,
+		&i.MerchantRateLimitPerSecond,
+		&i.MerchantRateLimitBurst,
+	)
+	return &i, err
+}
+
+const getAPIKeyByID = `-- name: GetAPIKeyByID :one
+SELECT k.id, k.merchant_id, k.revoked_at, k.scopes,
+       k.expires_at,
+       k.key_hash, k.previous_key_hash, k.previous_key_expires_at,
+       k.signing_secret,
+       k.rate_limit_per_second, k.rate_limit_burst,
+       mrl.rate_limit_per_second AS merchant_rate_limit_per_second,
+       mrl.rate_limit_burst AS merchant_rate_limit_burst
+FROM api_keys k
+LEFT JOIN merchant_rate_limits mrl ON mrl.merchant_id = k.merchant_id
+WHERE k.id = $1
+`
+
+type GetAPIKeyByIDRow struct {
+	ID                         pgtype.UUID
+	MerchantID                 pgtype.UUID
+	RevokedAt                  pgtype.Timestamptz
+	Scopes                     []string
+	ExpiresAt                  pgtype.Timestamptz
+	KeyHash                    string
+	PreviousKeyHash            pgtype.Text
+	PreviousKeyExpiresAt       pgtype.Timestamptz
+	SigningSecret              pgtype.Text
+	RateLimitPerSecond         pgtype.Int4
+	RateLimitBurst             pgtype.Int4
+	MerchantRateLimitPerSecond pgtype.Int4
+	MerchantRateLimitBurst     pgtype.Int4
+}
+
+// Same as GetAPIKeyByHash, for keys embedding their ID and for signed
+// requests, which name their key by ID. The caller verifies the key against
+// its hashes.
+func (q *Queries) GetAPIKeyByID(ctx context.Context, id pgtype.UUID) (*GetAPIKeyByIDRow, error) {
+	row := q.db.QueryRow(ctx, getAPIKeyByID, id)
+	var i GetAPIKeyByIDRow
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.RevokedAt,
+		&i.Scopes,
+		&i.ExpiresAt,
+		&i.KeyHash,
+		&i.PreviousKeyHash,
+		&i.PreviousKeyExpiresAt,
+		&i.SigningSecret,
+		&i.RateLimitPerSecond,
+		&i.RateLimitBurst,
+		&i.MerchantRateLimitPerSecond,
+		&i.MerchantRateLimitBurst,
+	)
 	return &i, err
 }
 
 const listAPIKeysByMerchantID = `-- name: ListAPIKeysByMerchantID :many
-SELECT id, merchant_id, name, key_prefix, key_hash, revoked_at, created_at FROM api_keys
+SELECT id, merchant_id, name, key_prefix, key_hash, revoked_at, created_at, rate_limit_per_second, rate_limit_burst, scopes, expires_at, previous_key_hash, previous_key_expires_at, rotated_at, last_used_at, signing_secret FROM api_keys
 WHERE merchant_id = $1
 ORDER BY created_at DESC
 `
@@ -86,6 +194,15 @@ func (q *Queries) ListAPIKeysByMerchantID(ctx context.Context, merchantID pgtype
 			&i.KeyHash,
 			&i.RevokedAt,
 			&i.CreatedAt,
+			&i.RateLimitPerSecond,
+			&i.RateLimitBurst,
+			&i.Scopes,
+			&i.ExpiresAt,
+			&i.PreviousKeyHash,
+			&i.PreviousKeyExpiresAt,
+			&i.RotatedAt,
+			&i.LastUsedAt,
+			&i.SigningSecret,
 		); err != nil {
 			return nil, err
 		}
@@ -97,10 +214,39 @@ func (q *Queries) ListAPIKeysByMerchantID(ctx context.Context, merchantID pgtype
 	return items, nil
 }
 
-const revokeAPIKey = `-- name: RevokeAPIKey :exec
+const notifyAPIKeyChanged = `-- name: NotifyAPIKeyChanged :exec
+SELECT pg_notify('api_key_changed', h.lookup_key)
+FROM api_keys k
+CROSS JOIN LATERAL unnest(ARRAY[k.key_hash, k.previous_key_hash, 'id:' || k.id::text]) AS h(lookup_key)
+WHERE k.id = $1
+  AND h.lookup_key IS NOT NULL
+`
+
+// Notifies the ingest API key caches, once the transaction commits, of the
+// current and previous hashes of the key, and of its ID.
+func (q *Queries) NotifyAPIKeyChanged(ctx context.Context, id pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, notifyAPIKeyChanged, id)
+	return err
+}
+
+const notifyMerchantAPIKeysChanged = `-- name: NotifyMerchantAPIKeysChanged :exec
+SELECT pg_notify('api_key_changed', h.lookup_key)
+FROM api_keys k
+CROSS JOIN LATERAL unnest(ARRAY[k.key_hash, k.previous_key_hash, 'id:' || k.id::text]) AS h(lookup_key)
+WHERE k.merchant_id = $1
+  AND h.lookup_key IS NOT NULL
+`
+
+func (q *Queries) NotifyMerchantAPIKeysChanged(ctx context.Context, merchantID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, notifyMerchantAPIKeysChanged, merchantID)
+	return err
+}
+
+const revokeAPIKey = `-- name: RevokeAPIKey :one
 UPDATE api_keys
 SET revoked_at = now()
 WHERE id = $1 AND merchant_id = $2 AND revoked_at IS NULL
+RETURNING id, merchant_id, name, key_prefix, key_hash, revoked_at, created_at, rate_limit_per_second, rate_limit_burst, scopes, expires_at, previous_key_hash, previous_key_expires_at, rotated_at, last_used_at, signing_secret
 `
 
 type RevokeAPIKeyParams struct {
@@ -108,7 +254,184 @@ type RevokeAPIKeyParams struct {
 	MerchantID pgtype.UUID
 }
 
-func (q *Queries) RevokeAPIKey(ctx context.Context, arg RevokeAPIKeyParams) error {
-	_, err := q.db.Exec(ctx, revokeAPIKey, arg.ID, arg.MerchantID)
+func (q *Queries) RevokeAPIKey(ctx context.Context, arg RevokeAPIKeyParams) (*ApiKey, error) {
+	row := q.db.QueryRow(ctx, revokeAPIKey, arg.ID, arg.MerchantID)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.Name,
+		&i.KeyPrefix,
+		&i.KeyHash,
+		&i.RevokedAt,
+		&i.CreatedAt,
+		&i.RateLimitPerSecond,
+		&i.RateLimitBurst,
+		&i.Scopes,
+		&i.ExpiresAt,
+		&i.PreviousKeyHash,
+		&i.PreviousKeyExpiresAt,
+		&i.RotatedAt,
+		&i.LastUsedAt,
+		&i.SigningSecret,
+	)
+	return &i, err
+}
+
+const rotateAPIKey = `-- name: RotateAPIKey :one
+UPDATE api_keys
+SET previous_key_hash = key_hash,
+    previous_key_expires_at = $1,
+    key_prefix = $2,
+    key_hash = $3,
+    rotated_at = now()
+WHERE id = $4
+  AND merchant_id = $5
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > now())
+RETURNING id, merchant_id, name, key_prefix, key_hash, revoked_at, created_at, rate_limit_per_second, rate_limit_burst, scopes, expires_at, previous_key_hash, previous_key_expires_at, rotated_at, last_used_at, signing_secret
+`
+
+type RotateAPIKeyParams struct {
+	PreviousKeyExpiresAt pgtype.Timestamptz
+	KeyPrefix            string
+	KeyHash              string
+	ID                   pgtype.UUID
+	MerchantID           pgtype.UUID
+}
+
+// Replaces the hash of an active key. The replaced hash still authenticates
+// until previous_key_expires_at, and the one it replaced, if any, no longer
+// does.
+func (q *Queries) RotateAPIKey(ctx context.Context, arg RotateAPIKeyParams) (*ApiKey, error) {
+	row := q.db.QueryRow(ctx, rotateAPIKey,
+		arg.PreviousKeyExpiresAt,
+		arg.KeyPrefix,
+		arg.KeyHash,
+		arg.ID,
+		arg.MerchantID,
+	)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.Name,
+		&i.KeyPrefix,
+		&i.KeyHash,
+		&i.RevokedAt,
+		&i.CreatedAt,
+		&i.RateLimitPerSecond,
+		&i.RateLimitBurst,
+		&i.Scopes,
+		&i.ExpiresAt,
+		&i.PreviousKeyHash,
+		&i.PreviousKeyExpiresAt,
+		&i.RotatedAt,
+		&i.LastUsedAt,
+		&i.SigningSecret,
+	)
+	return &i, err
+}
+
+const setAPIKeySigningSecret = `-- name: SetAPIKeySigningSecret :one
+UPDATE api_keys
+SET signing_secret = $3
+WHERE id = $1 AND merchant_id = $2 AND revoked_at IS NULL
+RETURNING id, merchant_id, name, key_prefix, key_hash, revoked_at, created_at, rate_limit_per_second, rate_limit_burst, scopes, expires_at, previous_key_hash, previous_key_expires_at, rotated_at, last_used_at, signing_secret
+`
+
+type SetAPIKeySigningSecretParams struct {
+	ID            pgtype.UUID
+	MerchantID    pgtype.UUID
+	SigningSecret pgtype.Text
+}
+
+func (q *Queries) SetAPIKeySigningSecret(ctx context.Context, arg SetAPIKeySigningSecretParams) (*ApiKey, error) {
+	row := q.db.QueryRow(ctx, setAPIKeySigningSecret, arg.ID, arg.MerchantID, arg.SigningSecret)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.Name,
+		&i.KeyPrefix,
+		&i.KeyHash,
+		&i.RevokedAt,
+		&i.CreatedAt,
+		&i.RateLimitPerSecond,
+		&i.RateLimitBurst,
+		&i.Scopes,
+		&i.ExpiresAt,
+		&i.PreviousKeyHash,
+		&i.PreviousKeyExpiresAt,
+		&i.RotatedAt,
+		&i.LastUsedAt,
+		&i.SigningSecret,
+	)
+	return &i, err
+}
+
+const touchAPIKeys = `-- name: TouchAPIKeys :exec
+UPDATE api_keys k
+SET last_used_at = u.last_used_at
+FROM (
+    SELECT unnest($1::uuid[]) AS id,
+           unnest($2::timestamptz[]) AS last_used_at
+) u
+WHERE k.id = u.id
+  AND (k.last_used_at IS NULL OR k.last_used_at < u.last_used_at)
+`
+
+type TouchAPIKeysParams struct {
+	Ids         []pgtype.UUID
+	LastUsedAts []pgtype.Timestamptz
+}
+
+// Records when the keys were last used, as batched by the ingest API.
+func (q *Queries) TouchAPIKeys(ctx context.Context, arg TouchAPIKeysParams) error {
+	_, err := q.db.Exec(ctx, touchAPIKeys, arg.Ids, arg.LastUsedAts)
 	return err
 }
+
+const updateAPIKeyRateLimit = `-- name: UpdateAPIKeyRateLimit :one
+UPDATE api_keys
+SET rate_limit_per_second = $3,
+    rate_limit_burst = $4
+WHERE id = $1 AND merchant_id = $2
+RETURNING id, merchant_id, name, key_prefix, key_hash, revoked_at, created_at, rate_limit_per_second, rate_limit_burst, scopes, expires_at, previous_key_hash, previous_key_expires_at, rotated_at, last_used_at, signing_secret
+`
+
+type UpdateAPIKeyRateLimitParams struct {
+	ID                 pgtype.UUID
+	MerchantID         pgtype.UUID
+	RateLimitPerSecond pgtype.Int4
+	RateLimitBurst     pgtype.Int4
+}
+
+func (q *Queries) UpdateAPIKeyRateLimit(ctx context.Context, arg UpdateAPIKeyRateLimitParams) (*ApiKey, error) {
+	row := q.db.QueryRow(ctx, updateAPIKeyRateLimit,
+		arg.ID,
+		arg.MerchantID,
+		arg.RateLimitPerSecond,
+		arg.RateLimitBurst,
+	)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.Name,
+		&i.KeyPrefix,
+		&i.KeyHash,
+		&i.RevokedAt,
+		&i.CreatedAt,
+		&i.RateLimitPerSecond,
+		&i.RateLimitBurst,
+		&i.Scopes,
+		&i.ExpiresAt,
+		&i.PreviousKeyHash,
+		&i.PreviousKeyExpiresAt,
+		&i.RotatedAt,
+		&i.LastUsedAt,
+		&i.SigningSecret,
+	)
+	return &i, err
+}
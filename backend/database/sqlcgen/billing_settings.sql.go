@@ -0,0 +1,69 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: billing_settings.sql
+
+package sqlcgen
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const getOrCreateBillingSettings = `-- name: GetOrCreateBillingSettings :one
+INSERT INTO billing_settings (merchant_id)
+VALUES ($1)
+ON CONFLICT (merchant_id) DO UPDATE
+SET merchant_id = EXCLUDED.merchant_id
+RETURNING merchant_id, timezone, anchor_day, updated_at, event_retention_months
+`
+
+func (q *Queries) GetOrCreateBillingSettings(ctx context.Context, merchantID pgtype.UUID) (*BillingSetting, error) {
+	row := q.db.QueryRow(ctx, getOrCreateBillingSettings, merchantID)
+	var i BillingSetting
+	err := row.Scan(
+		&i.MerchantID,
+		&i.Timezone,
+		&i.AnchorDay,
+		&i.UpdatedAt,
+		&i.EventRetentionMonths,
+	)
+	return &i, err
+}
+
+const updateBillingSettings = `-- name: UpdateBillingSettings :one
+INSERT INTO billing_settings (merchant_id, timezone, anchor_day, event_retention_months)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (merchant_id) DO UPDATE
+SET timezone = EXCLUDED.timezone,
+    anchor_day = EXCLUDED.anchor_day,
+    event_retention_months = EXCLUDED.event_retention_months,
+    updated_at = now()
+RETURNING merchant_id, timezone, anchor_day, updated_at, event_retention_months
+`
+
+type UpdateBillingSettingsParams struct {
+	MerchantID           pgtype.UUID
+	Timezone             string
+	AnchorDay            int32
+	EventRetentionMonths pgtype.Int4
+}
+
+func (q *Queries) UpdateBillingSettings(ctx context.Context, arg UpdateBillingSettingsParams) (*BillingSetting, error) {
+	row := q.db.QueryRow(ctx, updateBillingSettings,
+		arg.MerchantID,
+		arg.Timezone,
+		arg.AnchorDay,
+		arg.EventRetentionMonths,
+	)
+	var i BillingSetting
+	err := row.Scan(
+		&i.MerchantID,
+		&i.Timezone,
+		&i.AnchorDay,
+		&i.UpdatedAt,
+		&i.EventRetentionMonths,
+	)
+	return &i, err
+}
@@ -0,0 +1,283 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: coupons.sql
+
+package sqlcgen
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createCoupon = `-- name: CreateCoupon :one
+INSERT INTO coupons (merchant_id, name, percent_off, amount_off, duration, duration_periods, sku_ids)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, merchant_id, name, percent_off, amount_off, duration, duration_periods, sku_ids, revoked_at, created_at
+`
+
+type CreateCouponParams struct {
+	MerchantID      pgtype.UUID
+	Name            string
+	PercentOff      pgtype.Float8
+	AmountOff       pgtype.Float8
+	Duration        string
+	DurationPeriods pgtype.Int4
+	SkuIds          []pgtype.UUID
+}
+
+func (q *Queries) CreateCoupon(ctx context.Context, arg CreateCouponParams) (*Coupon, error) {
+	row := q.db.QueryRow(ctx, createCoupon,
+		arg.MerchantID,
+		arg.Name,
+		arg.PercentOff,
+		arg.AmountOff,
+		arg.Duration,
+		arg.DurationPeriods,
+		arg.SkuIds,
+	)
+	var i Coupon
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.Name,
+		&i.PercentOff,
+		&i.AmountOff,
+		&i.Duration,
+		&i.DurationPeriods,
+		&i.SkuIds,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return &i, err
+}
+
+const createCouponRedemption = `-- name: CreateCouponRedemption :one
+INSERT INTO coupon_redemptions (merchant_id, coupon_id, customer_id)
+VALUES ($1, $2, $3)
+RETURNING id, merchant_id, coupon_id, customer_id, periods_applied, revoked_at, created_at
+`
+
+type CreateCouponRedemptionParams struct {
+	MerchantID pgtype.UUID
+	CouponID   pgtype.UUID
+	CustomerID pgtype.UUID
+}
+
+func (q *Queries) CreateCouponRedemption(ctx context.Context, arg CreateCouponRedemptionParams) (*CouponRedemption, error) {
+	row := q.db.QueryRow(ctx, createCouponRedemption, arg.MerchantID, arg.CouponID, arg.CustomerID)
+	var i CouponRedemption
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.CouponID,
+		&i.CustomerID,
+		&i.PeriodsApplied,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return &i, err
+}
+
+const getCoupon = `-- name: GetCoupon :one
+SELECT id, merchant_id, name, percent_off, amount_off, duration, duration_periods, sku_ids, revoked_at, created_at FROM coupons
+WHERE id = $1 AND merchant_id = $2
+`
+
+type GetCouponParams struct {
+	ID         pgtype.UUID
+	MerchantID pgtype.UUID
+}
+
+func (q *Queries) GetCoupon(ctx context.Context, arg GetCouponParams) (*Coupon, error) {
+	row := q.db.QueryRow(ctx, getCoupon, arg.ID, arg.MerchantID)
+	var i Coupon
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.Name,
+		&i.PercentOff,
+		&i.AmountOff,
+		&i.Duration,
+		&i.DurationPeriods,
+		&i.SkuIds,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return &i, err
+}
+
+const incrementCouponRedemptionPeriods = `-- name: IncrementCouponRedemptionPeriods :exec
+UPDATE coupon_redemptions
+SET periods_applied = periods_applied + 1
+WHERE id IN (
+    SELECT DISTINCT coupon_redemption_id
+    FROM invoice_lines
+    WHERE invoice_id = $1 AND coupon_redemption_id IS NOT NULL
+)
+`
+
+func (q *Queries) IncrementCouponRedemptionPeriods(ctx context.Context, invoiceID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, incrementCouponRedemptionPeriods, invoiceID)
+	return err
+}
+
+const listActiveCouponRedemptionsByCustomer = `-- name: ListActiveCouponRedemptionsByCustomer :many
+SELECT r.id, c.name, c.percent_off, c.amount_off, c.sku_ids
+FROM coupon_redemptions r
+JOIN coupons c ON c.id = r.coupon_id
+WHERE r.merchant_id = $1
+  AND r.customer_id = $2
+  AND r.revoked_at IS NULL
+  AND (c.duration = 'forever' OR r.periods_applied < COALESCE(c.duration_periods, 1))
+ORDER BY r.created_at
+`
+
+type ListActiveCouponRedemptionsByCustomerParams struct {
+	MerchantID pgtype.UUID
+	CustomerID pgtype.UUID
+}
+
+type ListActiveCouponRedemptionsByCustomerRow struct {
+	ID         pgtype.UUID
+	Name       string
+	PercentOff pgtype.Float8
+	AmountOff  pgtype.Float8
+	SkuIds     []pgtype.UUID
+}
+
+func (q *Queries) ListActiveCouponRedemptionsByCustomer(ctx context.Context, arg ListActiveCouponRedemptionsByCustomerParams) ([]*ListActiveCouponRedemptionsByCustomerRow, error) {
+	rows, err := q.db.Query(ctx, listActiveCouponRedemptionsByCustomer, arg.MerchantID, arg.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ListActiveCouponRedemptionsByCustomerRow
+	for rows.Next() {
+		var i ListActiveCouponRedemptionsByCustomerRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.PercentOff,
+			&i.AmountOff,
+			&i.SkuIds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCouponRedemptionsByCouponID = `-- name: ListCouponRedemptionsByCouponID :many
+SELECT id, merchant_id, coupon_id, customer_id, periods_applied, revoked_at, created_at FROM coupon_redemptions
+WHERE coupon_id = $1 AND merchant_id = $2
+ORDER BY created_at DESC
+`
+
+type ListCouponRedemptionsByCouponIDParams struct {
+	CouponID   pgtype.UUID
+	MerchantID pgtype.UUID
+}
+
+func (q *Queries) ListCouponRedemptionsByCouponID(ctx context.Context, arg ListCouponRedemptionsByCouponIDParams) ([]*CouponRedemption, error) {
+	rows, err := q.db.Query(ctx, listCouponRedemptionsByCouponID, arg.CouponID, arg.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*CouponRedemption
+	for rows.Next() {
+		var i CouponRedemption
+		if err := rows.Scan(
+			&i.ID,
+			&i.MerchantID,
+			&i.CouponID,
+			&i.CustomerID,
+			&i.PeriodsApplied,
+			&i.RevokedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCouponsByMerchantID = `-- name: ListCouponsByMerchantID :many
+SELECT id, merchant_id, name, percent_off, amount_off, duration, duration_periods, sku_ids, revoked_at, created_at FROM coupons
+WHERE merchant_id = $1
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListCouponsByMerchantID(ctx context.Context, merchantID pgtype.UUID) ([]*Coupon, error) {
+	rows, err := q.db.Query(ctx, listCouponsByMerchantID, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*Coupon
+	for rows.Next() {
+		var i Coupon
+		if err := rows.Scan(
+			&i.ID,
+			&i.MerchantID,
+			&i.Name,
+			&i.PercentOff,
+			&i.AmountOff,
+			&i.Duration,
+			&i.DurationPeriods,
+			&i.SkuIds,
+			&i.RevokedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeCoupon = `-- name: RevokeCoupon :exec
+UPDATE coupons
+SET revoked_at = now()
+WHERE id = $1 AND merchant_id = $2 AND revoked_at IS NULL
+`
+
+type RevokeCouponParams struct {
+	ID         pgtype.UUID
+	MerchantID pgtype.UUID
+}
+
+func (q *Queries) RevokeCoupon(ctx context.Context, arg RevokeCouponParams) error {
+	_, err := q.db.Exec(ctx, revokeCoupon, arg.ID, arg.MerchantID)
+	return err
+}
+
+const revokeCouponRedemption = `-- name: RevokeCouponRedemption :exec
+UPDATE coupon_redemptions
+SET revoked_at = now()
+WHERE id = $1 AND coupon_id = $2 AND merchant_id = $3 AND revoked_at IS NULL
+`
+
+type RevokeCouponRedemptionParams struct {
+	ID         pgtype.UUID
+	CouponID   pgtype.UUID
+	MerchantID pgtype.UUID
+}
+
+func (q *Queries) RevokeCouponRedemption(ctx context.Context, arg RevokeCouponRedemptionParams) error {
+	_, err := q.db.Exec(ctx, revokeCouponRedemption, arg.ID, arg.CouponID, arg.MerchantID)
+	return err
+}
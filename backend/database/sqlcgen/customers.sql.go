@@ -0,0 +1,286 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: customers.sql
+
+package sqlcgen
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const getCustomer = `-- name: GetCustomer :one
+SELECT id, merchant_id, name, email, address_line1, address_line2, city, postal_code, region, country, tax_id, created_at, updated_at, payment_customer_id, ingest_suspended_at, timezone FROM customers
+WHERE id = $1 AND merchant_id = $2
+`
+
+type GetCustomerParams struct {
+	ID         pgtype.UUID
+	MerchantID pgtype.UUID
+}
+
+func (q *Queries) GetCustomer(ctx context.Context, arg GetCustomerParams) (*Customer, error) {
+	row := q.db.QueryRow(ctx, getCustomer, arg.ID, arg.MerchantID)
+	var i Customer
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.Name,
+		&i.Email,
+		&i.AddressLine1,
+		&i.AddressLine2,
+		&i.City,
+		&i.PostalCode,
+		&i.Region,
+		&i.Country,
+		&i.TaxID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.PaymentCustomerID,
+		&i.IngestSuspendedAt,
+		&i.Timezone,
+	)
+	return &i, err
+}
+
+const getCustomerIngestSettings = `-- name: GetCustomerIngestSettings :one
+SELECT (c.ingest_suspended_at IS NOT NULL)::boolean AS ingest_suspended,
+    COALESCE(c.timezone, bs.timezone, 'UTC')::text AS timezone,
+    COALESCE(bs.anchor_day, 1)::integer AS anchor_day
+FROM merchants m
+LEFT JOIN customers c ON c.merchant_id = m.id AND c.id = $1
+LEFT JOIN billing_settings bs ON bs.merchant_id = m.id
+WHERE m.id = $2
+`
+
+type GetCustomerIngestSettingsParams struct {
+	CustomerID pgtype.UUID
+	MerchantID pgtype.UUID
+}
+
+type GetCustomerIngestSettingsRow struct {
+	IngestSuspended bool
+	Timezone        string
+	AnchorDay       int32
+}
+
+// What the ingest API needs to know of a customer, customer row or not:
+// whether their ingestion is suspended, and the timezone and anchor day of
+// their billing periods.
+func (q *Queries) GetCustomerIngestSettings(ctx context.Context, arg GetCustomerIngestSettingsParams) (*GetCustomerIngestSettingsRow, error) {
+	row := q.db.QueryRow(ctx, getCustomerIngestSettings, arg.CustomerID, arg.MerchantID)
+	var i GetCustomerIngestSettingsRow
+	err := row.Scan(&i.IngestSuspended, &i.Timezone, &i.AnchorDay)
+	return &i, err
+}
+
+const listCustomersByMerchantID = `-- name: ListCustomersByMerchantID :many
+SELECT id, merchant_id, name, email, address_line1, address_line2, city, postal_code, region, country, tax_id, created_at, updated_at, payment_customer_id, ingest_suspended_at, timezone FROM customers
+WHERE merchant_id = $1
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListCustomersByMerchantID(ctx context.Context, merchantID pgtype.UUID) ([]*Customer, error) {
+	rows, err := q.db.Query(ctx, listCustomersByMerchantID, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*Customer
+	for rows.Next() {
+		var i Customer
+		if err := rows.Scan(
+			&i.ID,
+			&i.MerchantID,
+			&i.Name,
+			&i.Email,
+			&i.AddressLine1,
+			&i.AddressLine2,
+			&i.City,
+			&i.PostalCode,
+			&i.Region,
+			&i.Country,
+			&i.TaxID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.PaymentCustomerID,
+			&i.IngestSuspendedAt,
+			&i.Timezone,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const notifyCustomerChanged = `-- name: NotifyCustomerChanged :exec
+SELECT pg_notify('customer_changed', $1::uuid::text || ':' || $2::uuid::text)
+`
+
+type NotifyCustomerChangedParams struct {
+	MerchantID pgtype.UUID
+	CustomerID pgtype.UUID
+}
+
+// Notifies the ingest customer caches, once the transaction commits, that
+// the customer changed.
+func (q *Queries) NotifyCustomerChanged(ctx context.Context, arg NotifyCustomerChangedParams) error {
+	_, err := q.db.Exec(ctx, notifyCustomerChanged, arg.MerchantID, arg.CustomerID)
+	return err
+}
+
+const notifyMerchantCustomersChanged = `-- name: NotifyMerchantCustomersChanged :exec
+SELECT pg_notify('customer_changed', $1::uuid::text || ':')
+`
+
+// Notifies the ingest customer caches, once the transaction commits, that
+// all the customers of the merchant changed.
+func (q *Queries) NotifyMerchantCustomersChanged(ctx context.Context, merchantID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, notifyMerchantCustomersChanged, merchantID)
+	return err
+}
+
+const resumeCustomerIngest = `-- name: ResumeCustomerIngest :one
+UPDATE customers
+SET ingest_suspended_at = NULL, updated_at = now()
+WHERE id = $1 AND merchant_id = $2
+RETURNING id, merchant_id, name, email, address_line1, address_line2, city, postal_code, region, country, tax_id, created_at, updated_at, payment_customer_id, ingest_suspended_at, timezone
+`
+
+type ResumeCustomerIngestParams struct {
+	ID         pgtype.UUID
+	MerchantID pgtype.UUID
+}
+
+func (q *Queries) ResumeCustomerIngest(ctx context.Context, arg ResumeCustomerIngestParams) (*Customer, error) {
+	row := q.db.QueryRow(ctx, resumeCustomerIngest, arg.ID, arg.MerchantID)
+	var i Customer
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.Name,
+		&i.Email,
+		&i.AddressLine1,
+		&i.AddressLine2,
+		&i.City,
+		&i.PostalCode,
+		&i.Region,
+		&i.Country,
+		&i.TaxID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.PaymentCustomerID,
+		&i.IngestSuspendedAt,
+		&i.Timezone,
+	)
+	return &i, err
+}
+
+const setCustomerPaymentCustomerID = `-- name: SetCustomerPaymentCustomerID :exec
+UPDATE customers
+SET payment_customer_id = $3, updated_at = now()
+WHERE id = $1 AND merchant_id = $2
+`
+
+type SetCustomerPaymentCustomerIDParams struct {
+	ID                pgtype.UUID
+	MerchantID        pgtype.UUID
+	PaymentCustomerID pgtype.Text
+}
+
+func (q *Queries) SetCustomerPaymentCustomerID(ctx context.Context, arg SetCustomerPaymentCustomerIDParams) error {
+	_, err := q.db.Exec(ctx, setCustomerPaymentCustomerID, arg.ID, arg.MerchantID, arg.PaymentCustomerID)
+	return err
+}
+
+const suspendCustomerIngest = `-- name: SuspendCustomerIngest :exec
+UPDATE customers
+SET ingest_suspended_at = now(), updated_at = now()
+WHERE id = $1 AND merchant_id = $2 AND ingest_suspended_at IS NULL
+`
+
+type SuspendCustomerIngestParams struct {
+	ID         pgtype.UUID
+	MerchantID pgtype.UUID
+}
+
+func (q *Queries) SuspendCustomerIngest(ctx context.Context, arg SuspendCustomerIngestParams) error {
+	_, err := q.db.Exec(ctx, suspendCustomerIngest, arg.ID, arg.MerchantID)
+	return err
+}
+
+const upsertCustomer = `-- name: UpsertCustomer :one
+INSERT INTO customers (id, merchant_id, name, email, address_line1, address_line2, city, postal_code, region, country, tax_id, timezone)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+ON CONFLICT (merchant_id, id) DO UPDATE
+SET name = EXCLUDED.name,
+    email = EXCLUDED.email,
+    address_line1 = EXCLUDED.address_line1,
+    address_line2 = EXCLUDED.address_line2,
+    city = EXCLUDED.city,
+    postal_code = EXCLUDED.postal_code,
+    region = EXCLUDED.region,
+    country = EXCLUDED.country,
+    tax_id = EXCLUDED.tax_id,
+    timezone = EXCLUDED.timezone,
+    updated_at = now()
+RETURNING id, merchant_id, name, email, address_line1, address_line2, city, postal_code, region, country, tax_id, created_at, updated_at, payment_customer_id, ingest_suspended_at, timezone
+`
+
+type UpsertCustomerParams struct {
+	ID           pgtype.UUID
+	MerchantID   pgtype.UUID
+	Name         string
+	Email        pgtype.Text
+	AddressLine1 pgtype.Text
+	AddressLine2 pgtype.Text
+	City         pgtype.Text
+	PostalCode   pgtype.Text
+	Region       pgtype.Text
+	Country      pgtype.Text
+	TaxID        pgtype.Text
+	Timezone     pgtype.Text
+}
+
+func (q *Queries) UpsertCustomer(ctx context.Context, arg UpsertCustomerParams) (*Customer, error) {
+	row := q.db.QueryRow(ctx, upsertCustomer,
+		arg.ID,
+		arg.MerchantID,
+		arg.Name,
+		arg.Email,
+		arg.AddressLine1,
+		arg.AddressLine2,
+		arg.City,
+		arg.PostalCode,
+		arg.Region,
+		arg.Country,
+		arg.TaxID,
+		arg.Timezone,
+	)
+	var i Customer
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.Name,
+		&i.Email,
+		&i.AddressLine1,
+		&i.AddressLine2,
+		&i.City,
+		&i.PostalCode,
+		&i.Region,
+		&i.Country,
+		&i.TaxID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.PaymentCustomerID,
+		&i.IngestSuspendedAt,
+		&i.Timezone,
+	)
+	return &i, err
+}
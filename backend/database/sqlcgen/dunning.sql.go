@@ -0,0 +1,173 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: dunning.sql
+
+package sqlcgen
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const advanceInvoiceDunning = `-- name: AdvanceInvoiceDunning :exec
+UPDATE invoice_dunnings
+SET step = $2, next_attempt_at = $3, last_error = $4, error_count = $5
+WHERE invoice_id = $1
+`
+
+type AdvanceInvoiceDunningParams struct {
+	InvoiceID     pgtype.UUID
+	Step          int32
+	NextAttemptAt pgtype.Timestamptz
+	LastError     pgtype.Text
+	ErrorCount    int32
+}
+
+func (q *Queries) AdvanceInvoiceDunning(ctx context.Context, arg AdvanceInvoiceDunningParams) error {
+	_, err := q.db.Exec(ctx, advanceInvoiceDunning,
+		arg.InvoiceID,
+		arg.Step,
+		arg.NextAttemptAt,
+		arg.LastError,
+		arg.ErrorCount,
+	)
+	return err
+}
+
+const claimDueInvoiceDunning = `-- name: ClaimDueInvoiceDunning :one
+SELECT invoice_id, merchant_id, retry_days, step, started_at, next_attempt_at, last_error, outcome, completed_at, error_count FROM invoice_dunnings
+WHERE completed_at IS NULL AND next_attempt_at <= now()
+ORDER BY next_attempt_at
+LIMIT 1
+FOR UPDATE SKIP LOCKED
+`
+
+// The row stays locked until the claiming transaction ends.
+func (q *Queries) ClaimDueInvoiceDunning(ctx context.Context) (*InvoiceDunning, error) {
+	row := q.db.QueryRow(ctx, claimDueInvoiceDunning)
+	var i InvoiceDunning
+	err := row.Scan(
+		&i.InvoiceID,
+		&i.MerchantID,
+		&i.RetryDays,
+		&i.Step,
+		&i.StartedAt,
+		&i.NextAttemptAt,
+		&i.LastError,
+		&i.Outcome,
+		&i.CompletedAt,
+		&i.ErrorCount,
+	)
+	return &i, err
+}
+
+const completeInvoiceDunning = `-- name: CompleteInvoiceDunning :exec
+UPDATE invoice_dunnings
+SET outcome = $2, next_attempt_at = NULL, completed_at = now()
+WHERE invoice_id = $1 AND completed_at IS NULL
+`
+
+type CompleteInvoiceDunningParams struct {
+	InvoiceID pgtype.UUID
+	Outcome   pgtype.Text
+}
+
+func (q *Queries) CompleteInvoiceDunning(ctx context.Context, arg CompleteInvoiceDunningParams) error {
+	_, err := q.db.Exec(ctx, completeInvoiceDunning, arg.InvoiceID, arg.Outcome)
+	return err
+}
+
+const getDunningSettings = `-- name: GetDunningSettings :one
+SELECT merchant_id, retry_days, suspend_ingest, updated_at FROM dunning_settings
+WHERE merchant_id = $1
+`
+
+func (q *Queries) GetDunningSettings(ctx context.Context, merchantID pgtype.UUID) (*DunningSetting, error) {
+	row := q.db.QueryRow(ctx, getDunningSettings, merchantID)
+	var i DunningSetting
+	err := row.Scan(
+		&i.MerchantID,
+		&i.RetryDays,
+		&i.SuspendIngest,
+		&i.UpdatedAt,
+	)
+	return &i, err
+}
+
+const getInvoiceDunning = `-- name: GetInvoiceDunning :one
+SELECT invoice_id, merchant_id, retry_days, step, started_at, next_attempt_at, last_error, outcome, completed_at, error_count FROM invoice_dunnings
+WHERE invoice_id = $1
+`
+
+func (q *Queries) GetInvoiceDunning(ctx context.Context, invoiceID pgtype.UUID) (*InvoiceDunning, error) {
+	row := q.db.QueryRow(ctx, getInvoiceDunning, invoiceID)
+	var i InvoiceDunning
+	err := row.Scan(
+		&i.InvoiceID,
+		&i.MerchantID,
+		&i.RetryDays,
+		&i.Step,
+		&i.StartedAt,
+		&i.NextAttemptAt,
+		&i.LastError,
+		&i.Outcome,
+		&i.CompletedAt,
+		&i.ErrorCount,
+	)
+	return &i, err
+}
+
+const startInvoiceDunning = `-- name: StartInvoiceDunning :exec
+INSERT INTO invoice_dunnings (invoice_id, merchant_id, retry_days, next_attempt_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (invoice_id) DO NOTHING
+`
+
+type StartInvoiceDunningParams struct {
+	InvoiceID     pgtype.UUID
+	MerchantID    pgtype.UUID
+	RetryDays     []int32
+	NextAttemptAt pgtype.Timestamptz
+}
+
+// The retry schedule is copied from the merchant's settings so that later
+// changes only affect new dunnings.
+func (q *Queries) StartInvoiceDunning(ctx context.Context, arg StartInvoiceDunningParams) error {
+	_, err := q.db.Exec(ctx, startInvoiceDunning,
+		arg.InvoiceID,
+		arg.MerchantID,
+		arg.RetryDays,
+		arg.NextAttemptAt,
+	)
+	return err
+}
+
+const updateDunningSettings = `-- name: UpdateDunningSettings :one
+INSERT INTO dunning_settings (merchant_id, retry_days, suspend_ingest)
+VALUES ($1, $2, $3)
+ON CONFLICT (merchant_id) DO UPDATE
+SET retry_days = EXCLUDED.retry_days,
+    suspend_ingest = EXCLUDED.suspend_ingest,
+    updated_at = now()
+RETURNING merchant_id, retry_days, suspend_ingest, updated_at
+`
+
+type UpdateDunningSettingsParams struct {
+	MerchantID    pgtype.UUID
+	RetryDays     []int32
+	SuspendIngest bool
+}
+
+func (q *Queries) UpdateDunningSettings(ctx context.Context, arg UpdateDunningSettingsParams) (*DunningSetting, error) {
+	row := q.db.QueryRow(ctx, updateDunningSettings, arg.MerchantID, arg.RetryDays, arg.SuspendIngest)
+	var i DunningSetting
+	err := row.Scan(
+		&i.MerchantID,
+		&i.RetryDays,
+		&i.SuspendIngest,
+		&i.UpdatedAt,
+	)
+	return &i, err
+}
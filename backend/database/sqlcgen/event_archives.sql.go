@@ -0,0 +1,343 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: event_archives.sql
+
+package sqlcgen
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const getEventArchive = `-- name: GetEventArchive :one
+SELECT merchant_id, period_start, period_end, blob_key, event_count, database_event_count, size_bytes, sha256, archived_at, rehydrated_until FROM event_archives
+WHERE merchant_id = $1 AND period_start = $2
+`
+
+type GetEventArchiveParams struct {
+	MerchantID  pgtype.UUID
+	PeriodStart pgtype.Timestamptz
+}
+
+func (q *Queries) GetEventArchive(ctx context.Context, arg GetEventArchiveParams) (*EventArchive, error) {
+	row := q.db.QueryRow(ctx, getEventArchive, arg.MerchantID, arg.PeriodStart)
+	var i EventArchive
+	err := row.Scan(
+		&i.MerchantID,
+		&i.PeriodStart,
+		&i.PeriodEnd,
+		&i.BlobKey,
+		&i.EventCount,
+		&i.DatabaseEventCount,
+		&i.SizeBytes,
+		&i.Sha256,
+		&i.ArchivedAt,
+		&i.RehydratedUntil,
+	)
+	return &i, err
+}
+
+const insertArchivedEvents = `-- name: InsertArchivedEvents :execrows
+INSERT INTO events (id, merchant_id, customer_id, sku_id, amount, sent_at)
+SELECT unnest($1::uuid[]),
+       $2::uuid,
+       unnest($3::uuid[]),
+       unnest($4::uuid[]),
+       unnest($5::double precision[]),
+       unnest($6::timestamptz[])
+ON CONFLICT (id, sent_at) DO NOTHING
+`
+
+type InsertArchivedEventsParams struct {
+	Ids         []pgtype.UUID
+	MerchantID  pgtype.UUID
+	CustomerIds []pgtype.UUID
+	SkuIds      []pgtype.UUID
+	Amounts     []float64
+	SentAts     []pgtype.Timestamptz
+}
+
+// Restores archived events, skipping those still in the database.
+func (q *Queries) InsertArchivedEvents(ctx context.Context, arg InsertArchivedEventsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, insertArchivedEvents,
+		arg.Ids,
+		arg.MerchantID,
+		arg.CustomerIds,
+		arg.SkuIds,
+		arg.Amounts,
+		arg.SentAts,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const listArchivableMerchants = `-- name: ListArchivableMerchants :many
+SELECT m.merchant_id, m.event_count::bigint AS event_count
+FROM (
+    SELECT e.merchant_id, count(*) AS event_count
+    FROM events e
+    WHERE e.sent_at >= $1
+      AND e.sent_at < $2
+    GROUP BY e.merchant_id
+) m
+LEFT JOIN event_archives a ON a.merchant_id = m.merchant_id AND a.period_start = $1
+WHERE a.database_event_count IS DISTINCT FROM m.event_count
+  AND NOT EXISTS (
+    SELECT 1 FROM events e
+    WHERE e.merchant_id = m.merchant_id
+      AND e.sent_at >= $1
+      AND e.sent_at < $2
+      AND NOT EXISTS (
+        SELECT 1 FROM invoices i
+        WHERE i.merchant_id = e.merchant_id
+          AND i.customer_id = e.customer_id
+          AND i.period_start <= e.sent_at
+          AND e.sent_at < i.period_end
+          AND i.status <> 'draft'
+      )
+  )
+ORDER BY m.merchant_id
+`
+
+type ListArchivableMerchantsParams struct {
+	RangeStart pgtype.Timestamptz
+	RangeEnd   pgtype.Timestamptz
+}
+
+type ListArchivableMerchantsRow struct {
+	MerchantID pgtype.UUID
+	EventCount int64
+}
+
+// The merchants with events over [range_start, range_end) all covered by a
+// finalized invoice, and not archived yet or with events added since.
+// Late events of a month whose partition was dropped make the counts differ
+// too: they are merged into the existing archive.
+func (q *Queries) ListArchivableMerchants(ctx context.Context, arg ListArchivableMerchantsParams) ([]*ListArchivableMerchantsRow, error) {
+	rows, err := q.db.Query(ctx, listArchivableMerchants, arg.RangeStart, arg.RangeEnd)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ListArchivableMerchantsRow
+	for rows.Next() {
+		var i ListArchivableMerchantsRow
+		if err := rows.Scan(&i.MerchantID, &i.EventCount); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listEventArchivesByMerchantID = `-- name: ListEventArchivesByMerchantID :many
+SELECT merchant_id, period_start, period_end, blob_key, event_count, database_event_count, size_bytes, sha256, archived_at, rehydrated_until FROM event_archives
+WHERE merchant_id = $1
+ORDER BY period_start DESC
+`
+
+func (q *Queries) ListEventArchivesByMerchantID(ctx context.Context, merchantID pgtype.UUID) ([]*EventArchive, error) {
+	rows, err := q.db.Query(ctx, listEventArchivesByMerchantID, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*EventArchive
+	for rows.Next() {
+		var i EventArchive
+		if err := rows.Scan(
+			&i.MerchantID,
+			&i.PeriodStart,
+			&i.PeriodEnd,
+			&i.BlobKey,
+			&i.EventCount,
+			&i.DatabaseEventCount,
+			&i.SizeBytes,
+			&i.Sha256,
+			&i.ArchivedAt,
+			&i.RehydratedUntil,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listEventArchivesOverlapping = `-- name: ListEventArchivesOverlapping :many
+SELECT merchant_id, period_start, period_end, blob_key, event_count, database_event_count, size_bytes, sha256, archived_at, rehydrated_until FROM event_archives
+WHERE merchant_id = $1
+  AND period_start < $2
+  AND period_end > $3
+ORDER BY period_start
+`
+
+type ListEventArchivesOverlappingParams struct {
+	MerchantID pgtype.UUID
+	RangeEnd   pgtype.Timestamptz
+	RangeStart pgtype.Timestamptz
+}
+
+func (q *Queries) ListEventArchivesOverlapping(ctx context.Context, arg ListEventArchivesOverlappingParams) ([]*EventArchive, error) {
+	rows, err := q.db.Query(ctx, listEventArchivesOverlapping, arg.MerchantID, arg.RangeEnd, arg.RangeStart)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*EventArchive
+	for rows.Next() {
+		var i EventArchive
+		if err := rows.Scan(
+			&i.MerchantID,
+			&i.PeriodStart,
+			&i.PeriodEnd,
+			&i.BlobKey,
+			&i.EventCount,
+			&i.DatabaseEventCount,
+			&i.SizeBytes,
+			&i.Sha256,
+			&i.ArchivedAt,
+			&i.RehydratedUntil,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listEventsToArchive = `-- name: ListEventsToArchive :many
+SELECT id, merchant_id, customer_id, sku_id, amount, sent_at FROM events
+WHERE merchant_id = $1
+  AND sent_at >= $2
+  AND sent_at < $3
+  AND (sent_at, id) > ($4::timestamptz, $5::uuid)
+ORDER BY sent_at, id
+LIMIT $6
+`
+
+type ListEventsToArchiveParams struct {
+	MerchantID  pgtype.UUID
+	RangeStart  pgtype.Timestamptz
+	RangeEnd    pgtype.Timestamptz
+	AfterSentAt pgtype.Timestamptz
+	AfterID     pgtype.UUID
+	BatchSize   int32
+}
+
+// Keyset pagination over the merchant's events of the range, by (sent_at, id).
+func (q *Queries) ListEventsToArchive(ctx context.Context, arg ListEventsToArchiveParams) ([]*Event, error) {
+	rows, err := q.db.Query(ctx, listEventsToArchive,
+		arg.MerchantID,
+		arg.RangeStart,
+		arg.RangeEnd,
+		arg.AfterSentAt,
+		arg.AfterID,
+		arg.BatchSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*Event
+	for rows.Next() {
+		var i Event
+		if err := rows.Scan(
+			&i.ID,
+			&i.MerchantID,
+			&i.CustomerID,
+			&i.SkuID,
+			&i.Amount,
+			&i.SentAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const putEventArchive = `-- name: PutEventArchive :one
+INSERT INTO event_archives (merchant_id, period_start, period_end, blob_key, event_count, database_event_count, size_bytes, sha256)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (merchant_id, period_start) DO UPDATE
+SET period_end = EXCLUDED.period_end,
+    blob_key = EXCLUDED.blob_key,
+    event_count = EXCLUDED.event_count,
+    database_event_count = EXCLUDED.database_event_count,
+    size_bytes = EXCLUDED.size_bytes,
+    sha256 = EXCLUDED.sha256,
+    archived_at = now()
+RETURNING merchant_id, period_start, period_end, blob_key, event_count, database_event_count, size_bytes, sha256, archived_at, rehydrated_until
+`
+
+type PutEventArchiveParams struct {
+	MerchantID         pgtype.UUID
+	PeriodStart        pgtype.Timestamptz
+	PeriodEnd          pgtype.Timestamptz
+	BlobKey            string
+	EventCount         int64
+	DatabaseEventCount int64
+	SizeBytes          int64
+	Sha256             string
+}
+
+func (q *Queries) PutEventArchive(ctx context.Context, arg PutEventArchiveParams) (*EventArchive, error) {
+	row := q.db.QueryRow(ctx, putEventArchive,
+		arg.MerchantID,
+		arg.PeriodStart,
+		arg.PeriodEnd,
+		arg.BlobKey,
+		arg.EventCount,
+		arg.DatabaseEventCount,
+		arg.SizeBytes,
+		arg.Sha256,
+	)
+	var i EventArchive
+	err := row.Scan(
+		&i.MerchantID,
+		&i.PeriodStart,
+		&i.PeriodEnd,
+		&i.BlobKey,
+		&i.EventCount,
+		&i.DatabaseEventCount,
+		&i.SizeBytes,
+		&i.Sha256,
+		&i.ArchivedAt,
+		&i.RehydratedUntil,
+	)
+	return &i, err
+}
+
+const setEventArchiveRehydratedUntil = `-- name: SetEventArchiveRehydratedUntil :exec
+UPDATE event_archives
+SET rehydrated_until = $3
+WHERE merchant_id = $1 AND period_start = $2
+`
+
+type SetEventArchiveRehydratedUntilParams struct {
+	MerchantID      pgtype.UUID
+	PeriodStart     pgtype.Timestamptz
+	RehydratedUntil pgtype.Timestamptz
+}
+
+func (q *Queries) SetEventArchiveRehydratedUntil(ctx context.Context, arg SetEventArchiveRehydratedUntilParams) error {
+	_, err := q.db.Exec(ctx, setEventArchiveRehydratedUntil, arg.MerchantID, arg.PeriodStart, arg.RehydratedUntil)
+	return err
+}
@@ -0,0 +1,163 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: event_partitions.sql
+
+package sqlcgen
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const isEventRangeExpired = `-- name: IsEventRangeExpired :one
+SELECT (NOT EXISTS (
+    SELECT 1
+    FROM (
+        SELECT e.merchant_id, count(*) AS event_count
+        FROM events e
+        WHERE e.sent_at >= $1
+          AND e.sent_at < $2
+        GROUP BY e.merchant_id
+    ) m
+    LEFT JOIN billing_settings bs ON bs.merchant_id = m.merchant_id
+    LEFT JOIN event_archives a ON a.merchant_id = m.merchant_id AND a.period_start = $1
+    WHERE bs.event_retention_months IS NULL
+       OR $2::timestamptz > now() - make_interval(months => bs.event_retention_months)
+       OR a.database_event_count IS DISTINCT FROM m.event_count
+       OR a.rehydrated_until > now()
+       OR EXISTS (
+        SELECT 1 FROM events e
+        WHERE e.merchant_id = m.merchant_id
+          AND e.sent_at >= $1
+          AND e.sent_at < $2
+          AND NOT EXISTS (
+            SELECT 1 FROM invoices i
+            WHERE i.merchant_id = e.merchant_id
+              AND i.customer_id = e.customer_id
+              AND i.period_start <= e.sent_at
+              AND e.sent_at < i.period_end
+              AND i.status <> 'draft'
+          )
+       )
+))::boolean
+`
+
+type IsEventRangeExpiredParams struct {
+	RangeStart pgtype.Timestamptz
+	RangeEnd   pgtype.Timestamptz
+}
+
+// Whether the events over [range_start, range_end) can be dropped: every
+// merchant with events in the range has a retention window the range ended
+// before, all their events are archived and covered by a finalized invoice,
+// and none are held by a rehydration.
+func (q *Queries) IsEventRangeExpired(ctx context.Context, arg IsEventRangeExpiredParams) (bool, error) {
+	row := q.db.QueryRow(ctx, isEventRangeExpired, arg.RangeStart, arg.RangeEnd)
+	var column_1 bool
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const listDefaultPartitionMonths = `-- name: ListDefaultPartitionMonths :many
+SELECT DISTINCT date_trunc('month', sent_at, 'UTC')::timestamptz AS month
+FROM events_default
+ORDER BY month
+`
+
+// The months of the events that fell in the default partition, for lack of a
+// monthly partition.
+func (q *Queries) ListDefaultPartitionMonths(ctx context.Context) ([]pgtype.Timestamptz, error) {
+	rows, err := q.db.Query(ctx, listDefaultPartitionMonths)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []pgtype.Timestamptz
+	for rows.Next() {
+		var month pgtype.Timestamptz
+		if err := rows.Scan(&month); err != nil {
+			return nil, err
+		}
+		items = append(items, month)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listEventPartitions = `-- name: ListEventPartitions :many
+SELECT c.relname::text AS name
+FROM pg_catalog.pg_inherits i
+JOIN pg_catalog.pg_class c ON c.oid = i.inhrelid
+JOIN pg_catalog.pg_class p ON p.oid = i.inhparent
+WHERE p.relname = 'events'
+  AND c.relname <> 'events_default'
+ORDER BY c.relname
+`
+
+// The monthly partitions of events, i.e. all but the default one.
+func (q *Queries) ListEventPartitions(ctx context.Context) ([]string, error) {
+	rows, err := q.db.Query(ctx, listEventPartitions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		items = append(items, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUnarchivedExpiredEventMerchants = `-- name: ListUnarchivedExpiredEventMerchants :many
+SELECT m.merchant_id
+FROM (
+    SELECT e.merchant_id, count(*) AS event_count
+    FROM events e
+    WHERE e.sent_at >= $1
+      AND e.sent_at < $2
+    GROUP BY e.merchant_id
+) m
+JOIN billing_settings bs ON bs.merchant_id = m.merchant_id
+LEFT JOIN event_archives a ON a.merchant_id = m.merchant_id AND a.period_start = $1
+WHERE $2::timestamptz <= now() - make_interval(months => bs.event_retention_months)
+  AND a.database_event_count IS DISTINCT FROM m.event_count
+ORDER BY m.merchant_id
+`
+
+type ListUnarchivedExpiredEventMerchantsParams struct {
+	RangeStart pgtype.Timestamptz
+	RangeEnd   pgtype.Timestamptz
+}
+
+// The merchants with events over [range_start, range_end) past their
+// retention window but not (fully) archived, which hold the range back.
+func (q *Queries) ListUnarchivedExpiredEventMerchants(ctx context.Context, arg ListUnarchivedExpiredEventMerchantsParams) ([]pgtype.UUID, error) {
+	rows, err := q.db.Query(ctx, listUnarchivedExpiredEventMerchants, arg.RangeStart, arg.RangeEnd)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []pgtype.UUID
+	for rows.Next() {
+		var merchant_id pgtype.UUID
+		if err := rows.Scan(&merchant_id); err != nil {
+			return nil, err
+		}
+		items = append(items, merchant_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -65,3 +65,74 @@ func (q *Queries) ListEventsByMerchantID(ctx context.Context, merchantID pgtype.
 	}
 	return items, nil
 }
+
+const listUsageBySKU = `-- name: ListUsageBySKU :many
+SELECT e.sku_id, s.name, COALESCE(o.price_per_unit, s.price_per_unit)::double precision AS unit_price, SUM(e.amount)::double precision AS quantity
+FROM events e
+JOIN skus s ON s.id = e.sku_id
+LEFT JOIN LATERAL (
+    SELECT po.price_per_unit
+    FROM price_overrides po
+    WHERE po.merchant_id = e.merchant_id
+      AND po.sku_id = e.sku_id
+      AND po.customer_id = e.customer_id
+      AND po.revoked_at IS NULL
+      AND (po.starts_at IS NULL OR po.starts_at <= e.sent_at)
+      AND (po.ends_at IS NULL OR e.sent_at < po.ends_at)
+    ORDER BY po.created_at DESC
+    LIMIT 1
+) o ON true
+WHERE e.merchant_id = $1
+  AND e.customer_id = $2
+  AND e.sent_at >= $3
+  AND e.sent_at < $4
+GROUP BY e.sku_id, s.name, unit_price
+ORDER BY s.name, unit_price
+`
+
+type ListUsageBySKUParams struct {
+	MerchantID  pgtype.UUID
+	CustomerID  pgtype.UUID
+	PeriodStart pgtype.Timestamptz
+	PeriodEnd   pgtype.Timestamptz
+}
+
+type ListUsageBySKURow struct {
+	SkuID     pgtype.UUID
+	Name      string
+	UnitPrice float64
+	Quantity  float64
+}
+
+// Usage is rated per event: a customer's price override on the SKU takes
+// precedence over the SKU list price when the event falls within its window.
+// A SKU therefore yields one row per distinct unit price over the period.
+func (q *Queries) ListUsageBySKU(ctx context.Context, arg ListUsageBySKUParams) ([]*ListUsageBySKURow, error) {
+	rows, err := q.db.Query(ctx, listUsageBySKU,
+		arg.MerchantID,
+		arg.CustomerID,
+		arg.PeriodStart,
+		arg.PeriodEnd,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ListUsageBySKURow
+	for rows.Next() {
+		var i ListUsageBySKURow
+		if err := rows.Scan(
+			&i.SkuID,
+			&i.Name,
+			&i.UnitPrice,
+			&i.Quantity,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,125 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: invoice_settings.sql
+
+package sqlcgen
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const getInvoiceSettings = `-- name: GetInvoiceSettings :one
+SELECT merchant_id, number_prefix, last_invoice_number, payment_terms_days, accent_color, header_template, footer_template, logo, logo_content_type, updated_at FROM invoice_settings
+WHERE merchant_id = $1
+`
+
+func (q *Queries) GetInvoiceSettings(ctx context.Context, merchantID pgtype.UUID) (*InvoiceSetting, error) {
+	row := q.db.QueryRow(ctx, getInvoiceSettings, merchantID)
+	var i InvoiceSetting
+	err := row.Scan(
+		&i.MerchantID,
+		&i.NumberPrefix,
+		&i.LastInvoiceNumber,
+		&i.PaymentTermsDays,
+		&i.AccentColor,
+		&i.HeaderTemplate,
+		&i.FooterTemplate,
+		&i.Logo,
+		&i.LogoContentType,
+		&i.UpdatedAt,
+	)
+	return &i, err
+}
+
+const nextInvoiceNumber = `-- name: NextInvoiceNumber :one
+INSERT INTO invoice_settings (merchant_id, last_invoice_number)
+VALUES ($1, 1)
+ON CONFLICT (merchant_id) DO UPDATE
+SET last_invoice_number = invoice_settings.last_invoice_number + 1
+RETURNING number_prefix, last_invoice_number, payment_terms_days
+`
+
+type NextInvoiceNumberRow struct {
+	NumberPrefix      string
+	LastInvoiceNumber int32
+	PaymentTermsDays  int32
+}
+
+// Hands out the merchant's next invoice number. The row lock taken by the
+// upsert serializes concurrent finalizations.
+func (q *Queries) NextInvoiceNumber(ctx context.Context, merchantID pgtype.UUID) (*NextInvoiceNumberRow, error) {
+	row := q.db.QueryRow(ctx, nextInvoiceNumber, merchantID)
+	var i NextInvoiceNumberRow
+	err := row.Scan(&i.NumberPrefix, &i.LastInvoiceNumber, &i.PaymentTermsDays)
+	return &i, err
+}
+
+const updateInvoiceLogo = `-- name: UpdateInvoiceLogo :exec
+INSERT INTO invoice_settings (merchant_id, logo, logo_content_type)
+VALUES ($1, $2, $3)
+ON CONFLICT (merchant_id) DO UPDATE
+SET logo = EXCLUDED.logo,
+    logo_content_type = EXCLUDED.logo_content_type,
+    updated_at = now()
+`
+
+type UpdateInvoiceLogoParams struct {
+	MerchantID      pgtype.UUID
+	Logo            []byte
+	LogoContentType pgtype.Text
+}
+
+func (q *Queries) UpdateInvoiceLogo(ctx context.Context, arg UpdateInvoiceLogoParams) error {
+	_, err := q.db.Exec(ctx, updateInvoiceLogo, arg.MerchantID, arg.Logo, arg.LogoContentType)
+	return err
+}
+
+const updateInvoiceSettings = `-- name: UpdateInvoiceSettings :one
+INSERT INTO invoice_settings (merchant_id, number_prefix, payment_terms_days, accent_color, header_template, footer_template)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (merchant_id) DO UPDATE
+SET number_prefix = EXCLUDED.number_prefix,
+    payment_terms_days = EXCLUDED.payment_terms_days,
+    accent_color = EXCLUDED.accent_color,
+    header_template = EXCLUDED.header_template,
+    footer_template = EXCLUDED.footer_template,
+    updated_at = now()
+RETURNING merchant_id, number_prefix, last_invoice_number, payment_terms_days, accent_color, header_template, footer_template, logo, logo_content_type, updated_at
+`
+
+type UpdateInvoiceSettingsParams struct {
+	MerchantID       pgtype.UUID
+	NumberPrefix     string
+	PaymentTermsDays int32
+	AccentColor      string
+	HeaderTemplate   string
+	FooterTemplate   string
+}
+
+func (q *Queries) UpdateInvoiceSettings(ctx context.Context, arg UpdateInvoiceSettingsParams) (*InvoiceSetting, error) {
+	row := q.db.QueryRow(ctx, updateInvoiceSettings,
+		arg.MerchantID,
+		arg.NumberPrefix,
+		arg.PaymentTermsDays,
+		arg.AccentColor,
+		arg.HeaderTemplate,
+		arg.FooterTemplate,
+	)
+	var i InvoiceSetting
+	err := row.Scan(
+		&i.MerchantID,
+		&i.NumberPrefix,
+		&i.LastInvoiceNumber,
+		&i.PaymentTermsDays,
+		&i.AccentColor,
+		&i.HeaderTemplate,
+		&i.FooterTemplate,
+		&i.Logo,
+		&i.LogoContentType,
+		&i.UpdatedAt,
+	)
+	return &i, err
+}
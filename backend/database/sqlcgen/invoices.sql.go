@@ -0,0 +1,517 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: invoices.sql
+
+package sqlcgen
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createInvoice = `-- name: CreateInvoice :one
+INSERT INTO invoices (merchant_id, customer_id, period_start, period_end, subtotal, discount_total, tax_total, total)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, merchant_id, customer_id, status, period_start, period_end, subtotal, discount_total, total, finalized_at, created_at, tax_total, number, due_at, payment_provider, payment_id, paid_at
+`
+
+type CreateInvoiceParams struct {
+	MerchantID    pgtype.UUID
+	CustomerID    pgtype.UUID
+	PeriodStart   pgtype.Timestamptz
+	PeriodEnd     pgtype.Timestamptz
+	Subtotal      float64
+	DiscountTotal float64
+	TaxTotal      float64
+	Total         float64
+}
+
+func (q *Queries) CreateInvoice(ctx context.Context, arg CreateInvoiceParams) (*Invoice, error) {
+	row := q.db.QueryRow(ctx, createInvoice,
+		arg.MerchantID,
+		arg.CustomerID,
+		arg.PeriodStart,
+		arg.PeriodEnd,
+		arg.Subtotal,
+		arg.DiscountTotal,
+		arg.TaxTotal,
+		arg.Total,
+	)
+	var i Invoice
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.CustomerID,
+		&i.Status,
+		&i.PeriodStart,
+		&i.PeriodEnd,
+		&i.Subtotal,
+		&i.DiscountTotal,
+		&i.Total,
+		&i.FinalizedAt,
+		&i.CreatedAt,
+		&i.TaxTotal,
+		&i.Number,
+		&i.DueAt,
+		&i.PaymentProvider,
+		&i.PaymentID,
+		&i.PaidAt,
+	)
+	return &i, err
+}
+
+const createInvoiceLine = `-- name: CreateInvoiceLine :exec
+INSERT INTO invoice_lines (invoice_id, position, kind, description, sku_id, quantity, unit_price, amount, coupon_redemption_id, tax_rate_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+`
+
+type CreateInvoiceLineParams struct {
+	InvoiceID          pgtype.UUID
+	Position           int32
+	Kind               string
+	Description        string
+	SkuID              pgtype.UUID
+	Quantity           pgtype.Float8
+	UnitPrice          pgtype.Float8
+	Amount             float64
+	CouponRedemptionID pgtype.UUID
+	TaxRateID          pgtype.UUID
+}
+
+func (q *Queries) CreateInvoiceLine(ctx context.Context, arg CreateInvoiceLineParams) error {
+	_, err := q.db.Exec(ctx, createInvoiceLine,
+		arg.InvoiceID,
+		arg.Position,
+		arg.Kind,
+		arg.Description,
+		arg.SkuID,
+		arg.Quantity,
+		arg.UnitPrice,
+		arg.Amount,
+		arg.CouponRedemptionID,
+		arg.TaxRateID,
+	)
+	return err
+}
+
+const deleteDraftInvoice = `-- name: DeleteDraftInvoice :exec
+DELETE FROM invoices
+WHERE merchant_id = $1
+  AND customer_id = $2
+  AND period_start = $3
+  AND period_end = $4
+  AND status = 'draft'
+`
+
+type DeleteDraftInvoiceParams struct {
+	MerchantID  pgtype.UUID
+	CustomerID  pgtype.UUID
+	PeriodStart pgtype.Timestamptz
+	PeriodEnd   pgtype.Timestamptz
+}
+
+func (q *Queries) DeleteDraftInvoice(ctx context.Context, arg DeleteDraftInvoiceParams) error {
+	_, err := q.db.Exec(ctx, deleteDraftInvoice,
+		arg.MerchantID,
+		arg.CustomerID,
+		arg.PeriodStart,
+		arg.PeriodEnd,
+	)
+	return err
+}
+
+const finalizeInvoice = `-- name: FinalizeInvoice :one
+UPDATE invoices
+SET status = 'finalized', finalized_at = now()
+WHERE id = $1 AND merchant_id = $2 AND status = 'draft'
+RETURNING id, merchant_id, customer_id, status, period_start, period_end, subtotal, discount_total, total, finalized_at, created_at, tax_total, number, due_at, payment_provider, payment_id, paid_at
+`
+
+type FinalizeInvoiceParams struct {
+	ID         pgtype.UUID
+	MerchantID pgtype.UUID
+}
+
+func (q *Queries) FinalizeInvoice(ctx context.Context, arg FinalizeInvoiceParams) (*Invoice, error) {
+	row := q.db.QueryRow(ctx, finalizeInvoice, arg.ID, arg.MerchantID)
+	var i Invoice
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.CustomerID,
+		&i.Status,
+		&i.PeriodStart,
+		&i.PeriodEnd,
+		&i.Subtotal,
+		&i.DiscountTotal,
+		&i.Total,
+		&i.FinalizedAt,
+		&i.CreatedAt,
+		&i.TaxTotal,
+		&i.Number,
+		&i.DueAt,
+		&i.PaymentProvider,
+		&i.PaymentID,
+		&i.PaidAt,
+	)
+	return &i, err
+}
+
+const getInvoice = `-- name: GetInvoice :one
+SELECT id, merchant_id, customer_id, status, period_start, period_end, subtotal, discount_total, total, finalized_at, created_at, tax_total, number, due_at, payment_provider, payment_id, paid_at FROM invoices
+WHERE id = $1 AND merchant_id = $2
+`
+
+type GetInvoiceParams struct {
+	ID         pgtype.UUID
+	MerchantID pgtype.UUID
+}
+
+func (q *Queries) GetInvoice(ctx context.Context, arg GetInvoiceParams) (*Invoice, error) {
+	row := q.db.QueryRow(ctx, getInvoice, arg.ID, arg.MerchantID)
+	var i Invoice
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.CustomerID,
+		&i.Status,
+		&i.PeriodStart,
+		&i.PeriodEnd,
+		&i.Subtotal,
+		&i.DiscountTotal,
+		&i.Total,
+		&i.FinalizedAt,
+		&i.CreatedAt,
+		&i.TaxTotal,
+		&i.Number,
+		&i.DueAt,
+		&i.PaymentProvider,
+		&i.PaymentID,
+		&i.PaidAt,
+	)
+	return &i, err
+}
+
+const getInvoiceByPayment = `-- name: GetInvoiceByPayment :one
+SELECT id, merchant_id, customer_id, status, period_start, period_end, subtotal, discount_total, total, finalized_at, created_at, tax_total, number, due_at, payment_provider, payment_id, paid_at FROM invoices
+WHERE payment_provider = $1 AND payment_id = $2
+`
+
+type GetInvoiceByPaymentParams struct {
+	PaymentProvider pgtype.Text
+	PaymentID       pgtype.Text
+}
+
+func (q *Queries) GetInvoiceByPayment(ctx context.Context, arg GetInvoiceByPaymentParams) (*Invoice, error) {
+	row := q.db.QueryRow(ctx, getInvoiceByPayment, arg.PaymentProvider, arg.PaymentID)
+	var i Invoice
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.CustomerID,
+		&i.Status,
+		&i.PeriodStart,
+		&i.PeriodEnd,
+		&i.Subtotal,
+		&i.DiscountTotal,
+		&i.Total,
+		&i.FinalizedAt,
+		&i.CreatedAt,
+		&i.TaxTotal,
+		&i.Number,
+		&i.DueAt,
+		&i.PaymentProvider,
+		&i.PaymentID,
+		&i.PaidAt,
+	)
+	return &i, err
+}
+
+const hasUninvoicedUsage = `-- name: HasUninvoicedUsage :one
+SELECT (EXISTS (
+    SELECT 1 FROM events e
+    WHERE e.merchant_id = $1
+      AND e.customer_id = $2
+      AND e.sent_at >= $3
+      AND e.sent_at < $4
+) AND NOT EXISTS (
+    SELECT 1 FROM invoices i
+    WHERE i.merchant_id = $1
+      AND i.customer_id = $2
+      AND i.period_start = $3
+      AND i.period_end = $4
+      AND i.status <> 'draft'
+))::boolean
+`
+
+type HasUninvoicedUsageParams struct {
+	MerchantID  pgtype.UUID
+	CustomerID  pgtype.UUID
+	PeriodStart pgtype.Timestamptz
+	PeriodEnd   pgtype.Timestamptz
+}
+
+// Whether the customer has usage over the period and no finalized invoice for it.
+func (q *Queries) HasUninvoicedUsage(ctx context.Context, arg HasUninvoicedUsageParams) (bool, error) {
+	row := q.db.QueryRow(ctx, hasUninvoicedUsage,
+		arg.MerchantID,
+		arg.CustomerID,
+		arg.PeriodStart,
+		arg.PeriodEnd,
+	)
+	var column_1 bool
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const listBillableCustomers = `-- name: ListBillableCustomers :many
+SELECT DISTINCT uc.merchant_id, uc.customer_id,
+    COALESCE(c.timezone, bs.timezone, 'UTC')::text AS timezone,
+    COALESCE(bs.anchor_day, 1)::integer AS anchor_day
+FROM usage_counters uc
+LEFT JOIN customers c ON c.merchant_id = uc.merchant_id AND c.id = uc.customer_id
+LEFT JOIN billing_settings bs ON bs.merchant_id = uc.merchant_id
+WHERE uc.period_end >= $1
+`
+
+type ListBillableCustomersRow struct {
+	MerchantID pgtype.UUID
+	CustomerID pgtype.UUID
+	Timezone   string
+	AnchorDay  int32
+}
+
+// Customers with usage counters of periods ending since the given time, with
+// the timezone and anchor day their billing periods follow: the customer's
+// timezone, if set, takes precedence over the merchant's.
+func (q *Queries) ListBillableCustomers(ctx context.Context, since pgtype.Timestamptz) ([]*ListBillableCustomersRow, error) {
+	rows, err := q.db.Query(ctx, listBillableCustomers, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ListBillableCustomersRow
+	for rows.Next() {
+		var i ListBillableCustomersRow
+		if err := rows.Scan(
+			&i.MerchantID,
+			&i.CustomerID,
+			&i.Timezone,
+			&i.AnchorDay,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listInvoiceLines = `-- name: ListInvoiceLines :many
+SELECT id, invoice_id, position, kind, description, sku_id, quantity, unit_price, amount, coupon_redemption_id, tax_rate_id FROM invoice_lines
+WHERE invoice_id = $1
+ORDER BY position
+`
+
+func (q *Queries) ListInvoiceLines(ctx context.Context, invoiceID pgtype.UUID) ([]*InvoiceLine, error) {
+	rows, err := q.db.Query(ctx, listInvoiceLines, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*InvoiceLine
+	for rows.Next() {
+		var i InvoiceLine
+		if err := rows.Scan(
+			&i.ID,
+			&i.InvoiceID,
+			&i.Position,
+			&i.Kind,
+			&i.Description,
+			&i.SkuID,
+			&i.Quantity,
+			&i.UnitPrice,
+			&i.Amount,
+			&i.CouponRedemptionID,
+			&i.TaxRateID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listInvoicesByMerchantID = `-- name: ListInvoicesByMerchantID :many
+SELECT id, merchant_id, customer_id, status, period_start, period_end, subtotal, discount_total, total, finalized_at, created_at, tax_total, number, due_at, payment_provider, payment_id, paid_at FROM invoices
+WHERE merchant_id = $1
+ORDER BY period_start DESC, created_at DESC
+`
+
+func (q *Queries) ListInvoicesByMerchantID(ctx context.Context, merchantID pgtype.UUID) ([]*Invoice, error) {
+	rows, err := q.db.Query(ctx, listInvoicesByMerchantID, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*Invoice
+	for rows.Next() {
+		var i Invoice
+		if err := rows.Scan(
+			&i.ID,
+			&i.MerchantID,
+			&i.CustomerID,
+			&i.Status,
+			&i.PeriodStart,
+			&i.PeriodEnd,
+			&i.Subtotal,
+			&i.DiscountTotal,
+			&i.Total,
+			&i.FinalizedAt,
+			&i.CreatedAt,
+			&i.TaxTotal,
+			&i.Number,
+			&i.DueAt,
+			&i.PaymentProvider,
+			&i.PaymentID,
+			&i.PaidAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setInvoiceNumber = `-- name: SetInvoiceNumber :one
+UPDATE invoices
+SET number = $2, due_at = $3
+WHERE id = $1
+RETURNING id, merchant_id, customer_id, status, period_start, period_end, subtotal, discount_total, total, finalized_at, created_at, tax_total, number, due_at, payment_provider, payment_id, paid_at
+`
+
+type SetInvoiceNumberParams struct {
+	ID     pgtype.UUID
+	Number pgtype.Text
+	DueAt  pgtype.Timestamptz
+}
+
+func (q *Queries) SetInvoiceNumber(ctx context.Context, arg SetInvoiceNumberParams) (*Invoice, error) {
+	row := q.db.QueryRow(ctx, setInvoiceNumber, arg.ID, arg.Number, arg.DueAt)
+	var i Invoice
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.CustomerID,
+		&i.Status,
+		&i.PeriodStart,
+		&i.PeriodEnd,
+		&i.Subtotal,
+		&i.DiscountTotal,
+		&i.Total,
+		&i.FinalizedAt,
+		&i.CreatedAt,
+		&i.TaxTotal,
+		&i.Number,
+		&i.DueAt,
+		&i.PaymentProvider,
+		&i.PaymentID,
+		&i.PaidAt,
+	)
+	return &i, err
+}
+
+const setInvoicePayment = `-- name: SetInvoicePayment :one
+UPDATE invoices
+SET payment_provider = $2, payment_id = $3
+WHERE id = $1 AND payment_id IS NULL
+RETURNING id, merchant_id, customer_id, status, period_start, period_end, subtotal, discount_total, total, finalized_at, created_at, tax_total, number, due_at, payment_provider, payment_id, paid_at
+`
+
+type SetInvoicePaymentParams struct {
+	ID              pgtype.UUID
+	PaymentProvider pgtype.Text
+	PaymentID       pgtype.Text
+}
+
+func (q *Queries) SetInvoicePayment(ctx context.Context, arg SetInvoicePaymentParams) (*Invoice, error) {
+	row := q.db.QueryRow(ctx, setInvoicePayment, arg.ID, arg.PaymentProvider, arg.PaymentID)
+	var i Invoice
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.CustomerID,
+		&i.Status,
+		&i.PeriodStart,
+		&i.PeriodEnd,
+		&i.Subtotal,
+		&i.DiscountTotal,
+		&i.Total,
+		&i.FinalizedAt,
+		&i.CreatedAt,
+		&i.TaxTotal,
+		&i.Number,
+		&i.DueAt,
+		&i.PaymentProvider,
+		&i.PaymentID,
+		&i.PaidAt,
+	)
+	return &i, err
+}
+
+const updateInvoicePaymentStatus = `-- name: UpdateInvoicePaymentStatus :one
+UPDATE invoices
+SET status = $1,
+    paid_at = CASE WHEN $1 = 'paid' THEN now() ELSE paid_at END
+WHERE id = $2
+  AND status = ANY(CASE $1::text
+      WHEN 'paid' THEN ARRAY['finalized', 'payment_failed', 'uncollectible']
+      WHEN 'payment_failed' THEN ARRAY['finalized', 'payment_failed']
+      WHEN 'refunded' THEN ARRAY['paid']
+      WHEN 'uncollectible' THEN ARRAY['finalized', 'payment_failed']
+  END)
+RETURNING id, merchant_id, customer_id, status, period_start, period_end, subtotal, discount_total, total, finalized_at, created_at, tax_total, number, due_at, payment_provider, payment_id, paid_at
+`
+
+type UpdateInvoicePaymentStatusParams struct {
+	Status string
+	ID     pgtype.UUID
+}
+
+// Moves the invoice into a payment status, only from the statuses that lead
+// to it, so that late, duplicate or out of order provider events match no
+// row.
+func (q *Queries) UpdateInvoicePaymentStatus(ctx context.Context, arg UpdateInvoicePaymentStatusParams) (*Invoice, error) {
+	row := q.db.QueryRow(ctx, updateInvoicePaymentStatus, arg.Status, arg.ID)
+	var i Invoice
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.CustomerID,
+		&i.Status,
+		&i.PeriodStart,
+		&i.PeriodEnd,
+		&i.Subtotal,
+		&i.DiscountTotal,
+		&i.Total,
+		&i.FinalizedAt,
+		&i.CreatedAt,
+		&i.TaxTotal,
+		&i.Number,
+		&i.DueAt,
+		&i.PaymentProvider,
+		&i.PaymentID,
+		&i.PaidAt,
+	)
+	return &i, err
+}
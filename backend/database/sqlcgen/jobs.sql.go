@@ -0,0 +1,204 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: jobs.sql
+
+package sqlcgen
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimJob = `-- name: ClaimJob :one
+UPDATE jobs
+SET attempts = attempts + 1, locked_until = now() + $1::interval
+WHERE id = (
+    SELECT j.id FROM jobs j
+    WHERE j.completed_at IS NULL
+      AND j.failed_at IS NULL
+      AND j.run_at <= now()
+      AND (j.locked_until IS NULL OR j.locked_until < now())
+      AND j.kind = ANY($2::text[])
+    ORDER BY j.run_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, kind, args, unique_key, run_at, attempts, max_attempts, last_error, locked_until, completed_at, failed_at, created_at
+`
+
+type ClaimJobParams struct {
+	Lease pgtype.Interval
+	Kinds []string
+}
+
+// Leases the next due job: it is handed out again if the lease expires, e.g.
+// because the worker running it died.
+func (q *Queries) ClaimJob(ctx context.Context, arg ClaimJobParams) (*Job, error) {
+	row := q.db.QueryRow(ctx, claimJob, arg.Lease, arg.Kinds)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.Kind,
+		&i.Args,
+		&i.UniqueKey,
+		&i.RunAt,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.LastError,
+		&i.LockedUntil,
+		&i.CompletedAt,
+		&i.FailedAt,
+		&i.CreatedAt,
+	)
+	return &i, err
+}
+
+const claimJobSchedule = `-- name: ClaimJobSchedule :one
+UPDATE job_schedules
+SET next_run_at = now() + every, last_run_at = now()
+WHERE kind = (
+    SELECT s.kind FROM job_schedules s
+    WHERE s.next_run_at <= now() AND s.kind = ANY($1::text[])
+    ORDER BY s.next_run_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING kind, every, next_run_at, last_run_at, last_error
+`
+
+// Picks a due schedule among kinds and pushes its next run. Schedules do not
+// catch up on missed runs.
+func (q *Queries) ClaimJobSchedule(ctx context.Context, kinds []string) (*JobSchedule, error) {
+	row := q.db.QueryRow(ctx, claimJobSchedule, kinds)
+	var i JobSchedule
+	err := row.Scan(
+		&i.Kind,
+		&i.Every,
+		&i.NextRunAt,
+		&i.LastRunAt,
+		&i.LastError,
+	)
+	return &i, err
+}
+
+const completeJob = `-- name: CompleteJob :exec
+UPDATE jobs
+SET completed_at = now(), locked_until = NULL, last_error = NULL
+WHERE id = $1
+`
+
+func (q *Queries) CompleteJob(ctx context.Context, id pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, completeJob, id)
+	return err
+}
+
+const deleteCompletedJobs = `-- name: DeleteCompletedJobs :execrows
+DELETE FROM jobs
+WHERE completed_at < $1
+`
+
+func (q *Queries) DeleteCompletedJobs(ctx context.Context, completedAt pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteCompletedJobs, completedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const enqueueJob = `-- name: EnqueueJob :execrows
+INSERT INTO jobs (kind, args, unique_key, run_at, max_attempts)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (unique_key) DO NOTHING
+`
+
+type EnqueueJobParams struct {
+	Kind        string
+	Args        []byte
+	UniqueKey   pgtype.Text
+	RunAt       pgtype.Timestamptz
+	MaxAttempts int32
+}
+
+// A job with the unique key of a previous job is not enqueued again.
+func (q *Queries) EnqueueJob(ctx context.Context, arg EnqueueJobParams) (int64, error) {
+	result, err := q.db.Exec(ctx, enqueueJob,
+		arg.Kind,
+		arg.Args,
+		arg.UniqueKey,
+		arg.RunAt,
+		arg.MaxAttempts,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const failJob = `-- name: FailJob :exec
+UPDATE jobs
+SET failed_at = now(), locked_until = NULL, last_error = $2
+WHERE id = $1
+`
+
+type FailJobParams struct {
+	ID        pgtype.UUID
+	LastError pgtype.Text
+}
+
+func (q *Queries) FailJob(ctx context.Context, arg FailJobParams) error {
+	_, err := q.db.Exec(ctx, failJob, arg.ID, arg.LastError)
+	return err
+}
+
+const retryJob = `-- name: RetryJob :exec
+UPDATE jobs
+SET run_at = $2, locked_until = NULL, last_error = $3
+WHERE id = $1
+`
+
+type RetryJobParams struct {
+	ID        pgtype.UUID
+	RunAt     pgtype.Timestamptz
+	LastError pgtype.Text
+}
+
+func (q *Queries) RetryJob(ctx context.Context, arg RetryJobParams) error {
+	_, err := q.db.Exec(ctx, retryJob, arg.ID, arg.RunAt, arg.LastError)
+	return err
+}
+
+const setJobScheduleError = `-- name: SetJobScheduleError :exec
+UPDATE job_schedules
+SET last_error = $2
+WHERE kind = $1
+`
+
+type SetJobScheduleErrorParams struct {
+	Kind      string
+	LastError pgtype.Text
+}
+
+func (q *Queries) SetJobScheduleError(ctx context.Context, arg SetJobScheduleErrorParams) error {
+	_, err := q.db.Exec(ctx, setJobScheduleError, arg.Kind, arg.LastError)
+	return err
+}
+
+const upsertJobSchedule = `-- name: UpsertJobSchedule :exec
+INSERT INTO job_schedules (kind, every)
+VALUES ($1, $2)
+ON CONFLICT (kind) DO UPDATE
+SET every = EXCLUDED.every
+`
+
+type UpsertJobScheduleParams struct {
+	Kind  string
+	Every pgtype.Interval
+}
+
+// Registering a schedule again only updates its interval.
+func (q *Queries) UpsertJobSchedule(ctx context.Context, arg UpsertJobScheduleParams) error {
+	_, err := q.db.Exec(ctx, upsertJobSchedule, arg.Kind, arg.Every)
+	return err
+}
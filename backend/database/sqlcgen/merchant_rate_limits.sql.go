@@ -0,0 +1,67 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: merchant_rate_limits.sql
+
+package sqlcgen
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const deleteMerchantRateLimit = `-- name: DeleteMerchantRateLimit :exec
+DELETE FROM merchant_rate_limits
+WHERE merchant_id = $1
+`
+
+func (q *Queries) DeleteMerchantRateLimit(ctx context.Context, merchantID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteMerchantRateLimit, merchantID)
+	return err
+}
+
+const getMerchantRateLimit = `-- name: GetMerchantRateLimit :one
+SELECT merchant_id, rate_limit_per_second, rate_limit_burst, updated_at FROM merchant_rate_limits
+WHERE merchant_id = $1
+`
+
+func (q *Queries) GetMerchantRateLimit(ctx context.Context, merchantID pgtype.UUID) (*MerchantRateLimit, error) {
+	row := q.db.QueryRow(ctx, getMerchantRateLimit, merchantID)
+	var i MerchantRateLimit
+	err := row.Scan(
+		&i.MerchantID,
+		&i.RateLimitPerSecond,
+		&i.RateLimitBurst,
+		&i.UpdatedAt,
+	)
+	return &i, err
+}
+
+const putMerchantRateLimit = `-- name: PutMerchantRateLimit :one
+INSERT INTO merchant_rate_limits (merchant_id, rate_limit_per_second, rate_limit_burst)
+VALUES ($1, $2, $3)
+ON CONFLICT (merchant_id) DO UPDATE
+SET rate_limit_per_second = EXCLUDED.rate_limit_per_second,
+    rate_limit_burst = EXCLUDED.rate_limit_burst,
+    updated_at = now()
+RETURNING merchant_id, rate_limit_per_second, rate_limit_burst, updated_at
+`
+
+type PutMerchantRateLimitParams struct {
+	MerchantID         pgtype.UUID
+	RateLimitPerSecond int32
+	RateLimitBurst     int32
+}
+
+func (q *Queries) PutMerchantRateLimit(ctx context.Context, arg PutMerchantRateLimitParams) (*MerchantRateLimit, error) {
+	row := q.db.QueryRow(ctx, putMerchantRateLimit, arg.MerchantID, arg.RateLimitPerSecond, arg.RateLimitBurst)
+	var i MerchantRateLimit
+	err := row.Scan(
+		&i.MerchantID,
+		&i.RateLimitPerSecond,
+		&i.RateLimitBurst,
+		&i.UpdatedAt,
+	)
+	return &i, err
+}
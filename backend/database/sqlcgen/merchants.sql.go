@@ -44,15 +44,38 @@ func (q *Queries) CreateMerchant(ctx context.Context, arg CreateMerchantParams)
 	return &i, err
 }
 
+const createTestMerchant = `-- name: CreateTestMerchant :exec
+INSERT INTO merchants (email, password_hash, name, live_merchant_id)
+SELECT m.email, '', m.name, m.id
+FROM merchants m
+WHERE m.id = $1 AND m.live_merchant_id IS NULL
+ON CONFLICT (live_merchant_id) DO NOTHING
+`
+
+// Test merchants have no password, and only exist for live ones.
+func (q *Queries) CreateTestMerchant(ctx context.Context, id pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, createTestMerchant, id)
+	return err
+}
+
 const getMerchantByEmail = `-- name: GetMerchantByEmail :one
 SELECT id, email, password_hash, name, created_at, updated_at
 FROM merchants
-WHERE email = $1
+WHERE email = $1 AND live_merchant_id IS NULL
 `
 
-func (q *Queries) GetMerchantByEmail(ctx context.Context, email string) (*Merchant, error) {
+type GetMerchantByEmailRow struct {
+	ID           pgtype.UUID
+	Email        string
+	PasswordHash string
+	Name         string
+	CreatedAt    pgtype.Timestamptz
+	UpdatedAt    pgtype.Timestamptz
+}
+
+func (q *Queries) GetMerchantByEmail(ctx context.Context, email string) (*GetMerchantByEmailRow, error) {
 	row := q.db.QueryRow(ctx, getMerchantByEmail, email)
-	var i Merchant
+	var i GetMerchantByEmailRow
 	err := row.Scan(
 		&i.ID,
 		&i.Email,
@@ -90,3 +113,27 @@ func (q *Queries) GetMerchantByID(ctx context.Context, id pgtype.UUID) (*GetMerc
 	)
 	return &i, err
 }
+
+const getTestMerchantID = `-- name: GetTestMerchantID :one
+SELECT id FROM merchants
+WHERE live_merchant_id = $1
+`
+
+func (q *Queries) GetTestMerchantID(ctx context.Context, liveMerchantID pgtype.UUID) (pgtype.UUID, error) {
+	row := q.db.QueryRow(ctx, getTestMerchantID, liveMerchantID)
+	var id pgtype.UUID
+	err := row.Scan(&id)
+	return id, err
+}
+
+const isTestMerchant = `-- name: IsTestMerchant :one
+SELECT (live_merchant_id IS NOT NULL)::boolean FROM merchants
+WHERE id = $1
+`
+
+func (q *Queries) IsTestMerchant(ctx context.Context, id pgtype.UUID) (bool, error) {
+	row := q.db.QueryRow(ctx, isTestMerchant, id)
+	var column_1 bool
+	err := row.Scan(&column_1)
+	return column_1, err
+}
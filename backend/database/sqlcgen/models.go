@@ -9,16 +9,104 @@ import (
 )
 
 type ApiKey struct {
+	ID                   pgtype.UUID
+	MerchantID           pgtype.UUID
+	Name                 string
+	KeyPrefix            string
+	KeyHash              string
+	RevokedAt            pgtype.Timestamptz
+	CreatedAt            pgtype.Timestamptz
+	RateLimitPerSecond   pgtype.Int4
+	RateLimitBurst       pgtype.Int4
+	Scopes               []string
+	ExpiresAt            pgtype.Timestamptz
+	PreviousKeyHash      pgtype.Text
+	PreviousKeyExpiresAt pgtype.Timestamptz
+	RotatedAt            pgtype.Timestamptz
+	LastUsedAt           pgtype.Timestamptz
+	SigningSecret        pgtype.Text
+}
+
+type BillingSetting struct {
+	MerchantID           pgtype.UUID
+	Timezone             string
+	AnchorDay            int32
+	UpdatedAt            pgtype.Timestamptz
+	EventRetentionMonths pgtype.Int4
+}
+
+type Coupon struct {
+	ID              pgtype.UUID
+	MerchantID      pgtype.UUID
+	Name            string
+	PercentOff      pgtype.Float8
+	AmountOff       pgtype.Float8
+	Duration        string
+	DurationPeriods pgtype.Int4
+	SkuIds          []pgtype.UUID
+	RevokedAt       pgtype.Timestamptz
+	CreatedAt       pgtype.Timestamptz
+}
+
+type CouponRedemption struct {
+	ID             pgtype.UUID
+	MerchantID     pgtype.UUID
+	CouponID       pgtype.UUID
+	CustomerID     pgtype.UUID
+	PeriodsApplied int32
+	RevokedAt      pgtype.Timestamptz
+	CreatedAt      pgtype.Timestamptz
+}
+
+type Customer struct {
+	ID                pgtype.UUID
+	MerchantID        pgtype.UUID
+	Name              string
+	Email             pgtype.Text
+	AddressLine1      pgtype.Text
+	AddressLine2      pgtype.Text
+	City              pgtype.Text
+	PostalCode        pgtype.Text
+	Region            pgtype.Text
+	Country           pgtype.Text
+	TaxID             pgtype.Text
+	CreatedAt         pgtype.Timestamptz
+	UpdatedAt         pgtype.Timestamptz
+	PaymentCustomerID pgtype.Text
+	IngestSuspendedAt pgtype.Timestamptz
+	Timezone          pgtype.Text
+}
+
+type DunningSetting struct {
+	MerchantID    pgtype.UUID
+	RetryDays     []int32
+	SuspendIngest bool
+	UpdatedAt     pgtype.Timestamptz
+}
+
+type Event struct {
 	ID         pgtype.UUID
 	MerchantID pgtype.UUID
-	Name       string
-	KeyPrefix  string
-	KeyHash    string
-	RevokedAt  pgtype.Timestamptz
-	CreatedAt  pgtype.Timestamptz
+	CustomerID pgtype.UUID
+	SkuID      pgtype.UUID
+	Amount     float64
+	SentAt     pgtype.Timestamptz
 }
 
-type Event struct {
+type EventArchive struct {
+	MerchantID         pgtype.UUID
+	PeriodStart        pgtype.Timestamptz
+	PeriodEnd          pgtype.Timestamptz
+	BlobKey            string
+	EventCount         int64
+	DatabaseEventCount int64
+	SizeBytes          int64
+	Sha256             string
+	ArchivedAt         pgtype.Timestamptz
+	RehydratedUntil    pgtype.Timestamptz
+}
+
+type EventsDefault struct {
 	ID         pgtype.UUID
 	MerchantID pgtype.UUID
 	CustomerID pgtype.UUID
@@ -27,19 +115,154 @@ type Event struct {
 	SentAt     pgtype.Timestamptz
 }
 
+type Invoice struct {
+	ID              pgtype.UUID
+	MerchantID      pgtype.UUID
+	CustomerID      pgtype.UUID
+	Status          string
+	PeriodStart     pgtype.Timestamptz
+	PeriodEnd       pgtype.Timestamptz
+	Subtotal        float64
+	DiscountTotal   float64
+	Total           float64
+	FinalizedAt     pgtype.Timestamptz
+	CreatedAt       pgtype.Timestamptz
+	TaxTotal        float64
+	Number          pgtype.Text
+	DueAt           pgtype.Timestamptz
+	PaymentProvider pgtype.Text
+	PaymentID       pgtype.Text
+	PaidAt          pgtype.Timestamptz
+}
+
+type InvoiceDunning struct {
+	InvoiceID     pgtype.UUID
+	MerchantID    pgtype.UUID
+	RetryDays     []int32
+	Step          int32
+	StartedAt     pgtype.Timestamptz
+	NextAttemptAt pgtype.Timestamptz
+	LastError     pgtype.Text
+	Outcome       pgtype.Text
+	CompletedAt   pgtype.Timestamptz
+	ErrorCount    int32
+}
+
+type InvoiceLine struct {
+	ID                 pgtype.UUID
+	InvoiceID          pgtype.UUID
+	Position           int32
+	Kind               string
+	Description        string
+	SkuID              pgtype.UUID
+	Quantity           pgtype.Float8
+	UnitPrice          pgtype.Float8
+	Amount             float64
+	CouponRedemptionID pgtype.UUID
+	TaxRateID          pgtype.UUID
+}
+
+type InvoiceSetting struct {
+	MerchantID        pgtype.UUID
+	NumberPrefix      string
+	LastInvoiceNumber int32
+	PaymentTermsDays  int32
+	AccentColor       string
+	HeaderTemplate    string
+	FooterTemplate    string
+	Logo              []byte
+	LogoContentType   pgtype.Text
+	UpdatedAt         pgtype.Timestamptz
+}
+
+type Job struct {
+	ID          pgtype.UUID
+	Kind        string
+	Args        []byte
+	UniqueKey   pgtype.Text
+	RunAt       pgtype.Timestamptz
+	Attempts    int32
+	MaxAttempts int32
+	LastError   pgtype.Text
+	LockedUntil pgtype.Timestamptz
+	CompletedAt pgtype.Timestamptz
+	FailedAt    pgtype.Timestamptz
+	CreatedAt   pgtype.Timestamptz
+}
+
+type JobSchedule struct {
+	Kind      string
+	Every     pgtype.Interval
+	NextRunAt pgtype.Timestamptz
+	LastRunAt pgtype.Timestamptz
+	LastError pgtype.Text
+}
+
 type Merchant struct {
+	ID             pgtype.UUID
+	Email          string
+	PasswordHash   string
+	Name           string
+	CreatedAt      pgtype.Timestamptz
+	UpdatedAt      pgtype.Timestamptz
+	LiveMerchantID pgtype.UUID
+}
+
+type MerchantRateLimit struct {
+	MerchantID         pgtype.UUID
+	RateLimitPerSecond int32
+	RateLimitBurst     int32
+	UpdatedAt          pgtype.Timestamptz
+}
+
+type OutboxEvent struct {
+	ID            pgtype.UUID
+	MerchantID    pgtype.UUID
+	EventType     string
+	Payload       []byte
+	Attempts      int32
+	LastError     pgtype.Text
+	NextAttemptAt pgtype.Timestamptz
+	PublishedAt   pgtype.Timestamptz
+	CreatedAt     pgtype.Timestamptz
+}
+
+type PaymentWebhookEvent struct {
+	Provider      string
+	EventID       string
+	EventType     string
+	PaymentID     string
+	Payload       []byte
+	OccurredAt    pgtype.Timestamptz
+	ReceivedAt    pgtype.Timestamptz
+	Attempts      int32
+	LastError     pgtype.Text
+	NextAttemptAt pgtype.Timestamptz
+	ProcessedAt   pgtype.Timestamptz
+}
+
+type PriceOverride struct {
 	ID           pgtype.UUID
-	Email        string
-	PasswordHash string
-	Name         string
+	MerchantID   pgtype.UUID
+	SkuID        pgtype.UUID
+	CustomerID   pgtype.UUID
+	PricePerUnit float64
+	StartsAt     pgtype.Timestamptz
+	EndsAt       pgtype.Timestamptz
+	RevokedAt    pgtype.Timestamptz
 	CreatedAt    pgtype.Timestamptz
-	UpdatedAt    pgtype.Timestamptz
 }
 
 type SchemaMigration struct {
 	Version string
 }
 
+type SignatureNonce struct {
+	KeyID     pgtype.UUID
+	Nonce     string
+	ExpiresAt pgtype.Timestamptz
+}
+
 type Sku struct {
 	ID           pgtype.UUID
 	MerchantID   pgtype.UUID
@@ -49,3 +272,114 @@ type Sku struct {
 	RevokedAt    pgtype.Timestamptz
 	CreatedAt    pgtype.Timestamptz
 }
+
+type SpendCap struct {
+	MerchantID  pgtype.UUID
+	CustomerID  pgtype.UUID
+	AmountLimit float64
+	PerPeriod   bool
+	Spent       float64
+	PeriodStart pgtype.Timestamptz
+	CreatedAt   pgtype.Timestamptz
+	UpdatedAt   pgtype.Timestamptz
+}
+
+type TaxRate struct {
+	ID            pgtype.UUID
+	MerchantID    pgtype.UUID
+	Name          string
+	Country       string
+	Region        pgtype.Text
+	Percentage    float64
+	ReverseCharge bool
+	RevokedAt     pgtype.Timestamptz
+	CreatedAt     pgtype.Timestamptz
+}
+
+type UsageAlert struct {
+	ID         pgtype.UUID
+	MerchantID pgtype.UUID
+	CustomerID pgtype.UUID
+	SkuID      pgtype.UUID
+	Budget     float64
+	Thresholds []int32
+	CreatedAt  pgtype.Timestamptz
+	RevokedAt  pgtype.Timestamptz
+}
+
+type UsageAlertCrossing struct {
+	AlertID     pgtype.UUID
+	PeriodStart pgtype.Timestamptz
+	Threshold   int32
+	Usage       float64
+	CrossedAt   pgtype.Timestamptz
+}
+
+type UsageCounter struct {
+	MerchantID   pgtype.UUID
+	CustomerID   pgtype.UUID
+	SkuID        pgtype.UUID
+	PeriodStart  pgtype.Timestamptz
+	PeriodEnd    pgtype.Timestamptz
+	Quantity     float64
+	EventCount   int64
+	UpdatedAt    pgtype.Timestamptz
+	ReconciledAt pgtype.Timestamptz
+}
+
+type UsageRollupDirtyHour struct {
+	MerchantID pgtype.UUID
+	Hour       pgtype.Timestamptz
+}
+
+type UsageRollupsDaily struct {
+	MerchantID  pgtype.UUID
+	BucketStart pgtype.Timestamptz
+	CustomerID  pgtype.UUID
+	SkuID       pgtype.UUID
+	Quantity    float64
+	EventCount  int64
+}
+
+type UsageRollupsHourly struct {
+	MerchantID  pgtype.UUID
+	BucketStart pgtype.Timestamptz
+	CustomerID  pgtype.UUID
+	SkuID       pgtype.UUID
+	Quantity    float64
+	EventCount  int64
+}
+
+type WebhookDelivery struct {
+	ID                 pgtype.UUID
+	EndpointID         pgtype.UUID
+	MerchantID         pgtype.UUID
+	EventID            pgtype.UUID
+	EventType          string
+	Payload            []byte
+	Attempts           int32
+	LastResponseStatus pgtype.Int4
+	NextAttemptAt      pgtype.Timestamptz
+	DeliveredAt        pgtype.Timestamptz
+	FailedAt           pgtype.Timestamptz
+	CreatedAt          pgtype.Timestamptz
+}
+
+type WebhookDeliveryAttempt struct {
+	ID             pgtype.UUID
+	DeliveryID     pgtype.UUID
+	ResponseStatus pgtype.Int4
+	Error          pgtype.Text
+	DurationMs     int32
+	AttemptedAt    pgtype.Timestamptz
+}
+
+type WebhookEndpoint struct {
+	ID         pgtype.UUID
+	MerchantID pgtype.UUID
+	Url        string
+	Secret     string
+	EventTypes []string
+	CreatedAt  pgtype.Timestamptz
+	RevokedAt  pgtype.Timestamptz
+}
@@ -0,0 +1,109 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: outbox.sql
+
+package sqlcgen
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimOutboxEvents = `-- name: ClaimOutboxEvents :many
+SELECT id, merchant_id, event_type, payload, attempts, last_error, next_attempt_at, published_at, created_at FROM outbox_events
+WHERE published_at IS NULL AND next_attempt_at <= now()
+ORDER BY created_at
+LIMIT $1
+FOR UPDATE SKIP LOCKED
+`
+
+// Events are relayed in the order they were written. Claimed rows stay locked
+// until the relaying transaction ends.
+func (q *Queries) ClaimOutboxEvents(ctx context.Context, limit int32) ([]*OutboxEvent, error) {
+	rows, err := q.db.Query(ctx, claimOutboxEvents, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*OutboxEvent
+	for rows.Next() {
+		var i OutboxEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.MerchantID,
+			&i.EventType,
+			&i.Payload,
+			&i.Attempts,
+			&i.LastError,
+			&i.NextAttemptAt,
+			&i.PublishedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertOutboxEvent = `-- name: InsertOutboxEvent :one
+INSERT INTO outbox_events (merchant_id, event_type, payload)
+VALUES ($1, $2, $3)
+RETURNING id, merchant_id, event_type, payload, attempts, last_error, next_attempt_at, published_at, created_at
+`
+
+type InsertOutboxEventParams struct {
+	MerchantID pgtype.UUID
+	EventType  string
+	Payload    []byte
+}
+
+func (q *Queries) InsertOutboxEvent(ctx context.Context, arg InsertOutboxEventParams) (*OutboxEvent, error) {
+	row := q.db.QueryRow(ctx, insertOutboxEvent, arg.MerchantID, arg.EventType, arg.Payload)
+	var i OutboxEvent
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.EventType,
+		&i.Payload,
+		&i.Attempts,
+		&i.LastError,
+		&i.NextAttemptAt,
+		&i.PublishedAt,
+		&i.CreatedAt,
+	)
+	return &i, err
+}
+
+const markOutboxEventFailed = `-- name: MarkOutboxEventFailed :exec
+UPDATE outbox_events
+SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+WHERE id = $1
+`
+
+type MarkOutboxEventFailedParams struct {
+	ID            pgtype.UUID
+	LastError     pgtype.Text
+	NextAttemptAt pgtype.Timestamptz
+}
+
+func (q *Queries) MarkOutboxEventFailed(ctx context.Context, arg MarkOutboxEventFailedParams) error {
+	_, err := q.db.Exec(ctx, markOutboxEventFailed, arg.ID, arg.LastError, arg.NextAttemptAt)
+	return err
+}
+
+const markOutboxEventPublished = `-- name: MarkOutboxEventPublished :exec
+UPDATE outbox_events
+SET published_at = now(), attempts = attempts + 1, last_error = NULL
+WHERE id = $1
+`
+
+func (q *Queries) MarkOutboxEventPublished(ctx context.Context, id pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, markOutboxEventPublished, id)
+	return err
+}
@@ -0,0 +1,111 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: payment_webhook_events.sql
+
+package sqlcgen
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimPaymentWebhookEvent = `-- name: ClaimPaymentWebhookEvent :one
+SELECT provider, event_id, event_type, payment_id, payload, occurred_at, received_at, attempts, last_error, next_attempt_at, processed_at FROM payment_webhook_events
+WHERE processed_at IS NULL AND next_attempt_at <= now() AND attempts < $1::integer
+ORDER BY occurred_at
+LIMIT 1
+FOR UPDATE SKIP LOCKED
+`
+
+// Events are applied in the order they occurred at the provider. The row stays
+// locked until the claiming transaction ends.
+func (q *Queries) ClaimPaymentWebhookEvent(ctx context.Context, maxAttempts int32) (*PaymentWebhookEvent, error) {
+	row := q.db.QueryRow(ctx, claimPaymentWebhookEvent, maxAttempts)
+	var i PaymentWebhookEvent
+	err := row.Scan(
+		&i.Provider,
+		&i.EventID,
+		&i.EventType,
+		&i.PaymentID,
+		&i.Payload,
+		&i.OccurredAt,
+		&i.ReceivedAt,
+		&i.Attempts,
+		&i.LastError,
+		&i.NextAttemptAt,
+		&i.ProcessedAt,
+	)
+	return &i, err
+}
+
+const insertPaymentWebhookEvent = `-- name: InsertPaymentWebhookEvent :execrows
+INSERT INTO payment_webhook_events (provider, event_id, event_type, payment_id, payload, occurred_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (provider, event_id) DO NOTHING
+`
+
+type InsertPaymentWebhookEventParams struct {
+	Provider   string
+	EventID    string
+	EventType  string
+	PaymentID  string
+	Payload    []byte
+	OccurredAt pgtype.Timestamptz
+}
+
+// Providers deliver webhooks at least once: a redelivered event is a no-op.
+func (q *Queries) InsertPaymentWebhookEvent(ctx context.Context, arg InsertPaymentWebhookEventParams) (int64, error) {
+	result, err := q.db.Exec(ctx, insertPaymentWebhookEvent,
+		arg.Provider,
+		arg.EventID,
+		arg.EventType,
+		arg.PaymentID,
+		arg.Payload,
+		arg.OccurredAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const markPaymentWebhookEventFailed = `-- name: MarkPaymentWebhookEventFailed :exec
+UPDATE payment_webhook_events
+SET attempts = attempts + 1, last_error = $3, next_attempt_at = $4
+WHERE provider = $1 AND event_id = $2
+`
+
+type MarkPaymentWebhookEventFailedParams struct {
+	Provider      string
+	EventID       string
+	LastError     pgtype.Text
+	NextAttemptAt pgtype.Timestamptz
+}
+
+func (q *Queries) MarkPaymentWebhookEventFailed(ctx context.Context, arg MarkPaymentWebhookEventFailedParams) error {
+	_, err := q.db.Exec(ctx, markPaymentWebhookEventFailed,
+		arg.Provider,
+		arg.EventID,
+		arg.LastError,
+		arg.NextAttemptAt,
+	)
+	return err
+}
+
+const markPaymentWebhookEventProcessed = `-- name: MarkPaymentWebhookEventProcessed :exec
+UPDATE payment_webhook_events
+SET processed_at = now(), attempts = attempts + 1, last_error = NULL
+WHERE provider = $1 AND event_id = $2
+`
+
+type MarkPaymentWebhookEventProcessedParams struct {
+	Provider string
+	EventID  string
+}
+
+func (q *Queries) MarkPaymentWebhookEventProcessed(ctx context.Context, arg MarkPaymentWebhookEventProcessedParams) error {
+	_, err := q.db.Exec(ctx, markPaymentWebhookEventProcessed, arg.Provider, arg.EventID)
+	return err
+}
@@ -0,0 +1,109 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: price_overrides.sql
+
+package sqlcgen
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createPriceOverride = `-- name: CreatePriceOverride :one
+INSERT INTO price_overrides (merchant_id, sku_id, customer_id, price_per_unit, starts_at, ends_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, merchant_id, sku_id, customer_id, price_per_unit, starts_at, ends_at, revoked_at, created_at
+`
+
+type CreatePriceOverrideParams struct {
+	MerchantID   pgtype.UUID
+	SkuID        pgtype.UUID
+	CustomerID   pgtype.UUID
+	PricePerUnit float64
+	StartsAt     pgtype.Timestamptz
+	EndsAt       pgtype.Timestamptz
+}
+
+func (q *Queries) CreatePriceOverride(ctx context.Context, arg CreatePriceOverrideParams) (*PriceOverride, error) {
+	row := q.db.QueryRow(ctx, createPriceOverride,
+		arg.MerchantID,
+		arg.SkuID,
+		arg.CustomerID,
+		arg.PricePerUnit,
+		arg.StartsAt,
+		arg.EndsAt,
+	)
+	var i PriceOverride
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.SkuID,
+		&i.CustomerID,
+		&i.PricePerUnit,
+		&i.StartsAt,
+		&i.EndsAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return &i, err
+}
+
+const listPriceOverridesBySKU = `-- name: ListPriceOverridesBySKU :many
+SELECT id, merchant_id, sku_id, customer_id, price_per_unit, starts_at, ends_at, revoked_at, created_at FROM price_overrides
+WHERE sku_id = $1 AND merchant_id = $2
+ORDER BY created_at DESC
+`
+
+type ListPriceOverridesBySKUParams struct {
+	SkuID      pgtype.UUID
+	MerchantID pgtype.UUID
+}
+
+func (q *Queries) ListPriceOverridesBySKU(ctx context.Context, arg ListPriceOverridesBySKUParams) ([]*PriceOverride, error) {
+	rows, err := q.db.Query(ctx, listPriceOverridesBySKU, arg.SkuID, arg.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*PriceOverride
+	for rows.Next() {
+		var i PriceOverride
+		if err := rows.Scan(
+			&i.ID,
+			&i.MerchantID,
+			&i.SkuID,
+			&i.CustomerID,
+			&i.PricePerUnit,
+			&i.StartsAt,
+			&i.EndsAt,
+			&i.RevokedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokePriceOverride = `-- name: RevokePriceOverride :exec
+UPDATE price_overrides
+SET revoked_at = now()
+WHERE id = $1 AND sku_id = $2 AND merchant_id = $3 AND revoked_at IS NULL
+`
+
+type RevokePriceOverrideParams struct {
+	ID         pgtype.UUID
+	SkuID      pgtype.UUID
+	MerchantID pgtype.UUID
+}
+
+func (q *Queries) RevokePriceOverride(ctx context.Context, arg RevokePriceOverrideParams) error {
+	_, err := q.db.Exec(ctx, revokePriceOverride, arg.ID, arg.SkuID, arg.MerchantID)
+	return err
+}
@@ -0,0 +1,46 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: signature_nonces.sql
+
+package sqlcgen
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const deleteExpiredSignatureNonces = `-- name: DeleteExpiredSignatureNonces :execrows
+DELETE FROM signature_nonces
+WHERE expires_at < now()
+`
+
+func (q *Queries) DeleteExpiredSignatureNonces(ctx context.Context) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredSignatureNonces)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const useSignatureNonce = `-- name: UseSignatureNonce :execrows
+INSERT INTO signature_nonces (key_id, nonce, expires_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (key_id, nonce) DO NOTHING
+`
+
+type UseSignatureNonceParams struct {
+	KeyID     pgtype.UUID
+	Nonce     string
+	ExpiresAt pgtype.Timestamptz
+}
+
+// Records the nonce of the key, unless it was already used.
+func (q *Queries) UseSignatureNonce(ctx context.Context, arg UseSignatureNonceParams) (int64, error) {
+	result, err := q.db.Exec(ctx, useSignatureNonce, arg.KeyID, arg.Nonce, arg.ExpiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
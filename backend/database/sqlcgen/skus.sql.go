@@ -11,6 +11,24 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const countMerchantSKUs = `-- name: CountMerchantSKUs :one
+SELECT count(*) FROM skus
+WHERE merchant_id = $1 AND id = ANY($2::uuid[])
+`
+
+type CountMerchantSKUsParams struct {
+	MerchantID pgtype.UUID
+	Ids        []pgtype.UUID
+}
+
+// How many of the given SKUs belong to the merchant.
+func (q *Queries) CountMerchantSKUs(ctx context.Context, arg CountMerchantSKUsParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countMerchantSKUs, arg.MerchantID, arg.Ids)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createSKU = `-- name: CreateSKU :one
 INSERT INTO skus (merchant_id, name, unit, price_per_unit)
 VALUES ($1, $2, $4, $3)
@@ -44,6 +62,31 @@ func (q *Queries) CreateSKU(ctx context.Context, arg CreateSKUParams) (*Sku, err
 	return &i, err
 }
 
+const getSKU = `-- name: GetSKU :one
+SELECT id, merchant_id, name, unit, price_per_unit, revoked_at, created_at FROM skus
+WHERE id = $1 AND merchant_id = $2
+`
+
+type GetSKUParams struct {
+	ID         pgtype.UUID
+	MerchantID pgtype.UUID
+}
+
+func (q *Queries) GetSKU(ctx context.Context, arg GetSKUParams) (*Sku, error) {
+	row := q.db.QueryRow(ctx, getSKU, arg.ID, arg.MerchantID)
+	var i Sku
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.Name,
+		&i.Unit,
+		&i.PricePerUnit,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return &i, err
+}
+
 const listSKUsByMerchantID = `-- name: ListSKUsByMerchantID :many
 SELECT id, merchant_id, name, unit, price_per_unit, revoked_at, created_at FROM skus
 WHERE merchant_id = $1
@@ -78,10 +121,11 @@ func (q *Queries) ListSKUsByMerchantID(ctx context.Context, merchantID pgtype.UU
 	return items, nil
 }
 
-const revokeSKU = `-- name: RevokeSKU :exec
+const revokeSKU = `-- name: RevokeSKU :one
 UPDATE skus
 SET revoked_at = now()
 WHERE id = $1 AND merchant_id = $2 AND revoked_at IS NULL
+RETURNING id, merchant_id, name, unit, price_per_unit, revoked_at, created_at
 `
 
 type RevokeSKUParams struct {
@@ -89,7 +133,17 @@ type RevokeSKUParams struct {
 	MerchantID pgtype.UUID
 }
 
-func (q *Queries) RevokeSKU(ctx context.Context, arg RevokeSKUParams) error {
-	_, err := q.db.Exec(ctx, revokeSKU, arg.ID, arg.MerchantID)
-	return err
+func (q *Queries) RevokeSKU(ctx context.Context, arg RevokeSKUParams) (*Sku, error) {
+	row := q.db.QueryRow(ctx, revokeSKU, arg.ID, arg.MerchantID)
+	var i Sku
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.Name,
+		&i.Unit,
+		&i.PricePerUnit,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return &i, err
 }
@@ -0,0 +1,224 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: spend_caps.sql
+
+package sqlcgen
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const consumeSpendCap = `-- name: ConsumeSpendCap :execrows
+UPDATE spend_caps
+SET spent = CASE WHEN per_period AND period_start IS DISTINCT FROM $1 THEN 0 ELSE spent END + $2::double precision,
+    period_start = $1,
+    updated_at = now()
+WHERE merchant_id = $3
+  AND customer_id = $4
+  AND CASE WHEN per_period AND period_start IS DISTINCT FROM $1 THEN 0 ELSE spent END + $2::double precision <= amount_limit
+`
+
+type ConsumeSpendCapParams struct {
+	PeriodStart pgtype.Timestamptz
+	Cost        float64
+	MerchantID  pgtype.UUID
+	CustomerID  pgtype.UUID
+}
+
+// Adds cost to the spend, starting over when a per-period cap enters a new
+// period, unless that exceeds the limit. The row lock serializes concurrent
+// events of the customer.
+func (q *Queries) ConsumeSpendCap(ctx context.Context, arg ConsumeSpendCapParams) (int64, error) {
+	result, err := q.db.Exec(ctx, consumeSpendCap,
+		arg.PeriodStart,
+		arg.Cost,
+		arg.MerchantID,
+		arg.CustomerID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteSpendCap = `-- name: DeleteSpendCap :exec
+DELETE FROM spend_caps
+WHERE merchant_id = $1 AND customer_id = $2
+`
+
+type DeleteSpendCapParams struct {
+	MerchantID pgtype.UUID
+	CustomerID pgtype.UUID
+}
+
+func (q *Queries) DeleteSpendCap(ctx context.Context, arg DeleteSpendCapParams) error {
+	_, err := q.db.Exec(ctx, deleteSpendCap, arg.MerchantID, arg.CustomerID)
+	return err
+}
+
+const getSpendCap = `-- name: GetSpendCap :one
+SELECT merchant_id, customer_id, amount_limit, per_period, spent, period_start, created_at, updated_at FROM spend_caps
+WHERE merchant_id = $1 AND customer_id = $2
+`
+
+type GetSpendCapParams struct {
+	MerchantID pgtype.UUID
+	CustomerID pgtype.UUID
+}
+
+func (q *Queries) GetSpendCap(ctx context.Context, arg GetSpendCapParams) (*SpendCap, error) {
+	row := q.db.QueryRow(ctx, getSpendCap, arg.MerchantID, arg.CustomerID)
+	var i SpendCap
+	err := row.Scan(
+		&i.MerchantID,
+		&i.CustomerID,
+		&i.AmountLimit,
+		&i.PerPeriod,
+		&i.Spent,
+		&i.PeriodStart,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return &i, err
+}
+
+const getSpendCapForEvent = `-- name: GetSpendCapForEvent :one
+SELECT sc.merchant_id, sc.customer_id, sc.amount_limit, sc.per_period, sc.spent, sc.period_start, sc.created_at, sc.updated_at,
+    COALESCE(c.timezone, bs.timezone, 'UTC')::text AS timezone,
+    COALESCE(bs.anchor_day, 1)::integer AS anchor_day,
+    COALESCE((
+        SELECT po.price_per_unit
+        FROM price_overrides po
+        WHERE po.merchant_id = sc.merchant_id
+          AND po.sku_id = s.id
+          AND po.customer_id = sc.customer_id
+          AND po.revoked_at IS NULL
+          AND (po.starts_at IS NULL OR po.starts_at <= $1)
+          AND (po.ends_at IS NULL OR $1 < po.ends_at)
+        ORDER BY po.created_at DESC
+        LIMIT 1
+    ), s.price_per_unit)::double precision AS unit_price
+FROM spend_caps sc
+JOIN skus s ON s.id = $2 AND s.merchant_id = sc.merchant_id
+LEFT JOIN customers c ON c.merchant_id = sc.merchant_id AND c.id = sc.customer_id
+LEFT JOIN billing_settings bs ON bs.merchant_id = sc.merchant_id
+WHERE sc.merchant_id = $3 AND sc.customer_id = $4
+`
+
+type GetSpendCapForEventParams struct {
+	SentAt     pgtype.Timestamptz
+	SkuID      pgtype.UUID
+	MerchantID pgtype.UUID
+	CustomerID pgtype.UUID
+}
+
+type GetSpendCapForEventRow struct {
+	MerchantID  pgtype.UUID
+	CustomerID  pgtype.UUID
+	AmountLimit float64
+	PerPeriod   bool
+	Spent       float64
+	PeriodStart pgtype.Timestamptz
+	CreatedAt   pgtype.Timestamptz
+	UpdatedAt   pgtype.Timestamptz
+	Timezone    string
+	AnchorDay   int32
+	UnitPrice   float64
+}
+
+// The customer's cap, with what is needed to charge an event against it in
+// a single round trip: the billing period settings of the customer and the
+// unit price of the event's SKU, a price override taking precedence.
+func (q *Queries) GetSpendCapForEvent(ctx context.Context, arg GetSpendCapForEventParams) (*GetSpendCapForEventRow, error) {
+	row := q.db.QueryRow(ctx, getSpendCapForEvent,
+		arg.SentAt,
+		arg.SkuID,
+		arg.MerchantID,
+		arg.CustomerID,
+	)
+	var i GetSpendCapForEventRow
+	err := row.Scan(
+		&i.MerchantID,
+		&i.CustomerID,
+		&i.AmountLimit,
+		&i.PerPeriod,
+		&i.Spent,
+		&i.PeriodStart,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Timezone,
+		&i.AnchorDay,
+		&i.UnitPrice,
+	)
+	return &i, err
+}
+
+const putSpendCap = `-- name: PutSpendCap :one
+INSERT INTO spend_caps (merchant_id, customer_id, amount_limit, per_period)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (merchant_id, customer_id) DO UPDATE
+SET amount_limit = EXCLUDED.amount_limit,
+    per_period = EXCLUDED.per_period,
+    updated_at = now()
+RETURNING merchant_id, customer_id, amount_limit, per_period, spent, period_start, created_at, updated_at
+`
+
+type PutSpendCapParams struct {
+	MerchantID  pgtype.UUID
+	CustomerID  pgtype.UUID
+	AmountLimit float64
+	PerPeriod   bool
+}
+
+// Changing the limit keeps the spend recorded so far.
+func (q *Queries) PutSpendCap(ctx context.Context, arg PutSpendCapParams) (*SpendCap, error) {
+	row := q.db.QueryRow(ctx, putSpendCap,
+		arg.MerchantID,
+		arg.CustomerID,
+		arg.AmountLimit,
+		arg.PerPeriod,
+	)
+	var i SpendCap
+	err := row.Scan(
+		&i.MerchantID,
+		&i.CustomerID,
+		&i.AmountLimit,
+		&i.PerPeriod,
+		&i.Spent,
+		&i.PeriodStart,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return &i, err
+}
+
+const refundSpendCap = `-- name: RefundSpendCap :exec
+UPDATE spend_caps
+SET spent = GREATEST(spent - $1::double precision, 0),
+    updated_at = now()
+WHERE merchant_id = $2
+  AND customer_id = $3
+  AND (NOT per_period OR period_start = $4)
+`
+
+type RefundSpendCapParams struct {
+	Cost        float64
+	MerchantID  pgtype.UUID
+	CustomerID  pgtype.UUID
+	PeriodStart pgtype.Timestamptz
+}
+
+// Gives back cost charged in the given period, unless a per-period cap has
+// started over since.
+func (q *Queries) RefundSpendCap(ctx context.Context, arg RefundSpendCapParams) error {
+	_, err := q.db.Exec(ctx, refundSpendCap,
+		arg.Cost,
+		arg.MerchantID,
+		arg.CustomerID,
+		arg.PeriodStart,
+	)
+	return err
+}
@@ -0,0 +1,148 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: tax_rates.sql
+
+package sqlcgen
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createTaxRate = `-- name: CreateTaxRate :one
+INSERT INTO tax_rates (merchant_id, name, country, region, percentage, reverse_charge)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, merchant_id, name, country, region, percentage, reverse_charge, revoked_at, created_at
+`
+
+type CreateTaxRateParams struct {
+	MerchantID    pgtype.UUID
+	Name          string
+	Country       string
+	Region        pgtype.Text
+	Percentage    float64
+	ReverseCharge bool
+}
+
+func (q *Queries) CreateTaxRate(ctx context.Context, arg CreateTaxRateParams) (*TaxRate, error) {
+	row := q.db.QueryRow(ctx, createTaxRate,
+		arg.MerchantID,
+		arg.Name,
+		arg.Country,
+		arg.Region,
+		arg.Percentage,
+		arg.ReverseCharge,
+	)
+	var i TaxRate
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.Name,
+		&i.Country,
+		&i.Region,
+		&i.Percentage,
+		&i.ReverseCharge,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return &i, err
+}
+
+const listApplicableTaxRates = `-- name: ListApplicableTaxRates :many
+SELECT id, merchant_id, name, country, region, percentage, reverse_charge, revoked_at, created_at FROM tax_rates
+WHERE merchant_id = $1
+  AND country = $2
+  AND (region IS NULL OR region = $3)
+  AND revoked_at IS NULL
+ORDER BY region NULLS FIRST, created_at
+`
+
+type ListApplicableTaxRatesParams struct {
+	MerchantID pgtype.UUID
+	Country    string
+	Region     pgtype.Text
+}
+
+func (q *Queries) ListApplicableTaxRates(ctx context.Context, arg ListApplicableTaxRatesParams) ([]*TaxRate, error) {
+	rows, err := q.db.Query(ctx, listApplicableTaxRates, arg.MerchantID, arg.Country, arg.Region)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*TaxRate
+	for rows.Next() {
+		var i TaxRate
+		if err := rows.Scan(
+			&i.ID,
+			&i.MerchantID,
+			&i.Name,
+			&i.Country,
+			&i.Region,
+			&i.Percentage,
+			&i.ReverseCharge,
+			&i.RevokedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTaxRatesByMerchantID = `-- name: ListTaxRatesByMerchantID :many
+SELECT id, merchant_id, name, country, region, percentage, reverse_charge, revoked_at, created_at FROM tax_rates
+WHERE merchant_id = $1
+ORDER BY country, region NULLS FIRST, created_at
+`
+
+func (q *Queries) ListTaxRatesByMerchantID(ctx context.Context, merchantID pgtype.UUID) ([]*TaxRate, error) {
+	rows, err := q.db.Query(ctx, listTaxRatesByMerchantID, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*TaxRate
+	for rows.Next() {
+		var i TaxRate
+		if err := rows.Scan(
+			&i.ID,
+			&i.MerchantID,
+			&i.Name,
+			&i.Country,
+			&i.Region,
+			&i.Percentage,
+			&i.ReverseCharge,
+			&i.RevokedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeTaxRate = `-- name: RevokeTaxRate :exec
+UPDATE tax_rates
+SET revoked_at = now()
+WHERE id = $1 AND merchant_id = $2 AND revoked_at IS NULL
+`
+
+type RevokeTaxRateParams struct {
+	ID         pgtype.UUID
+	MerchantID pgtype.UUID
+}
+
+func (q *Queries) RevokeTaxRate(ctx context.Context, arg RevokeTaxRateParams) error {
+	_, err := q.db.Exec(ctx, revokeTaxRate, arg.ID, arg.MerchantID)
+	return err
+}
@@ -0,0 +1,161 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: test_data.sql
+
+package sqlcgen
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const deleteMerchantCouponRedemptions = `-- name: DeleteMerchantCouponRedemptions :exec
+DELETE FROM coupon_redemptions
+WHERE merchant_id = $1
+`
+
+func (q *Queries) DeleteMerchantCouponRedemptions(ctx context.Context, merchantID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteMerchantCouponRedemptions, merchantID)
+	return err
+}
+
+const deleteMerchantCustomers = `-- name: DeleteMerchantCustomers :execrows
+DELETE FROM customers
+WHERE merchant_id = $1
+`
+
+func (q *Queries) DeleteMerchantCustomers(ctx context.Context, merchantID pgtype.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteMerchantCustomers, merchantID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteMerchantDailyRollups = `-- name: DeleteMerchantDailyRollups :exec
+DELETE FROM usage_rollups_daily
+WHERE merchant_id = $1
+`
+
+func (q *Queries) DeleteMerchantDailyRollups(ctx context.Context, merchantID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteMerchantDailyRollups, merchantID)
+	return err
+}
+
+const deleteMerchantEventArchives = `-- name: DeleteMerchantEventArchives :many
+DELETE FROM event_archives
+WHERE merchant_id = $1
+RETURNING blob_key
+`
+
+// Returns the blob keys of the archives, to delete them from the blob store
+// once committed.
+func (q *Queries) DeleteMerchantEventArchives(ctx context.Context, merchantID pgtype.UUID) ([]string, error) {
+	rows, err := q.db.Query(ctx, deleteMerchantEventArchives, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var blob_key string
+		if err := rows.Scan(&blob_key); err != nil {
+			return nil, err
+		}
+		items = append(items, blob_key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteMerchantEvents = `-- name: DeleteMerchantEvents :execrows
+DELETE FROM events
+WHERE merchant_id = $1
+`
+
+func (q *Queries) DeleteMerchantEvents(ctx context.Context, merchantID pgtype.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteMerchantEvents, merchantID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteMerchantHourlyRollups = `-- name: DeleteMerchantHourlyRollups :exec
+DELETE FROM usage_rollups_hourly
+WHERE merchant_id = $1
+`
+
+func (q *Queries) DeleteMerchantHourlyRollups(ctx context.Context, merchantID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteMerchantHourlyRollups, merchantID)
+	return err
+}
+
+const deleteMerchantInvoices = `-- name: DeleteMerchantInvoices :exec
+DELETE FROM invoices
+WHERE merchant_id = $1
+`
+
+// Their lines and dunnings are deleted along.
+func (q *Queries) DeleteMerchantInvoices(ctx context.Context, merchantID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteMerchantInvoices, merchantID)
+	return err
+}
+
+const deleteMerchantPriceOverrides = `-- name: DeleteMerchantPriceOverrides :exec
+DELETE FROM price_overrides
+WHERE merchant_id = $1
+`
+
+func (q *Queries) DeleteMerchantPriceOverrides(ctx context.Context, merchantID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteMerchantPriceOverrides, merchantID)
+	return err
+}
+
+const deleteMerchantRollupDirtyHours = `-- name: DeleteMerchantRollupDirtyHours :exec
+DELETE FROM usage_rollup_dirty_hours
+WHERE merchant_id = $1
+`
+
+func (q *Queries) DeleteMerchantRollupDirtyHours(ctx context.Context, merchantID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteMerchantRollupDirtyHours, merchantID)
+	return err
+}
+
+const deleteMerchantSpendCaps = `-- name: DeleteMerchantSpendCaps :exec
+DELETE FROM spend_caps
+WHERE merchant_id = $1
+`
+
+func (q *Queries) DeleteMerchantSpendCaps(ctx context.Context, merchantID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteMerchantSpendCaps, merchantID)
+	return err
+}
+
+const deleteMerchantUsageAlertCrossings = `-- name: DeleteMerchantUsageAlertCrossings :exec
+
+DELETE FROM usage_alert_crossings
+WHERE alert_id IN (SELECT id FROM usage_alerts WHERE merchant_id = $1)
+`
+
+// Wiping the data of a test merchant. The queries are run in this order, so
+// that rows are deleted before those they reference. Configuration, such as
+// SKUs, settings and API keys, is kept.
+func (q *Queries) DeleteMerchantUsageAlertCrossings(ctx context.Context, merchantID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteMerchantUsageAlertCrossings, merchantID)
+	return err
+}
+
+const deleteMerchantUsageCounters = `-- name: DeleteMerchantUsageCounters :exec
+DELETE FROM usage_counters
+WHERE merchant_id = $1
+`
+
+func (q *Queries) DeleteMerchantUsageCounters(ctx context.Context, merchantID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteMerchantUsageCounters, merchantID)
+	return err
+}
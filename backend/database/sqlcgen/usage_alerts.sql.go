@@ -0,0 +1,306 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: usage_alerts.sql
+
+package sqlcgen
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createUsageAlert = `-- name: CreateUsageAlert :one
+INSERT INTO usage_alerts (merchant_id, customer_id, sku_id, budget, thresholds)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, merchant_id, customer_id, sku_id, budget, thresholds, created_at, revoked_at
+`
+
+type CreateUsageAlertParams struct {
+	MerchantID pgtype.UUID
+	CustomerID pgtype.UUID
+	SkuID      pgtype.UUID
+	Budget     float64
+	Thresholds []int32
+}
+
+func (q *Queries) CreateUsageAlert(ctx context.Context, arg CreateUsageAlertParams) (*UsageAlert, error) {
+	row := q.db.QueryRow(ctx, createUsageAlert,
+		arg.MerchantID,
+		arg.CustomerID,
+		arg.SkuID,
+		arg.Budget,
+		arg.Thresholds,
+	)
+	var i UsageAlert
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.CustomerID,
+		&i.SkuID,
+		&i.Budget,
+		&i.Thresholds,
+		&i.CreatedAt,
+		&i.RevokedAt,
+	)
+	return &i, err
+}
+
+const getCustomerSpend = `-- name: GetCustomerSpend :one
+SELECT COALESCE(SUM(uc.quantity * COALESCE(o.price_per_unit, s.price_per_unit)), 0)::double precision AS spend
+FROM usage_counters uc
+JOIN skus s ON s.id = uc.sku_id
+LEFT JOIN LATERAL (
+    SELECT po.price_per_unit
+    FROM price_overrides po
+    WHERE po.merchant_id = uc.merchant_id
+      AND po.sku_id = uc.sku_id
+      AND po.customer_id = uc.customer_id
+      AND po.revoked_at IS NULL
+      AND (po.starts_at IS NULL OR po.starts_at <= now())
+      AND (po.ends_at IS NULL OR now() < po.ends_at)
+    ORDER BY po.created_at DESC
+    LIMIT 1
+) o ON true
+WHERE uc.merchant_id = $1
+  AND uc.customer_id = $2
+  AND uc.period_start = $3
+`
+
+type GetCustomerSpendParams struct {
+	MerchantID  pgtype.UUID
+	CustomerID  pgtype.UUID
+	PeriodStart pgtype.Timestamptz
+}
+
+// The customer's spend over the period, at the current prices: the
+// customer's active price override on a SKU takes precedence over the SKU
+// list price.
+func (q *Queries) GetCustomerSpend(ctx context.Context, arg GetCustomerSpendParams) (float64, error) {
+	row := q.db.QueryRow(ctx, getCustomerSpend, arg.MerchantID, arg.CustomerID, arg.PeriodStart)
+	var spend float64
+	err := row.Scan(&spend)
+	return spend, err
+}
+
+const getSKUUsage = `-- name: GetSKUUsage :one
+SELECT COALESCE(SUM(quantity), 0)::double precision AS quantity
+FROM usage_counters
+WHERE merchant_id = $1
+  AND customer_id = $2
+  AND sku_id = $3
+  AND period_start = $4
+`
+
+type GetSKUUsageParams struct {
+	MerchantID  pgtype.UUID
+	CustomerID  pgtype.UUID
+	SkuID       pgtype.UUID
+	PeriodStart pgtype.Timestamptz
+}
+
+func (q *Queries) GetSKUUsage(ctx context.Context, arg GetSKUUsageParams) (float64, error) {
+	row := q.db.QueryRow(ctx, getSKUUsage,
+		arg.MerchantID,
+		arg.CustomerID,
+		arg.SkuID,
+		arg.PeriodStart,
+	)
+	var quantity float64
+	err := row.Scan(&quantity)
+	return quantity, err
+}
+
+const getUsageAlert = `-- name: GetUsageAlert :one
+SELECT id, merchant_id, customer_id, sku_id, budget, thresholds, created_at, revoked_at FROM usage_alerts
+WHERE id = $1 AND merchant_id = $2
+`
+
+type GetUsageAlertParams struct {
+	ID         pgtype.UUID
+	MerchantID pgtype.UUID
+}
+
+func (q *Queries) GetUsageAlert(ctx context.Context, arg GetUsageAlertParams) (*UsageAlert, error) {
+	row := q.db.QueryRow(ctx, getUsageAlert, arg.ID, arg.MerchantID)
+	var i UsageAlert
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.CustomerID,
+		&i.SkuID,
+		&i.Budget,
+		&i.Thresholds,
+		&i.CreatedAt,
+		&i.RevokedAt,
+	)
+	return &i, err
+}
+
+const listUsageAlertCrossings = `-- name: ListUsageAlertCrossings :many
+SELECT alert_id, period_start, threshold, usage, crossed_at FROM usage_alert_crossings
+WHERE alert_id = $1
+ORDER BY crossed_at DESC
+LIMIT 100
+`
+
+func (q *Queries) ListUsageAlertCrossings(ctx context.Context, alertID pgtype.UUID) ([]*UsageAlertCrossing, error) {
+	rows, err := q.db.Query(ctx, listUsageAlertCrossings, alertID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*UsageAlertCrossing
+	for rows.Next() {
+		var i UsageAlertCrossing
+		if err := rows.Scan(
+			&i.AlertID,
+			&i.PeriodStart,
+			&i.Threshold,
+			&i.Usage,
+			&i.CrossedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsageAlertsByMerchantID = `-- name: ListUsageAlertsByMerchantID :many
+SELECT id, merchant_id, customer_id, sku_id, budget, thresholds, created_at, revoked_at FROM usage_alerts
+WHERE merchant_id = $1
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListUsageAlertsByMerchantID(ctx context.Context, merchantID pgtype.UUID) ([]*UsageAlert, error) {
+	rows, err := q.db.Query(ctx, listUsageAlertsByMerchantID, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*UsageAlert
+	for rows.Next() {
+		var i UsageAlert
+		if err := rows.Scan(
+			&i.ID,
+			&i.MerchantID,
+			&i.CustomerID,
+			&i.SkuID,
+			&i.Budget,
+			&i.Thresholds,
+			&i.CreatedAt,
+			&i.RevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsageAlertsToEvaluate = `-- name: ListUsageAlertsToEvaluate :many
+SELECT a.id, a.merchant_id, a.customer_id, a.sku_id, a.budget, a.thresholds, a.created_at, a.revoked_at,
+    COALESCE(c.timezone, bs.timezone, 'UTC')::text AS timezone,
+    COALESCE(bs.anchor_day, 1)::integer AS anchor_day
+FROM usage_alerts a
+LEFT JOIN customers c ON c.merchant_id = a.merchant_id AND c.id = a.customer_id
+LEFT JOIN billing_settings bs ON bs.merchant_id = a.merchant_id
+WHERE a.revoked_at IS NULL
+`
+
+type ListUsageAlertsToEvaluateRow struct {
+	ID         pgtype.UUID
+	MerchantID pgtype.UUID
+	CustomerID pgtype.UUID
+	SkuID      pgtype.UUID
+	Budget     float64
+	Thresholds []int32
+	CreatedAt  pgtype.Timestamptz
+	RevokedAt  pgtype.Timestamptz
+	Timezone   string
+	AnchorDay  int32
+}
+
+// Active alerts, with the timezone and anchor day of their customer's
+// billing periods.
+func (q *Queries) ListUsageAlertsToEvaluate(ctx context.Context) ([]*ListUsageAlertsToEvaluateRow, error) {
+	rows, err := q.db.Query(ctx, listUsageAlertsToEvaluate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ListUsageAlertsToEvaluateRow
+	for rows.Next() {
+		var i ListUsageAlertsToEvaluateRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.MerchantID,
+			&i.CustomerID,
+			&i.SkuID,
+			&i.Budget,
+			&i.Thresholds,
+			&i.CreatedAt,
+			&i.RevokedAt,
+			&i.Timezone,
+			&i.AnchorDay,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const recordUsageAlertCrossing = `-- name: RecordUsageAlertCrossing :execrows
+INSERT INTO usage_alert_crossings (alert_id, period_start, threshold, usage)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT DO NOTHING
+`
+
+type RecordUsageAlertCrossingParams struct {
+	AlertID     pgtype.UUID
+	PeriodStart pgtype.Timestamptz
+	Threshold   int32
+	Usage       float64
+}
+
+// Records a crossing once per alert, period and threshold.
+func (q *Queries) RecordUsageAlertCrossing(ctx context.Context, arg RecordUsageAlertCrossingParams) (int64, error) {
+	result, err := q.db.Exec(ctx, recordUsageAlertCrossing,
+		arg.AlertID,
+		arg.PeriodStart,
+		arg.Threshold,
+		arg.Usage,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const revokeUsageAlert = `-- name: RevokeUsageAlert :exec
+UPDATE usage_alerts
+SET revoked_at = now()
+WHERE id = $1 AND merchant_id = $2 AND revoked_at IS NULL
+`
+
+type RevokeUsageAlertParams struct {
+	ID         pgtype.UUID
+	MerchantID pgtype.UUID
+}
+
+func (q *Queries) RevokeUsageAlert(ctx context.Context, arg RevokeUsageAlertParams) error {
+	_, err := q.db.Exec(ctx, revokeUsageAlert, arg.ID, arg.MerchantID)
+	return err
+}
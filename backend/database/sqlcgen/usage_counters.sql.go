@@ -0,0 +1,259 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: usage_counters.sql
+
+package sqlcgen
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const aggregateUsage = `-- name: AggregateUsage :one
+SELECT COALESCE(SUM(amount), 0)::double precision AS quantity, COUNT(*) AS event_count
+FROM events
+WHERE merchant_id = $1
+  AND customer_id = $2
+  AND sku_id = $3
+  AND sent_at >= $4
+  AND sent_at < $5
+`
+
+type AggregateUsageParams struct {
+	MerchantID  pgtype.UUID
+	CustomerID  pgtype.UUID
+	SkuID       pgtype.UUID
+	PeriodStart pgtype.Timestamptz
+	PeriodEnd   pgtype.Timestamptz
+}
+
+type AggregateUsageRow struct {
+	Quantity   float64
+	EventCount int64
+}
+
+func (q *Queries) AggregateUsage(ctx context.Context, arg AggregateUsageParams) (*AggregateUsageRow, error) {
+	row := q.db.QueryRow(ctx, aggregateUsage,
+		arg.MerchantID,
+		arg.CustomerID,
+		arg.SkuID,
+		arg.PeriodStart,
+		arg.PeriodEnd,
+	)
+	var i AggregateUsageRow
+	err := row.Scan(&i.Quantity, &i.EventCount)
+	return &i, err
+}
+
+const getBillingPeriodSettings = `-- name: GetBillingPeriodSettings :one
+SELECT COALESCE(c.timezone, bs.timezone, 'UTC')::text AS timezone,
+    COALESCE(bs.anchor_day, 1)::integer AS anchor_day
+FROM merchants m
+LEFT JOIN customers c ON c.merchant_id = m.id AND c.id = $1
+LEFT JOIN billing_settings bs ON bs.merchant_id = m.id
+WHERE m.id = $2
+`
+
+type GetBillingPeriodSettingsParams struct {
+	CustomerID pgtype.UUID
+	MerchantID pgtype.UUID
+}
+
+type GetBillingPeriodSettingsRow struct {
+	Timezone  string
+	AnchorDay int32
+}
+
+// The timezone and anchor day of the customer's billing periods: the
+// customer's timezone, if set, takes precedence over the merchant's.
+func (q *Queries) GetBillingPeriodSettings(ctx context.Context, arg GetBillingPeriodSettingsParams) (*GetBillingPeriodSettingsRow, error) {
+	row := q.db.QueryRow(ctx, getBillingPeriodSettings, arg.CustomerID, arg.MerchantID)
+	var i GetBillingPeriodSettingsRow
+	err := row.Scan(&i.Timezone, &i.AnchorDay)
+	return &i, err
+}
+
+const incrementUsageCounter = `-- name: IncrementUsageCounter :exec
+INSERT INTO usage_counters (merchant_id, customer_id, sku_id, period_start, period_end, quantity, event_count)
+VALUES ($1, $2, $3, $4, $5, $6, 1)
+ON CONFLICT (merchant_id, customer_id, period_start, sku_id) DO UPDATE
+SET quantity = usage_counters.quantity + EXCLUDED.quantity,
+    event_count = usage_counters.event_count + 1,
+    updated_at = now()
+`
+
+type IncrementUsageCounterParams struct {
+	MerchantID  pgtype.UUID
+	CustomerID  pgtype.UUID
+	SkuID       pgtype.UUID
+	PeriodStart pgtype.Timestamptz
+	PeriodEnd   pgtype.Timestamptz
+	Quantity    float64
+}
+
+func (q *Queries) IncrementUsageCounter(ctx context.Context, arg IncrementUsageCounterParams) error {
+	_, err := q.db.Exec(ctx, incrementUsageCounter,
+		arg.MerchantID,
+		arg.CustomerID,
+		arg.SkuID,
+		arg.PeriodStart,
+		arg.PeriodEnd,
+		arg.Quantity,
+	)
+	return err
+}
+
+const listUsageCounters = `-- name: ListUsageCounters :many
+SELECT uc.sku_id, s.name, uc.quantity, uc.event_count, uc.updated_at
+FROM usage_counters uc
+JOIN skus s ON s.id = uc.sku_id
+WHERE uc.merchant_id = $1
+  AND uc.customer_id = $2
+  AND uc.period_start = $3
+ORDER BY s.name
+`
+
+type ListUsageCountersParams struct {
+	MerchantID  pgtype.UUID
+	CustomerID  pgtype.UUID
+	PeriodStart pgtype.Timestamptz
+}
+
+type ListUsageCountersRow struct {
+	SkuID      pgtype.UUID
+	Name       string
+	Quantity   float64
+	EventCount int64
+	UpdatedAt  pgtype.Timestamptz
+}
+
+func (q *Queries) ListUsageCounters(ctx context.Context, arg ListUsageCountersParams) ([]*ListUsageCountersRow, error) {
+	rows, err := q.db.Query(ctx, listUsageCounters, arg.MerchantID, arg.CustomerID, arg.PeriodStart)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ListUsageCountersRow
+	for rows.Next() {
+		var i ListUsageCountersRow
+		if err := rows.Scan(
+			&i.SkuID,
+			&i.Name,
+			&i.Quantity,
+			&i.EventCount,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsageCountersToReconcile = `-- name: ListUsageCountersToReconcile :many
+SELECT merchant_id, customer_id, sku_id, period_start
+FROM usage_counters
+WHERE period_end > now() AND period_start <= now()
+`
+
+type ListUsageCountersToReconcileRow struct {
+	MerchantID  pgtype.UUID
+	CustomerID  pgtype.UUID
+	SkuID       pgtype.UUID
+	PeriodStart pgtype.Timestamptz
+}
+
+// The counters of current periods. Those of closed periods are left as is:
+// their events may be archived and dropped, and summing what remains would
+// undercount them.
+func (q *Queries) ListUsageCountersToReconcile(ctx context.Context) ([]*ListUsageCountersToReconcileRow, error) {
+	rows, err := q.db.Query(ctx, listUsageCountersToReconcile)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ListUsageCountersToReconcileRow
+	for rows.Next() {
+		var i ListUsageCountersToReconcileRow
+		if err := rows.Scan(
+			&i.MerchantID,
+			&i.CustomerID,
+			&i.SkuID,
+			&i.PeriodStart,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockUsageCounter = `-- name: LockUsageCounter :one
+SELECT merchant_id, customer_id, sku_id, period_start, period_end, quantity, event_count, updated_at, reconciled_at FROM usage_counters
+WHERE merchant_id = $1 AND customer_id = $2 AND period_start = $3 AND sku_id = $4
+FOR UPDATE
+`
+
+type LockUsageCounterParams struct {
+	MerchantID  pgtype.UUID
+	CustomerID  pgtype.UUID
+	PeriodStart pgtype.Timestamptz
+	SkuID       pgtype.UUID
+}
+
+func (q *Queries) LockUsageCounter(ctx context.Context, arg LockUsageCounterParams) (*UsageCounter, error) {
+	row := q.db.QueryRow(ctx, lockUsageCounter,
+		arg.MerchantID,
+		arg.CustomerID,
+		arg.PeriodStart,
+		arg.SkuID,
+	)
+	var i UsageCounter
+	err := row.Scan(
+		&i.MerchantID,
+		&i.CustomerID,
+		&i.SkuID,
+		&i.PeriodStart,
+		&i.PeriodEnd,
+		&i.Quantity,
+		&i.EventCount,
+		&i.UpdatedAt,
+		&i.ReconciledAt,
+	)
+	return &i, err
+}
+
+const setUsageCounter = `-- name: SetUsageCounter :exec
+UPDATE usage_counters
+SET quantity = $5, event_count = $6, reconciled_at = now()
+WHERE merchant_id = $1 AND customer_id = $2 AND period_start = $3 AND sku_id = $4
+`
+
+type SetUsageCounterParams struct {
+	MerchantID  pgtype.UUID
+	CustomerID  pgtype.UUID
+	PeriodStart pgtype.Timestamptz
+	SkuID       pgtype.UUID
+	Quantity    float64
+	EventCount  int64
+}
+
+func (q *Queries) SetUsageCounter(ctx context.Context, arg SetUsageCounterParams) error {
+	_, err := q.db.Exec(ctx, setUsageCounter,
+		arg.MerchantID,
+		arg.CustomerID,
+		arg.PeriodStart,
+		arg.SkuID,
+		arg.Quantity,
+		arg.EventCount,
+	)
+	return err
+}
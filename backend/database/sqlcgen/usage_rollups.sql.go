@@ -0,0 +1,304 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: usage_rollups.sql
+
+package sqlcgen
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimRollupDirtyHours = `-- name: ClaimRollupDirtyHours :many
+DELETE FROM usage_rollup_dirty_hours
+WHERE (merchant_id, hour) IN (
+    SELECT d.merchant_id, d.hour FROM usage_rollup_dirty_hours d
+    ORDER BY d.hour
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING merchant_id, hour
+`
+
+func (q *Queries) ClaimRollupDirtyHours(ctx context.Context, batchSize int32) ([]*UsageRollupDirtyHour, error) {
+	rows, err := q.db.Query(ctx, claimRollupDirtyHours, batchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*UsageRollupDirtyHour
+	for rows.Next() {
+		var i UsageRollupDirtyHour
+		if err := rows.Scan(&i.MerchantID, &i.Hour); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteDailyRollups = `-- name: DeleteDailyRollups :exec
+DELETE FROM usage_rollups_daily
+WHERE merchant_id = $1 AND bucket_start = $2
+`
+
+type DeleteDailyRollupsParams struct {
+	MerchantID  pgtype.UUID
+	BucketStart pgtype.Timestamptz
+}
+
+func (q *Queries) DeleteDailyRollups(ctx context.Context, arg DeleteDailyRollupsParams) error {
+	_, err := q.db.Exec(ctx, deleteDailyRollups, arg.MerchantID, arg.BucketStart)
+	return err
+}
+
+const deleteHourlyRollups = `-- name: DeleteHourlyRollups :exec
+DELETE FROM usage_rollups_hourly
+WHERE merchant_id = $1 AND bucket_start = $2
+`
+
+type DeleteHourlyRollupsParams struct {
+	MerchantID  pgtype.UUID
+	BucketStart pgtype.Timestamptz
+}
+
+func (q *Queries) DeleteHourlyRollups(ctx context.Context, arg DeleteHourlyRollupsParams) error {
+	_, err := q.db.Exec(ctx, deleteHourlyRollups, arg.MerchantID, arg.BucketStart)
+	return err
+}
+
+const insertDailyRollups = `-- name: InsertDailyRollups :exec
+INSERT INTO usage_rollups_daily (merchant_id, bucket_start, customer_id, sku_id, quantity, event_count)
+SELECT h.merchant_id, $1::timestamptz, h.customer_id, h.sku_id, SUM(h.quantity), SUM(h.event_count)
+FROM usage_rollups_hourly h
+WHERE h.merchant_id = $2
+  AND h.bucket_start >= $1::timestamptz
+  AND h.bucket_start < $1::timestamptz + interval '1 day'
+GROUP BY h.merchant_id, h.customer_id, h.sku_id
+`
+
+type InsertDailyRollupsParams struct {
+	BucketStart pgtype.Timestamptz
+	MerchantID  pgtype.UUID
+}
+
+func (q *Queries) InsertDailyRollups(ctx context.Context, arg InsertDailyRollupsParams) error {
+	_, err := q.db.Exec(ctx, insertDailyRollups, arg.BucketStart, arg.MerchantID)
+	return err
+}
+
+const insertHourlyRollups = `-- name: InsertHourlyRollups :exec
+INSERT INTO usage_rollups_hourly (merchant_id, bucket_start, customer_id, sku_id, quantity, event_count)
+SELECT e.merchant_id, $1::timestamptz, e.customer_id, e.sku_id, SUM(e.amount), COUNT(*)
+FROM events e
+WHERE e.merchant_id = $2
+  AND e.sent_at >= $1::timestamptz
+  AND e.sent_at < $1::timestamptz + interval '1 hour'
+GROUP BY e.merchant_id, e.customer_id, e.sku_id
+`
+
+type InsertHourlyRollupsParams struct {
+	BucketStart pgtype.Timestamptz
+	MerchantID  pgtype.UUID
+}
+
+func (q *Queries) InsertHourlyRollups(ctx context.Context, arg InsertHourlyRollupsParams) error {
+	_, err := q.db.Exec(ctx, insertHourlyRollups, arg.BucketStart, arg.MerchantID)
+	return err
+}
+
+const listDailyUsage = `-- name: ListDailyUsage :many
+SELECT bucket_start, sku_id, SUM(quantity)::double precision AS quantity, SUM(event_count)::bigint AS event_count
+FROM usage_rollups_daily
+WHERE merchant_id = $1
+  AND bucket_start >= $2
+  AND bucket_start < $3
+  AND ($4::uuid IS NULL OR customer_id = $4)
+  AND ($5::uuid IS NULL OR sku_id = $5)
+GROUP BY bucket_start, sku_id
+ORDER BY bucket_start, sku_id
+`
+
+type ListDailyUsageParams struct {
+	MerchantID pgtype.UUID
+	FromTime   pgtype.Timestamptz
+	ToTime     pgtype.Timestamptz
+	CustomerID pgtype.UUID
+	SkuID      pgtype.UUID
+}
+
+type ListDailyUsageRow struct {
+	BucketStart pgtype.Timestamptz
+	SkuID       pgtype.UUID
+	Quantity    float64
+	EventCount  int64
+}
+
+func (q *Queries) ListDailyUsage(ctx context.Context, arg ListDailyUsageParams) ([]*ListDailyUsageRow, error) {
+	rows, err := q.db.Query(ctx, listDailyUsage,
+		arg.MerchantID,
+		arg.FromTime,
+		arg.ToTime,
+		arg.CustomerID,
+		arg.SkuID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ListDailyUsageRow
+	for rows.Next() {
+		var i ListDailyUsageRow
+		if err := rows.Scan(
+			&i.BucketStart,
+			&i.SkuID,
+			&i.Quantity,
+			&i.EventCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listHourlyUsage = `-- name: ListHourlyUsage :many
+SELECT bucket_start, sku_id, SUM(quantity)::double precision AS quantity, SUM(event_count)::bigint AS event_count
+FROM usage_rollups_hourly
+WHERE merchant_id = $1
+  AND bucket_start >= $2
+  AND bucket_start < $3
+  AND ($4::uuid IS NULL OR customer_id = $4)
+  AND ($5::uuid IS NULL OR sku_id = $5)
+GROUP BY bucket_start, sku_id
+ORDER BY bucket_start, sku_id
+`
+
+type ListHourlyUsageParams struct {
+	MerchantID pgtype.UUID
+	FromTime   pgtype.Timestamptz
+	ToTime     pgtype.Timestamptz
+	CustomerID pgtype.UUID
+	SkuID      pgtype.UUID
+}
+
+type ListHourlyUsageRow struct {
+	BucketStart pgtype.Timestamptz
+	SkuID       pgtype.UUID
+	Quantity    float64
+	EventCount  int64
+}
+
+func (q *Queries) ListHourlyUsage(ctx context.Context, arg ListHourlyUsageParams) ([]*ListHourlyUsageRow, error) {
+	rows, err := q.db.Query(ctx, listHourlyUsage,
+		arg.MerchantID,
+		arg.FromTime,
+		arg.ToTime,
+		arg.CustomerID,
+		arg.SkuID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ListHourlyUsageRow
+	for rows.Next() {
+		var i ListHourlyUsageRow
+		if err := rows.Scan(
+			&i.BucketStart,
+			&i.SkuID,
+			&i.Quantity,
+			&i.EventCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMinuteUsage = `-- name: ListMinuteUsage :many
+SELECT date_trunc('minute', sent_at)::timestamptz AS bucket_start, sku_id, SUM(amount)::double precision AS quantity, COUNT(*)::bigint AS event_count
+FROM events
+WHERE merchant_id = $1
+  AND sent_at >= $2
+  AND sent_at < $3
+  AND ($4::uuid IS NULL OR customer_id = $4)
+  AND ($5::uuid IS NULL OR sku_id = $5)
+GROUP BY 1, sku_id
+ORDER BY 1, sku_id
+`
+
+type ListMinuteUsageParams struct {
+	MerchantID pgtype.UUID
+	FromTime   pgtype.Timestamptz
+	ToTime     pgtype.Timestamptz
+	CustomerID pgtype.UUID
+	SkuID      pgtype.UUID
+}
+
+type ListMinuteUsageRow struct {
+	BucketStart pgtype.Timestamptz
+	SkuID       pgtype.UUID
+	Quantity    float64
+	EventCount  int64
+}
+
+// Aggregates raw events, for granularities finer than the rollups.
+func (q *Queries) ListMinuteUsage(ctx context.Context, arg ListMinuteUsageParams) ([]*ListMinuteUsageRow, error) {
+	rows, err := q.db.Query(ctx, listMinuteUsage,
+		arg.MerchantID,
+		arg.FromTime,
+		arg.ToTime,
+		arg.CustomerID,
+		arg.SkuID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ListMinuteUsageRow
+	for rows.Next() {
+		var i ListMinuteUsageRow
+		if err := rows.Scan(
+			&i.BucketStart,
+			&i.SkuID,
+			&i.Quantity,
+			&i.EventCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markRollupHourDirty = `-- name: MarkRollupHourDirty :exec
+INSERT INTO usage_rollup_dirty_hours (merchant_id, hour)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING
+`
+
+type MarkRollupHourDirtyParams struct {
+	MerchantID pgtype.UUID
+	Hour       pgtype.Timestamptz
+}
+
+func (q *Queries) MarkRollupHourDirty(ctx context.Context, arg MarkRollupHourDirtyParams) error {
+	_, err := q.db.Exec(ctx, markRollupHourDirty, arg.MerchantID, arg.Hour)
+	return err
+}
@@ -0,0 +1,404 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: webhooks.sql
+
+package sqlcgen
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimWebhookDelivery = `-- name: ClaimWebhookDelivery :one
+UPDATE webhook_deliveries
+SET next_attempt_at = now() + $1::interval
+WHERE id = (
+    SELECT d.id FROM webhook_deliveries d
+    WHERE d.next_attempt_at <= now()
+    ORDER BY d.next_attempt_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, endpoint_id, merchant_id, event_id, event_type, payload, attempts, last_response_status, next_attempt_at, delivered_at, failed_at, created_at
+`
+
+// Leases the next due delivery: it is not handed out again before the lease
+// expires, even if the claiming process dies mid-delivery.
+func (q *Queries) ClaimWebhookDelivery(ctx context.Context, lease pgtype.Interval) (*WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, claimWebhookDelivery, lease)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.EndpointID,
+		&i.MerchantID,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Attempts,
+		&i.LastResponseStatus,
+		&i.NextAttemptAt,
+		&i.DeliveredAt,
+		&i.FailedAt,
+		&i.CreatedAt,
+	)
+	return &i, err
+}
+
+const createWebhookDelivery = `-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (endpoint_id, merchant_id, event_id, event_type, payload)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, endpoint_id, merchant_id, event_id, event_type, payload, attempts, last_response_status, next_attempt_at, delivered_at, failed_at, created_at
+`
+
+type CreateWebhookDeliveryParams struct {
+	EndpointID pgtype.UUID
+	MerchantID pgtype.UUID
+	EventID    pgtype.UUID
+	EventType  string
+	Payload    []byte
+}
+
+func (q *Queries) CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) (*WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, createWebhookDelivery,
+		arg.EndpointID,
+		arg.MerchantID,
+		arg.EventID,
+		arg.EventType,
+		arg.Payload,
+	)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.EndpointID,
+		&i.MerchantID,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Attempts,
+		&i.LastResponseStatus,
+		&i.NextAttemptAt,
+		&i.DeliveredAt,
+		&i.FailedAt,
+		&i.CreatedAt,
+	)
+	return &i, err
+}
+
+const createWebhookEndpoint = `-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (merchant_id, url, secret, event_types)
+VALUES ($1, $2, $3, $4)
+RETURNING id, merchant_id, url, secret, event_types, created_at, revoked_at
+`
+
+type CreateWebhookEndpointParams struct {
+	MerchantID pgtype.UUID
+	Url        string
+	Secret     string
+	EventTypes []string
+}
+
+func (q *Queries) CreateWebhookEndpoint(ctx context.Context, arg CreateWebhookEndpointParams) (*WebhookEndpoint, error) {
+	row := q.db.QueryRow(ctx, createWebhookEndpoint,
+		arg.MerchantID,
+		arg.Url,
+		arg.Secret,
+		arg.EventTypes,
+	)
+	var i WebhookEndpoint
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.Url,
+		&i.Secret,
+		&i.EventTypes,
+		&i.CreatedAt,
+		&i.RevokedAt,
+	)
+	return &i, err
+}
+
+const getWebhookDelivery = `-- name: GetWebhookDelivery :one
+SELECT id, endpoint_id, merchant_id, event_id, event_type, payload, attempts, last_response_status, next_attempt_at, delivered_at, failed_at, created_at FROM webhook_deliveries
+WHERE id = $1 AND endpoint_id = $2 AND merchant_id = $3
+`
+
+type GetWebhookDeliveryParams struct {
+	ID         pgtype.UUID
+	EndpointID pgtype.UUID
+	MerchantID pgtype.UUID
+}
+
+func (q *Queries) GetWebhookDelivery(ctx context.Context, arg GetWebhookDeliveryParams) (*WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, getWebhookDelivery, arg.ID, arg.EndpointID, arg.MerchantID)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.EndpointID,
+		&i.MerchantID,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Attempts,
+		&i.LastResponseStatus,
+		&i.NextAttemptAt,
+		&i.DeliveredAt,
+		&i.FailedAt,
+		&i.CreatedAt,
+	)
+	return &i, err
+}
+
+const getWebhookEndpoint = `-- name: GetWebhookEndpoint :one
+SELECT id, merchant_id, url, secret, event_types, created_at, revoked_at FROM webhook_endpoints
+WHERE id = $1 AND merchant_id = $2
+`
+
+type GetWebhookEndpointParams struct {
+	ID         pgtype.UUID
+	MerchantID pgtype.UUID
+}
+
+func (q *Queries) GetWebhookEndpoint(ctx context.Context, arg GetWebhookEndpointParams) (*WebhookEndpoint, error) {
+	row := q.db.QueryRow(ctx, getWebhookEndpoint, arg.ID, arg.MerchantID)
+	var i WebhookEndpoint
+	err := row.Scan(
+		&i.ID,
+		&i.MerchantID,
+		&i.Url,
+		&i.Secret,
+		&i.EventTypes,
+		&i.CreatedAt,
+		&i.RevokedAt,
+	)
+	return &i, err
+}
+
+const listWebhookDeliveriesByEndpoint = `-- name: ListWebhookDeliveriesByEndpoint :many
+SELECT id, endpoint_id, merchant_id, event_id, event_type, payload, attempts, last_response_status, next_attempt_at, delivered_at, failed_at, created_at FROM webhook_deliveries
+WHERE endpoint_id = $1 AND merchant_id = $2
+ORDER BY created_at DESC
+LIMIT 100
+`
+
+type ListWebhookDeliveriesByEndpointParams struct {
+	EndpointID pgtype.UUID
+	MerchantID pgtype.UUID
+}
+
+func (q *Queries) ListWebhookDeliveriesByEndpoint(ctx context.Context, arg ListWebhookDeliveriesByEndpointParams) ([]*WebhookDelivery, error) {
+	rows, err := q.db.Query(ctx, listWebhookDeliveriesByEndpoint, arg.EndpointID, arg.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.EndpointID,
+			&i.MerchantID,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.Attempts,
+			&i.LastResponseStatus,
+			&i.NextAttemptAt,
+			&i.DeliveredAt,
+			&i.FailedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookDeliveryAttempts = `-- name: ListWebhookDeliveryAttempts :many
+SELECT id, delivery_id, response_status, error, duration_ms, attempted_at FROM webhook_delivery_attempts
+WHERE delivery_id = $1
+ORDER BY attempted_at
+`
+
+func (q *Queries) ListWebhookDeliveryAttempts(ctx context.Context, deliveryID pgtype.UUID) ([]*WebhookDeliveryAttempt, error) {
+	rows, err := q.db.Query(ctx, listWebhookDeliveryAttempts, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*WebhookDeliveryAttempt
+	for rows.Next() {
+		var i WebhookDeliveryAttempt
+		if err := rows.Scan(
+			&i.ID,
+			&i.DeliveryID,
+			&i.ResponseStatus,
+			&i.Error,
+			&i.DurationMs,
+			&i.AttemptedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookEndpointsByMerchantID = `-- name: ListWebhookEndpointsByMerchantID :many
+SELECT id, merchant_id, url, secret, event_types, created_at, revoked_at FROM webhook_endpoints
+WHERE merchant_id = $1
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListWebhookEndpointsByMerchantID(ctx context.Context, merchantID pgtype.UUID) ([]*WebhookEndpoint, error) {
+	rows, err := q.db.Query(ctx, listWebhookEndpointsByMerchantID, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*WebhookEndpoint
+	for rows.Next() {
+		var i WebhookEndpoint
+		if err := rows.Scan(
+			&i.ID,
+			&i.MerchantID,
+			&i.Url,
+			&i.Secret,
+			&i.EventTypes,
+			&i.CreatedAt,
+			&i.RevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookEndpointsForEvent = `-- name: ListWebhookEndpointsForEvent :many
+SELECT id, merchant_id, url, secret, event_types, created_at, revoked_at FROM webhook_endpoints
+WHERE merchant_id = $1
+  AND revoked_at IS NULL
+  AND (cardinality(event_types) = 0 OR $2::text = ANY(event_types))
+`
+
+type ListWebhookEndpointsForEventParams struct {
+	MerchantID pgtype.UUID
+	EventType  string
+}
+
+// An endpoint without event types subscribes to all of them.
+func (q *Queries) ListWebhookEndpointsForEvent(ctx context.Context, arg ListWebhookEndpointsForEventParams) ([]*WebhookEndpoint, error) {
+	rows, err := q.db.Query(ctx, listWebhookEndpointsForEvent, arg.MerchantID, arg.EventType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*WebhookEndpoint
+	for rows.Next() {
+		var i WebhookEndpoint
+		if err := rows.Scan(
+			&i.ID,
+			&i.MerchantID,
+			&i.Url,
+			&i.Secret,
+			&i.EventTypes,
+			&i.CreatedAt,
+			&i.RevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markWebhookDeliveryFailed = `-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries
+SET attempts = attempts + 1,
+    last_response_status = $2,
+    next_attempt_at = $3,
+    failed_at = CASE WHEN $3::timestamptz IS NULL THEN now() END
+WHERE id = $1
+`
+
+type MarkWebhookDeliveryFailedParams struct {
+	ID                 pgtype.UUID
+	LastResponseStatus pgtype.Int4
+	NextAttemptAt      pgtype.Timestamptz
+}
+
+// A delivery without a next attempt has exhausted its retries.
+func (q *Queries) MarkWebhookDeliveryFailed(ctx context.Context, arg MarkWebhookDeliveryFailedParams) error {
+	_, err := q.db.Exec(ctx, markWebhookDeliveryFailed, arg.ID, arg.LastResponseStatus, arg.NextAttemptAt)
+	return err
+}
+
+const markWebhookDeliverySucceeded = `-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET attempts = attempts + 1, last_response_status = $2, next_attempt_at = NULL, delivered_at = now()
+WHERE id = $1
+`
+
+type MarkWebhookDeliverySucceededParams struct {
+	ID                 pgtype.UUID
+	LastResponseStatus pgtype.Int4
+}
+
+func (q *Queries) MarkWebhookDeliverySucceeded(ctx context.Context, arg MarkWebhookDeliverySucceededParams) error {
+	_, err := q.db.Exec(ctx, markWebhookDeliverySucceeded, arg.ID, arg.LastResponseStatus)
+	return err
+}
+
+const recordWebhookDeliveryAttempt = `-- name: RecordWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (delivery_id, response_status, error, duration_ms)
+VALUES ($1, $2, $3, $4)
+`
+
+type RecordWebhookDeliveryAttemptParams struct {
+	DeliveryID     pgtype.UUID
+	ResponseStatus pgtype.Int4
+	Error          pgtype.Text
+	DurationMs     int32
+}
+
+func (q *Queries) RecordWebhookDeliveryAttempt(ctx context.Context, arg RecordWebhookDeliveryAttemptParams) error {
+	_, err := q.db.Exec(ctx, recordWebhookDeliveryAttempt,
+		arg.DeliveryID,
+		arg.ResponseStatus,
+		arg.Error,
+		arg.DurationMs,
+	)
+	return err
+}
+
+const revokeWebhookEndpoint = `-- name: RevokeWebhookEndpoint :exec
+UPDATE webhook_endpoints
+SET revoked_at = now()
+WHERE id = $1 AND merchant_id = $2 AND revoked_at IS NULL
+`
+
+type RevokeWebhookEndpointParams struct {
+	ID         pgtype.UUID
+	MerchantID pgtype.UUID
+}
+
+func (q *Queries) RevokeWebhookEndpoint(ctx context.Context, arg RevokeWebhookEndpointParams) error {
+	_, err := q.db.Exec(ctx, revokeWebhookEndpoint, arg.ID, arg.MerchantID)
+	return err
+}
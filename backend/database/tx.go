@@ -0,0 +1,28 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// InTx runs fn inside a transaction. The transaction is committed if fn
+// returns nil and rolled back otherwise.
+func InTx(ctx context.Context, pool *pgxpool.Pool, fn func(q *sqlcgen.Queries) error) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("pool.Begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(sqlcgen.New(pool).WithTx(tx)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("tx.Commit: %w", err)
+	}
+	return nil
+}
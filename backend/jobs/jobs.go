@@ -0,0 +1,59 @@
+// Package jobs runs background work: one-off jobs enqueued by the
+// application and periodic jobs run on a fixed interval. PostgresRunner runs
+// them off a Postgres queue; the temporal package provides a Temporal
+// backed Runner.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Handler runs a job. A job whose handler returns an error is retried with
+// backoff until it runs out of attempts, so handlers must be idempotent.
+type Handler func(ctx context.Context, args json.RawMessage) error
+
+type EnqueueOptions struct {
+	// UniqueKey deduplicates jobs: a job is not enqueued again while a job
+	// with the same key exists, whatever its state.
+	UniqueKey string
+	// RunAt delays the job. Zero means now.
+	RunAt time.Time
+	// MaxAttempts defaults to DEFAULT_MAX_ATTEMPTS.
+	MaxAttempts int
+}
+
+type Queue interface {
+	// Enqueue queues a job of the given kind. args are JSON encoded and
+	// handed to the kind's handler.
+	Enqueue(ctx context.Context, kind string, args any, opts EnqueueOptions) error
+}
+
+// Runner runs jobs. Handlers and schedules must be registered before Run.
+type Runner interface {
+	Queue
+	Handle(kind string, handler Handler)
+	// Schedule runs the kind's handler every interval, with null args. A
+	// scheduled run is not retried: the next one takes over.
+	Schedule(kind string, every time.Duration)
+	Run(ctx context.Context) error
+}
+
+const (
+	DEFAULT_MAX_ATTEMPTS = 10
+	// JOB_TIMEOUT bounds a single run of a handler.
+	JOB_TIMEOUT      = 10 * time.Minute
+	RETRY_BASE_DELAY = 10 * time.Second
+	RETRY_MAX_DELAY  = time.Hour
+)
+
+// RetryDelay is the backoff before the next attempt of a job that failed
+// attempts times.
+func RetryDelay(attempts int) time.Duration {
+	delay := RETRY_BASE_DELAY
+	for i := 1; i < attempts && delay < RETRY_MAX_DELAY; i++ {
+		delay *= 2
+	}
+	return min(delay, RETRY_MAX_DELAY)
+}
@@ -0,0 +1,263 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// JOB_LEASE must exceed JOB_TIMEOUT so that a running job is not handed
+	// out twice.
+	JOB_LEASE     = JOB_TIMEOUT + 5*time.Minute
+	POLL_INTERVAL = time.Second
+	// COMPLETED_JOB_RETENTION is how long completed jobs are kept, along
+	// with their unique keys.
+	COMPLETED_JOB_RETENTION = 30 * 24 * time.Hour
+
+	KIND_CLEANUP = "jobs.cleanup"
+)
+
+// PostgresRunner runs jobs stored in Postgres. Workers claim jobs with
+// SELECT ... FOR UPDATE SKIP LOCKED, so any number of them can share the
+// queue.
+type PostgresRunner struct {
+	logger      *zap.Logger
+	queries     *sqlcgen.Queries
+	concurrency int
+
+	mu        sync.RWMutex
+	handlers  map[string]Handler
+	schedules map[string]time.Duration
+}
+
+func NewPostgresRunner(logger *zap.Logger, queries *sqlcgen.Queries, concurrency int) *PostgresRunner {
+	r := &PostgresRunner{
+		logger:      logger.With(zap.String("component", "jobs")),
+		queries:     queries,
+		concurrency: max(concurrency, 1),
+		handlers:    make(map[string]Handler),
+		schedules:   make(map[string]time.Duration),
+	}
+	r.Handle(KIND_CLEANUP, r.cleanup)
+	r.Schedule(KIND_CLEANUP, time.Hour)
+	return r
+}
+
+var _ Runner = (*PostgresRunner)(nil)
+
+func (r *PostgresRunner) Handle(kind string, handler Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[kind] = handler
+}
+
+func (r *PostgresRunner) Schedule(kind string, every time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.schedules[kind] = every
+}
+
+func (r *PostgresRunner) Enqueue(ctx context.Context, kind string, args any, opts EnqueueOptions) error {
+	payload, err := json.Marshal(args)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	runAt := opts.RunAt
+	if runAt.IsZero() {
+		runAt = time.Now()
+	}
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DEFAULT_MAX_ATTEMPTS
+	}
+
+	_, err = r.queries.EnqueueJob(ctx, sqlcgen.EnqueueJobParams{
+		Kind:        kind,
+		Args:        payload,
+		UniqueKey:   pgtype.Text{String: opts.UniqueKey, Valid: opts.UniqueKey != ""},
+		RunAt:       pgtype.Timestamptz{Time: runAt, Valid: true},
+		MaxAttempts: int32(maxAttempts),
+	})
+	if err != nil {
+		return fmt.Errorf("queries.EnqueueJob: %w", err)
+	}
+	return nil
+}
+
+// Run registers the schedules, then runs due jobs with up to concurrency
+// workers until ctx is cancelled.
+func (r *PostgresRunner) Run(ctx context.Context) error {
+	r.mu.RLock()
+	kinds := make([]string, 0, len(r.handlers))
+	for kind := range r.handlers {
+		kinds = append(kinds, kind)
+	}
+	schedules := make(map[string]time.Duration, len(r.schedules))
+	for kind, every := range r.schedules {
+		schedules[kind] = every
+	}
+	r.mu.RUnlock()
+
+	for kind, every := range schedules {
+		err := r.queries.UpsertJobSchedule(ctx, sqlcgen.UpsertJobScheduleParams{
+			Kind:  kind,
+			Every: pgtype.Interval{Microseconds: every.Microseconds(), Valid: true},
+		})
+		if err != nil {
+			return fmt.Errorf("queries.UpsertJobSchedule: %w", err)
+		}
+	}
+
+	r.logger.Info("job runner started", zap.Strings("kinds", kinds), zap.Int("concurrency", r.concurrency))
+	errGrp, ctx := errgroup.WithContext(ctx)
+	for range r.concurrency {
+		errGrp.Go(func() error {
+			return r.work(ctx, kinds)
+		})
+	}
+	return errGrp.Wait()
+}
+
+func (r *PostgresRunner) work(ctx context.Context, kinds []string) error {
+	ticker := time.NewTicker(POLL_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		for {
+			ran, err := r.runNext(ctx, kinds)
+			if err != nil {
+				r.logger.Error("failed to run job", zap.Error(err))
+				break
+			}
+			if !ran {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// runNext runs a due scheduled job, or else the next due queued job.
+func (r *PostgresRunner) runNext(ctx context.Context, kinds []string) (bool, error) {
+	schedule, err := r.queries.ClaimJobSchedule(ctx, kinds)
+	if err == nil {
+		runErr := r.run(ctx, schedule.Kind, json.RawMessage("null"))
+		err = r.queries.SetJobScheduleError(ctx, sqlcgen.SetJobScheduleErrorParams{
+			Kind:      schedule.Kind,
+			LastError: errorText(runErr),
+		})
+		if err != nil {
+			return false, fmt.Errorf("queries.SetJobScheduleError: %w", err)
+		}
+		if runErr != nil {
+			r.logger.Warn("scheduled job failed", zap.String("kind", schedule.Kind), zap.Error(runErr))
+		}
+		return true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return false, fmt.Errorf("queries.ClaimJobSchedule: %w", err)
+	}
+
+	job, err := r.queries.ClaimJob(ctx, sqlcgen.ClaimJobParams{
+		Lease: pgtype.Interval{Microseconds: JOB_LEASE.Microseconds(), Valid: true},
+		Kinds: kinds,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("queries.ClaimJob: %w", err)
+	}
+
+	runErr := r.run(ctx, job.Kind, job.Args)
+	switch {
+	case runErr == nil:
+		err = r.queries.CompleteJob(ctx, job.ID)
+		if err != nil {
+			return false, fmt.Errorf("queries.CompleteJob: %w", err)
+		}
+	case job.Attempts >= job.MaxAttempts:
+		r.logger.Error("job failed",
+			zap.String("job_id", job.ID.String()),
+			zap.String("kind", job.Kind),
+			zap.Int32("attempts", job.Attempts),
+			zap.Error(runErr),
+		)
+		err = r.queries.FailJob(ctx, sqlcgen.FailJobParams{
+			ID:        job.ID,
+			LastError: errorText(runErr),
+		})
+		if err != nil {
+			return false, fmt.Errorf("queries.FailJob: %w", err)
+		}
+	default:
+		delay := RetryDelay(int(job.Attempts))
+		r.logger.Warn("job attempt failed",
+			zap.String("job_id", job.ID.String()),
+			zap.String("kind", job.Kind),
+			zap.Int32("attempt", job.Attempts),
+			zap.Duration("retry_in", delay),
+			zap.Error(runErr),
+		)
+		err = r.queries.RetryJob(ctx, sqlcgen.RetryJobParams{
+			ID:        job.ID,
+			RunAt:     pgtype.Timestamptz{Time: time.Now().Add(delay), Valid: true},
+			LastError: errorText(runErr),
+		})
+		if err != nil {
+			return false, fmt.Errorf("queries.RetryJob: %w", err)
+		}
+	}
+	return true, nil
+}
+
+func (r *PostgresRunner) run(ctx context.Context, kind string, args json.RawMessage) (err error) {
+	r.mu.RLock()
+	handler := r.handlers[kind]
+	r.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, JOB_TIMEOUT)
+	defer cancel()
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return handler(ctx, args)
+}
+
+func (r *PostgresRunner) cleanup(ctx context.Context, _ json.RawMessage) error {
+	deleted, err := r.queries.DeleteCompletedJobs(ctx, pgtype.Timestamptz{
+		Time:  time.Now().Add(-COMPLETED_JOB_RETENTION),
+		Valid: true,
+	})
+	if err != nil {
+		return fmt.Errorf("queries.DeleteCompletedJobs: %w", err)
+	}
+	if deleted > 0 {
+		r.logger.Info("completed jobs deleted", zap.Int64("count", deleted))
+	}
+	return nil
+}
+
+func errorText(err error) pgtype.Text {
+	if err == nil {
+		return pgtype.Text{}
+	}
+	return pgtype.Text{String: err.Error(), Valid: true}
+}
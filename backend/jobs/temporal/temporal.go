@@ -0,0 +1,140 @@
+// Package temporal implements jobs.Runner on Temporal: each job runs as a
+// workflow executing the kind's handler as an activity, and schedules are
+// Temporal schedules.
+package temporal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"billbo.com/backend/jobs"
+	"github.com/google/uuid"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+	"go.uber.org/zap"
+)
+
+const JOB_WORKFLOW = "billbo.Job"
+
+type Runner struct {
+	logger    *zap.Logger
+	client    client.Client
+	taskQueue string
+	worker    worker.Worker
+	schedules map[string]time.Duration
+}
+
+func NewRunner(logger *zap.Logger, c client.Client, taskQueue string) *Runner {
+	w := worker.New(c, taskQueue, worker.Options{})
+	w.RegisterWorkflowWithOptions(JobWorkflow, workflow.RegisterOptions{Name: JOB_WORKFLOW})
+	return &Runner{
+		logger:    logger.With(zap.String("component", "jobs")),
+		client:    c,
+		taskQueue: taskQueue,
+		worker:    w,
+		schedules: make(map[string]time.Duration),
+	}
+}
+
+var _ jobs.Runner = (*Runner)(nil)
+
+// JobWorkflow runs the activity registered for kind, letting Temporal retry
+// it up to maxAttempts times.
+func JobWorkflow(ctx workflow.Context, kind string, args json.RawMessage, maxAttempts int32) error {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: jobs.JOB_TIMEOUT,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    jobs.RETRY_BASE_DELAY,
+			BackoffCoefficient: 2,
+			MaximumInterval:    jobs.RETRY_MAX_DELAY,
+			MaximumAttempts:    maxAttempts,
+		},
+	})
+	return workflow.ExecuteActivity(ctx, kind, args).Get(ctx, nil)
+}
+
+func (r *Runner) Handle(kind string, handler jobs.Handler) {
+	r.worker.RegisterActivityWithOptions(
+		func(ctx context.Context, args json.RawMessage) error {
+			return handler(ctx, args)
+		},
+		activity.RegisterOptions{Name: kind},
+	)
+}
+
+func (r *Runner) Schedule(kind string, every time.Duration) {
+	r.schedules[kind] = every
+}
+
+// Enqueue starts a job workflow. The unique key, if any, is the workflow ID:
+// Temporal rejects a second workflow with the same ID.
+func (r *Runner) Enqueue(ctx context.Context, kind string, args any, opts jobs.EnqueueOptions) error {
+	payload, err := json.Marshal(args)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = jobs.DEFAULT_MAX_ATTEMPTS
+	}
+
+	options := client.StartWorkflowOptions{
+		ID:                    "job-" + uuid.NewString(),
+		TaskQueue:             r.taskQueue,
+		WorkflowIDReusePolicy: enums.WORKFLOW_ID_REUSE_POLICY_REJECT_DUPLICATE,
+	}
+	if opts.UniqueKey != "" {
+		options.ID = "job-" + opts.UniqueKey
+	}
+	if delay := time.Until(opts.RunAt); delay > 0 {
+		options.StartDelay = delay
+	}
+
+	_, err = r.client.ExecuteWorkflow(ctx, options, JOB_WORKFLOW, kind, json.RawMessage(payload), int32(maxAttempts))
+	var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+	if errors.As(err, &alreadyStarted) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("client.ExecuteWorkflow: %w", err)
+	}
+	return nil
+}
+
+// Run creates the schedules, then runs the worker until ctx is cancelled.
+func (r *Runner) Run(ctx context.Context) error {
+	for kind, every := range r.schedules {
+		_, err := r.client.ScheduleClient().Create(ctx, client.ScheduleOptions{
+			ID: "schedule-" + kind,
+			Spec: client.ScheduleSpec{
+				Intervals: []client.ScheduleIntervalSpec{{Every: every}},
+			},
+			Overlap: enums.SCHEDULE_OVERLAP_POLICY_SKIP,
+			Action: &client.ScheduleWorkflowAction{
+				ID:        "scheduled-" + kind,
+				Workflow:  JOB_WORKFLOW,
+				Args:      []any{kind, json.RawMessage("null"), int32(1)},
+				TaskQueue: r.taskQueue,
+			},
+		})
+		if err != nil && !errors.Is(err, temporal.ErrScheduleAlreadyRunning) {
+			return fmt.Errorf("ScheduleClient.Create: %w", err)
+		}
+	}
+
+	if err := r.worker.Start(); err != nil {
+		return fmt.Errorf("worker.Start: %w", err)
+	}
+	r.logger.Info("temporal worker started", zap.String("task_queue", r.taskQueue))
+	<-ctx.Done()
+	r.worker.Stop()
+	return ctx.Err()
+}
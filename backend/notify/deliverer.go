@@ -0,0 +1,195 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+)
+
+const (
+	SIGNATURE_HEADER  = "X-BillBo-Signature"
+	EVENT_TYPE_HEADER = "X-BillBo-Event"
+	DELIVERY_HEADER   = "X-BillBo-Delivery"
+
+	// MAX_DELIVERY_ATTEMPTS spreads retries over about 15 hours.
+	MAX_DELIVERY_ATTEMPTS = 12
+	RETRY_BASE_DELAY      = 30 * time.Second
+	RETRY_MAX_DELAY       = 6 * time.Hour
+
+	DELIVERY_TIMEOUT = 10 * time.Second
+	// DELIVERY_LEASE must exceed DELIVERY_TIMEOUT so that a delivery is not
+	// handed out twice while in flight.
+	DELIVERY_LEASE = time.Minute
+)
+
+// Sign computes the X-BillBo-Signature header of a payload sent at
+// timestamp: "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<payload>">".
+// Merchants recompute it with their endpoint secret to authenticate
+// deliveries.
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliverer sends queued deliveries to merchant endpoints, retrying failures
+// with exponential backoff.
+type Deliverer struct {
+	logger  *zap.Logger
+	queries *sqlcgen.Queries
+	client  *http.Client
+}
+
+func NewDeliverer(logger *zap.Logger, queries *sqlcgen.Queries) *Deliverer {
+	return &Deliverer{
+		logger:  logger.With(zap.String("component", "webhook_deliverer")),
+		queries: queries,
+		client:  newClient(),
+	}
+}
+
+// DeliverDue sends the deliveries that are due.
+func (d *Deliverer) DeliverDue(ctx context.Context) error {
+	for {
+		delivered, err := d.deliverNext(ctx)
+		if err != nil {
+			return fmt.Errorf("deliverNext: %w", err)
+		}
+		if !delivered {
+			return nil
+		}
+	}
+}
+
+func (d *Deliverer) deliverNext(ctx context.Context) (bool, error) {
+	delivery, err := d.queries.ClaimWebhookDelivery(ctx, pgtype.Interval{
+		Microseconds: DELIVERY_LEASE.Microseconds(),
+		Valid:        true,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("queries.ClaimWebhookDelivery: %w", err)
+	}
+
+	endpoint, err := d.queries.GetWebhookEndpoint(ctx, sqlcgen.GetWebhookEndpointParams{
+		ID:         delivery.EndpointID,
+		MerchantID: delivery.MerchantID,
+	})
+	if err != nil {
+		return false, fmt.Errorf("queries.GetWebhookEndpoint: %w", err)
+	}
+
+	var status pgtype.Int4
+	var deliveryErr error
+	start := time.Now()
+	if endpoint.RevokedAt.Valid {
+		deliveryErr = errors.New("endpoint revoked")
+	} else {
+		status, deliveryErr = d.send(ctx, endpoint, delivery)
+	}
+	duration := time.Since(start)
+
+	err = d.queries.RecordWebhookDeliveryAttempt(ctx, sqlcgen.RecordWebhookDeliveryAttemptParams{
+		DeliveryID:     delivery.ID,
+		ResponseStatus: status,
+		Error:          errorText(deliveryErr),
+		DurationMs:     int32(duration.Milliseconds()),
+	})
+	if err != nil {
+		return false, fmt.Errorf("queries.RecordWebhookDeliveryAttempt: %w", err)
+	}
+
+	if deliveryErr == nil {
+		err = d.queries.MarkWebhookDeliverySucceeded(ctx, sqlcgen.MarkWebhookDeliverySucceededParams{
+			ID:                 delivery.ID,
+			LastResponseStatus: status,
+		})
+		if err != nil {
+			return false, fmt.Errorf("queries.MarkWebhookDeliverySucceeded: %w", err)
+		}
+		return true, nil
+	}
+
+	var next pgtype.Timestamptz
+	attempts := int(delivery.Attempts) + 1
+	if attempts < MAX_DELIVERY_ATTEMPTS && !endpoint.RevokedAt.Valid {
+		next = pgtype.Timestamptz{Time: time.Now().Add(retryDelay(attempts)), Valid: true}
+	}
+	d.logger.Warn("webhook delivery failed",
+		zap.String("delivery_id", delivery.ID.String()),
+		zap.String("url", endpoint.Url),
+		zap.Int("attempt", attempts),
+		zap.Bool("retrying", next.Valid),
+		zap.Error(deliveryErr),
+	)
+	err = d.queries.MarkWebhookDeliveryFailed(ctx, sqlcgen.MarkWebhookDeliveryFailedParams{
+		ID:                 delivery.ID,
+		LastResponseStatus: status,
+		NextAttemptAt:      next,
+	})
+	if err != nil {
+		return false, fmt.Errorf("queries.MarkWebhookDeliveryFailed: %w", err)
+	}
+	return true, nil
+}
+
+// send posts the delivery payload. Any non-2xx response is a failure.
+func (d *Deliverer) send(ctx context.Context, endpoint *sqlcgen.WebhookEndpoint, delivery *sqlcgen.WebhookDelivery) (pgtype.Int4, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return pgtype.Int4{}, fmt.Errorf("http.NewRequest: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "BillBo-Webhooks/1.0")
+	req.Header.Set(EVENT_TYPE_HEADER, delivery.EventType)
+	req.Header.Set(DELIVERY_HEADER, delivery.ID.String())
+	req.Header.Set(SIGNATURE_HEADER, Sign(endpoint.Secret, time.Now(), delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return pgtype.Int4{}, fmt.Errorf("client.Do: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	status := pgtype.Int4{Int32: int32(resp.StatusCode), Valid: true}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return status, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return status, nil
+}
+
+// retryDelay doubles the delay after each failed attempt, up to
+// RETRY_MAX_DELAY.
+func retryDelay(attempts int) time.Duration {
+	delay := RETRY_BASE_DELAY
+	for i := 1; i < attempts && delay < RETRY_MAX_DELAY; i++ {
+		delay *= 2
+	}
+	return min(delay, RETRY_MAX_DELAY)
+}
+
+func errorText(err error) pgtype.Text {
+	if err == nil {
+		return pgtype.Text{}
+	}
+	return pgtype.Text{String: err.Error(), Valid: true}
+}
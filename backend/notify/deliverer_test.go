@@ -0,0 +1,287 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"billbo.com/backend/database/dbtest"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+const testSecret = "whsec_test"
+
+// newTestDeliverer returns a deliverer allowed to reach the test servers,
+// which listen on loopback.
+func newTestDeliverer(queries *sqlcgen.Queries) *Deliverer {
+	d := NewDeliverer(zap.NewNop(), queries)
+	d.client = &http.Client{Timeout: DELIVERY_TIMEOUT}
+	return d
+}
+
+func TestSendSignsPayload(t *testing.T) {
+	var (
+		header http.Header
+		body   []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	delivery := &sqlcgen.WebhookDelivery{
+		ID:        pgtype.UUID{Bytes: uuid.New(), Valid: true},
+		EventType: "invoice.paid",
+		Payload:   []byte(`{"type":"invoice.paid"}`),
+	}
+	d := newTestDeliverer(nil)
+	status, err := d.send(context.Background(), &sqlcgen.WebhookEndpoint{Url: server.URL, Secret: testSecret}, delivery)
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if status.Int32 != http.StatusNoContent {
+		t.Fatalf("got status %d, want 204", status.Int32)
+	}
+
+	if string(body) != string(delivery.Payload) {
+		t.Fatalf("got body %s, want %s", body, delivery.Payload)
+	}
+	if got := header.Get(EVENT_TYPE_HEADER); got != delivery.EventType {
+		t.Fatalf("got %s %q, want %q", EVENT_TYPE_HEADER, got, delivery.EventType)
+	}
+	if got := header.Get(DELIVERY_HEADER); got != delivery.ID.String() {
+		t.Fatalf("got %s %q, want %q", DELIVERY_HEADER, got, delivery.ID.String())
+	}
+
+	// The receiver recomputes the signature from the timestamp it carries.
+	signature := header.Get(SIGNATURE_HEADER)
+	t0, _, ok := strings.Cut(strings.TrimPrefix(signature, "t="), ",")
+	if !ok {
+		t.Fatalf("malformed %s %q", SIGNATURE_HEADER, signature)
+	}
+	unix, err := strconv.ParseInt(t0, 10, 64)
+	if err != nil {
+		t.Fatalf("strconv.ParseInt: %v", err)
+	}
+	if want := Sign(testSecret, time.Unix(unix, 0), body); signature != want {
+		t.Fatalf("got %s %q, want %q", SIGNATURE_HEADER, signature, want)
+	}
+	if signature == Sign("whsec_other", time.Unix(unix, 0), body) {
+		t.Fatal("signature does not depend on the secret")
+	}
+}
+
+func TestSendFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	d := newTestDeliverer(nil)
+	d.client.Timeout = 50 * time.Millisecond
+	delivery := &sqlcgen.WebhookDelivery{Payload: []byte(`{}`)}
+
+	status, err := d.send(context.Background(), &sqlcgen.WebhookEndpoint{Url: server.URL, Secret: testSecret}, delivery)
+	if err == nil || status.Int32 != http.StatusServiceUnavailable {
+		t.Fatalf("got status %v, error %v, want a 503 failure", status, err)
+	}
+
+	status, err = d.send(context.Background(), &sqlcgen.WebhookEndpoint{Url: server.URL + "/slow", Secret: testSecret}, delivery)
+	if err == nil || status.Valid {
+		t.Fatalf("got status %v, error %v, want a timeout without status", status, err)
+	}
+}
+
+func TestSendRefusesNonPublicAddresses(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	d := NewDeliverer(zap.NewNop(), nil)
+	status, err := d.send(context.Background(), &sqlcgen.WebhookEndpoint{Url: server.URL, Secret: testSecret}, &sqlcgen.WebhookDelivery{Payload: []byte(`{}`)})
+	if !errors.Is(err, ErrForbiddenAddress) || status.Valid {
+		t.Fatalf("got status %v, error %v, want ErrForbiddenAddress", status, err)
+	}
+	if requests.Load() != 0 {
+		t.Fatal("the request reached the loopback server")
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	for _, tt := range []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.215.14", true},
+		{"2606:2800:21f:cb07:6820:80da:af6b:8b2c", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"::ffff:169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"100.100.100.200", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"224.0.0.1", false},
+	} {
+		if got := isPublic(netip.MustParseAddr(tt.ip)); got != tt.want {
+			t.Errorf("isPublic(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for _, tt := range []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, RETRY_BASE_DELAY},
+		{2, 2 * RETRY_BASE_DELAY},
+		{3, 4 * RETRY_BASE_DELAY},
+		{MAX_DELIVERY_ATTEMPTS, RETRY_MAX_DELAY},
+		{100, RETRY_MAX_DELAY},
+	} {
+		if got := retryDelay(tt.attempts); got != tt.want {
+			t.Errorf("retryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+// createDelivery queues a delivery to an endpoint of url for a new merchant.
+func createDelivery(t *testing.T, pool *pgxpool.Pool, url string) *sqlcgen.WebhookDelivery {
+	t.Helper()
+	ctx := context.Background()
+	queries := sqlcgen.New(pool)
+
+	merchantID := dbtest.CreateMerchant(t, pool)
+	endpoint, err := queries.CreateWebhookEndpoint(ctx, sqlcgen.CreateWebhookEndpointParams{
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+		Url:        url,
+		Secret:     testSecret,
+		EventTypes: []string{},
+	})
+	if err != nil {
+		t.Fatalf("queries.CreateWebhookEndpoint: %v", err)
+	}
+
+	delivery, err := queries.CreateWebhookDelivery(ctx, sqlcgen.CreateWebhookDeliveryParams{
+		EndpointID: endpoint.ID,
+		MerchantID: endpoint.MerchantID,
+		EventID:    pgtype.UUID{Bytes: uuid.New(), Valid: true},
+		EventType:  "invoice.paid",
+		Payload:    []byte(`{"type":"invoice.paid"}`),
+	})
+	if err != nil {
+		t.Fatalf("queries.CreateWebhookDelivery: %v", err)
+	}
+	return delivery
+}
+
+func getDelivery(t *testing.T, pool *pgxpool.Pool, delivery *sqlcgen.WebhookDelivery) *sqlcgen.WebhookDelivery {
+	t.Helper()
+	row, err := sqlcgen.New(pool).GetWebhookDelivery(context.Background(), sqlcgen.GetWebhookDeliveryParams{
+		ID:         delivery.ID,
+		EndpointID: delivery.EndpointID,
+		MerchantID: delivery.MerchantID,
+	})
+	if err != nil {
+		t.Fatalf("queries.GetWebhookDelivery: %v", err)
+	}
+	return row
+}
+
+func TestDeliverDueRetriesThenGivesUp(t *testing.T) {
+	pool := dbtest.NewPool(t)
+	ctx := context.Background()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	d := newTestDeliverer(sqlcgen.New(pool))
+	d.client.Timeout = 50 * time.Millisecond
+
+	for _, path := range []string{"/", "/slow"} {
+		delivery := createDelivery(t, pool, server.URL+path)
+
+		// A 5xx or a timeout is retried after the first backoff step.
+		before := time.Now()
+		if err := d.DeliverDue(ctx); err != nil {
+			t.Fatalf("DeliverDue: %v", err)
+		}
+		row := getDelivery(t, pool, delivery)
+		if row.Attempts != 1 || row.FailedAt.Valid || !row.NextAttemptAt.Valid {
+			t.Fatalf("%s: got %d attempts, failed at %v, next attempt at %v, want a retry", path, row.Attempts, row.FailedAt, row.NextAttemptAt)
+		}
+		if next := row.NextAttemptAt.Time; next.Before(before.Add(RETRY_BASE_DELAY)) || next.After(time.Now().Add(RETRY_BASE_DELAY)) {
+			t.Fatalf("%s: got next attempt in %v, want %v", path, next.Sub(before), RETRY_BASE_DELAY)
+		}
+
+		// The last attempt gives up.
+		_, err := pool.Exec(ctx,
+			`UPDATE webhook_deliveries SET attempts = $2, next_attempt_at = now() WHERE id = $1`,
+			delivery.ID, MAX_DELIVERY_ATTEMPTS-1,
+		)
+		if err != nil {
+			t.Fatalf("update delivery: %v", err)
+		}
+		if err := d.DeliverDue(ctx); err != nil {
+			t.Fatalf("DeliverDue: %v", err)
+		}
+		row = getDelivery(t, pool, delivery)
+		if row.Attempts != MAX_DELIVERY_ATTEMPTS || !row.FailedAt.Valid || row.NextAttemptAt.Valid {
+			t.Fatalf("%s: got %d attempts, failed at %v, next attempt at %v, want to give up", path, row.Attempts, row.FailedAt, row.NextAttemptAt)
+		}
+	}
+	if requests.Load() == 0 {
+		t.Fatal("no request reached the endpoint")
+	}
+}
+
+func TestDeliverDueSucceeds(t *testing.T) {
+	pool := dbtest.NewPool(t)
+	ctx := context.Background()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	delivery := createDelivery(t, pool, server.URL)
+	if err := newTestDeliverer(sqlcgen.New(pool)).DeliverDue(ctx); err != nil {
+		t.Fatalf("DeliverDue: %v", err)
+	}
+	row := getDelivery(t, pool, delivery)
+	if row.Attempts != 1 || !row.DeliveredAt.Valid || row.NextAttemptAt.Valid || row.LastResponseStatus.Int32 != http.StatusOK {
+		t.Fatalf("got %d attempts, delivered at %v, next attempt at %v, want delivered", row.Attempts, row.DeliveredAt, row.NextAttemptAt)
+	}
+}
@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+const DIAL_TIMEOUT = 5 * time.Second
+
+var ErrForbiddenAddress = errors.New("webhook endpoint address is not public")
+
+// nonPublicPrefixes are the ranges, on top of those netip classifies as
+// private, loopback or link-local, that are not reachable from the internet.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// isPublic reports whether ip is a public unicast address.
+func isPublic(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// dialPublic refuses connections to non-public addresses, so that merchants
+// cannot make the worker post into the internal network, e.g. to the cloud
+// metadata service. It runs once the host is resolved, which also covers
+// names resolving, or later rebinding, to internal addresses.
+func dialPublic(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("net.SplitHostPort: %w", err)
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("netip.ParseAddr: %w", err)
+	}
+	if !isPublic(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip)
+	}
+	return nil
+}
+
+// newClient returns the client deliveries are sent with, which only
+// connects to public addresses, without proxy.
+func newClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout: DIAL_TIMEOUT,
+		Control: dialPublic,
+	}).DialContext
+	return &http.Client{Transport: transport, Timeout: DELIVERY_TIMEOUT}
+}
@@ -0,0 +1,75 @@
+// Package notify delivers BillBo events to the webhook endpoints merchants
+// register from the dashboard.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"billbo.com/backend/database/sqlcgen"
+	"billbo.com/backend/outbox"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// EventTypes are the outbox events merchants can subscribe to.
+var EventTypes = []string{
+	outbox.EventInvoiceFinalized,
+	outbox.EventInvoicePaid,
+	outbox.EventUsageThresholdCrossed,
+	outbox.EventAPIKeyRevoked,
+}
+
+// Envelope is the body of every webhook request. ID is the outbox event ID:
+// receivers use it to discard duplicate deliveries.
+type Envelope struct {
+	ID        string          `json:"ID"`
+	Type      string          `json:"Type"`
+	CreatedAt string          `json:"CreatedAt"`
+	Data      json.RawMessage `json:"Data"`
+}
+
+// Subscriber returns the outbox handler queueing one delivery of each event
+// per endpoint of the merchant subscribed to its type. The deliveries are
+// queued in the relay transaction, so that relaying an event again does not
+// queue them twice.
+func Subscriber() outbox.HandlerFunc {
+	return func(ctx context.Context, queries *sqlcgen.Queries, event *outbox.Event) error {
+		merchantID := pgtype.UUID{Bytes: event.MerchantID, Valid: true}
+		endpoints, err := queries.ListWebhookEndpointsForEvent(ctx, sqlcgen.ListWebhookEndpointsForEventParams{
+			MerchantID: merchantID,
+			EventType:  event.Type,
+		})
+		if err != nil {
+			return fmt.Errorf("queries.ListWebhookEndpointsForEvent: %w", err)
+		}
+		if len(endpoints) == 0 {
+			return nil
+		}
+
+		payload, err := json.Marshal(Envelope{
+			ID:        event.ID.String(),
+			Type:      event.Type,
+			CreatedAt: event.CreatedAt.UTC().Format(time.RFC3339),
+			Data:      event.Payload,
+		})
+		if err != nil {
+			return fmt.Errorf("json.Marshal: %w", err)
+		}
+
+		for _, endpoint := range endpoints {
+			_, err := queries.CreateWebhookDelivery(ctx, sqlcgen.CreateWebhookDeliveryParams{
+				EndpointID: endpoint.ID,
+				MerchantID: merchantID,
+				EventID:    pgtype.UUID{Bytes: event.ID, Valid: true},
+				EventType:  event.Type,
+				Payload:    payload,
+			})
+			if err != nil {
+				return fmt.Errorf("queries.CreateWebhookDelivery: %w", err)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"billbo.com/backend/database/dbtest"
+	"billbo.com/backend/database/sqlcgen"
+	"billbo.com/backend/outbox"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+)
+
+func TestSubscriberQueuesDeliveriesOnce(t *testing.T) {
+	pool := dbtest.NewPool(t)
+	ctx := context.Background()
+	queries := sqlcgen.New(pool)
+
+	merchantID := pgtype.UUID{Bytes: dbtest.CreateMerchant(t, pool), Valid: true}
+	endpoint, err := queries.CreateWebhookEndpoint(ctx, sqlcgen.CreateWebhookEndpointParams{
+		MerchantID: merchantID,
+		Url:        "https://example.com/webhooks",
+		Secret:     testSecret,
+		EventTypes: []string{},
+	})
+	if err != nil {
+		t.Fatalf("queries.CreateWebhookEndpoint: %v", err)
+	}
+	if err := outbox.Emit(ctx, queries, merchantID, outbox.EventInvoicePaid, map[string]string{}); err != nil {
+		t.Fatalf("outbox.Emit: %v", err)
+	}
+
+	// Another subscriber fails the first relay of the event, which is
+	// relayed again.
+	failed := false
+	relay := outbox.NewRelay(zap.NewNop(), pool)
+	relay.Subscribe("webhooks", EventTypes, Subscriber())
+	relay.Subscribe("flaky", EventTypes, func(ctx context.Context, _ *sqlcgen.Queries, _ *outbox.Event) error {
+		if !failed {
+			failed = true
+			return errors.New("unavailable")
+		}
+		return nil
+	})
+
+	for range 2 {
+		if err := relay.RelayPending(ctx); err != nil {
+			t.Fatalf("relay.RelayPending: %v", err)
+		}
+		_, err := pool.Exec(ctx,
+			`UPDATE outbox_events SET next_attempt_at = now() WHERE merchant_id = $1 AND published_at IS NULL`,
+			merchantID,
+		)
+		if err != nil {
+			t.Fatalf("update outbox events: %v", err)
+		}
+	}
+
+	deliveries, err := queries.ListWebhookDeliveriesByEndpoint(ctx, sqlcgen.ListWebhookDeliveriesByEndpointParams{
+		EndpointID: endpoint.ID,
+		MerchantID: merchantID,
+	})
+	if err != nil {
+		t.Fatalf("queries.ListWebhookDeliveriesByEndpoint: %v", err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(deliveries))
+	}
+}
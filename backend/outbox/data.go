@@ -0,0 +1,76 @@
+package outbox
+
+import (
+	"time"
+
+	"billbo.com/backend/database/sqlcgen"
+)
+
+// Event payloads. They are part of the outbound webhook contract: fields may
+// be added, not renamed or removed.
+
+type InvoiceData struct {
+	ID         string  `json:"ID"`
+	Number     *string `json:"Number"`
+	CustomerID string  `json:"CustomerID"`
+	Status     string  `json:"Status"`
+	Total      float64 `json:"Total"`
+	DueAt      *string `json:"DueAt"`
+}
+
+func NewInvoiceData(row *sqlcgen.Invoice) *InvoiceData {
+	d := &InvoiceData{
+		ID:         row.ID.String(),
+		CustomerID: row.CustomerID.String(),
+		Status:     row.Status,
+		Total:      row.Total,
+	}
+	if row.Number.Valid {
+		d.Number = &row.Number.String
+	}
+	if row.DueAt.Valid {
+		s := row.DueAt.Time.Format(time.RFC3339)
+		d.DueAt = &s
+	}
+	return d
+}
+
+type APIKeyData struct {
+	ID        string `json:"ID"`
+	Name      string `json:"Name"`
+	KeyPrefix string `json:"KeyPrefix"`
+}
+
+func NewAPIKeyData(row *sqlcgen.ApiKey) *APIKeyData {
+	return &APIKeyData{
+		ID:        row.ID.String(),
+		Name:      row.Name,
+		KeyPrefix: row.KeyPrefix,
+	}
+}
+
+type SKUData struct {
+	ID   string `json:"ID"`
+	Name string `json:"Name"`
+}
+
+func NewSKUData(row *sqlcgen.Sku) *SKUData {
+	return &SKUData{
+		ID:   row.ID.String(),
+		Name: row.Name,
+	}
+}
+
+// UsageThresholdData reports the first time in a billing period a customer's
+// usage reaches Threshold percent of an alert's budget. SKUID is nil for
+// alerts on total spend.
+type UsageThresholdData struct {
+	AlertID     string  `json:"AlertID"`
+	CustomerID  string  `json:"CustomerID"`
+	SKUID       *string `json:"SKUID"`
+	Threshold   int32   `json:"Threshold"`
+	Budget      float64 `json:"Budget"`
+	Usage       float64 `json:"Usage"`
+	PeriodStart string  `json:"PeriodStart"`
+	PeriodEnd   string  `json:"PeriodEnd"`
+}
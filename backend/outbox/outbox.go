@@ -0,0 +1,63 @@
+// Package outbox implements a transactional outbox: events are written in the
+// transaction of the change they describe, then relayed to in-process
+// subscribers by a background Relay.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Event types.
+const (
+	EventAPIKeyCreated         = "api_key.created"
+	EventAPIKeyRevoked         = "api_key.revoked"
+	EventSKURevoked            = "sku.revoked"
+	EventInvoiceFinalized      = "invoice.finalized"
+	EventInvoicePaid           = "invoice.paid"
+	EventUsageThresholdCrossed = "usage.threshold_crossed"
+)
+
+type Event struct {
+	ID         uuid.UUID
+	MerchantID uuid.UUID
+	Type       string
+	// Payload is the JSON encoding of the data passed to Emit.
+	Payload   json.RawMessage
+	CreatedAt time.Time
+}
+
+func eventFromDB(row *sqlcgen.OutboxEvent) *Event {
+	return &Event{
+		ID:         row.ID.Bytes,
+		MerchantID: row.MerchantID.Bytes,
+		Type:       row.EventType,
+		Payload:    row.Payload,
+		CreatedAt:  row.CreatedAt.Time,
+	}
+}
+
+// Emit writes an event to the outbox. queries must be bound to the
+// transaction of the change so that the event is published if and only if
+// the change commits.
+func Emit(ctx context.Context, queries *sqlcgen.Queries, merchantID pgtype.UUID, eventType string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	_, err = queries.InsertOutboxEvent(ctx, sqlcgen.InsertOutboxEventParams{
+		MerchantID: merchantID,
+		EventType:  eventType,
+		Payload:    payload,
+	})
+	if err != nil {
+		return fmt.Errorf("queries.InsertOutboxEvent: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+const (
+	RELAY_BATCH_SIZE  = 100
+	RELAY_MAX_BACKOFF = time.Hour
+)
+
+// HandlerFunc processes an event. Events are delivered at least once: an
+// event is handed again to all its subscribers until every one of them
+// succeeds, so handlers must be idempotent. queries is bound to the relay
+// transaction: what handlers write through it is committed along with the
+// event being published, or not at all, so those writes happen once.
+type HandlerFunc func(ctx context.Context, queries *sqlcgen.Queries, event *Event) error
+
+type subscription struct {
+	name       string
+	eventTypes []string
+	handler    HandlerFunc
+}
+
+// Relay publishes outbox events to the subscribers registered with
+// Subscribe.
+type Relay struct {
+	logger        *zap.Logger
+	pool          *pgxpool.Pool
+	subscriptions []subscription
+}
+
+func NewRelay(logger *zap.Logger, pool *pgxpool.Pool) *Relay {
+	return &Relay{
+		logger: logger.With(zap.String("component", "outbox")),
+		pool:   pool,
+	}
+}
+
+// Subscribe registers a handler for some event types. It must be called
+// before RelayPending.
+func (r *Relay) Subscribe(name string, eventTypes []string, handler HandlerFunc) {
+	r.subscriptions = append(r.subscriptions, subscription{
+		name:       name,
+		eventTypes: eventTypes,
+		handler:    handler,
+	})
+}
+
+// RelayPending publishes the pending events that are due.
+func (r *Relay) RelayPending(ctx context.Context) error {
+	for {
+		n, err := r.relayBatch(ctx)
+		if err != nil {
+			return fmt.Errorf("relayBatch: %w", err)
+		}
+		if n < RELAY_BATCH_SIZE {
+			return nil
+		}
+	}
+}
+
+// relayBatch publishes the oldest pending events. Claimed rows stay locked
+// until they are marked, so concurrent relays skip them.
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("pool.Begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	q := sqlcgen.New(r.pool).WithTx(tx)
+	rows, err := q.ClaimOutboxEvents(ctx, RELAY_BATCH_SIZE)
+	if err != nil {
+		return 0, fmt.Errorf("queries.ClaimOutboxEvents: %w", err)
+	}
+
+	for _, row := range rows {
+		if err := r.publish(ctx, tx, eventFromDB(row)); err != nil {
+			backoff := min(time.Duration(1<<min(row.Attempts, 12))*time.Second, RELAY_MAX_BACKOFF)
+			r.logger.Warn("failed to publish outbox event",
+				zap.String("event_id", row.ID.String()),
+				zap.String("event_type", row.EventType),
+				zap.Int32("attempt", row.Attempts+1),
+				zap.Duration("retry_in", backoff),
+				zap.Error(err),
+			)
+			err = q.MarkOutboxEventFailed(ctx, sqlcgen.MarkOutboxEventFailedParams{
+				ID:            row.ID,
+				LastError:     pgtype.Text{String: err.Error(), Valid: true},
+				NextAttemptAt: pgtype.Timestamptz{Time: time.Now().Add(backoff), Valid: true},
+			})
+			if err != nil {
+				return 0, fmt.Errorf("queries.MarkOutboxEventFailed: %w", err)
+			}
+			continue
+		}
+
+		if err := q.MarkOutboxEventPublished(ctx, row.ID); err != nil {
+			return 0, fmt.Errorf("queries.MarkOutboxEventPublished: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("tx.Commit: %w", err)
+	}
+	return len(rows), nil
+}
+
+// publish hands the event to its subscribers within a savepoint of the relay
+// transaction, released only if all of them succeed.
+func (r *Relay) publish(ctx context.Context, tx pgx.Tx, event *Event) error {
+	savepoint, err := tx.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("tx.Begin: %w", err)
+	}
+	defer savepoint.Rollback(ctx)
+
+	q := sqlcgen.New(r.pool).WithTx(savepoint)
+	var errs []error
+	for _, s := range r.subscriptions {
+		if !slices.Contains(s.eventTypes, event.Type) {
+			continue
+		}
+		if err := s.handler(ctx, q, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	if err := savepoint.Commit(ctx); err != nil {
+		return fmt.Errorf("savepoint.Commit: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+// Package partitions maintains the monthly partitions of the events table:
+// it creates them ahead of time and drops them once past retention.
+package partitions
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+const (
+	// PARTITIONS_AHEAD is the number of months, after the current one,
+	// partitioned ahead of time.
+	PARTITIONS_AHEAD = 3
+
+	// PARTITION_NAME_LAYOUT names the partition of a month, e.g. events_2026_10.
+	PARTITION_NAME_LAYOUT = "events_2006_01"
+)
+
+// Partition is the partition of the events of a month (in UTC), [Start, End).
+type Partition struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+}
+
+// MonthPartition returns the partition of the month t falls in.
+func MonthPartition(t time.Time) Partition {
+	t = t.UTC()
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return Partition{
+		Name:  start.Format(PARTITION_NAME_LAYOUT),
+		Start: start,
+		End:   start.AddDate(0, 1, 0),
+	}
+}
+
+// ParsePartition returns the partition of the given name.
+func ParsePartition(name string) (Partition, error) {
+	start, err := time.Parse(PARTITION_NAME_LAYOUT, name)
+	if err != nil {
+		return Partition{}, fmt.Errorf("ParsePartition: %w", err)
+	}
+	return MonthPartition(start), nil
+}
+
+// Maintainer creates and drops the partitions of the events table.
+type Maintainer struct {
+	logger *zap.Logger
+	pool   *pgxpool.Pool
+}
+
+func NewMaintainer(logger *zap.Logger, pool *pgxpool.Pool) *Maintainer {
+	return &Maintainer{
+		logger: logger.With(zap.String("component", "partitions")),
+		pool:   pool,
+	}
+}
+
+// Maintain creates the partitions of the current and next months, and of the
+// months with events in the default partition, then drops the partitions of
+// past months whose events are all invoiced and past their merchant's
+// retention window.
+//
+// Partitions hold the events of every merchant, so one is only dropped once
+// the longest retention window among its merchants is over: the events of
+// merchants without a retention setting are kept forever.
+func (m *Maintainer) Maintain(ctx context.Context) error {
+	queries := sqlcgen.New(m.pool)
+
+	names, err := queries.ListEventPartitions(ctx)
+	if err != nil {
+		return fmt.Errorf("queries.ListEventPartitions: %w", err)
+	}
+	existing := make(map[string]bool, len(names))
+	for _, name := range names {
+		existing[name] = true
+	}
+
+	now := time.Now()
+	var wanted []Partition
+	for i := range PARTITIONS_AHEAD + 1 {
+		wanted = append(wanted, MonthPartition(now.AddDate(0, i, 0)))
+	}
+	months, err := queries.ListDefaultPartitionMonths(ctx)
+	if err != nil {
+		return fmt.Errorf("queries.ListDefaultPartitionMonths: %w", err)
+	}
+	for _, month := range months {
+		wanted = append(wanted, MonthPartition(month.Time))
+	}
+
+	for _, p := range wanted {
+		if existing[p.Name] {
+			continue
+		}
+		if err := m.createPartition(ctx, p); err != nil {
+			return fmt.Errorf("createPartition: %w", err)
+		}
+		existing[p.Name] = true
+		m.logger.Info("created events partition", zap.String("partition", p.Name))
+	}
+
+	current := MonthPartition(now)
+	for _, name := range names {
+		p, err := ParsePartition(name)
+		if err != nil {
+			m.logger.Warn("unexpected events partition", zap.String("partition", name))
+			continue
+		}
+		if p.End.After(current.Start) {
+			continue
+		}
+		dropped, err := m.dropPartitionIfExpired(ctx, p)
+		if err != nil {
+			return fmt.Errorf("dropPartitionIfExpired: %w", err)
+		}
+		if dropped {
+			m.logger.Info("dropped events partition", zap.String("partition", p.Name))
+		}
+	}
+	return nil
+}
+
+// createPartition creates the partition p, moving its events out of the
+// default partition: a partition cannot be attached while the default one
+// holds rows in its range.
+func (m *Maintainer) createPartition(ctx context.Context, p Partition) error {
+	tx, err := m.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("pool.Begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	name := pgx.Identifier{p.Name}.Sanitize()
+	_, err = tx.Exec(ctx, fmt.Sprintf("CREATE TABLE %s (LIKE events INCLUDING DEFAULTS)", name))
+	if err != nil {
+		return fmt.Errorf("tx.Exec: create table: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+		WITH moved AS (
+			DELETE FROM events_default
+			WHERE sent_at >= $1 AND sent_at < $2
+			RETURNING *
+		)
+		INSERT INTO %s SELECT * FROM moved`, name),
+		p.Start, p.End,
+	)
+	if err != nil {
+		return fmt.Errorf("tx.Exec: move events: %w", err)
+	}
+
+	// Attaching creates the partition's indexes from the events ones.
+	_, err = tx.Exec(ctx, fmt.Sprintf(
+		"ALTER TABLE events ATTACH PARTITION %s FOR VALUES FROM ('%s') TO ('%s')",
+		name, p.Start.Format(time.RFC3339), p.End.Format(time.RFC3339),
+	))
+	if err != nil {
+		return fmt.Errorf("tx.Exec: attach partition: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("tx.Commit: %w", err)
+	}
+	return nil
+}
+
+// dropPartitionIfExpired detaches and drops the partition p if its events
+// can be dropped. The partition is locked against writes first, so that no
+// late event lands in it once checked.
+func (m *Maintainer) dropPartitionIfExpired(ctx context.Context, p Partition) (bool, error) {
+	tx, err := m.pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("pool.Begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	name := pgx.Identifier{p.Name}.Sanitize()
+	_, err = tx.Exec(ctx, fmt.Sprintf("LOCK TABLE %s IN SHARE MODE", name))
+	if err != nil {
+		return false, fmt.Errorf("tx.Exec: lock partition: %w", err)
+	}
+
+	queries := sqlcgen.New(m.pool).WithTx(tx)
+	rangeStart := pgtype.Timestamptz{Time: p.Start, Valid: true}
+	rangeEnd := pgtype.Timestamptz{Time: p.End, Valid: true}
+	expired, err := queries.IsEventRangeExpired(ctx, sqlcgen.IsEventRangeExpiredParams{
+		RangeStart: rangeStart,
+		RangeEnd:   rangeEnd,
+	})
+	if err != nil {
+		return false, fmt.Errorf("queries.IsEventRangeExpired: %w", err)
+	}
+	if !expired {
+		// Retention only drops archived events: without archiving, a
+		// partition past every retention window is kept forever.
+		merchantIDs, err := queries.ListUnarchivedExpiredEventMerchants(ctx, sqlcgen.ListUnarchivedExpiredEventMerchantsParams{
+			RangeStart: rangeStart,
+			RangeEnd:   rangeEnd,
+		})
+		if err != nil {
+			return false, fmt.Errorf("queries.ListUnarchivedExpiredEventMerchants: %w", err)
+		}
+		if len(merchantIDs) > 0 {
+			ids := make([]string, len(merchantIDs))
+			for i, id := range merchantIDs {
+				ids[i] = id.String()
+			}
+			m.logger.Warn("events partition past retention held back by unarchived events",
+				zap.String("partition", p.Name),
+				zap.Strings("merchant_ids", ids),
+			)
+		}
+		return false, nil
+	}
+
+	_, err = tx.Exec(ctx, fmt.Sprintf("ALTER TABLE events DETACH PARTITION %s", name))
+	if err != nil {
+		return false, fmt.Errorf("tx.Exec: detach partition: %w", err)
+	}
+	_, err = tx.Exec(ctx, fmt.Sprintf("DROP TABLE %s", name))
+	if err != nil {
+		return false, fmt.Errorf("tx.Exec: drop table: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("tx.Commit: %w", err)
+	}
+	return true, nil
+}
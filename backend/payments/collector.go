@@ -0,0 +1,251 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"billbo.com/backend/billing"
+	"billbo.com/backend/database/sqlcgen"
+	"billbo.com/backend/outbox"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvoiceNotCollectible = errors.New("invoice is not collectible")
+	ErrInvoiceNotPaid        = errors.New("invoice is not paid")
+	ErrCustomerNotFound      = errors.New("customer has no billing details")
+	ErrUnknownPayment        = errors.New("payment does not match any invoice")
+	ErrTestMode              = errors.New("test mode invoices are not sent to payment providers")
+)
+
+// Collector pushes finalized invoices to a PaymentProvider and applies the
+// payment events it reports back to the invoices.
+type Collector struct {
+	logger   *zap.Logger
+	queries  *sqlcgen.Queries
+	provider PaymentProvider
+	currency string
+}
+
+func NewCollector(
+	logger *zap.Logger,
+	queries *sqlcgen.Queries,
+	provider PaymentProvider,
+	currency string,
+) *Collector {
+	return &Collector{
+		logger: logger.With(
+			zap.String("component", "payments"),
+			zap.String("provider", provider.Name()),
+		),
+		queries:  queries,
+		provider: provider,
+		currency: currency,
+	}
+}
+
+func (c *Collector) Provider() PaymentProvider {
+	return c.provider
+}
+
+// CollectInvoice creates the customer with the provider if needed, then asks
+// the provider to collect the invoice. An invoice is only pushed once, and
+// never for a test merchant.
+func (c *Collector) CollectInvoice(ctx context.Context, merchantID, invoiceID uuid.UUID) (*sqlcgen.Invoice, error) {
+	invoice, err := c.queries.GetInvoice(ctx, sqlcgen.GetInvoiceParams{
+		ID:         pgtype.UUID{Bytes: invoiceID, Valid: true},
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, billing.ErrInvoiceNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("queries.GetInvoice: %w", err)
+	}
+	if invoice.Status != billing.InvoiceStatusFinalized || invoice.PaymentID.Valid {
+		return nil, ErrInvoiceNotCollectible
+	}
+
+	testMode, err := c.queries.IsTestMerchant(ctx, invoice.MerchantID)
+	if err != nil {
+		return nil, fmt.Errorf("queries.IsTestMerchant: %w", err)
+	}
+	if testMode {
+		return nil, ErrTestMode
+	}
+
+	customer, err := c.queries.GetCustomer(ctx, sqlcgen.GetCustomerParams{
+		ID:         invoice.CustomerID,
+		MerchantID: invoice.MerchantID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrCustomerNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("queries.GetCustomer: %w", err)
+	}
+
+	customerID := customer.PaymentCustomerID.String
+	if !customer.PaymentCustomerID.Valid {
+		customerID, err = c.provider.CreateCustomer(ctx, CreateCustomerParams{
+			ReferenceID: customer.ID.String(),
+			Name:        customer.Name,
+			Email:       customer.Email.String,
+			Address: Address{
+				Line1:      customer.AddressLine1.String,
+				Line2:      customer.AddressLine2.String,
+				City:       customer.City.String,
+				PostalCode: customer.PostalCode.String,
+				Region:     customer.Region.String,
+				Country:    customer.Country.String,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("provider.CreateCustomer: %w", err)
+		}
+		err = c.queries.SetCustomerPaymentCustomerID(ctx, sqlcgen.SetCustomerPaymentCustomerIDParams{
+			ID:                customer.ID,
+			MerchantID:        customer.MerchantID,
+			PaymentCustomerID: pgtype.Text{String: customerID, Valid: true},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("queries.SetCustomerPaymentCustomerID: %w", err)
+		}
+	}
+
+	payment, err := c.provider.CreatePayment(ctx, CreatePaymentParams{
+		InvoiceID:     invoice.ID.String(),
+		InvoiceNumber: invoice.Number.String,
+		CustomerID:    customerID,
+		Amount:        int64(math.Round(invoice.Total * 100)),
+		Currency:      c.currency,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("provider.CreatePayment: %w", err)
+	}
+
+	invoice, err = c.queries.SetInvoicePayment(ctx, sqlcgen.SetInvoicePaymentParams{
+		ID:              invoice.ID,
+		PaymentProvider: pgtype.Text{String: c.provider.Name(), Valid: true},
+		PaymentID:       pgtype.Text{String: payment.ID, Valid: true},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Another request pushed the invoice concurrently. The provider
+		// call is idempotent per invoice, so both refer to the same payment.
+		return nil, ErrInvoiceNotCollectible
+	}
+	if err != nil {
+		return nil, fmt.Errorf("queries.SetInvoicePayment: %w", err)
+	}
+
+	c.logger.Info("invoice pushed for collection",
+		zap.String("invoice_id", invoice.ID.String()),
+		zap.String("payment_id", payment.ID),
+		zap.String("payment_status", string(payment.Status)),
+	)
+	return invoice, nil
+}
+
+// RefundInvoice refunds a paid invoice. The invoice is marked refunded when
+// the provider confirms the refund.
+func (c *Collector) RefundInvoice(ctx context.Context, merchantID, invoiceID uuid.UUID) error {
+	invoice, err := c.queries.GetInvoice(ctx, sqlcgen.GetInvoiceParams{
+		ID:         pgtype.UUID{Bytes: invoiceID, Valid: true},
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return billing.ErrInvoiceNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("queries.GetInvoice: %w", err)
+	}
+	if invoice.Status != billing.InvoiceStatusPaid || invoice.PaymentProvider.String != c.provider.Name() {
+		return ErrInvoiceNotPaid
+	}
+
+	if err := c.provider.Refund(ctx, invoice.PaymentID.String); err != nil {
+		return fmt.Errorf("provider.Refund: %w", err)
+	}
+	return nil
+}
+
+// ApplyEvent moves the invoice a payment event refers to into the matching
+// status. Events the invoice already moved past are skipped. queries lets
+// the caller run it within its own transaction.
+func (c *Collector) ApplyEvent(ctx context.Context, queries *sqlcgen.Queries, event *Event) (*sqlcgen.Invoice, error) {
+	var status string
+	switch event.Type {
+	case EventPaymentSucceeded:
+		status = billing.InvoiceStatusPaid
+	case EventPaymentFailed:
+		status = billing.InvoiceStatusPaymentFailed
+	case EventPaymentRefunded:
+		status = billing.InvoiceStatusRefunded
+	default:
+		return nil, fmt.Errorf("ApplyEvent: %w: %s", ErrUnhandledEvent, event.Type)
+	}
+
+	invoice, err := queries.GetInvoiceByPayment(ctx, sqlcgen.GetInvoiceByPaymentParams{
+		PaymentProvider: pgtype.Text{String: c.provider.Name(), Valid: true},
+		PaymentID:       pgtype.Text{String: event.PaymentID, Valid: true},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("ApplyEvent: %w: %s", ErrUnknownPayment, event.PaymentID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("queries.GetInvoiceByPayment: %w", err)
+	}
+
+	updated, err := queries.UpdateInvoicePaymentStatus(ctx, sqlcgen.UpdateInvoicePaymentStatusParams{
+		ID:     invoice.ID,
+		Status: status,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// The invoice already moved past the event, e.g. a failure reported
+		// after the invoice was paid or marked uncollectible.
+		c.logger.Info("payment event skipped",
+			zap.String("invoice_id", invoice.ID.String()),
+			zap.String("event_id", event.ID),
+			zap.String("invoice_status", invoice.Status),
+			zap.String("event_status", status),
+		)
+		return invoice, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("queries.UpdateInvoicePaymentStatus: %w", err)
+	}
+	invoice = updated
+
+	switch status {
+	case billing.InvoiceStatusPaid:
+		err = queries.CompleteInvoiceDunning(ctx, sqlcgen.CompleteInvoiceDunningParams{
+			InvoiceID: invoice.ID,
+			Outcome:   pgtype.Text{String: DunningOutcomePaid, Valid: true},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("queries.CompleteInvoiceDunning: %w", err)
+		}
+
+		err = outbox.Emit(ctx, queries, invoice.MerchantID, outbox.EventInvoicePaid, outbox.NewInvoiceData(invoice))
+		if err != nil {
+			return nil, fmt.Errorf("outbox.Emit: %w", err)
+		}
+	case billing.InvoiceStatusPaymentFailed:
+		invoice, err = c.dunPaymentFailure(ctx, queries, invoice)
+		if err != nil {
+			return nil, fmt.Errorf("dunPaymentFailure: %w", err)
+		}
+	}
+
+	c.logger.Info("invoice payment status updated",
+		zap.String("invoice_id", invoice.ID.String()),
+		zap.String("event_id", event.ID),
+		zap.String("status", status),
+	)
+	return invoice, nil
+}
@@ -0,0 +1,110 @@
+package payments_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"billbo.com/backend/billing"
+	"billbo.com/backend/database/dbtest"
+	"billbo.com/backend/database/sqlcgen"
+	"billbo.com/backend/payments"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestCollectorPaidAndRefunded(t *testing.T) {
+	pool := dbtest.NewPool(t)
+	collector, provider := newTestCollector(pool)
+	ctx := context.Background()
+	queries := sqlcgen.New(pool)
+
+	invoice := collect(t, collector, createFinalizedInvoice(t, pool))
+	if _, err := collector.CollectInvoice(ctx, invoice.MerchantID.Bytes, invoice.ID.Bytes); !errors.Is(err, payments.ErrInvoiceNotCollectible) {
+		t.Fatalf("collecting twice: got %v, want ErrInvoiceNotCollectible", err)
+	}
+
+	paid, err := collector.ApplyEvent(ctx, queries, webhookEvent(t, provider, payments.EventPaymentSucceeded, invoice.PaymentID.String))
+	if err != nil {
+		t.Fatalf("collector.ApplyEvent: %v", err)
+	}
+	if paid.Status != billing.InvoiceStatusPaid || !paid.PaidAt.Valid {
+		t.Fatalf("got status %q, paid at %v, want paid", paid.Status, paid.PaidAt)
+	}
+
+	if err := collector.RefundInvoice(ctx, invoice.MerchantID.Bytes, invoice.ID.Bytes); err != nil {
+		t.Fatalf("collector.RefundInvoice: %v", err)
+	}
+	refunded, err := collector.ApplyEvent(ctx, queries, webhookEvent(t, provider, payments.EventPaymentRefunded, invoice.PaymentID.String))
+	if err != nil {
+		t.Fatalf("collector.ApplyEvent: %v", err)
+	}
+	if refunded.Status != billing.InvoiceStatusRefunded {
+		t.Fatalf("got status %q, want refunded", refunded.Status)
+	}
+	if err := collector.RefundInvoice(ctx, invoice.MerchantID.Bytes, invoice.ID.Bytes); !errors.Is(err, payments.ErrInvoiceNotPaid) {
+		t.Fatalf("refunding twice: got %v, want ErrInvoiceNotPaid", err)
+	}
+
+	// A failure reported late leaves the refunded invoice as it is, without
+	// dunning it.
+	late, err := collector.ApplyEvent(ctx, queries, webhookEvent(t, provider, payments.EventPaymentFailed, invoice.PaymentID.String))
+	if err != nil {
+		t.Fatalf("collector.ApplyEvent: %v", err)
+	}
+	if late.Status != billing.InvoiceStatusRefunded {
+		t.Fatalf("got status %q after a late failure, want refunded", late.Status)
+	}
+	if _, err := queries.GetInvoiceDunning(ctx, invoice.ID); !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("queries.GetInvoiceDunning: got %v, want no dunning", err)
+	}
+}
+
+func TestCollectorPaymentFailed(t *testing.T) {
+	pool := dbtest.NewPool(t)
+	collector, provider := newTestCollector(pool)
+	ctx := context.Background()
+	queries := sqlcgen.New(pool)
+
+	invoice := collect(t, collector, createFinalizedInvoice(t, pool))
+
+	failed, err := collector.ApplyEvent(ctx, queries, webhookEvent(t, provider, payments.EventPaymentFailed, invoice.PaymentID.String))
+	if err != nil {
+		t.Fatalf("collector.ApplyEvent: %v", err)
+	}
+	if failed.Status != billing.InvoiceStatusPaymentFailed {
+		t.Fatalf("got status %q, want payment_failed", failed.Status)
+	}
+	dunning, err := queries.GetInvoiceDunning(ctx, invoice.ID)
+	if err != nil {
+		t.Fatalf("queries.GetInvoiceDunning: %v", err)
+	}
+	if dunning.CompletedAt.Valid || !dunning.NextAttemptAt.Valid {
+		t.Fatal("dunning is not scheduled")
+	}
+
+	// A later success ends the dunning.
+	paid, err := collector.ApplyEvent(ctx, queries, webhookEvent(t, provider, payments.EventPaymentSucceeded, invoice.PaymentID.String))
+	if err != nil {
+		t.Fatalf("collector.ApplyEvent: %v", err)
+	}
+	if paid.Status != billing.InvoiceStatusPaid {
+		t.Fatalf("got status %q, want paid", paid.Status)
+	}
+	dunning, err = queries.GetInvoiceDunning(ctx, invoice.ID)
+	if err != nil {
+		t.Fatalf("queries.GetInvoiceDunning: %v", err)
+	}
+	if !dunning.CompletedAt.Valid || dunning.Outcome.String != payments.DunningOutcomePaid {
+		t.Fatalf("got dunning outcome %q, want paid", dunning.Outcome.String)
+	}
+}
+
+func TestCollectorUnknownPayment(t *testing.T) {
+	pool := dbtest.NewPool(t)
+	collector, provider := newTestCollector(pool)
+
+	_, err := collector.ApplyEvent(context.Background(), sqlcgen.New(pool), webhookEvent(t, provider, payments.EventPaymentSucceeded, "pay_unknown"))
+	if !errors.Is(err, payments.ErrUnknownPayment) {
+		t.Fatalf("got %v, want ErrUnknownPayment", err)
+	}
+}
@@ -0,0 +1,288 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"billbo.com/backend/billing"
+	"billbo.com/backend/database"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// Dunning outcomes.
+const (
+	DunningOutcomePaid          = "paid"
+	DunningOutcomeUncollectible = "uncollectible"
+	// DunningOutcomeClosed ends dunnings of invoices that left the
+	// payment_failed status otherwise, e.g. refunded.
+	DunningOutcomeClosed = "closed"
+)
+
+const (
+	// DUNNING_ERROR_DELAY postpones a retry the provider could not process.
+	DUNNING_ERROR_DELAY = time.Hour
+	// DUNNING_MAX_ERRORS bounds the consecutive provider errors of a retry,
+	// e.g. for a payment the provider does not know, after which it counts
+	// as failed.
+	DUNNING_MAX_ERRORS = 24
+	// DUNNING_OUTCOME_DEADLINE is how long dunning waits for the outcome of
+	// the last retry, e.g. a payment requiring the customer's action, before
+	// marking the invoice uncollectible.
+	DUNNING_OUTCOME_DEADLINE = 3 * 24 * time.Hour
+)
+
+// DefaultDunningSettings returns the settings of a merchant who never saved
+// any, matching the column defaults of dunning_settings. They are not
+// stored.
+func DefaultDunningSettings(merchantID pgtype.UUID) *sqlcgen.DunningSetting {
+	return &sqlcgen.DunningSetting{
+		MerchantID: merchantID,
+		RetryDays:  []int32{3, 5, 7},
+	}
+}
+
+// dunningSettings returns the merchant's settings, or the defaults.
+func dunningSettings(ctx context.Context, queries *sqlcgen.Queries, merchantID pgtype.UUID) (*sqlcgen.DunningSetting, error) {
+	settings, err := queries.GetDunningSettings(ctx, merchantID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return DefaultDunningSettings(merchantID), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("queries.GetDunningSettings: %w", err)
+	}
+	return settings, nil
+}
+
+// dunPaymentFailure starts the dunning of an invoice on its first payment
+// failure. Once all retries of the schedule have failed, or their outcome
+// is still unknown at the deadline, the invoice is marked uncollectible.
+func (c *Collector) dunPaymentFailure(ctx context.Context, queries *sqlcgen.Queries, invoice *sqlcgen.Invoice) (*sqlcgen.Invoice, error) {
+	settings, err := dunningSettings(ctx, queries, invoice.MerchantID)
+	if err != nil {
+		return nil, fmt.Errorf("dunningSettings: %w", err)
+	}
+
+	dunning, err := queries.GetInvoiceDunning(ctx, invoice.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if len(settings.RetryDays) == 0 {
+			return c.markUncollectible(ctx, queries, invoice, settings)
+		}
+		err = queries.StartInvoiceDunning(ctx, sqlcgen.StartInvoiceDunningParams{
+			InvoiceID:  invoice.ID,
+			MerchantID: invoice.MerchantID,
+			RetryDays:  settings.RetryDays,
+			NextAttemptAt: pgtype.Timestamptz{
+				Time:  time.Now().AddDate(0, 0, int(settings.RetryDays[0])),
+				Valid: true,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("queries.StartInvoiceDunning: %w", err)
+		}
+		c.logger.Info("invoice dunning started", zap.String("invoice_id", invoice.ID.String()))
+		return invoice, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("queries.GetInvoiceDunning: %w", err)
+	}
+
+	// Failures of intermediate retries wait for the next one.
+	if dunning.CompletedAt.Valid || int(dunning.Step) < len(dunning.RetryDays) {
+		return invoice, nil
+	}
+	return c.markUncollectible(ctx, queries, invoice, settings)
+}
+
+func (c *Collector) markUncollectible(
+	ctx context.Context,
+	queries *sqlcgen.Queries,
+	invoice *sqlcgen.Invoice,
+	settings *sqlcgen.DunningSetting,
+) (*sqlcgen.Invoice, error) {
+	updated, err := queries.UpdateInvoicePaymentStatus(ctx, sqlcgen.UpdateInvoicePaymentStatusParams{
+		ID:     invoice.ID,
+		Status: billing.InvoiceStatusUncollectible,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Paid in the meantime.
+		return invoice, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("queries.UpdateInvoicePaymentStatus: %w", err)
+	}
+	invoice = updated
+
+	err = queries.CompleteInvoiceDunning(ctx, sqlcgen.CompleteInvoiceDunningParams{
+		InvoiceID: invoice.ID,
+		Outcome:   pgtype.Text{String: DunningOutcomeUncollectible, Valid: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("queries.CompleteInvoiceDunning: %w", err)
+	}
+
+	if settings.SuspendIngest {
+		err = queries.SuspendCustomerIngest(ctx, sqlcgen.SuspendCustomerIngestParams{
+			ID:         invoice.CustomerID,
+			MerchantID: invoice.MerchantID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("queries.SuspendCustomerIngest: %w", err)
+		}
+		err = queries.NotifyCustomerChanged(ctx, sqlcgen.NotifyCustomerChangedParams{
+			MerchantID: invoice.MerchantID,
+			CustomerID: invoice.CustomerID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("queries.NotifyCustomerChanged: %w", err)
+		}
+	}
+
+	c.logger.Info("invoice marked uncollectible",
+		zap.String("invoice_id", invoice.ID.String()),
+		zap.Bool("ingest_suspended", settings.SuspendIngest),
+	)
+	return invoice, nil
+}
+
+// DunningProcessor retries failed invoice payments on their dunning
+// schedule.
+type DunningProcessor struct {
+	logger    *zap.Logger
+	pool      *pgxpool.Pool
+	collector *Collector
+}
+
+func NewDunningProcessor(logger *zap.Logger, pool *pgxpool.Pool, collector *Collector) *DunningProcessor {
+	return &DunningProcessor{
+		logger:    logger.With(zap.String("component", "dunning")),
+		pool:      pool,
+		collector: collector,
+	}
+}
+
+// ProcessDue runs the dunning steps that are due.
+func (p *DunningProcessor) ProcessDue(ctx context.Context) error {
+	for {
+		processed, err := p.processNext(ctx)
+		if err != nil {
+			return fmt.Errorf("processNext: %w", err)
+		}
+		if !processed {
+			return nil
+		}
+	}
+}
+
+// processNext runs the next due dunning step, if any. The dunning row stays
+// locked during the provider call so that a step is never run twice.
+func (p *DunningProcessor) processNext(ctx context.Context) (bool, error) {
+	var processed bool
+	err := database.InTx(ctx, p.pool, func(q *sqlcgen.Queries) error {
+		dunning, err := q.ClaimDueInvoiceDunning(ctx)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("queries.ClaimDueInvoiceDunning: %w", err)
+		}
+		processed = true
+
+		invoice, err := q.GetInvoice(ctx, sqlcgen.GetInvoiceParams{
+			ID:         dunning.InvoiceID,
+			MerchantID: dunning.MerchantID,
+		})
+		if err != nil {
+			return fmt.Errorf("queries.GetInvoice: %w", err)
+		}
+		if invoice.Status != billing.InvoiceStatusPaymentFailed {
+			err = q.CompleteInvoiceDunning(ctx, sqlcgen.CompleteInvoiceDunningParams{
+				InvoiceID: dunning.InvoiceID,
+				Outcome:   pgtype.Text{String: DunningOutcomeClosed, Valid: true},
+			})
+			if err != nil {
+				return fmt.Errorf("queries.CompleteInvoiceDunning: %w", err)
+			}
+			return nil
+		}
+
+		step := int(dunning.Step)
+		if step >= len(dunning.RetryDays) {
+			// The deadline of the last retry passed without its outcome.
+			return p.markUncollectible(ctx, q, invoice)
+		}
+
+		var lastError pgtype.Text
+		payment, err := p.collector.provider.RetryPayment(ctx, invoice.PaymentID.String, step+1)
+		if err != nil {
+			p.logger.Warn("failed to retry payment",
+				zap.String("invoice_id", invoice.ID.String()),
+				zap.Int("step", step),
+				zap.Int32("errors", dunning.ErrorCount+1),
+				zap.Error(err),
+			)
+			lastError = pgtype.Text{String: err.Error(), Valid: true}
+			if dunning.ErrorCount+1 < DUNNING_MAX_ERRORS {
+				err = q.AdvanceInvoiceDunning(ctx, sqlcgen.AdvanceInvoiceDunningParams{
+					InvoiceID:     dunning.InvoiceID,
+					Step:          dunning.Step,
+					NextAttemptAt: pgtype.Timestamptz{Time: time.Now().Add(DUNNING_ERROR_DELAY), Valid: true},
+					LastError:     lastError,
+					ErrorCount:    dunning.ErrorCount + 1,
+				})
+				if err != nil {
+					return fmt.Errorf("queries.AdvanceInvoiceDunning: %w", err)
+				}
+				return nil
+			}
+			// The error persists: the retry failed.
+			payment = &Payment{Status: PaymentStatusFailed}
+		}
+
+		// Retries are scheduled in days after the first failure. After the
+		// last one, dunning waits for its outcome until the deadline.
+		last := step+1 >= len(dunning.RetryDays)
+		next := pgtype.Timestamptz{Time: time.Now().Add(DUNNING_OUTCOME_DEADLINE), Valid: true}
+		if !last {
+			next.Time = dunning.StartedAt.Time.AddDate(0, 0, int(dunning.RetryDays[step+1]))
+		}
+		err = q.AdvanceInvoiceDunning(ctx, sqlcgen.AdvanceInvoiceDunningParams{
+			InvoiceID:     dunning.InvoiceID,
+			Step:          dunning.Step + 1,
+			NextAttemptAt: next,
+			LastError:     lastError,
+		})
+		if err != nil {
+			return fmt.Errorf("queries.AdvanceInvoiceDunning: %w", err)
+		}
+		p.logger.Info("payment retried",
+			zap.String("invoice_id", invoice.ID.String()),
+			zap.Int("step", step+1),
+			zap.String("payment_status", string(payment.Status)),
+		)
+
+		// A retry the provider rejects synchronously may not be reported
+		// through a webhook.
+		if payment.Status == PaymentStatusFailed && last {
+			return p.markUncollectible(ctx, q, invoice)
+		}
+		return nil
+	})
+	return processed, err
+}
+
+func (p *DunningProcessor) markUncollectible(ctx context.Context, q *sqlcgen.Queries, invoice *sqlcgen.Invoice) error {
+	settings, err := dunningSettings(ctx, q, invoice.MerchantID)
+	if err != nil {
+		return fmt.Errorf("dunningSettings: %w", err)
+	}
+	if _, err := p.collector.markUncollectible(ctx, q, invoice, settings); err != nil {
+		return fmt.Errorf("markUncollectible: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package payments_test
+
+import (
+	"context"
+	"testing"
+
+	"billbo.com/backend/billing"
+	"billbo.com/backend/database/dbtest"
+	"billbo.com/backend/database/sqlcgen"
+	"billbo.com/backend/payments"
+	"billbo.com/backend/payments/fake"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// failPayment collects a new invoice and fails its payment, which starts
+// its dunning on the default schedule.
+func failPayment(t *testing.T, pool *pgxpool.Pool, collector *payments.Collector, provider *fake.Provider) *sqlcgen.Invoice {
+	t.Helper()
+	invoice := collect(t, collector, createFinalizedInvoice(t, pool))
+	invoice, err := collector.ApplyEvent(context.Background(), sqlcgen.New(pool), webhookEvent(t, provider, payments.EventPaymentFailed, invoice.PaymentID.String))
+	if err != nil {
+		t.Fatalf("collector.ApplyEvent: %v", err)
+	}
+	return invoice
+}
+
+// makeDue sets the dunning step of the invoice and its provider errors so
+// far, and makes it due.
+func makeDue(t *testing.T, pool *pgxpool.Pool, invoice *sqlcgen.Invoice, step, errorCount int) {
+	t.Helper()
+	_, err := pool.Exec(context.Background(),
+		`UPDATE invoice_dunnings SET step = $2, error_count = $3, next_attempt_at = now() WHERE invoice_id = $1`,
+		invoice.ID, step, errorCount,
+	)
+	if err != nil {
+		t.Fatalf("update dunning: %v", err)
+	}
+}
+
+func processDue(t *testing.T, processor *payments.DunningProcessor) {
+	t.Helper()
+	if err := processor.ProcessDue(context.Background()); err != nil {
+		t.Fatalf("processor.ProcessDue: %v", err)
+	}
+}
+
+func getDunning(t *testing.T, pool *pgxpool.Pool, invoice *sqlcgen.Invoice) *sqlcgen.InvoiceDunning {
+	t.Helper()
+	dunning, err := sqlcgen.New(pool).GetInvoiceDunning(context.Background(), invoice.ID)
+	if err != nil {
+		t.Fatalf("queries.GetInvoiceDunning: %v", err)
+	}
+	return dunning
+}
+
+func TestDunningDeadline(t *testing.T) {
+	pool := dbtest.NewPool(t)
+	collector, provider := newTestCollector(pool)
+	processor := payments.NewDunningProcessor(zap.NewNop(), pool, collector)
+
+	// The fake provider leaves every retry pending.
+	invoice := failPayment(t, pool, collector, provider)
+	retries := len(getDunning(t, pool, invoice).RetryDays)
+	for step := range retries {
+		makeDue(t, pool, invoice, step, 0)
+		processDue(t, processor)
+	}
+	dunning := getDunning(t, pool, invoice)
+	if int(dunning.Step) != retries || dunning.CompletedAt.Valid || !dunning.NextAttemptAt.Valid {
+		t.Fatalf("got step %d, completed at %v, next attempt at %v, want to wait for the last outcome", dunning.Step, dunning.CompletedAt, dunning.NextAttemptAt)
+	}
+	if got := getInvoice(t, pool, invoice.MerchantID.Bytes, invoice.ID.Bytes); got.Status != billing.InvoiceStatusPaymentFailed {
+		t.Fatalf("got status %q before the deadline, want payment_failed", got.Status)
+	}
+
+	// No outcome by the deadline.
+	makeDue(t, pool, invoice, retries, 0)
+	processDue(t, processor)
+	if got := getInvoice(t, pool, invoice.MerchantID.Bytes, invoice.ID.Bytes); got.Status != billing.InvoiceStatusUncollectible {
+		t.Fatalf("got status %q after the deadline, want uncollectible", got.Status)
+	}
+	if dunning := getDunning(t, pool, invoice); dunning.Outcome.String != payments.DunningOutcomeUncollectible {
+		t.Fatalf("got dunning outcome %q, want uncollectible", dunning.Outcome.String)
+	}
+}
+
+func TestDunningProviderErrors(t *testing.T) {
+	pool := dbtest.NewPool(t)
+	collector, provider := newTestCollector(pool)
+	invoice := failPayment(t, pool, collector, provider)
+	retries := len(getDunning(t, pool, invoice).RetryDays)
+
+	// Another provider does not know the payment.
+	other, _ := newTestCollector(pool)
+	processor := payments.NewDunningProcessor(zap.NewNop(), pool, other)
+
+	makeDue(t, pool, invoice, 0, 0)
+	processDue(t, processor)
+	dunning := getDunning(t, pool, invoice)
+	if dunning.Step != 0 || dunning.ErrorCount != 1 || !dunning.LastError.Valid {
+		t.Fatalf("got step %d, %d errors, want the retry postponed", dunning.Step, dunning.ErrorCount)
+	}
+
+	// The retry persistently failing counts as failed.
+	makeDue(t, pool, invoice, 0, payments.DUNNING_MAX_ERRORS-1)
+	processDue(t, processor)
+	dunning = getDunning(t, pool, invoice)
+	if dunning.Step != 1 || dunning.ErrorCount != 0 {
+		t.Fatalf("got step %d, %d errors, want the next step", dunning.Step, dunning.ErrorCount)
+	}
+
+	// So does the last one, which ends the dunning.
+	makeDue(t, pool, invoice, retries-1, payments.DUNNING_MAX_ERRORS-1)
+	processDue(t, processor)
+	if got := getInvoice(t, pool, invoice.MerchantID.Bytes, invoice.ID.Bytes); got.Status != billing.InvoiceStatusUncollectible {
+		t.Fatalf("got status %q, want uncollectible", got.Status)
+	}
+}
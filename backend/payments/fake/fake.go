@@ -0,0 +1,140 @@
+// Package fake implements an in-memory payments.PaymentProvider for local
+// development. Payments stay pending until a webhook is simulated with
+// Webhook.
+package fake
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"billbo.com/backend/payments"
+	"github.com/google/uuid"
+)
+
+const (
+	PROVIDER_NAME    = "fake"
+	SIGNATURE_HEADER = "X-Fake-Signature"
+)
+
+var ErrPaymentNotFound = errors.New("payment not found")
+
+type Provider struct {
+	secret []byte
+
+	mu        sync.Mutex
+	customers map[string]payments.CreateCustomerParams
+	// payments maps payment IDs to their params, invoicePayments maps
+	// invoice IDs to payment IDs to mimic idempotent creation.
+	payments        map[string]payments.CreatePaymentParams
+	invoicePayments map[string]string
+	refunded        map[string]bool
+}
+
+func NewProvider(webhookSecret string) *Provider {
+	return &Provider{
+		secret:          []byte(webhookSecret),
+		customers:       make(map[string]payments.CreateCustomerParams),
+		payments:        make(map[string]payments.CreatePaymentParams),
+		invoicePayments: make(map[string]string),
+		refunded:        make(map[string]bool),
+	}
+}
+
+var _ payments.PaymentProvider = (*Provider)(nil)
+
+func (p *Provider) Name() string {
+	return PROVIDER_NAME
+}
+
+func (p *Provider) CreateCustomer(ctx context.Context, params payments.CreateCustomerParams) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	id := "cus_" + uuid.NewString()
+	p.customers[id] = params
+	return id, nil
+}
+
+func (p *Provider) CreatePayment(ctx context.Context, params payments.CreatePaymentParams) (*payments.Payment, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.customers[params.CustomerID]; !ok {
+		return nil, fmt.Errorf("customer %s: %w", params.CustomerID, ErrPaymentNotFound)
+	}
+	id, ok := p.invoicePayments[params.InvoiceID]
+	if !ok {
+		id = "pay_" + uuid.NewString()
+		p.payments[id] = params
+		p.invoicePayments[params.InvoiceID] = id
+	}
+	return &payments.Payment{ID: id, Status: payments.PaymentStatusPending}, nil
+}
+
+func (p *Provider) RetryPayment(ctx context.Context, paymentID string, attempt int) (*payments.Payment, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.payments[paymentID]; !ok {
+		return nil, ErrPaymentNotFound
+	}
+	return &payments.Payment{ID: paymentID, Status: payments.PaymentStatusPending}, nil
+}
+
+func (p *Provider) Refund(ctx context.Context, paymentID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.payments[paymentID]; !ok {
+		return ErrPaymentNotFound
+	}
+	p.refunded[paymentID] = true
+	return nil
+}
+
+// Webhook builds a signed webhook request body and headers, as the provider
+// would send them when a payment changes status.
+func (p *Provider) Webhook(eventType payments.EventType, paymentID string) ([]byte, http.Header, error) {
+	payload, err := json.Marshal(payments.Event{
+		ID:         "evt_" + uuid.NewString(),
+		Type:       eventType,
+		PaymentID:  paymentID,
+		OccurredAt: time.Now(),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("json.Marshal: %w", err)
+	}
+	header := http.Header{}
+	header.Set(SIGNATURE_HEADER, p.sign(payload))
+	return payload, header, nil
+}
+
+func (p *Provider) ParseWebhook(payload []byte, header http.Header) (*payments.Event, error) {
+	if !hmac.Equal([]byte(header.Get(SIGNATURE_HEADER)), []byte(p.sign(payload))) {
+		return nil, payments.ErrInvalidSignature
+	}
+	var event payments.Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	switch event.Type {
+	case payments.EventPaymentSucceeded, payments.EventPaymentFailed, payments.EventPaymentRefunded:
+		return &event, nil
+	default:
+		return nil, payments.ErrUnhandledEvent
+	}
+}
+
+func (p *Provider) sign(payload []byte) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,91 @@
+package payments_test
+
+import (
+	"context"
+	"testing"
+
+	"billbo.com/backend/billing"
+	"billbo.com/backend/database/dbtest"
+	"billbo.com/backend/database/sqlcgen"
+	"billbo.com/backend/payments"
+	"billbo.com/backend/payments/fake"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+func newTestCollector(pool *pgxpool.Pool) (*payments.Collector, *fake.Provider) {
+	provider := fake.NewProvider("whsec_test")
+	return payments.NewCollector(zap.NewNop(), sqlcgen.New(pool), provider, "usd"), provider
+}
+
+// createFinalizedInvoice creates a merchant and a customer, and a finalized
+// invoice of theirs.
+func createFinalizedInvoice(t *testing.T, pool *pgxpool.Pool) *sqlcgen.Invoice {
+	t.Helper()
+	ctx := context.Background()
+
+	merchantID := dbtest.CreateMerchant(t, pool)
+	customerID := uuid.New()
+	_, err := pool.Exec(ctx,
+		`INSERT INTO customers (id, merchant_id, name, email, country) VALUES ($1, $2, 'Test customer', 'customer@example.com', 'US')`,
+		customerID, merchantID,
+	)
+	if err != nil {
+		t.Fatalf("insert customer: %v", err)
+	}
+
+	invoiceID := uuid.New()
+	_, err = pool.Exec(ctx,
+		`INSERT INTO invoices (id, merchant_id, customer_id, status, period_start, period_end, subtotal, discount_total, total, number, finalized_at, due_at)
+		 VALUES ($1, $2, $3, $4, now() - interval '1 month', now(), 42.5, 0, 42.5, 'INV-1', now(), now() + interval '30 days')`,
+		invoiceID, merchantID, customerID, billing.InvoiceStatusFinalized,
+	)
+	if err != nil {
+		t.Fatalf("insert invoice: %v", err)
+	}
+
+	return getInvoice(t, pool, merchantID, invoiceID)
+}
+
+func getInvoice(t *testing.T, pool *pgxpool.Pool, merchantID, invoiceID uuid.UUID) *sqlcgen.Invoice {
+	t.Helper()
+	invoice, err := sqlcgen.New(pool).GetInvoice(context.Background(), sqlcgen.GetInvoiceParams{
+		ID:         pgtype.UUID{Bytes: invoiceID, Valid: true},
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("queries.GetInvoice: %v", err)
+	}
+	return invoice
+}
+
+// collect pushes the invoice to the provider and returns it with its
+// payment.
+func collect(t *testing.T, collector *payments.Collector, invoice *sqlcgen.Invoice) *sqlcgen.Invoice {
+	t.Helper()
+	invoice, err := collector.CollectInvoice(context.Background(), invoice.MerchantID.Bytes, invoice.ID.Bytes)
+	if err != nil {
+		t.Fatalf("collector.CollectInvoice: %v", err)
+	}
+	if !invoice.PaymentID.Valid {
+		t.Fatal("collected invoice has no payment ID")
+	}
+	return invoice
+}
+
+// webhookEvent builds a webhook of the provider and parses it back, as the
+// webhooks route would.
+func webhookEvent(t *testing.T, provider *fake.Provider, eventType payments.EventType, paymentID string) *payments.Event {
+	t.Helper()
+	payload, header, err := provider.Webhook(eventType, paymentID)
+	if err != nil {
+		t.Fatalf("provider.Webhook: %v", err)
+	}
+	event, err := provider.ParseWebhook(payload, header)
+	if err != nil {
+		t.Fatalf("provider.ParseWebhook: %v", err)
+	}
+	return event
+}
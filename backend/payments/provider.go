@@ -0,0 +1,99 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrInvalidSignature is returned by ParseWebhook when the payload was
+	// not signed by the provider.
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrUnhandledEvent is returned by ParseWebhook for well-formed events
+	// BillBo does not act upon. They should be acknowledged and dropped.
+	ErrUnhandledEvent = errors.New("unhandled webhook event")
+)
+
+// PaymentProvider is a payment service that collects invoice payments from
+// customers on behalf of BillBo.
+type PaymentProvider interface {
+	// Name identifies the provider in stored references, e.g. "stripe".
+	Name() string
+	// CreateCustomer registers a customer with the provider and returns the
+	// provider's customer ID.
+	CreateCustomer(ctx context.Context, p CreateCustomerParams) (string, error)
+	// CreatePayment asks the provider to collect an invoice. The outcome is
+	// reported asynchronously through webhooks.
+	CreatePayment(ctx context.Context, p CreatePaymentParams) (*Payment, error)
+	// RetryPayment attempts to collect a failed payment again. attempt makes
+	// the call idempotent per retry.
+	RetryPayment(ctx context.Context, paymentID string, attempt int) (*Payment, error)
+	// Refund refunds a collected payment in full.
+	Refund(ctx context.Context, paymentID string) error
+	// ParseWebhook verifies a webhook request sent by the provider and
+	// extracts the payment event it carries.
+	ParseWebhook(payload []byte, header http.Header) (*Event, error)
+}
+
+type Address struct {
+	Line1      string
+	Line2      string
+	City       string
+	PostalCode string
+	Region     string
+	Country    string
+}
+
+type CreateCustomerParams struct {
+	// ReferenceID is BillBo's customer ID, stored on the provider side.
+	ReferenceID string
+	Name        string
+	Email       string
+	Address     Address
+}
+
+type CreatePaymentParams struct {
+	// InvoiceID is BillBo's invoice ID. Providers use it to make the call
+	// idempotent and attach it to the payment for reconciliation.
+	InvoiceID string
+	// InvoiceNumber is shown to the customer.
+	InvoiceNumber string
+	CustomerID    string
+	// Amount is in the currency's smallest unit, e.g. cents.
+	Amount   int64
+	Currency string
+}
+
+type Payment struct {
+	ID     string
+	Status PaymentStatus
+}
+
+type PaymentStatus string
+
+const (
+	// PaymentStatusPending means the provider accepted the payment but its
+	// outcome is not known yet.
+	PaymentStatusPending   PaymentStatus = "pending"
+	PaymentStatusSucceeded PaymentStatus = "succeeded"
+	PaymentStatusFailed    PaymentStatus = "failed"
+)
+
+type EventType string
+
+const (
+	EventPaymentSucceeded EventType = "payment.succeeded"
+	EventPaymentFailed    EventType = "payment.failed"
+	EventPaymentRefunded  EventType = "payment.refunded"
+)
+
+// Event is a payment status change reported by a provider webhook.
+type Event struct {
+	// ID is the provider's event ID, unique per provider.
+	ID         string
+	Type       EventType
+	PaymentID  string
+	OccurredAt time.Time
+}
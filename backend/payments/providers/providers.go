@@ -0,0 +1,47 @@
+// Package providers builds the configured payment provider.
+package providers
+
+import (
+	"fmt"
+
+	"billbo.com/backend/payments"
+	"billbo.com/backend/payments/fake"
+	"billbo.com/backend/payments/stripe"
+)
+
+// Config is the payment provider configuration, shared by the commands that
+// collect payments. NewPaymentProvider requires PAYMENT_PROVIDER and
+// PAYMENT_WEBHOOK_SECRET, which the worker may go without.
+type Config struct {
+	// PaymentProvider is either "stripe" or "fake", an in-memory provider
+	// for local development, only allowed with AllowFakePaymentProvider.
+	PaymentProvider string `env:"PAYMENT_PROVIDER"`
+	PaymentCurrency string `env:"PAYMENT_CURRENCY,default=usd"`
+	// PaymentWebhookSecret verifies the provider webhooks, which anyone can
+	// send to the public webhooks route.
+	PaymentWebhookSecret     string `env:"PAYMENT_WEBHOOK_SECRET"`
+	StripeSecretKey          string `env:"STRIPE_SECRET_KEY"`
+	AllowFakePaymentProvider bool   `env:"ALLOW_FAKE_PAYMENT_PROVIDER,default=false"`
+}
+
+func (cfg Config) NewPaymentProvider() (payments.PaymentProvider, error) {
+	if cfg.PaymentProvider == "" {
+		return nil, fmt.Errorf("NewPaymentProvider: PAYMENT_PROVIDER is required")
+	}
+	if cfg.PaymentWebhookSecret == "" {
+		return nil, fmt.Errorf("NewPaymentProvider: PAYMENT_WEBHOOK_SECRET is required")
+	}
+	switch cfg.PaymentProvider {
+	case stripe.PROVIDER_NAME:
+		if cfg.StripeSecretKey == "" {
+			return nil, fmt.Errorf("NewPaymentProvider: STRIPE_SECRET_KEY is required")
+		}
+		return stripe.NewProvider(cfg.StripeSecretKey, cfg.PaymentWebhookSecret), nil
+	case fake.PROVIDER_NAME:
+		if !cfg.AllowFakePaymentProvider {
+			return nil, fmt.Errorf("NewPaymentProvider: the fake payment provider is for local development only, set ALLOW_FAKE_PAYMENT_PROVIDER to use it")
+		}
+		return fake.NewProvider(cfg.PaymentWebhookSecret), nil
+	}
+	return nil, fmt.Errorf("NewPaymentProvider: unknown payment provider %q", cfg.PaymentProvider)
+}
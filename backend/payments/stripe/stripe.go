@@ -0,0 +1,207 @@
+// Package stripe implements payments.PaymentProvider on top of Stripe
+// PaymentIntents. BillBo collects on a single platform Stripe account.
+package stripe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"billbo.com/backend/payments"
+	"github.com/stripe/stripe-go/v82"
+	"github.com/stripe/stripe-go/v82/webhook"
+)
+
+const PROVIDER_NAME = "stripe"
+
+type Provider struct {
+	client        *stripe.Client
+	webhookSecret string
+}
+
+func NewProvider(secretKey, webhookSecret string) *Provider {
+	return &Provider{
+		client:        stripe.NewClient(secretKey),
+		webhookSecret: webhookSecret,
+	}
+}
+
+var _ payments.PaymentProvider = (*Provider)(nil)
+
+func (p *Provider) Name() string {
+	return PROVIDER_NAME
+}
+
+func (p *Provider) CreateCustomer(ctx context.Context, params payments.CreateCustomerParams) (string, error) {
+	create := &stripe.CustomerCreateParams{
+		Name: stripe.String(params.Name),
+		Metadata: map[string]string{
+			"billbo_customer_id": params.ReferenceID,
+		},
+	}
+	if params.Email != "" {
+		create.Email = stripe.String(params.Email)
+	}
+	if params.Address.Country != "" {
+		create.Address = &stripe.AddressParams{
+			Line1:      stripe.String(params.Address.Line1),
+			Line2:      stripe.String(params.Address.Line2),
+			City:       stripe.String(params.Address.City),
+			PostalCode: stripe.String(params.Address.PostalCode),
+			State:      stripe.String(params.Address.Region),
+			Country:    stripe.String(params.Address.Country),
+		}
+	}
+	create.SetIdempotencyKey("billbo-customer-" + params.ReferenceID)
+
+	customer, err := p.client.V1Customers.Create(ctx, create)
+	if err != nil {
+		return "", fmt.Errorf("V1Customers.Create: %w", err)
+	}
+	return customer.ID, nil
+}
+
+// CreatePayment creates a PaymentIntent for the invoice and, when the
+// customer has a default payment method, charges it off-session. Otherwise
+// the PaymentIntent waits for the customer to provide a payment method.
+func (p *Provider) CreatePayment(ctx context.Context, params payments.CreatePaymentParams) (*payments.Payment, error) {
+	customer, err := p.client.V1Customers.Retrieve(ctx, params.CustomerID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("V1Customers.Retrieve: %w", err)
+	}
+
+	create := &stripe.PaymentIntentCreateParams{
+		Amount:      stripe.Int64(params.Amount),
+		Currency:    stripe.String(params.Currency),
+		Customer:    stripe.String(params.CustomerID),
+		Description: stripe.String("Invoice " + params.InvoiceNumber),
+		Metadata: map[string]string{
+			"billbo_invoice_id": params.InvoiceID,
+		},
+	}
+	create.SetIdempotencyKey("billbo-invoice-" + params.InvoiceID)
+
+	intent, err := p.client.V1PaymentIntents.Create(ctx, create)
+	if err != nil {
+		return nil, fmt.Errorf("V1PaymentIntents.Create: %w", err)
+	}
+
+	if customer.InvoiceSettings == nil || customer.InvoiceSettings.DefaultPaymentMethod == nil {
+		return &payments.Payment{ID: intent.ID, Status: payments.PaymentStatusPending}, nil
+	}
+
+	confirm := &stripe.PaymentIntentConfirmParams{
+		PaymentMethod: stripe.String(customer.InvoiceSettings.DefaultPaymentMethod.ID),
+		OffSession:    stripe.Bool(true),
+	}
+	confirm.SetIdempotencyKey("billbo-invoice-confirm-" + params.InvoiceID)
+
+	confirmed, err := p.client.V1PaymentIntents.Confirm(ctx, intent.ID, confirm)
+	var stripeErr *stripe.Error
+	if errors.As(err, &stripeErr) && stripeErr.Type == stripe.ErrorTypeCard {
+		// The decline is also reported through the payment_failed webhook.
+		return &payments.Payment{ID: intent.ID, Status: payments.PaymentStatusFailed}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("V1PaymentIntents.Confirm: %w", err)
+	}
+	return &payments.Payment{ID: confirmed.ID, Status: paymentStatus(confirmed.Status)}, nil
+}
+
+// RetryPayment confirms the PaymentIntent again with the customer's current
+// default payment method, which they may have updated since the failure.
+func (p *Provider) RetryPayment(ctx context.Context, paymentID string, attempt int) (*payments.Payment, error) {
+	intent, err := p.client.V1PaymentIntents.Retrieve(ctx, paymentID, &stripe.PaymentIntentRetrieveParams{
+		Params: stripe.Params{Expand: []*string{stripe.String("customer")}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("V1PaymentIntents.Retrieve: %w", err)
+	}
+	if intent.Status == stripe.PaymentIntentStatusSucceeded || intent.Status == stripe.PaymentIntentStatusProcessing {
+		return &payments.Payment{ID: intent.ID, Status: paymentStatus(intent.Status)}, nil
+	}
+	customer := intent.Customer
+	if customer == nil || customer.InvoiceSettings == nil || customer.InvoiceSettings.DefaultPaymentMethod == nil {
+		return &payments.Payment{ID: intent.ID, Status: payments.PaymentStatusFailed}, nil
+	}
+
+	confirm := &stripe.PaymentIntentConfirmParams{
+		PaymentMethod: stripe.String(customer.InvoiceSettings.DefaultPaymentMethod.ID),
+		OffSession:    stripe.Bool(true),
+	}
+	confirm.SetIdempotencyKey(fmt.Sprintf("billbo-payment-retry-%s-%d", paymentID, attempt))
+
+	confirmed, err := p.client.V1PaymentIntents.Confirm(ctx, intent.ID, confirm)
+	var stripeErr *stripe.Error
+	if errors.As(err, &stripeErr) && stripeErr.Type == stripe.ErrorTypeCard {
+		return &payments.Payment{ID: intent.ID, Status: payments.PaymentStatusFailed}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("V1PaymentIntents.Confirm: %w", err)
+	}
+	return &payments.Payment{ID: confirmed.ID, Status: paymentStatus(confirmed.Status)}, nil
+}
+
+func (p *Provider) Refund(ctx context.Context, paymentID string) error {
+	params := &stripe.RefundCreateParams{
+		PaymentIntent: stripe.String(paymentID),
+	}
+	params.SetIdempotencyKey("billbo-refund-" + paymentID)
+
+	if _, err := p.client.V1Refunds.Create(ctx, params); err != nil {
+		return fmt.Errorf("V1Refunds.Create: %w", err)
+	}
+	return nil
+}
+
+func (p *Provider) ParseWebhook(payload []byte, header http.Header) (*payments.Event, error) {
+	event, err := webhook.ConstructEvent(payload, header.Get("Stripe-Signature"), p.webhookSecret)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", payments.ErrInvalidSignature, err)
+	}
+
+	parsed := &payments.Event{
+		ID:         event.ID,
+		OccurredAt: time.Unix(event.Created, 0),
+	}
+	switch event.Type {
+	case stripe.EventTypePaymentIntentSucceeded, stripe.EventTypePaymentIntentPaymentFailed:
+		var intent stripe.PaymentIntent
+		if err := json.Unmarshal(event.Data.Raw, &intent); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal: %w", err)
+		}
+		parsed.PaymentID = intent.ID
+		parsed.Type = payments.EventPaymentSucceeded
+		if event.Type == stripe.EventTypePaymentIntentPaymentFailed {
+			parsed.Type = payments.EventPaymentFailed
+		}
+	case stripe.EventTypeChargeRefunded:
+		var charge stripe.Charge
+		if err := json.Unmarshal(event.Data.Raw, &charge); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal: %w", err)
+		}
+		if charge.PaymentIntent == nil || !charge.Refunded {
+			// Partial refunds keep the invoice paid.
+			return nil, payments.ErrUnhandledEvent
+		}
+		parsed.PaymentID = charge.PaymentIntent.ID
+		parsed.Type = payments.EventPaymentRefunded
+	default:
+		return nil, payments.ErrUnhandledEvent
+	}
+	return parsed, nil
+}
+
+func paymentStatus(status stripe.PaymentIntentStatus) payments.PaymentStatus {
+	switch status {
+	case stripe.PaymentIntentStatusSucceeded:
+		return payments.PaymentStatusSucceeded
+	case stripe.PaymentIntentStatusCanceled, stripe.PaymentIntentStatusRequiresPaymentMethod:
+		return payments.PaymentStatusFailed
+	default:
+		return payments.PaymentStatusPending
+	}
+}
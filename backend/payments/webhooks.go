@@ -0,0 +1,144 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"billbo.com/backend/database"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+const (
+	// WEBHOOK_MAX_ATTEMPTS bounds how many times an event is applied before
+	// it is left for manual inspection.
+	WEBHOOK_MAX_ATTEMPTS = 10
+	WEBHOOK_MAX_BACKOFF  = time.Hour
+)
+
+// WebhookProcessor stores verified provider webhooks, then applies them to
+// invoices in the background, retrying failed attempts with backoff.
+type WebhookProcessor struct {
+	logger    *zap.Logger
+	pool      *pgxpool.Pool
+	collector *Collector
+}
+
+func NewWebhookProcessor(logger *zap.Logger, pool *pgxpool.Pool, collector *Collector) *WebhookProcessor {
+	return &WebhookProcessor{
+		logger: logger.With(
+			zap.String("component", "payment_webhooks"),
+			zap.String("provider", collector.Provider().Name()),
+		),
+		pool:      pool,
+		collector: collector,
+	}
+}
+
+// Receive verifies a webhook request and stores its event. It returns
+// ErrInvalidSignature for forged requests and ErrUnhandledEvent for events
+// that are acknowledged without being stored.
+func (p *WebhookProcessor) Receive(ctx context.Context, payload []byte, header http.Header) error {
+	provider := p.collector.Provider()
+	event, err := provider.ParseWebhook(payload, header)
+	if err != nil {
+		return fmt.Errorf("provider.ParseWebhook: %w", err)
+	}
+
+	inserted, err := sqlcgen.New(p.pool).InsertPaymentWebhookEvent(ctx, sqlcgen.InsertPaymentWebhookEventParams{
+		Provider:   provider.Name(),
+		EventID:    event.ID,
+		EventType:  string(event.Type),
+		PaymentID:  event.PaymentID,
+		Payload:    payload,
+		OccurredAt: pgtype.Timestamptz{Time: event.OccurredAt, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("queries.InsertPaymentWebhookEvent: %w", err)
+	}
+	if inserted == 0 {
+		p.logger.Debug("duplicate webhook event ignored", zap.String("event_id", event.ID))
+	}
+	return nil
+}
+
+// ProcessDue applies the stored events that are due, oldest first.
+func (p *WebhookProcessor) ProcessDue(ctx context.Context) error {
+	for {
+		processed, err := p.processNext(ctx)
+		if err != nil {
+			return fmt.Errorf("processNext: %w", err)
+		}
+		if !processed {
+			return nil
+		}
+	}
+}
+
+// processNext applies the oldest pending event, if any. The event row stays
+// locked while it is applied so concurrent processors skip it.
+func (p *WebhookProcessor) processNext(ctx context.Context) (bool, error) {
+	var (
+		processed bool
+		applyErr  error
+		row       *sqlcgen.PaymentWebhookEvent
+	)
+	err := database.InTx(ctx, p.pool, func(q *sqlcgen.Queries) error {
+		var err error
+		row, err = q.ClaimPaymentWebhookEvent(ctx, WEBHOOK_MAX_ATTEMPTS)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("queries.ClaimPaymentWebhookEvent: %w", err)
+		}
+		processed = true
+
+		_, applyErr = p.collector.ApplyEvent(ctx, q, &Event{
+			ID:         row.EventID,
+			Type:       EventType(row.EventType),
+			PaymentID:  row.PaymentID,
+			OccurredAt: row.OccurredAt.Time,
+		})
+		if applyErr != nil {
+			// Rolling back the partial update also releases the row lock.
+			return applyErr
+		}
+
+		err = q.MarkPaymentWebhookEventProcessed(ctx, sqlcgen.MarkPaymentWebhookEventProcessedParams{
+			Provider: row.Provider,
+			EventID:  row.EventID,
+		})
+		if err != nil {
+			return fmt.Errorf("queries.MarkPaymentWebhookEventProcessed: %w", err)
+		}
+		return nil
+	})
+	if applyErr == nil {
+		return processed, err
+	}
+
+	backoff := min(time.Duration(1<<row.Attempts)*time.Minute, WEBHOOK_MAX_BACKOFF)
+	p.logger.Warn("failed to apply webhook event",
+		zap.String("event_id", row.EventID),
+		zap.Int32("attempt", row.Attempts+1),
+		zap.Duration("retry_in", backoff),
+		zap.Error(applyErr),
+	)
+	err = sqlcgen.New(p.pool).MarkPaymentWebhookEventFailed(ctx, sqlcgen.MarkPaymentWebhookEventFailedParams{
+		Provider:      row.Provider,
+		EventID:       row.EventID,
+		LastError:     pgtype.Text{String: applyErr.Error(), Valid: true},
+		NextAttemptAt: pgtype.Timestamptz{Time: time.Now().Add(backoff), Valid: true},
+	})
+	if err != nil {
+		return false, fmt.Errorf("queries.MarkPaymentWebhookEventFailed: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,66 @@
+package payments_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"billbo.com/backend/billing"
+	"billbo.com/backend/database/dbtest"
+	"billbo.com/backend/payments"
+	"billbo.com/backend/payments/fake"
+	"go.uber.org/zap"
+)
+
+func TestWebhookProcessor(t *testing.T) {
+	pool := dbtest.NewPool(t)
+	collector, provider := newTestCollector(pool)
+	processor := payments.NewWebhookProcessor(zap.NewNop(), pool, collector)
+	ctx := context.Background()
+
+	invoice := collect(t, collector, createFinalizedInvoice(t, pool))
+
+	receive := func(eventType payments.EventType) {
+		t.Helper()
+		payload, header, err := provider.Webhook(eventType, invoice.PaymentID.String)
+		if err != nil {
+			t.Fatalf("provider.Webhook: %v", err)
+		}
+		if err := processor.Receive(ctx, payload, header); err != nil {
+			t.Fatalf("processor.Receive: %v", err)
+		}
+		if err := processor.ProcessDue(ctx); err != nil {
+			t.Fatalf("processor.ProcessDue: %v", err)
+		}
+	}
+
+	receive(payments.EventPaymentFailed)
+	if got := getInvoice(t, pool, invoice.MerchantID.Bytes, invoice.ID.Bytes); got.Status != billing.InvoiceStatusPaymentFailed {
+		t.Fatalf("got status %q, want payment_failed", got.Status)
+	}
+
+	receive(payments.EventPaymentSucceeded)
+	if got := getInvoice(t, pool, invoice.MerchantID.Bytes, invoice.ID.Bytes); got.Status != billing.InvoiceStatusPaid {
+		t.Fatalf("got status %q, want paid", got.Status)
+	}
+
+	receive(payments.EventPaymentRefunded)
+	if got := getInvoice(t, pool, invoice.MerchantID.Bytes, invoice.ID.Bytes); got.Status != billing.InvoiceStatusRefunded {
+		t.Fatalf("got status %q, want refunded", got.Status)
+	}
+}
+
+func TestWebhookProcessorRejectsForgedWebhooks(t *testing.T) {
+	// Rejected before reaching the database.
+	collector, _ := newTestCollector(nil)
+	processor := payments.NewWebhookProcessor(zap.NewNop(), nil, collector)
+
+	// Signed with another secret.
+	payload, header, err := fake.NewProvider("whsec_forged").Webhook(payments.EventPaymentSucceeded, "pay_forged")
+	if err != nil {
+		t.Fatalf("provider.Webhook: %v", err)
+	}
+	if err := processor.Receive(context.Background(), payload, header); !errors.Is(err, payments.ErrInvalidSignature) {
+		t.Fatalf("got %v, want ErrInvalidSignature", err)
+	}
+}
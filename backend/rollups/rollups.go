@@ -0,0 +1,223 @@
+// Package rollups maintains hourly and daily usage aggregates per merchant,
+// customer and SKU, and serves usage aggregations from them.
+package rollups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"billbo.com/backend/database"
+	"billbo.com/backend/database/sqlcgen"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+const (
+	GRANULARITY_MINUTE = "minute"
+	GRANULARITY_HOUR   = "hour"
+	GRANULARITY_DAY    = "day"
+
+	ROLLUP_BATCH_SIZE = 100
+)
+
+type granularity struct {
+	unit time.Duration
+	// maxRange bounds the time range of an aggregation.
+	maxRange time.Duration
+}
+
+var granularities = map[string]granularity{
+	GRANULARITY_MINUTE: {unit: time.Minute, maxRange: 24 * time.Hour},
+	GRANULARITY_HOUR:   {unit: time.Hour, maxRange: 31 * 24 * time.Hour},
+	GRANULARITY_DAY:    {unit: 24 * time.Hour, maxRange: 366 * 24 * time.Hour},
+}
+
+var ErrRangeTooLarge = errors.New("time range too large for the granularity")
+
+// MarkDirty schedules the hourly and daily buckets of an event for
+// aggregation. queries must be bound to the transaction inserting the event.
+func MarkDirty(ctx context.Context, queries *sqlcgen.Queries, merchantID uuid.UUID, sentAt time.Time) error {
+	err := queries.MarkRollupHourDirty(ctx, sqlcgen.MarkRollupHourDirtyParams{
+		MerchantID: pgtype.UUID{Bytes: merchantID, Valid: true},
+		Hour:       pgtype.Timestamptz{Time: sentAt.UTC().Truncate(time.Hour), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("queries.MarkRollupHourDirty: %w", err)
+	}
+	return nil
+}
+
+// Aggregator rolls up the hours marked dirty.
+type Aggregator struct {
+	logger *zap.Logger
+	pool   *pgxpool.Pool
+}
+
+func NewAggregator(logger *zap.Logger, pool *pgxpool.Pool) *Aggregator {
+	return &Aggregator{
+		logger: logger.With(zap.String("component", "rollups")),
+		pool:   pool,
+	}
+}
+
+// RollUpDirty re-aggregates the dirty hours, and the days they fall in, from
+// scratch: a late event simply makes its buckets dirty again.
+func (a *Aggregator) RollUpDirty(ctx context.Context) error {
+	for {
+		n, err := a.rollUpBatch(ctx)
+		if err != nil {
+			return fmt.Errorf("rollUpBatch: %w", err)
+		}
+		if n < ROLLUP_BATCH_SIZE {
+			return nil
+		}
+	}
+}
+
+func (a *Aggregator) rollUpBatch(ctx context.Context) (int, error) {
+	var n int
+	err := database.InTx(ctx, a.pool, func(q *sqlcgen.Queries) error {
+		hours, err := q.ClaimRollupDirtyHours(ctx, ROLLUP_BATCH_SIZE)
+		if err != nil {
+			return fmt.Errorf("queries.ClaimRollupDirtyHours: %w", err)
+		}
+		n = len(hours)
+
+		type dayKey struct {
+			merchantID pgtype.UUID
+			day        time.Time
+		}
+		days := make(map[dayKey]struct{})
+		for _, hour := range hours {
+			err := q.DeleteHourlyRollups(ctx, sqlcgen.DeleteHourlyRollupsParams{
+				MerchantID:  hour.MerchantID,
+				BucketStart: hour.Hour,
+			})
+			if err != nil {
+				return fmt.Errorf("queries.DeleteHourlyRollups: %w", err)
+			}
+			err = q.InsertHourlyRollups(ctx, sqlcgen.InsertHourlyRollupsParams{
+				BucketStart: hour.Hour,
+				MerchantID:  hour.MerchantID,
+			})
+			if err != nil {
+				return fmt.Errorf("queries.InsertHourlyRollups: %w", err)
+			}
+			days[dayKey{hour.MerchantID, hour.Hour.Time.UTC().Truncate(24 * time.Hour)}] = struct{}{}
+		}
+
+		for key := range days {
+			day := pgtype.Timestamptz{Time: key.day, Valid: true}
+			err := q.DeleteDailyRollups(ctx, sqlcgen.DeleteDailyRollupsParams{
+				MerchantID:  key.merchantID,
+				BucketStart: day,
+			})
+			if err != nil {
+				return fmt.Errorf("queries.DeleteDailyRollups: %w", err)
+			}
+			err = q.InsertDailyRollups(ctx, sqlcgen.InsertDailyRollupsParams{
+				BucketStart: day,
+				MerchantID:  key.merchantID,
+			})
+			if err != nil {
+				return fmt.Errorf("queries.InsertDailyRollups: %w", err)
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+type Bucket struct {
+	Start      time.Time
+	SkuID      uuid.UUID
+	Quantity   float64
+	EventCount int64
+}
+
+type QueryParams struct {
+	MerchantID  uuid.UUID
+	Granularity string
+	From        time.Time
+	To          time.Time
+	// CustomerID and SkuID optionally filter the usage.
+	CustomerID *uuid.UUID
+	SkuID      *uuid.UUID
+}
+
+// Query aggregates usage over [From, To) per bucket of the granularity and
+// SKU. Hours and days (UTC) are read from the rollups, which lag ingestion
+// by up to the rollup interval; finer granularities aggregate raw events.
+// From and To are truncated to the granularity.
+func Query(ctx context.Context, queries *sqlcgen.Queries, p QueryParams) ([]Bucket, error) {
+	g, ok := granularities[p.Granularity]
+	if !ok {
+		return nil, fmt.Errorf("Query: unknown granularity %q", p.Granularity)
+	}
+	from := pgtype.Timestamptz{Time: p.From.UTC().Truncate(g.unit), Valid: true}
+	to := pgtype.Timestamptz{Time: p.To.UTC().Truncate(g.unit), Valid: true}
+	if to.Time.Sub(from.Time) > g.maxRange {
+		return nil, ErrRangeTooLarge
+	}
+	merchantID := pgtype.UUID{Bytes: p.MerchantID, Valid: true}
+	customerID := optionalUUID(p.CustomerID)
+	skuID := optionalUUID(p.SkuID)
+
+	var buckets []Bucket
+	switch p.Granularity {
+	case GRANULARITY_MINUTE:
+		rows, err := queries.ListMinuteUsage(ctx, sqlcgen.ListMinuteUsageParams{
+			MerchantID: merchantID,
+			FromTime:   from,
+			ToTime:     to,
+			CustomerID: customerID,
+			SkuID:      skuID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("queries.ListMinuteUsage: %w", err)
+		}
+		for _, row := range rows {
+			buckets = append(buckets, Bucket{row.BucketStart.Time, row.SkuID.Bytes, row.Quantity, row.EventCount})
+		}
+	case GRANULARITY_HOUR:
+		rows, err := queries.ListHourlyUsage(ctx, sqlcgen.ListHourlyUsageParams{
+			MerchantID: merchantID,
+			FromTime:   from,
+			ToTime:     to,
+			CustomerID: customerID,
+			SkuID:      skuID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("queries.ListHourlyUsage: %w", err)
+		}
+		for _, row := range rows {
+			buckets = append(buckets, Bucket{row.BucketStart.Time, row.SkuID.Bytes, row.Quantity, row.EventCount})
+		}
+	case GRANULARITY_DAY:
+		rows, err := queries.ListDailyUsage(ctx, sqlcgen.ListDailyUsageParams{
+			MerchantID: merchantID,
+			FromTime:   from,
+			ToTime:     to,
+			CustomerID: customerID,
+			SkuID:      skuID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("queries.ListDailyUsage: %w", err)
+		}
+		for _, row := range rows {
+			buckets = append(buckets, Bucket{row.BucketStart.Time, row.SkuID.Bytes, row.Quantity, row.EventCount})
+		}
+	}
+	return buckets, nil
+}
+
+func optionalUUID(id *uuid.UUID) pgtype.UUID {
+	if id == nil {
+		return pgtype.UUID{}
+	}
+	return pgtype.UUID{Bytes: *id, Valid: true}
+}
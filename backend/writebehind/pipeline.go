@@ -0,0 +1,319 @@
+// Package writebehind implements the asynchronous ingest mode: accepted
+// events are appended to a local write-ahead log and acknowledged, then
+// flushed to Postgres in batches.
+package writebehind
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"billbo.com/backend/billing"
+	"billbo.com/backend/database/sqlcgen"
+	"billbo.com/backend/rollups"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+const FLUSH_RETRY_DELAY = time.Second
+
+var ErrBufferFull = errors.New("ingest buffer full")
+
+// Event is an accepted event. Its ID is set when accepted, so that events
+// replayed from the log after a crash are not inserted twice.
+type Event struct {
+	ID         uuid.UUID `json:"id"`
+	MerchantID uuid.UUID `json:"merchant_id"`
+	CustomerID uuid.UUID `json:"customer_id"`
+	SkuID      uuid.UUID `json:"sku_id"`
+	Amount     float64   `json:"amount"`
+	SentAt     time.Time `json:"sent_at"`
+	// Charge is what accepting the event charged to the customer's spend
+	// cap, refunded if the event cannot be inserted.
+	Charge *billing.SpendCapCharge `json:"charge,omitempty"`
+}
+
+type Options struct {
+	// BufferSize bounds the events accepted and not flushed yet.
+	BufferSize    int
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+type pending struct {
+	event   Event
+	segment uint64
+	// synced receives the result of syncing the event to the log. Events
+	// that failed to sync were refused, and are not flushed.
+	synced chan error
+}
+
+// Pipeline accepts events into the write-ahead log and flushes them to the
+// events table.
+type Pipeline struct {
+	logger *zap.Logger
+	pool   *pgxpool.Pool
+	wal    *WAL
+	opts   Options
+
+	// mu orders the events in the queue as in the log, so that the segments
+	// before the one of the last flushed event are fully flushed.
+	mu    sync.Mutex
+	queue chan pending
+	// slots holds a token per event accepted and not flushed yet.
+	slots chan struct{}
+}
+
+func NewPipeline(logger *zap.Logger, pool *pgxpool.Pool, wal *WAL, opts Options) *Pipeline {
+	return &Pipeline{
+		logger: logger.With(zap.String("component", "writebehind")),
+		pool:   pool,
+		wal:    wal,
+		opts:   opts,
+		queue:  make(chan pending, opts.BufferSize),
+		slots:  make(chan struct{}, opts.BufferSize),
+	}
+}
+
+// Accept durably appends the event to the log, to be flushed later. It
+// returns ErrBufferFull, without blocking, when the flusher falls behind.
+// The event is queued as soon as it is written, to keep the order of the
+// log, but only flushed once synced: an event Accept fails is never
+// flushed.
+func (p *Pipeline) Accept(e Event) error {
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		return ErrBufferFull
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		<-p.slots
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	p.mu.Lock()
+	pos, err := p.wal.Write(data)
+	if err != nil {
+		p.mu.Unlock()
+		<-p.slots
+		return fmt.Errorf("wal.Write: %w", err)
+	}
+	synced := make(chan error, 1)
+	p.queue <- pending{event: e, segment: pos.Segment, synced: synced}
+	p.mu.Unlock()
+
+	err = p.wal.Sync(pos)
+	synced <- err
+	if err != nil {
+		return fmt.Errorf("wal.Sync: %w", err)
+	}
+	return nil
+}
+
+// Replay flushes the records of the log left by a previous run, then
+// removes them. It must be called before accepting events.
+func (p *Pipeline) Replay(ctx context.Context, records [][]byte) error {
+	events := make([]Event, 0, len(records))
+	for _, record := range records {
+		var e Event
+		if err := json.Unmarshal(record, &e); err != nil {
+			p.logger.Error("skipping invalid WAL record", zap.Error(err))
+			continue
+		}
+		events = append(events, e)
+	}
+
+	for batch := range slices.Chunk(events, p.opts.BatchSize) {
+		if err := p.flush(ctx, batch); err != nil {
+			return fmt.Errorf("flush: %w", err)
+		}
+	}
+	if len(events) > 0 {
+		p.logger.Info("replayed WAL", zap.Int("events", len(events)))
+	}
+
+	if err := p.wal.Truncate(p.wal.Segment()); err != nil {
+		return fmt.Errorf("wal.Truncate: %w", err)
+	}
+	return nil
+}
+
+// Run flushes the accepted events, by batches of up to BatchSize or every
+// FlushInterval, until ctx is canceled. Failed flushes are retried, while
+// the buffer fills up. Events left unflushed are replayed on restart.
+func (p *Pipeline) Run(ctx context.Context) error {
+	for {
+		var batch []pending
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case first := <-p.queue:
+			batch = append(batch, first)
+		}
+
+		timer := time.NewTimer(p.opts.FlushInterval)
+	collect:
+		for len(batch) < p.opts.BatchSize {
+			select {
+			case next := <-p.queue:
+				batch = append(batch, next)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		events := make([]Event, 0, len(batch))
+		for _, item := range batch {
+			if err := <-item.synced; err != nil {
+				continue
+			}
+			events = append(events, item.event)
+		}
+		for len(events) > 0 {
+			err := p.flush(ctx, events)
+			if err == nil {
+				break
+			}
+			p.logger.Error("failed to flush events", zap.Int("events", len(events)), zap.Error(err))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(FLUSH_RETRY_DELAY):
+			}
+		}
+
+		for range batch {
+			<-p.slots
+		}
+		if err := p.wal.Truncate(batch[len(batch)-1].segment); err != nil {
+			p.logger.Error("failed to truncate WAL", zap.Error(err))
+		}
+	}
+}
+
+// flush copies the events to a temporary table, then inserts those not
+// inserted yet along with their running usage counters and rollup marks.
+// Events of unknown merchants or SKUs, which the events foreign keys reject
+// and Accept's callers check beforehand, are logged in full and left out
+// rather than failing the batch forever, and their spend cap charge is
+// refunded.
+func (p *Pipeline) flush(ctx context.Context, events []Event) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("pool.Begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "CREATE TEMPORARY TABLE events_ingest (LIKE events) ON COMMIT DROP")
+	if err != nil {
+		return fmt.Errorf("tx.Exec: create table: %w", err)
+	}
+
+	_, err = tx.CopyFrom(ctx,
+		pgx.Identifier{"events_ingest"},
+		[]string{"id", "merchant_id", "customer_id", "sku_id", "amount", "sent_at"},
+		pgx.CopyFromSlice(len(events), func(i int) ([]any, error) {
+			e := events[i]
+			return []any{e.ID, e.MerchantID, e.CustomerID, e.SkuID, e.Amount, e.SentAt}, nil
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("tx.CopyFrom: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT i.id
+		FROM events_ingest i
+		WHERE NOT EXISTS (SELECT 1 FROM merchants m WHERE m.id = i.merchant_id)
+		   OR NOT EXISTS (SELECT 1 FROM skus s WHERE s.id = i.sku_id)`)
+	if err != nil {
+		return fmt.Errorf("tx.Query: select rejected events: %w", err)
+	}
+	rejectedIDs, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return fmt.Errorf("pgx.CollectRows: %w", err)
+	}
+
+	rows, err = tx.Query(ctx, `
+		INSERT INTO events (id, merchant_id, customer_id, sku_id, amount, sent_at)
+		SELECT i.id, i.merchant_id, i.customer_id, i.sku_id, i.amount, i.sent_at
+		FROM events_ingest i
+		WHERE EXISTS (SELECT 1 FROM merchants m WHERE m.id = i.merchant_id)
+		  AND EXISTS (SELECT 1 FROM skus s WHERE s.id = i.sku_id)
+		ON CONFLICT (id, sent_at) DO NOTHING
+		RETURNING merchant_id, customer_id, sku_id, amount, sent_at`)
+	if err != nil {
+		return fmt.Errorf("tx.Query: insert events: %w", err)
+	}
+	inserted, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Event, error) {
+		var e Event
+		err := row.Scan(&e.MerchantID, &e.CustomerID, &e.SkuID, &e.Amount, &e.SentAt)
+		return e, err
+	})
+	if err != nil {
+		return fmt.Errorf("pgx.CollectRows: %w", err)
+	}
+
+	q := sqlcgen.New(p.pool).WithTx(tx)
+	var rejected []Event
+	for _, e := range events {
+		if !slices.Contains(rejectedIDs, e.ID) {
+			continue
+		}
+		if err := billing.RefundSpendCap(ctx, q, e.Charge); err != nil {
+			return fmt.Errorf("billing.RefundSpendCap: %w", err)
+		}
+		rejected = append(rejected, e)
+	}
+
+	type hour struct {
+		merchantID uuid.UUID
+		start      time.Time
+	}
+	dirty := make(map[hour]bool)
+	for _, e := range inserted {
+		err := billing.RecordUsage(ctx, q, billing.RecordUsageParams{
+			MerchantID: e.MerchantID,
+			CustomerID: e.CustomerID,
+			SkuID:      e.SkuID,
+			Amount:     e.Amount,
+			SentAt:     e.SentAt,
+		})
+		if err != nil {
+			return fmt.Errorf("billing.RecordUsage: %w", err)
+		}
+
+		h := hour{merchantID: e.MerchantID, start: e.SentAt.UTC().Truncate(time.Hour)}
+		if !dirty[h] {
+			if err := rollups.MarkDirty(ctx, q, h.merchantID, h.start); err != nil {
+				return fmt.Errorf("rollups.MarkDirty: %w", err)
+			}
+			dirty[h] = true
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("tx.Commit: %w", err)
+	}
+
+	for _, e := range rejected {
+		p.logger.Error("acknowledged event of an unknown merchant or SKU not inserted",
+			zap.String("event_id", e.ID.String()),
+			zap.String("merchant_id", e.MerchantID.String()),
+			zap.String("customer_id", e.CustomerID.String()),
+			zap.String("sku_id", e.SkuID.String()),
+			zap.Float64("amount", e.Amount),
+			zap.Time("sent_at", e.SentAt),
+		)
+	}
+	return nil
+}
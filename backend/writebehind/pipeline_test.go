@@ -0,0 +1,87 @@
+package writebehind
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"billbo.com/backend/database/dbtest"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func TestReplayAfterRestartDoesNotInsertTwice(t *testing.T) {
+	pool := dbtest.NewPool(t)
+	ctx := context.Background()
+
+	merchantID := dbtest.CreateMerchant(t, pool)
+	skuID := uuid.New()
+	_, err := pool.Exec(ctx,
+		`INSERT INTO skus (id, merchant_id, name, price_per_unit) VALUES ($1, $2, 'Test SKU', 1)`,
+		skuID, merchantID,
+	)
+	if err != nil {
+		t.Fatalf("insert sku: %v", err)
+	}
+
+	dir := t.TempDir()
+	w, _, err := OpenWAL(dir)
+	if err != nil {
+		t.Fatalf("OpenWAL: %v", err)
+	}
+	customerID := uuid.New()
+	var records []string
+	for range 3 {
+		data, err := json.Marshal(Event{
+			ID:         uuid.New(),
+			MerchantID: merchantID,
+			CustomerID: customerID,
+			SkuID:      skuID,
+			Amount:     2,
+			SentAt:     time.Now().UTC(),
+		})
+		if err != nil {
+			t.Fatalf("json.Marshal: %v", err)
+		}
+		records = append(records, string(data))
+	}
+	writeRecords(t, w, records...)
+
+	// The events are replayed, then replayed again as if the process had
+	// crashed before truncating the log.
+	w, replayed := reopen(t, w)
+	p := NewPipeline(zap.NewNop(), pool, w, Options{BufferSize: 10, BatchSize: 2, FlushInterval: time.Millisecond})
+	for range 2 {
+		raw := make([][]byte, len(replayed))
+		for i, record := range replayed {
+			raw[i] = []byte(record)
+		}
+		if err := p.Replay(ctx, raw); err != nil {
+			t.Fatalf("pipeline.Replay: %v", err)
+		}
+	}
+
+	var events int
+	var quantity float64
+	var eventCount int64
+	err = pool.QueryRow(ctx, `SELECT count(*) FROM events WHERE merchant_id = $1`, merchantID).Scan(&events)
+	if err != nil {
+		t.Fatalf("count events: %v", err)
+	}
+	err = pool.QueryRow(ctx,
+		`SELECT sum(quantity), sum(event_count) FROM usage_counters WHERE merchant_id = $1`, merchantID,
+	).Scan(&quantity, &eventCount)
+	if err != nil {
+		t.Fatalf("sum usage counters: %v", err)
+	}
+	if events != 3 || eventCount != 3 || quantity != 6 {
+		t.Fatalf("got %d events, counted %d for %v, want 3 events for 6", events, eventCount, quantity)
+	}
+
+	// The replayed records are truncated: a restart replays nothing.
+	_, replayed = reopen(t, w)
+	if len(replayed) != 0 {
+		t.Fatalf("got %d records after replaying, want none", len(replayed))
+	}
+}
@@ -0,0 +1,281 @@
+package writebehind
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// WAL_SEGMENT_MAX_BYTES is the size past which the log moves on to a new
+	// segment file.
+	WAL_SEGMENT_MAX_BYTES = 64 << 20
+	WAL_SEGMENT_SUFFIX    = ".wal"
+	// WAL_RECORD_MAX_BYTES bounds the size of a record. A larger length read
+	// back from a segment is a corrupt header, which ends it.
+	WAL_RECORD_MAX_BYTES = 1 << 20
+
+	// walHeaderSize is the size of a record header: the length and the
+	// CRC-32C of the payload.
+	walHeaderSize = 8
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var ErrRecordTooLarge = errors.New("WAL record too large")
+
+// Position is where a record was written in the log.
+type Position struct {
+	Segment uint64
+	seq     uint64
+}
+
+// WAL is an append-only log of records, split into numbered segment files.
+// Writes are made durable by Sync, which fsyncs every record written so far:
+// concurrent writers share the fsync of the first of them to call it.
+type WAL struct {
+	dir string
+	// segmentMaxBytes is WAL_SEGMENT_MAX_BYTES, but for tests.
+	segmentMaxBytes int64
+
+	// mu guards the current segment and the records written to it.
+	mu      sync.Mutex
+	file    *os.File
+	segment uint64
+	size    int64
+	written uint64
+	// err fails the writes following a failed write, which may have left a
+	// torn record behind, or a failed fsync, after which the kernel may have
+	// dropped the records written so far and report the next fsync as
+	// successful.
+	err error
+
+	// syncMu serializes fsyncs and segment rotations.
+	syncMu sync.Mutex
+	synced uint64
+}
+
+// OpenWAL opens the log in dir and returns the records of its existing
+// segments, to be replayed. A torn record at the end of a segment, from a
+// crash mid-write, ends it. New records go to a new segment, so that the
+// replayed ones are removed with Truncate once they are applied.
+func OpenWAL(dir string) (*WAL, [][]byte, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("os.MkdirAll: %w", err)
+	}
+
+	segments, err := listSegments(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("listSegments: %w", err)
+	}
+
+	var records [][]byte
+	var last uint64
+	for _, segment := range segments {
+		segmentRecords, err := readSegment(segmentPath(dir, segment))
+		if err != nil {
+			return nil, nil, fmt.Errorf("readSegment: %w", err)
+		}
+		records = append(records, segmentRecords...)
+		last = segment
+	}
+
+	w := &WAL{dir: dir, segmentMaxBytes: WAL_SEGMENT_MAX_BYTES, segment: last + 1}
+	w.file, err = os.OpenFile(segmentPath(dir, w.segment), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("os.OpenFile: %w", err)
+	}
+	return w, records, nil
+}
+
+func segmentPath(dir string, segment uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", segment, WAL_SEGMENT_SUFFIX))
+}
+
+// listSegments returns the segment numbers in dir, in order.
+func listSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadDir: %w", err)
+	}
+	var segments []uint64
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), WAL_SEGMENT_SUFFIX)
+		if !ok {
+			continue
+		}
+		segment, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+	slices.Sort(segments)
+	return segments, nil
+}
+
+func readSegment(path string) ([][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.Open: %w", err)
+	}
+	defer f.Close()
+
+	var records [][]byte
+	header := make([]byte, walHeaderSize)
+	for {
+		if _, err := io.ReadFull(f, header); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return records, nil
+			}
+			return nil, fmt.Errorf("io.ReadFull: %w", err)
+		}
+		size := binary.LittleEndian.Uint32(header[0:4])
+		if size > WAL_RECORD_MAX_BYTES {
+			return records, nil
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(f, data); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return records, nil
+			}
+			return nil, fmt.Errorf("io.ReadFull: %w", err)
+		}
+		if crc32.Checksum(data, crcTable) != binary.LittleEndian.Uint32(header[4:8]) {
+			return records, nil
+		}
+		records = append(records, data)
+	}
+}
+
+// Write appends a record to the log. It is not durable until synced.
+func (w *WAL) Write(data []byte) (Position, error) {
+	if len(data) > WAL_RECORD_MAX_BYTES {
+		return Position{}, ErrRecordTooLarge
+	}
+	buf := make([]byte, walHeaderSize+len(data))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(data)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.Checksum(data, crcTable))
+	copy(buf[walHeaderSize:], data)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return Position{}, w.err
+	}
+	n, err := w.file.Write(buf)
+	w.size += int64(n)
+	if err != nil {
+		w.err = fmt.Errorf("file.Write: %w", err)
+		return Position{}, w.err
+	}
+	w.written++
+	return Position{Segment: w.segment, seq: w.written}, nil
+}
+
+// Sync makes the record at pos, and all those written before it, durable.
+// Once a sync failed, the log refuses every write and sync.
+func (w *WAL) Sync(pos Position) error {
+	w.syncMu.Lock()
+	defer w.syncMu.Unlock()
+	if w.synced >= pos.seq {
+		return nil
+	}
+
+	w.mu.Lock()
+	file, written, size, err := w.file, w.written, w.size, w.err
+	w.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		return w.fail(fmt.Errorf("file.Sync: %w", err))
+	}
+	w.synced = written
+
+	if size >= w.segmentMaxBytes {
+		if err := w.rotate(); err != nil {
+			return fmt.Errorf("rotate: %w", err)
+		}
+	}
+	return nil
+}
+
+// fail stops the log from accepting writes, and returns err.
+func (w *WAL) fail(err error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+	return w.err
+}
+
+// rotate moves on to a new segment. syncMu must be held.
+func (w *WAL) rotate() error {
+	w.mu.Lock()
+	next, err := os.OpenFile(segmentPath(w.dir, w.segment+1), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		w.mu.Unlock()
+		return fmt.Errorf("os.OpenFile: %w", err)
+	}
+	previous, written := w.file, w.written
+	w.file, w.segment, w.size = next, w.segment+1, 0
+	w.mu.Unlock()
+
+	if err := previous.Sync(); err != nil {
+		return w.fail(fmt.Errorf("previous.Sync: %w", err))
+	}
+	w.synced = written
+	if err := previous.Close(); err != nil {
+		return fmt.Errorf("previous.Close: %w", err)
+	}
+	return nil
+}
+
+// Segment returns the segment records are written to.
+func (w *WAL) Segment() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.segment
+}
+
+// Truncate removes the segments before the given one, whose records are
+// all applied.
+func (w *WAL) Truncate(before uint64) error {
+	segments, err := listSegments(w.dir)
+	if err != nil {
+		return fmt.Errorf("listSegments: %w", err)
+	}
+	for _, segment := range segments {
+		if segment >= before || segment >= w.Segment() {
+			break
+		}
+		if err := os.Remove(segmentPath(w.dir, segment)); err != nil {
+			return fmt.Errorf("os.Remove: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close syncs and closes the current segment.
+func (w *WAL) Close() error {
+	w.syncMu.Lock()
+	defer w.syncMu.Unlock()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("file.Sync: %w", err)
+	}
+	return w.file.Close()
+}
@@ -0,0 +1,176 @@
+package writebehind
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+)
+
+func writeRecords(t *testing.T, w *WAL, records ...string) {
+	t.Helper()
+	for _, record := range records {
+		pos, err := w.Write([]byte(record))
+		if err != nil {
+			t.Fatalf("wal.Write: %v", err)
+		}
+		if err := w.Sync(pos); err != nil {
+			t.Fatalf("wal.Sync: %v", err)
+		}
+	}
+}
+
+// reopen closes the log and opens it again, as a restart would.
+func reopen(t *testing.T, w *WAL) (*WAL, []string) {
+	t.Helper()
+	if err := w.Close(); err != nil {
+		t.Fatalf("wal.Close: %v", err)
+	}
+	w, records, err := OpenWAL(w.dir)
+	if err != nil {
+		t.Fatalf("OpenWAL: %v", err)
+	}
+	t.Cleanup(func() { w.Close() })
+
+	var got []string
+	for _, record := range records {
+		got = append(got, string(record))
+	}
+	return w, got
+}
+
+// appendToSegment appends raw bytes to the current segment, as a crash
+// mid-write or a corruption would leave them.
+func appendToSegment(t *testing.T, w *WAL, data []byte) {
+	t.Helper()
+	f, err := os.OpenFile(segmentPath(w.dir, w.Segment()), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("os.OpenFile: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		t.Fatalf("f.Write: %v", err)
+	}
+}
+
+func TestWALReplaysRecords(t *testing.T) {
+	w, records, err := OpenWAL(t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenWAL: %v", err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("got %d records in a new log", len(records))
+	}
+
+	writeRecords(t, w, "a", "b", "c")
+	w, got := reopen(t, w)
+	if want := []string{"a", "b", "c"}; !slices.Equal(got, want) {
+		t.Fatalf("got records %q, want %q", got, want)
+	}
+
+	// New records go to a new segment, after the replayed ones.
+	writeRecords(t, w, "d")
+	_, got = reopen(t, w)
+	if want := []string{"a", "b", "c", "d"}; !slices.Equal(got, want) {
+		t.Fatalf("got records %q, want %q", got, want)
+	}
+}
+
+func TestWALTornTail(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		tail []byte
+	}{
+		{"torn header", []byte{5, 0, 0}},
+		{"torn payload", []byte{5, 0, 0, 0, 0, 0, 0, 0, 'x', 'y'}},
+		{"corrupt payload", []byte{1, 0, 0, 0, 0, 0, 0, 0, 'x'}},
+		{"corrupt length", []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w, _, err := OpenWAL(t.TempDir())
+			if err != nil {
+				t.Fatalf("OpenWAL: %v", err)
+			}
+			writeRecords(t, w, "a", "b")
+			appendToSegment(t, w, tt.tail)
+
+			_, got := reopen(t, w)
+			if want := []string{"a", "b"}; !slices.Equal(got, want) {
+				t.Fatalf("got records %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWALRejectsLargeRecords(t *testing.T) {
+	w, _, err := OpenWAL(t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenWAL: %v", err)
+	}
+	defer w.Close()
+
+	if _, err := w.Write(make([]byte, WAL_RECORD_MAX_BYTES+1)); err != ErrRecordTooLarge {
+		t.Fatalf("got %v, want ErrRecordTooLarge", err)
+	}
+	// The log still accepts records.
+	writeRecords(t, w, "a")
+}
+
+func TestWALFailedSyncStopsWrites(t *testing.T) {
+	w, _, err := OpenWAL(t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenWAL: %v", err)
+	}
+	pos, err := w.Write([]byte("a"))
+	if err != nil {
+		t.Fatalf("wal.Write: %v", err)
+	}
+
+	// Syncing a closed file fails, as a failed fsync would.
+	w.file.Close()
+	if err := w.Sync(pos); err == nil {
+		t.Fatal("wal.Sync succeeded on a closed file")
+	}
+	if _, err := w.Write([]byte("b")); err == nil {
+		t.Fatal("wal.Write succeeded after a failed sync")
+	}
+	if err := w.Sync(pos); err == nil {
+		t.Fatal("wal.Sync succeeded after a failed sync")
+	}
+}
+
+func TestWALTruncateAcrossRotation(t *testing.T) {
+	w, _, err := OpenWAL(t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenWAL: %v", err)
+	}
+	// Every synced record fills its segment.
+	w.segmentMaxBytes = 1
+
+	first := w.Segment()
+	for i := range 4 {
+		writeRecords(t, w, fmt.Sprint(i))
+	}
+	if got := w.Segment(); got != first+4 {
+		t.Fatalf("got segment %d, want %d", got, first+4)
+	}
+
+	// The segments of records 0 and 1 are applied.
+	if err := w.Truncate(first + 2); err != nil {
+		t.Fatalf("wal.Truncate: %v", err)
+	}
+	w, got := reopen(t, w)
+	if want := []string{"2", "3"}; !slices.Equal(got, want) {
+		t.Fatalf("got records %q, want %q", got, want)
+	}
+
+	// The current segment is never removed.
+	writeRecords(t, w, "4")
+	if err := w.Truncate(w.Segment() + 1); err != nil {
+		t.Fatalf("wal.Truncate: %v", err)
+	}
+	_, got = reopen(t, w)
+	if want := []string{"4"}; !slices.Equal(got, want) {
+		t.Fatalf("got records %q, want %q", got, want)
+	}
+}
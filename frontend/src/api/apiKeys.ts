@@ -1,9 +1,23 @@
 import { makeApiDelete, makeApiGet, makeApiPost } from "./generic";
 
+export const API_KEY_SCOPES = [
+  "events:write",
+  "events:read",
+  "usage:read",
+] as const;
+
+export type APIKeyScope = (typeof API_KEY_SCOPES)[number];
+
 export type APIKey = {
   ID: string;
   Name: string;
   KeyPrefix: string;
+  Scopes: APIKeyScope[];
+  HasSigningSecret: boolean;
+  ExpiresAt: string | null;
+  RotatedAt: string | null;
+  PreviousKeyExpiresAt: string | null;
+  LastUsedAt: string | null;
   RevokedAt: string | null;
   CreatedAt: string;
 };
@@ -13,10 +27,16 @@ export type CreateAPIKeyResponse = {
   name: string;
   key: string;
   key_prefix: string;
+  scopes: APIKeyScope[];
+  expires_at: string | null;
+  signing_secret: string | null;
+  previous_key_expires_at: string | null;
 };
 
 type CreateAPIKeyBody = {
   name: string;
+  scopes: APIKeyScope[];
+  expires_at?: string;
 };
 
 const listAPIKeys = makeApiGet<undefined, APIKey[]>("/api/v1/api-keys/");
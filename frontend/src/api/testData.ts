@@ -0,0 +1,7 @@
+import { makeApiDelete } from "./generic";
+
+const wipeTestData = makeApiDelete<void>("/api/v1/test-data/");
+
+export const testDataApi = {
+  wipe: () => wipeTestData({}),
+};
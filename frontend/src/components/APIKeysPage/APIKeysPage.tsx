@@ -2,8 +2,10 @@ import { useState } from "react";
 import { useQueryClient } from "@tanstack/react-query";
 import { type ColumnDef } from "@tanstack/react-table";
 import {
+  API_KEY_SCOPES,
   apiKeysApi,
   type APIKey,
+  type APIKeyScope,
   type CreateAPIKeyResponse,
 } from "@/api/apiKeys";
 import { useListAPIKeys } from "@/queries/useListAPIKeys";
@@ -14,6 +16,7 @@ export function APIKeysPage() {
   const { data: keys, isPending, error } = useListAPIKeys();
   const queryClient = useQueryClient();
   const [name, setName] = useState("");
+  const [scopes, setScopes] = useState<APIKeyScope[]>(["events:write"]);
   const [isCreating, setIsCreating] = useState(false);
   const [createdKey, setCreatedKey] = useState<CreateAPIKeyResponse | null>(
     null,
@@ -22,10 +25,10 @@ export function APIKeysPage() {
 
   async function handleCreate(e: React.FormEvent) {
     e.preventDefault();
-    if (!name.trim()) return;
+    if (!name.trim() || scopes.length === 0) return;
     setIsCreating(true);
     try {
-      const result = await apiKeysApi.create({ name: name.trim() });
+      const result = await apiKeysApi.create({ name: name.trim(), scopes });
       setCreatedKey(result);
       setName("");
       await queryClient.invalidateQueries({ queryKey: ["api-keys"] });
@@ -34,6 +37,14 @@ export function APIKeysPage() {
     }
   }
 
+  function toggleScope(scope: APIKeyScope) {
+    setScopes((current) =>
+      current.includes(scope)
+        ? current.filter((s) => s !== scope)
+        : [...current, scope],
+    );
+  }
+
   async function handleRevoke(id: string) {
     setRevokingID(id);
     try {
@@ -63,9 +74,24 @@ export function APIKeysPage() {
             placeholder="e.g. Production"
           />
         </label>
+        <fieldset className="flex flex-col gap-1">
+          <legend className="text-sm font-medium text-gray-700">Scopes</legend>
+          <div className="flex flex-row gap-3 py-2">
+            {API_KEY_SCOPES.map((scope) => (
+              <label key={scope} className="flex items-center gap-1 text-sm">
+                <input
+                  type="checkbox"
+                  checked={scopes.includes(scope)}
+                  onChange={() => toggleScope(scope)}
+                />
+                <span className="font-mono text-xs">{scope}</span>
+              </label>
+            ))}
+          </div>
+        </fieldset>
         <button
           type="submit"
-          disabled={isCreating}
+          disabled={isCreating || scopes.length === 0}
           className="bg-blue-600 text-white rounded-md px-4 py-2 text-sm font-medium hover:bg-blue-700 disabled:opacity-50"
         >
           {isCreating ? "Creating..." : "Create key"}
@@ -80,6 +106,16 @@ export function APIKeysPage() {
           <code className="block text-sm font-mono bg-white px-3 py-2 rounded border border-green-200 select-all">
             {createdKey.key}
           </code>
+          {createdKey.signing_secret && (
+            <>
+              <p className="text-sm text-green-800 mt-3 mb-2">
+                Signing secret, to sign requests instead of sending the key:
+              </p>
+              <code className="block text-sm font-mono bg-white px-3 py-2 rounded border border-green-200 select-all">
+                {createdKey.signing_secret}
+              </code>
+            </>
+          )}
           <button
             onClick={() => setCreatedKey(null)}
             className="mt-2 text-sm text-green-700 hover:underline"
@@ -114,17 +150,46 @@ export function APIKeysPage() {
                   </span>
                 ),
               },
+              {
+                accessorKey: "Scopes",
+                header: "Scopes",
+                enableSorting: false,
+                cell: (info) => (
+                  <span className="font-mono text-xs">
+                    {info.getValue<APIKeyScope[]>().join(", ")}
+                  </span>
+                ),
+              },
               {
                 accessorKey: "CreatedAt",
                 header: "Created",
                 cell: (info) => formatDate(info.getValue<string>()),
               },
+              {
+                accessorKey: "LastUsedAt",
+                header: "Last used",
+                cell: (info) => {
+                  const value = info.getValue<string | null>();
+                  return value ? formatDate(value) : "Never";
+                },
+              },
+              {
+                accessorKey: "ExpiresAt",
+                header: "Expires",
+                cell: (info) => {
+                  const value = info.getValue<string | null>();
+                  return value ? formatDate(value) : "Never";
+                },
+              },
               {
                 accessorKey: "RevokedAt",
                 header: "Status",
-                cell: (info) =>
-                  info.getValue<string | null>() ? (
+                cell: ({ row }) =>
+                  row.original.RevokedAt ? (
                     <span className="text-red-600">Revoked</span>
+                  ) : row.original.ExpiresAt &&
+                    new Date(row.original.ExpiresAt) <= new Date() ? (
+                    <span className="text-gray-500">Expired</span>
                   ) : (
                     <span className="text-green-600">Active</span>
                   ),
@@ -1,11 +1,38 @@
+import { useState } from "react";
+import { useQueryClient } from "@tanstack/react-query";
 import { useAuth } from "@/hooks/useAuth";
 import { Button } from "@/components/shared/Button";
+import { testDataApi } from "@/api/testData";
+import { getMode, setMode, type Mode } from "@/lib/mode";
 
 export function Header() {
   const { logout, merchantName } = useAuth();
+  const queryClient = useQueryClient();
+  const [mode, setModeState] = useState<Mode>(getMode);
+
+  async function toggleMode() {
+    const next = mode === "live" ? "test" : "live";
+    setMode(next);
+    setModeState(next);
+    await queryClient.resetQueries();
+  }
+
+  async function wipeTestData() {
+    if (!window.confirm("Delete all test events, customers and invoices?")) {
+      return;
+    }
+    await testDataApi.wipe();
+    await queryClient.resetQueries();
+  }
 
   return (
-    <header className="flex flex-row items-center justify-between px-6 py-3 bg-linear-to-r from-blue-600 to-purple-600 shadow-sm">
+    <header
+      className={`flex flex-row items-center justify-between px-6 py-3 shadow-sm ${
+        mode === "test"
+          ? "bg-linear-to-r from-orange-500 to-amber-500"
+          : "bg-linear-to-r from-blue-600 to-purple-600"
+      }`}
+    >
       <div className="flex flex-row items-center gap-4">
         <span className="text-sm font-semibold text-white">{merchantName}</span>
         <Button to="/">Home</Button>
@@ -13,7 +40,15 @@ export function Header() {
         <Button to="/api-keys">API Keys</Button>
         <Button to="/skus">SKUs</Button>
       </div>
-      <Button onClick={logout}>Log out</Button>
+      <div className="flex flex-row items-center gap-4">
+        {mode === "test" && (
+          <Button onClick={wipeTestData}>Wipe test data</Button>
+        )}
+        <Button onClick={toggleMode}>
+          {mode === "test" ? "Test mode" : "Live mode"}
+        </Button>
+        <Button onClick={logout}>Log out</Button>
+      </div>
     </header>
   );
 }
@@ -0,0 +1,13 @@
+export type Mode = "live" | "test";
+
+// The dashboard API serves the data of the mode set in the "mode" cookie.
+const MODE_COOKIE_NAME = "mode";
+
+export function getMode(): Mode {
+  const match = document.cookie.match(/(?:^|;\s*)mode=(live|test)/);
+  return match ? (match[1] as Mode) : "live";
+}
+
+export function setMode(mode: Mode) {
+  document.cookie = `${MODE_COOKIE_NAME}=${mode}; path=/; SameSite=Lax`;
+}
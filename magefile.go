@@ -102,6 +102,14 @@ func IngestAPI() error {
 	return cmd.Run()
 }
 
+// Worker starts the background job worker.
+func Worker() error {
+	cmd := exec.Command("go", "run", "./backend/cmd/worker")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
 // Frontend starts the frontend dev server.
 func Frontend() error {
 	return sh.RunV("npm", "--prefix", "frontend", "run", "dev")